@@ -0,0 +1,45 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/tigorlazuardi/claw/lib/claw"
+	"github.com/tigorlazuardi/claw/migrations"
+	"github.com/urfave/cli/v3"
+)
+
+// BackfillHashesCommand creates the CLI command that hashes images downloaded before content hashing was introduced.
+func BackfillHashesCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "backfill-hashes",
+		Usage: "Compute content and perceptual hashes of existing images and merge duplicates",
+		Description: "Computes the SHA-256 of every image in the library that has no content hash yet.\n" +
+			"Images with identical content are merged into one, keeping the other sources and posts as references.\n" +
+			"Perceptual hashes used for near-duplicate detection are computed as well.\n" +
+			"Stop the server before running it: merges are only serialized with downloads of the same process,\n" +
+			"so it refuses to run while a server uses the same database.\n" +
+			"It is safe to run multiple times.",
+		Action: runBackfillHashes,
+	}
+}
+
+func runBackfillHashes(ctx context.Context, cmd *cli.Command) error {
+	db, err := openDatabase()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := migrations.Migrate(ctx, db); err != nil {
+		return err
+	}
+
+	result, err := claw.New(db, cfg.Claw).BackfillImageHashes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to backfill image hashes: %w", err)
+	}
+	slog.Info("image hashes backfilled", "hashed", result.Hashed, "merged", result.Merged, "missing", result.Missing, "perceptual_hashed", result.PerceptualHashed)
+	return nil
+}
@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/XSAM/otelsql"
+	"github.com/j2gg0s/otsql"
+	"github.com/tigorlazuardi/claw/lib/logger"
+	"github.com/tigorlazuardi/claw/lib/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+
+	sqlite "github.com/ncruces/go-sqlite3/driver"
+
+	_ "github.com/ncruces/go-sqlite3/embed"
+)
+
+// openDatabase opens the configured SQLite database with logging, tracing, and metrics instrumentation.
+func openDatabase() (*sql.DB, error) {
+	conn, err := (&sqlite.SQLite{}).OpenConnector(cfg.Database.Path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	conn = otsql.WrapConnector(conn,
+		otsql.WithHooks(
+			logger.LoggerHook{Logger: slog.Default()},
+			&otel.DBClientDurationMetricHook{Address: cfg.Database.Path},
+		),
+		otsql.WithDatabaase("claw"),
+	)
+	dbAttrs := []attribute.KeyValue{
+		semconv.DBSystemSqlite,
+		semconv.ServerAddress("file://" + cfg.Database.Path),
+		semconv.DBNamespace("claw"),
+	}
+	db := otelsql.OpenDB(conn,
+		otelsql.WithAttributes(dbAttrs...),
+		otelsql.WithDisableSkipErrMeasurement(true),
+	)
+
+	if err := otelsql.RegisterDBStatsMetrics(db); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed to register database metrics: %w", err)
+	}
+
+	abs, _ := filepath.Abs(cfg.Database.Path)
+	slog.Info("Database connected", "path", abs)
+	return db, nil
+}
@@ -8,30 +8,20 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
-	"path/filepath"
 	"syscall"
 	"time"
 
 	"connectrpc.com/connect"
 	"connectrpc.com/otelconnect"
-	"github.com/XSAM/otelsql"
-	"github.com/j2gg0s/otsql"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/tigorlazuardi/claw/lib/claw"
-	"github.com/tigorlazuardi/claw/lib/logger"
 	"github.com/tigorlazuardi/claw/lib/otel"
 	"github.com/tigorlazuardi/claw/lib/server"
 	"github.com/tigorlazuardi/claw/lib/server/gen/claw/v1/clawv1connect"
 	"github.com/tigorlazuardi/claw/migrations"
 	"github.com/urfave/cli/v3"
-	"go.opentelemetry.io/otel/attribute"
-	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
-
-	sqlite "github.com/ncruces/go-sqlite3/driver"
-
-	_ "github.com/ncruces/go-sqlite3/embed"
 )
 
 // Change the version file inside CI/CD pipeline during build time
@@ -49,35 +39,12 @@ func ServerCommand() *cli.Command {
 
 // runServer starts the HTTP server with ConnectRPC handlers
 func runServer(ctx context.Context, cmd *cli.Command) error {
-	conn, err := (&sqlite.SQLite{}).OpenConnector(cfg.Database.Path)
+	db, err := openDatabase()
 	if err != nil {
-		return fmt.Errorf("failed to open database: %w", err)
-	}
-	conn = otsql.WrapConnector(conn,
-		otsql.WithHooks(
-			logger.LoggerHook{Logger: slog.Default()},
-			&otel.DBClientDurationMetricHook{Address: cfg.Database.Path},
-		),
-		otsql.WithDatabaase("claw"),
-	)
-	dbAttrs := []attribute.KeyValue{
-		semconv.DBSystemSqlite,
-		semconv.ServerAddress("file://" + cfg.Database.Path),
-		semconv.DBNamespace("claw"),
+		return err
 	}
-	db := otelsql.OpenDB(conn,
-		otelsql.WithAttributes(dbAttrs...),
-		otelsql.WithDisableSkipErrMeasurement(true),
-	)
 	defer db.Close()
 
-	if err := otelsql.RegisterDBStatsMetrics(db); err != nil {
-		return fmt.Errorf("failed to register database metrics: %w", err)
-	}
-
-	abs, _ := filepath.Abs(cfg.Database.Path)
-	slog.Info("Database connected", "path", abs)
-
 	// Initialize the claw service
 	clawService := claw.New(db, cfg.Claw)
 
@@ -149,6 +116,9 @@ func runServer(ctx context.Context, cmd *cli.Command) error {
 		w.Write([]byte("pong"))
 	}))
 
+	// Serves image, thumbnail, and device files from the download base directory.
+	server.NewFileHandler(clawService, slog.Default()).Register(mux)
+
 	webuiFragment, distFS, err := CreateViteFragment()
 	if err != nil {
 		return fmt.Errorf("failed to create Vite fragment: %w", err)
@@ -33,6 +33,7 @@ func main() {
 		Version: Version,
 		Commands: []*cli.Command{
 			internal.ServerCommand(),
+			internal.BackfillHashesCommand(),
 		},
 		Before: internal.Before,
 		After:  internal.After,
@@ -11,7 +11,13 @@ import (
 	"github.com/tigorlazuardi/claw/lib/claw/config"
 	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
 	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/source/booru"
+	"github.com/tigorlazuardi/claw/lib/claw/source/feed"
+	"github.com/tigorlazuardi/claw/lib/claw/source/localdir"
+	"github.com/tigorlazuardi/claw/lib/claw/source/plugin"
 	"github.com/tigorlazuardi/claw/lib/claw/source/reddit"
+	"github.com/tigorlazuardi/claw/lib/claw/source/script"
+	"github.com/tigorlazuardi/claw/lib/claw/source/wallhaven"
 	"golang.org/x/sync/semaphore"
 )
 
@@ -59,6 +65,10 @@ func New(db *sql.DB, config *config.Config, opts ...Option) *Claw {
 		config: config,
 	}
 
+	// Requests of sources and image downloads share the limits of their hosts.
+	limiter := newHostLimiter(config)
+	client := limiter.Client(http.DefaultClient)
+
 	// Initialize scheduler with default backends
 	cl.scheduler = &scheduler{
 		claw:           cl,
@@ -68,10 +78,40 @@ func New(db *sql.DB, config *config.Config, opts ...Option) *Claw {
 		imageSemaphore: semaphore.NewWeighted(leastCommonMultiple),
 		wg:             &sync.WaitGroup{},
 		reloadSignal:   broadcast.NewRelay[struct{}](),
+		scheduleSignal: broadcast.NewRelay[struct{}](),
 		logger:         cl.logger,
 		backends: map[string]source.Source{
 			reddit.SourceName: &reddit.Reddit{
-				Client: http.DefaultClient,
+				Client: client,
+				Config: func() reddit.Config {
+					return redditConfig(config.Sources.Reddit)
+				},
+			},
+			booru.DanbooruSourceName: booru.NewDanbooru(client, func() booru.Config {
+				return booruConfig(config.Sources.Danbooru)
+			}),
+			booru.GelbooruSourceName: booru.NewGelbooru(client, func() booru.Config {
+				return booruConfig(config.Sources.Gelbooru)
+			}),
+			booru.MoebooruSourceName: booru.NewMoebooru(client, func() booru.Config {
+				return booruConfig(config.Sources.Moebooru)
+			}),
+			feed.SourceName: &feed.Feed{
+				Client: client,
+			},
+			localdir.SourceName: &localdir.LocalDir{
+				Config: func() localdir.Config {
+					return localdir.Config{AllowedDirs: config.Sources.LocalDir.AllowedDirs}
+				},
+			},
+			wallhaven.SourceName: &wallhaven.Wallhaven{
+				Client: client,
+				Config: func() wallhaven.Config {
+					return wallhaven.Config{
+						APIKey:    config.Sources.Wallhaven.APIKey,
+						FetchTags: config.Sources.Wallhaven.FetchTags,
+					}
+				},
 			},
 		},
 		httpclient: http.DefaultClient,
@@ -81,14 +121,80 @@ func New(db *sql.DB, config *config.Config, opts ...Option) *Claw {
 	for _, opt := range opts {
 		opt(cl)
 	}
+	cl.scheduler.logger = cl.logger
+	// Scripts log through the console of the runtime, so the script source needs the final logger.
+	cl.scheduler.backends[script.SourceName] = &script.Script{
+		Logger: cl.logger,
+		Config: func() script.Config {
+			cfg := config.Sources.Script
+			return script.Config{
+				Dir:                  cfg.Dir,
+				AllowedHosts:         cfg.AllowedHosts,
+				AllowPrivateNetworks: cfg.AllowPrivateNetworks,
+				Timeout:              cfg.Timeout,
+				MaxFetches:           cfg.MaxFetches,
+			}
+		},
+	}
+	// Webhooks go to the user's own endpoints, so they are not rate limited.
+	cl.scheduler.webhooks = newWebhookDispatcher(config, cl.scheduler.httpclient, cl.logger)
+	cl.scheduler.httpclient = limiter.Client(cl.scheduler.httpclient)
+	cl.registerPlugins()
 
 	return cl
 }
 
+// registerPlugins adds the plugins in config as source backends. Invalid plugins,
+// and plugins named after an existing backend are skipped.
+func (cl *Claw) registerPlugins() {
+	for _, cfg := range cl.config.Plugins {
+		if err := cfg.ValidateAndNormalize(); err != nil {
+			cl.logger.Error("invalid plugin config, skipping plugin", "plugin", cfg, "error", err)
+			continue
+		}
+		if _, exists := cl.scheduler.backends[cfg.Name]; exists {
+			cl.logger.Error("plugin name is already used by another source, skipping plugin", "plugin", cfg)
+			continue
+		}
+		cl.scheduler.backends[cfg.Name] = plugin.New(plugin.Config{
+			Name:       cfg.Name,
+			Command:    cfg.Command,
+			Args:       cfg.Args,
+			Env:        cfg.Env,
+			Timeout:    cfg.Timeout,
+			RunTimeout: cfg.RunTimeout,
+		}, cl.logger)
+	}
+}
+
+func redditConfig(cfg config.Reddit) reddit.Config {
+	return reddit.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		Username:     cfg.Username,
+		Password:     cfg.Password,
+		UserAgent:    cfg.UserAgent,
+	}
+}
+
+func booruConfig(cfg config.Booru) booru.Config {
+	return booru.Config{
+		BaseURL: cfg.BaseURL,
+		Login:   cfg.Login,
+		APIKey:  cfg.APIKey,
+	}
+}
+
 func (claw *Claw) RereadConfig() {
 	claw.scheduler.reloadSignal.Broadcast(struct{}{})
 }
 
+// RearmSchedules signals the scheduler to reload schedules and recompute their next runs,
+// and to restart the watchers of changed sources.
+func (claw *Claw) RearmSchedules() {
+	claw.scheduler.scheduleSignal.Broadcast(struct{}{})
+}
+
 // StartSchedculer starts the job scheduler
 //
 // It blocks until the given context is cancelled
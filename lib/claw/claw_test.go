@@ -0,0 +1,92 @@
+package claw
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// newTestClaw returns a Claw backed by a migrated database in a temporary directory.
+func newTestClaw(t *testing.T) *Claw {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "claw.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	goose.SetLogger(goose.NopLogger())
+	require.NoError(t, migrations.Migrate(context.Background(), db))
+	return New(db, config.DefaultConfig(), WithLogger(slog.New(slog.DiscardHandler)))
+}
+
+// insertTestImage inserts an image of a new source, without a file.
+func insertTestImage(t *testing.T, cl *Claw, downloadURL string) model.Images {
+	t.Helper()
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES("claw.script.v1", "Script", downloadURL).
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	var imageRow model.Images
+	err = Images.INSERT(Images.SourceID, Images.DownloadURL, Images.Width, Images.Height, Images.Filesize,
+		Images.ThumbnailPath, Images.ImagePath, Images.CreatedAt, Images.UpdatedAt).
+		MODEL(model.Images{
+			SourceID:    *src.ID,
+			DownloadURL: downloadURL,
+			ImagePath:   "images/" + path.Base(downloadURL),
+			CreatedAt:   types.UnixMilliNow(),
+			UpdatedAt:   types.UnixMilliNow(),
+		}).
+		RETURNING(Images.AllColumns).
+		QueryContext(ctx, cl.db, &imageRow)
+	require.NoError(t, err)
+	return imageRow
+}
+
+// insertTestDevice inserts a 1920x1080 device that accepts any image.
+func insertTestDevice(t *testing.T, cl *Claw, slug string) model.Devices {
+	t.Helper()
+	var device model.Devices
+	err := Devices.INSERT(Devices.Slug, Devices.Name, Devices.Width, Devices.Height, Devices.CreatedAt, Devices.UpdatedAt).
+		MODEL(model.Devices{
+			Slug:      slug,
+			Name:      slug,
+			Width:     1920,
+			Height:    1080,
+			CreatedAt: types.UnixMilliNow(),
+			UpdatedAt: types.UnixMilliNow(),
+		}).
+		RETURNING(Devices.AllColumns).
+		QueryContext(context.Background(), cl.db, &device)
+	require.NoError(t, err)
+	return device
+}
+
+// insertTestJob inserts a job of the source with the given status.
+func insertTestJob(t *testing.T, cl *Claw, sourceID int64, status clawv1.JobStatus) model.Jobs {
+	t.Helper()
+	var job model.Jobs
+	err := Jobs.INSERT(Jobs.SourceID, Jobs.Status, Jobs.CreatedAt).
+		MODEL(model.Jobs{
+			SourceID:  sourceID,
+			Status:    status.String(),
+			CreatedAt: types.UnixMilliNow(),
+		}).
+		RETURNING(Jobs.AllColumns).
+		QueryContext(context.Background(), cl.db, &job)
+	require.NoError(t, err)
+	return job
+}
@@ -8,9 +8,12 @@ import (
 )
 
 type Config struct {
-	Download  Download  `koanf:"download"`
-	Scheduler Scheduler `koanf:"scheduler"`
-	Webhooks  Webhooks  `koanf:"webhooks"`
+	Download   Download   `koanf:"download"`
+	Scheduler  Scheduler  `koanf:"scheduler"`
+	Webhooks   Webhooks   `koanf:"webhooks"`
+	Similarity Similarity `koanf:"similarity"`
+	Sources    Sources    `koanf:"sources"`
+	Plugins    []Plugin   `koanf:"plugins"`
 
 	OnConfigChange func(newCfg *Config) `koanf:"-"`
 	koanf          *koanf.Koanf         `koanf:"-"`
@@ -29,9 +32,12 @@ func (cfg *Config) Close() error {
 
 func DefaultConfig() *Config {
 	return &Config{
-		Download:  DefaultDownload(),
-		Scheduler: DefaultScheduler(),
-		koanf:     koanf.New("."),
+		Download:   DefaultDownload(),
+		Scheduler:  DefaultScheduler(),
+		Webhooks:   DefaultWebhooks(),
+		Similarity: DefaultSimilarity(),
+		Sources:    DefaultSources(),
+		koanf:      koanf.New("."),
 	}
 }
 
@@ -5,6 +5,7 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/adrg/xdg"
@@ -17,6 +18,8 @@ type Download struct {
 	StallMonitor      StallMonitor `koanf:"stall_monitor"`
 	FilenameMaxLength int          `koanf:"filename_max_length"`
 	SanityCheck       SanityCheck  `koanf:"sanity_check"`
+	Thumbnail         Thumbnail    `koanf:"thumbnail"`
+	RateLimit         RateLimit    `koanf:"rate_limit"`
 }
 
 func (do Download) LogValue() slog.Value {
@@ -26,6 +29,8 @@ func (do Download) LogValue() slog.Value {
 		slog.Int("filename_max_length", do.FilenameMaxLength),
 		slog.Any("stall_monitor", do.StallMonitor),
 		slog.Any("sanity_check", do.SanityCheck),
+		slog.Any("thumbnail", do.Thumbnail),
+		slog.Any("rate_limit", do.RateLimit),
 	)
 }
 
@@ -36,6 +41,8 @@ func DefaultDownload() Download {
 		StallMonitor:      DefaultStallMonitor(),
 		FilenameMaxLength: 100,
 		SanityCheck:       DefaultSanityCheck(),
+		Thumbnail:         DefaultThumbnail(),
+		RateLimit:         DefaultRateLimit(),
 	}
 }
 
@@ -86,9 +93,10 @@ func (b ByteSize) String() string {
 
 func DefaultStallMonitor() StallMonitor {
 	return StallMonitor{
-		Enabled:       true,
-		Speed:         10 * 1024, // 10 KB/s
-		SpeedDuration: 10 * time.Second,
+		Enabled:                true,
+		Speed:                  10 * 1024, // 10 KB/s
+		SpeedDuration:          10 * time.Second,
+		NoDataReceivedDuration: 10 * time.Second,
 	}
 }
 
@@ -103,3 +111,100 @@ func DefaultSanityCheck() SanityCheck {
 		MinImageFilesize: 64 * 1024, // 10 KB
 	}
 }
+
+type Thumbnail struct {
+	// MaxWidth is the maximum width of generated thumbnails in pixels (default: 480).
+	MaxWidth int `koanf:"max_width"`
+	// MaxHeight is the maximum height of generated thumbnails in pixels (default: 960).
+	MaxHeight int `koanf:"max_height"`
+	// Quality is the JPEG quality of generated thumbnails, from 1 to 100 (default: 80).
+	Quality int `koanf:"quality"`
+}
+
+func (th Thumbnail) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("max_width", th.MaxWidth),
+		slog.Int("max_height", th.MaxHeight),
+		slog.Int("quality", th.Quality),
+	)
+}
+
+func DefaultThumbnail() Thumbnail {
+	return Thumbnail{
+		MaxWidth:  480,
+		MaxHeight: 960,
+		Quality:   80,
+	}
+}
+
+// RateLimit limits the requests claw makes to a single host, both to source APIs and for image downloads.
+type RateLimit struct {
+	// RequestsPerSecond is the number of requests per second allowed to a host (default: 2).
+	// Zero or negative values disable the rate limit.
+	RequestsPerSecond float64 `koanf:"requests_per_second"`
+	// Burst is the number of requests that can be made at once before the rate limit kicks in (default: 4).
+	Burst int `koanf:"burst"`
+	// MaxConcurrency is the maximum number of requests in flight to a host, including the transfer
+	// of the response body (default: 4). Zero or negative values disable the limit.
+	MaxConcurrency int `koanf:"max_concurrency"`
+	// MaxRetryAfter caps how long requests to a host are paused when it responds with a Retry-After header
+	// on 429 or 503 responses (default: 5 minutes).
+	MaxRetryAfter time.Duration `koanf:"max_retry_after"`
+	// Hosts overrides the limits for specific hosts, e.g. "i.imgur.com". A host also matches its subdomains,
+	// so "imgur.com" applies to "i.imgur.com" unless it has an override of its own.
+	//
+	// Zero values fall back to the defaults above.
+	Hosts map[string]HostRateLimit `koanf:"hosts"`
+}
+
+func (ra RateLimit) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Float64("requests_per_second", ra.RequestsPerSecond),
+		slog.Int("burst", ra.Burst),
+		slog.Int("max_concurrency", ra.MaxConcurrency),
+		slog.Duration("max_retry_after", ra.MaxRetryAfter),
+		slog.Int("hosts", len(ra.Hosts)),
+	)
+}
+
+// ForHost returns the limits of the given host, with the overrides applied.
+func (ra RateLimit) ForHost(host string) HostRateLimit {
+	limit := HostRateLimit{
+		RequestsPerSecond: ra.RequestsPerSecond,
+		Burst:             ra.Burst,
+		MaxConcurrency:    ra.MaxConcurrency,
+	}
+	for host != "" {
+		if override, ok := ra.Hosts[host]; ok {
+			if override.RequestsPerSecond > 0 {
+				limit.RequestsPerSecond = override.RequestsPerSecond
+			}
+			if override.Burst > 0 {
+				limit.Burst = override.Burst
+			}
+			if override.MaxConcurrency > 0 {
+				limit.MaxConcurrency = override.MaxConcurrency
+			}
+			break
+		}
+		_, parent, _ := strings.Cut(host, ".")
+		host = parent
+	}
+	return limit
+}
+
+// HostRateLimit is the limits of a single host. See RateLimit for the fields.
+type HostRateLimit struct {
+	RequestsPerSecond float64 `koanf:"requests_per_second"`
+	Burst             int     `koanf:"burst"`
+	MaxConcurrency    int     `koanf:"max_concurrency"`
+}
+
+func DefaultRateLimit() RateLimit {
+	return RateLimit{
+		RequestsPerSecond: 2,
+		Burst:             4,
+		MaxConcurrency:    4,
+		MaxRetryAfter:     5 * time.Minute,
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Plugin configures an out-of-process source. The executable speaks the JSON-RPC protocol
+// described in package github.com/tigorlazuardi/claw/lib/claw/source/plugin over its stdin and stdout.
+//
+// Changes to plugins only take effect after restart.
+type Plugin struct {
+	// Name is the source name the plugin implements, e.g. "acme.pixiv.v1".
+	//
+	// It must match the name the plugin reports, and must not be the name of a built-in source.
+	Name string `koanf:"name"`
+	// Command is the path to the plugin executable.
+	Command string `koanf:"command"`
+	// Args are passed to the executable.
+	Args []string `koanf:"args"`
+	// Env is added to the environment of the plugin, on top of the environment of claw.
+	Env map[string]string `koanf:"env"`
+	// Timeout is the time limit of describing the plugin, validating parameters,
+	// and checking schedule conflicts (default: 30 seconds).
+	Timeout time.Duration `koanf:"timeout"`
+	// RunTimeout is the time limit of running the source in a job (default: 10 minutes).
+	RunTimeout time.Duration `koanf:"run_timeout"`
+}
+
+func (pl Plugin) LogValue() slog.Value {
+	// Env is left out, since it usually holds credentials.
+	return slog.GroupValue(
+		slog.String("name", pl.Name),
+		slog.String("command", pl.Command),
+		slog.Any("args", pl.Args),
+		slog.Int("env", len(pl.Env)),
+		slog.Duration("timeout", pl.Timeout),
+		slog.Duration("run_timeout", pl.RunTimeout),
+	)
+}
+
+func (pl *Plugin) ValidateAndNormalize() error {
+	pl.Name = strings.TrimSpace(pl.Name)
+	if pl.Name == "" {
+		return errors.New("plugin name cannot be empty")
+	}
+	if strings.TrimSpace(pl.Command) == "" {
+		return errors.New("plugin command cannot be empty")
+	}
+	if pl.Timeout <= 0 {
+		pl.Timeout = 30 * time.Second
+	}
+	if pl.RunTimeout <= 0 {
+		pl.RunTimeout = 10 * time.Minute
+	}
+	return nil
+}
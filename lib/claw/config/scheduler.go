@@ -1,7 +1,9 @@
 package config
 
 import (
+	"fmt"
 	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -19,6 +21,31 @@ type Scheduler struct {
 
 	// ExitTimeout is the time to wait for workers to finish when shutting down (default: 10 seconds).
 	ExitTimeout time.Duration `koanf:"exit_timeout"`
+
+	// CatchUp decides what to do with schedule runs that were missed while claw was not running (default: once).
+	//
+	// Accepted values are "skip", "once", and "all".
+	CatchUp CatchUpPolicy `koanf:"catch_up"`
+	// MaxCatchUpRuns is the maximum number of missed runs to create jobs for, per schedule,
+	// when CatchUp is set to "all" (default: 24).
+	MaxCatchUpRuns int `koanf:"max_catch_up_runs"`
+
+	// BackfillPageDelay is the time to wait between pages of backfill jobs (default: 5 seconds).
+	//
+	// Backfill jobs go through the entire history of a source, so this keeps them from using up
+	// the rate limit of the source for scheduled jobs.
+	BackfillPageDelay time.Duration `koanf:"backfill_page_delay"`
+
+	// MaxAttempts is the number of times a job is run before it is marked as failed (default: 3).
+	//
+	// Only jobs failing with errors that may be temporary are run again: network errors, timeouts,
+	// stalled downloads, and 408, 429, or 5xx responses. Jobs failing with other errors, e.g. an invalid
+	// parameter, fail right away. Set to 1 to disable retrying.
+	MaxAttempts int `koanf:"max_attempts"`
+	// RetryBackoff is the wait time before the first retry. It doubles on every subsequent retry (default: 1 minute).
+	RetryBackoff time.Duration `koanf:"retry_backoff"`
+	// MaxRetryBackoff caps the wait time between retries (default: 30 minutes).
+	MaxRetryBackoff time.Duration `koanf:"max_retry_backoff"`
 }
 
 func (sc Scheduler) LogValue() slog.Value {
@@ -27,6 +54,12 @@ func (sc Scheduler) LogValue() slog.Value {
 		slog.Int("download_workers", sc.DownloadWorkers),
 		slog.Duration("poll_interval", sc.PollInterval),
 		slog.Duration("exit_timeout", sc.ExitTimeout),
+		slog.String("catch_up", string(sc.CatchUp)),
+		slog.Int("max_catch_up_runs", sc.MaxCatchUpRuns),
+		slog.Duration("backfill_page_delay", sc.BackfillPageDelay),
+		slog.Int("max_attempts", sc.MaxAttempts),
+		slog.Duration("retry_backoff", sc.RetryBackoff),
+		slog.Duration("max_retry_backoff", sc.MaxRetryBackoff),
 	)
 }
 
@@ -36,5 +69,42 @@ func DefaultScheduler() Scheduler {
 		MaxWorkers:      3,
 		DownloadWorkers: 5,
 		ExitTimeout:     10 * time.Second,
+		CatchUp:         CatchUpOnce,
+		MaxCatchUpRuns:  24,
+
+		BackfillPageDelay: 5 * time.Second,
+
+		MaxAttempts:     3,
+		RetryBackoff:    time.Minute,
+		MaxRetryBackoff: 30 * time.Minute,
 	}
 }
+
+// CatchUpPolicy is the strategy for schedule runs missed during downtime.
+type CatchUpPolicy string
+
+const (
+	// CatchUpSkip ignores missed runs and waits for the next regular tick.
+	CatchUpSkip CatchUpPolicy = "skip"
+	// CatchUpOnce creates a single job if one or more runs were missed.
+	CatchUpOnce CatchUpPolicy = "once"
+	// CatchUpAll creates a job for every missed run, capped by MaxCatchUpRuns.
+	CatchUpAll CatchUpPolicy = "all"
+)
+
+func (ca *CatchUpPolicy) UnmarshalText(text []byte) error {
+	switch policy := CatchUpPolicy(strings.ToLower(strings.TrimSpace(string(text)))); policy {
+	case CatchUpSkip, CatchUpOnce, CatchUpAll:
+		*ca = policy
+		return nil
+	case "":
+		*ca = CatchUpOnce
+		return nil
+	default:
+		return fmt.Errorf("invalid catch up policy %q: must be one of 'skip', 'once', or 'all'", string(text))
+	}
+}
+
+func (ca CatchUpPolicy) MarshalText() ([]byte, error) {
+	return []byte(ca), nil
+}
@@ -0,0 +1,29 @@
+package config
+
+import "log/slog"
+
+// Similarity configures near-duplicate detection using perceptual hashes.
+type Similarity struct {
+	// Threshold is the maximum Hamming distance (0-64) between perceptual hashes
+	// for two images to be considered near-duplicates.
+	//
+	// Default: 8
+	Threshold int `koanf:"threshold"`
+	// SkipDeviceDuplicates skips assigning an image to a device that already has a near-duplicate of it.
+	//
+	// Default: false
+	SkipDeviceDuplicates bool `koanf:"skip_device_duplicates"`
+}
+
+func (si Similarity) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("threshold", si.Threshold),
+		slog.Bool("skip_device_duplicates", si.SkipDeviceDuplicates),
+	)
+}
+
+func DefaultSimilarity() Similarity {
+	return Similarity{
+		Threshold: 8,
+	}
+}
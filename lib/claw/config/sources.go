@@ -0,0 +1,166 @@
+package config
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/adrg/xdg"
+)
+
+// Sources configures the built-in sources.
+type Sources struct {
+	Reddit   Reddit `koanf:"reddit"`
+	Danbooru Booru  `koanf:"danbooru"`
+	Gelbooru Booru  `koanf:"gelbooru"`
+	Moebooru Booru  `koanf:"moebooru"`
+
+	Wallhaven Wallhaven `koanf:"wallhaven"`
+	LocalDir  LocalDir  `koanf:"localdir"`
+	Script    Script    `koanf:"script"`
+}
+
+func (so Sources) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Any("reddit", so.Reddit),
+		slog.Any("danbooru", so.Danbooru),
+		slog.Any("gelbooru", so.Gelbooru),
+		slog.Any("moebooru", so.Moebooru),
+		slog.Any("wallhaven", so.Wallhaven),
+		slog.Any("localdir", so.LocalDir),
+		slog.Any("script", so.Script),
+	)
+}
+
+func DefaultSources() Sources {
+	return Sources{
+		Danbooru: Booru{BaseURL: "https://danbooru.donmai.us"},
+		Gelbooru: Booru{BaseURL: "https://gelbooru.com"},
+		Moebooru: Booru{BaseURL: "https://yande.re"},
+		Script: Script{
+			Dir:        filepath.Join(xdg.ConfigHome, "claw", "scripts"),
+			Timeout:    5 * time.Minute,
+			MaxFetches: 100,
+		},
+	}
+}
+
+// Reddit configures the claw.reddit.v1 source.
+//
+// Without a ClientID, the public JSON endpoints of reddit.com are used. They are heavily rate limited
+// and may be blocked entirely for some networks. Create an app at https://www.reddit.com/prefs/apps
+// to use the OAuth API at oauth.reddit.com instead.
+type Reddit struct {
+	// ClientID is the ID of the Reddit app, shown under the app name.
+	ClientID string `koanf:"client_id"`
+	// ClientSecret is the secret of a "script" app. Leave empty for an "installed app".
+	ClientSecret string `koanf:"client_secret"`
+	// Username and Password of a developer of the "script" app.
+	//
+	// Optional. When empty, the app authenticates as itself without a user context.
+	Username string `koanf:"username"`
+	Password string `koanf:"password"`
+	// UserAgent sent to Reddit. Reddit asks for a unique and descriptive user agent,
+	// e.g. "linux:claw:v1.0.0 (by /u/yourname)".
+	//
+	// Default: "claw/1.0"
+	UserAgent string `koanf:"user_agent"`
+}
+
+func (re Reddit) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("client_id", re.ClientID),
+		slog.Bool("client_secret_set", re.ClientSecret != ""),
+		slog.String("username", re.Username),
+		slog.Bool("password_set", re.Password != ""),
+		slog.String("user_agent", re.UserAgent),
+	)
+}
+
+// Booru configures the site of a booru source: claw.danbooru.v1, claw.gelbooru.v1, or claw.moebooru.v1.
+//
+// Parameters that are a tag query search this site. Parameters that are a full URL search the site of the URL,
+// and the credentials are not sent there.
+type Booru struct {
+	// BaseURL of the site, e.g. https://danbooru.donmai.us, https://gelbooru.com, or https://konachan.com.
+	//
+	// Set this to use a mirror or a self-hosted instance.
+	BaseURL string `koanf:"base_url"`
+	// Login is the username for Danbooru and Moebooru, and the numeric user ID for Gelbooru.
+	//
+	// Optional. Sites limit how many tags can be searched at once without an account.
+	Login string `koanf:"login"`
+	// APIKey is the API key for Danbooru and Gelbooru, found in the account settings.
+	// For Moebooru, this is the password hash of the account.
+	//
+	// Optional, but must be set together with Login.
+	APIKey string `koanf:"api_key"`
+}
+
+func (bo Booru) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("base_url", bo.BaseURL),
+		slog.String("login", bo.Login),
+		slog.Bool("api_key_set", bo.APIKey != ""),
+	)
+}
+
+// Wallhaven configures the claw.wallhaven.v1 source.
+type Wallhaven struct {
+	// APIKey of a Wallhaven account, found at https://wallhaven.cc/settings/account.
+	//
+	// Optional. Required to search NSFW wallpapers.
+	APIKey string `koanf:"api_key"`
+	// FetchTags fetches the tags and uploader of every wallpaper, which are not part of search results.
+	//
+	// This costs one extra API call per wallpaper. Wallhaven allows 45 API calls per minute,
+	// so runs with a large countback take several minutes.
+	//
+	// Default: false
+	FetchTags bool `koanf:"fetch_tags"`
+}
+
+func (wa Wallhaven) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Bool("api_key_set", wa.APIKey != ""),
+		slog.Bool("fetch_tags", wa.FetchTags),
+	)
+}
+
+// LocalDir configures the claw.localdir.v1 source.
+type LocalDir struct {
+	// AllowedDirs restricts the directories that sources can import from, e.g. ["/home/me/Pictures"].
+	// Subdirectories of the listed directories are allowed too. "~/" is expanded to the home directory.
+	//
+	// When empty, any directory readable by Claw can be imported from. Set this when other people
+	// can create sources, so they cannot read arbitrary files on the host.
+	AllowedDirs []string `koanf:"allowed_dirs"`
+}
+
+// Script configures the claw.script.v1 source, which runs JavaScript files in Dir as sources.
+//
+// Scripts are reloaded when they change. Changes to this config take effect on the next run.
+type Script struct {
+	// Dir holds the scripts. A source with parameter "mysite" runs the file "mysite.js" in this directory.
+	//
+	// Default: "scripts" in the claw config directory, e.g. ~/.config/claw/scripts
+	Dir string `koanf:"dir"`
+	// AllowedHosts restricts the hosts scripts can fetch from, e.g. ["example.com"]. Subdomains of the listed
+	// hosts are allowed too. The download and thumbnail URLs of emitted images must be on these hosts as well.
+	//
+	// When empty, scripts can fetch from any host.
+	AllowedHosts []string `koanf:"allowed_hosts"`
+	// AllowPrivateNetworks lets scripts fetch from loopback, private, and link-local addresses, and emit images
+	// with download and thumbnail URLs on these addresses.
+	//
+	// Default: false, so scripts cannot reach services on the host or the local network.
+	AllowPrivateNetworks bool `koanf:"allow_private_networks"`
+	// Timeout is the time limit of a script run.
+	//
+	// Default: 5 minutes
+	Timeout time.Duration `koanf:"timeout"`
+	// MaxFetches is the number of fetch calls a script may make in a run.
+	//
+	// Default: 100
+	MaxFetches int `koanf:"max_fetches"`
+}
@@ -2,8 +2,10 @@ package config
 
 import (
 	"errors"
+	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type Webhooks struct {
@@ -12,6 +14,43 @@ type Webhooks struct {
 	ImageDownloadSuccess []Webhook `koanf:"image_downloaded,omitempty"`
 	ImageDownloadFailed  []Webhook `koanf:"image_failed,omitempty"`
 	ImageAssigned        []Webhook `koanf:"image_assigned,omitempty"`
+
+	// QueueSize is the maximum number of pending webhook deliveries (default: 256).
+	//
+	// When the queue is full, new deliveries are dropped so slow receivers cannot stall downloads.
+	// Changes only take effect after restart.
+	QueueSize int `koanf:"queue_size"`
+	// Workers is the number of concurrent webhook deliveries (default: 2).
+	//
+	// Changes only take effect after restart.
+	Workers int `koanf:"workers"`
+	// RetryBackoff is the wait time before the first retry. It doubles on every subsequent retry (default: 1 second).
+	RetryBackoff time.Duration `koanf:"retry_backoff"`
+	// MaxRetryBackoff caps the wait time between retries (default: 1 minute).
+	MaxRetryBackoff time.Duration `koanf:"max_retry_backoff"`
+}
+
+func (hooks Webhooks) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("job_start", len(hooks.JobStart)),
+		slog.Int("job_finished", len(hooks.JobFinished)),
+		slog.Int("image_downloaded", len(hooks.ImageDownloadSuccess)),
+		slog.Int("image_failed", len(hooks.ImageDownloadFailed)),
+		slog.Int("image_assigned", len(hooks.ImageAssigned)),
+		slog.Int("queue_size", hooks.QueueSize),
+		slog.Int("workers", hooks.Workers),
+		slog.Duration("retry_backoff", hooks.RetryBackoff),
+		slog.Duration("max_retry_backoff", hooks.MaxRetryBackoff),
+	)
+}
+
+func DefaultWebhooks() Webhooks {
+	return Webhooks{
+		QueueSize:       256,
+		Workers:         2,
+		RetryBackoff:    time.Second,
+		MaxRetryBackoff: time.Minute,
+	}
 }
 
 func (hooks Webhooks) ValidateAndNormalize() error {
@@ -37,6 +76,10 @@ type Webhook struct {
 	URL     string  `koanf:"url"`
 	Method  string  `koanf:"method"`
 	Headers Headers `koanf:"headers"`
+	// Timeout is the time limit of a single delivery attempt (default: 10 seconds).
+	Timeout time.Duration `koanf:"timeout"`
+	// MaxAttempts is the number of delivery attempts before the delivery is dropped (default: 3).
+	MaxAttempts int `koanf:"max_attempts"`
 }
 
 func (web *Webhook) ValidateAndNormalize() error {
@@ -49,6 +92,12 @@ func (web *Webhook) ValidateAndNormalize() error {
 	if web.Headers == nil {
 		web.Headers = make(Headers)
 	}
+	if web.Timeout <= 0 {
+		web.Timeout = 10 * time.Second
+	}
+	if web.MaxAttempts <= 0 {
+		web.MaxAttempts = 3
+	}
 	return nil
 }
 
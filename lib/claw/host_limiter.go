@@ -0,0 +1,234 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"golang.org/x/time/rate"
+)
+
+// hostIdleTimeout is how long the limit of a host is kept after its last request.
+const hostIdleTimeout = 10 * time.Minute
+
+// hostLimiter limits the requests to every host by config.Download.RateLimit, so claw does not get banned
+// by image hosts or source APIs for making too many requests at once.
+//
+// Requests to a host are paused when the host responds with a Retry-After header on 429 or 503 responses.
+// Limits are read from config on every request, so they follow config reloads.
+type hostLimiter struct {
+	config    *config.Config
+	mu        sync.Mutex
+	hosts     map[string]*hostLimit
+	lastEvict time.Time
+}
+
+type hostLimit struct {
+	config      config.HostRateLimit // the limits the limiter and concurrency were last set to
+	limiter     *rate.Limiter
+	concurrency int           // 0 if concurrency is not limited
+	inFlight    int           // requests holding a slot
+	released    chan struct{} // closed and replaced when a slot is released or the concurrency changes
+	users       int           // requests using the limit, including those waiting for a slot
+	lastUsed    time.Time
+	pausedUntil time.Time
+}
+
+func newHostLimiter(cfg *config.Config) *hostLimiter {
+	return &hostLimiter{config: cfg, hosts: make(map[string]*hostLimit), lastEvict: time.Now()}
+}
+
+func newHostLimit(cfg config.HostRateLimit) *hostLimit {
+	limit := &hostLimit{
+		config:      cfg,
+		limiter:     rate.NewLimiter(rate.Inf, 0),
+		concurrency: max(cfg.MaxConcurrency, 0),
+		released:    make(chan struct{}),
+	}
+	if cfg.RequestsPerSecond > 0 {
+		limit.limiter = rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), max(cfg.Burst, 1))
+	}
+	return limit
+}
+
+// update changes the limits in place, so requests that already hold a slot count against the new concurrency.
+func (limit *hostLimit) update(cfg config.HostRateLimit) {
+	limit.config = cfg
+	if cfg.RequestsPerSecond > 0 {
+		limit.limiter.SetLimit(rate.Limit(cfg.RequestsPerSecond))
+		limit.limiter.SetBurst(max(cfg.Burst, 1))
+	} else {
+		limit.limiter.SetLimit(rate.Inf)
+	}
+	limit.concurrency = max(cfg.MaxConcurrency, 0)
+	// Waiters recheck the concurrency, in case it was raised.
+	limit.broadcast()
+}
+
+func (limit *hostLimit) broadcast() {
+	close(limit.released)
+	limit.released = make(chan struct{})
+}
+
+// get returns the limit of the host for a request, updating it if the config of the host changed.
+// The request must call done once it is finished.
+func (hl *hostLimiter) get(host string) *hostLimit {
+	cfg := hl.config.Download.RateLimit.ForHost(host)
+	now := time.Now()
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	hl.evictIdle(now)
+	limit, ok := hl.hosts[host]
+	if !ok {
+		limit = newHostLimit(cfg)
+		hl.hosts[host] = limit
+	} else if limit.config != cfg {
+		limit.update(cfg)
+	}
+	limit.users++
+	limit.lastUsed = now
+	return limit
+}
+
+// done marks a request of get as finished, and releases its slot if it acquired one.
+func (hl *hostLimiter) done(limit *hostLimit, acquired bool) {
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	limit.users--
+	limit.lastUsed = time.Now()
+	if acquired {
+		limit.inFlight--
+		limit.broadcast()
+	}
+}
+
+// acquire waits for a free slot within the concurrency of the host.
+func (hl *hostLimiter) acquire(ctx context.Context, limit *hostLimit) error {
+	for {
+		hl.mu.Lock()
+		if limit.concurrency == 0 || limit.inFlight < limit.concurrency {
+			limit.inFlight++
+			hl.mu.Unlock()
+			return nil
+		}
+		released := limit.released
+		hl.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// evictIdle removes the limits of hosts without requests for hostIdleTimeout, so the hosts of one-off downloads
+// do not pile up. Limits that are still paused or refilling their burst are kept, since a new limit would
+// forget them. hl.mu must be held.
+func (hl *hostLimiter) evictIdle(now time.Time) {
+	if now.Sub(hl.lastEvict) < hostIdleTimeout {
+		return
+	}
+	hl.lastEvict = now
+	for host, limit := range hl.hosts {
+		if limit.users == 0 &&
+			now.Sub(limit.lastUsed) >= hostIdleTimeout &&
+			!now.Before(limit.pausedUntil) &&
+			limit.limiter.TokensAt(now) >= float64(limit.limiter.Burst()) {
+			delete(hl.hosts, host)
+		}
+	}
+}
+
+// pause delays all further requests to the host of limit for d, capped by config.RateLimit.MaxRetryAfter.
+func (hl *hostLimiter) pause(limit *hostLimit, d time.Duration) {
+	if maxPause := hl.config.Download.RateLimit.MaxRetryAfter; maxPause > 0 {
+		d = min(d, maxPause)
+	}
+	hl.mu.Lock()
+	defer hl.mu.Unlock()
+	if until := time.Now().Add(d); until.After(limit.pausedUntil) {
+		limit.pausedUntil = until
+	}
+}
+
+func (hl *hostLimiter) waitForPause(ctx context.Context, limit *hostLimit) error {
+	hl.mu.Lock()
+	wait := time.Until(limit.pausedUntil)
+	hl.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Client returns a Doer that sends requests through client within the limits of their hosts.
+func (hl *hostLimiter) Client(client Doer) Doer {
+	return &rateLimitedClient{client: client, limiter: hl}
+}
+
+type rateLimitedClient struct {
+	client  Doer
+	limiter *hostLimiter
+}
+
+// Do waits for a free slot and the rate limit of the host before sending the request.
+//
+// The slot is held until the response body is closed, so MaxConcurrency also covers the transfer of the body.
+func (rc *rateLimitedClient) Do(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	if host == "" {
+		return rc.client.Do(req)
+	}
+	ctx := req.Context()
+	limit := rc.limiter.get(host)
+	if err := rc.limiter.acquire(ctx, limit); err != nil {
+		rc.limiter.done(limit, false)
+		return nil, err
+	}
+	release := sync.OnceFunc(func() { rc.limiter.done(limit, true) })
+	if err := rc.limiter.waitForPause(ctx, limit); err != nil {
+		release()
+		return nil, err
+	}
+	if err := limit.limiter.Wait(ctx); err != nil {
+		release()
+		return nil, fmt.Errorf("failed to wait for rate limit of %s: %w", host, err)
+	}
+
+	resp, err := rc.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		if retryAfter := source.RetryAfter(resp.Header); retryAfter > 0 {
+			rc.limiter.pause(limit, retryAfter)
+		}
+	}
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases the slot of the request when the response body is closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (body *releasingBody) Close() error {
+	defer body.release()
+	return body.ReadCloser.Close()
+}
@@ -0,0 +1,182 @@
+package claw
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+)
+
+func TestHostLimiter(t *testing.T) {
+	t.Run("pauses host on Retry-After", func(t *testing.T) {
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if requests.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0.2")
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := config.DefaultConfig()
+		cfg.Download.RateLimit.RequestsPerSecond = 0
+		client := newHostLimiter(cfg).Client(http.DefaultClient)
+
+		get := func() *http.Response {
+			req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+			require.NoError(t, err)
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			return resp
+		}
+		assert.Equal(t, http.StatusTooManyRequests, get().StatusCode)
+		start := time.Now()
+		assert.Equal(t, http.StatusOK, get().StatusCode)
+		assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+	})
+
+	t.Run("limits concurrency until body is closed", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := config.DefaultConfig()
+		cfg.Download.RateLimit.Hosts = map[string]config.HostRateLimit{
+			"127.0.0.1": {MaxConcurrency: 1},
+		}
+		client := newHostLimiter(cfg).Client(http.DefaultClient)
+
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		first, err := client.Do(req)
+		require.NoError(t, err)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			resp, err := client.Do(req.Clone(req.Context()))
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+		select {
+		case <-done:
+			t.Fatal("second request was sent before the body of the first was closed")
+		case <-time.After(100 * time.Millisecond):
+		}
+		_ = first.Body.Close()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("second request was not sent after the body of the first was closed")
+		}
+	})
+
+	t.Run("lowered concurrency counts requests holding a slot", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := config.DefaultConfig()
+		cfg.Download.RateLimit.Hosts = map[string]config.HostRateLimit{
+			"127.0.0.1": {MaxConcurrency: 2},
+		}
+		client := newHostLimiter(cfg).Client(http.DefaultClient)
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		first, err := client.Do(req)
+		require.NoError(t, err)
+		second, err := client.Do(req.Clone(req.Context()))
+		require.NoError(t, err)
+
+		cfg.Download.RateLimit.Hosts = map[string]config.HostRateLimit{
+			"127.0.0.1": {MaxConcurrency: 1},
+		}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			resp, err := client.Do(req.Clone(req.Context()))
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+		_ = first.Body.Close()
+		select {
+		case <-done:
+			t.Fatal("third request was sent while the second still held the only slot")
+		case <-time.After(100 * time.Millisecond):
+		}
+		_ = second.Body.Close()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("third request was not sent after every slot was released")
+		}
+	})
+
+	t.Run("evicts idle hosts", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		cfg := config.DefaultConfig()
+		cfg.Download.RateLimit.MaxRetryAfter = 0
+		limiter := newHostLimiter(cfg)
+		client := limiter.Client(http.DefaultClient)
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(t, err)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		hosts := func() int {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			return len(limiter.hosts)
+		}
+		evict := func(after time.Duration) {
+			limiter.mu.Lock()
+			defer limiter.mu.Unlock()
+			limiter.lastEvict = time.Time{} // evict now, regardless of the last eviction
+			limiter.evictIdle(time.Now().Add(after))
+		}
+
+		evict(2 * hostIdleTimeout)
+		assert.Equal(t, 1, hosts(), "hosts with requests in flight are kept")
+
+		_ = resp.Body.Close()
+		limit := limiter.get("127.0.0.1")
+		limiter.pause(limit, 3*hostIdleTimeout)
+		limiter.done(limit, false)
+		evict(2 * hostIdleTimeout)
+		assert.Equal(t, 1, hosts(), "paused hosts are kept")
+
+		evict(hostIdleTimeout / 2)
+		assert.Equal(t, 1, hosts(), "recently used hosts are kept")
+
+		evict(4 * hostIdleTimeout)
+		assert.Equal(t, 0, hosts())
+	})
+}
+
+func TestRateLimitForHost(t *testing.T) {
+	cfg := config.DefaultRateLimit()
+	cfg.Hosts = map[string]config.HostRateLimit{
+		"imgur.com":   {RequestsPerSecond: 1},
+		"i.imgur.com": {MaxConcurrency: 2},
+	}
+	assert.Equal(t, config.HostRateLimit{RequestsPerSecond: 2, Burst: 4, MaxConcurrency: 2}, cfg.ForHost("i.imgur.com"))
+	assert.Equal(t, config.HostRateLimit{RequestsPerSecond: 1, Burst: 4, MaxConcurrency: 4}, cfg.ForHost("s.imgur.com"))
+	assert.Equal(t, config.HostRateLimit{RequestsPerSecond: 2, Burst: 4, MaxConcurrency: 4}, cfg.ForHost("danbooru.donmai.us"))
+}
@@ -0,0 +1,102 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// AssignTags adds tags to images, creating tags that do not exist yet.
+//
+// With replace_existing, tags of the images that are not in the request are removed.
+// Image IDs that do not exist are ignored.
+func (s *Claw) AssignTags(ctx context.Context, req *clawv1.AssignTagsRequest) (*clawv1.AssignTagsResponse, error) {
+	names := normalizeTagNames(req.Tags)
+	if len(req.ImageIds) == 0 || (len(names) == 0 && !req.ReplaceExisting) {
+		return &clawv1.AssignTagsResponse{
+			UpdatedCount: 0,
+		}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	imageIDExprs := make([]Expression, len(req.ImageIds))
+	for i, id := range req.ImageIds {
+		imageIDExprs[i] = Int64(id)
+	}
+	var images []model.Images
+	err = SELECT(Images.ID).
+		FROM(Images).
+		WHERE(Images.ID.IN(imageIDExprs...)).
+		QueryContext(ctx, tx, &images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get images: %w", err)
+	}
+	if len(images) == 0 {
+		return &clawv1.AssignTagsResponse{
+			UpdatedCount: 0,
+		}, nil
+	}
+	imageIDExprs = imageIDExprs[:0]
+	for _, image := range images {
+		imageIDExprs = append(imageIDExprs, Int64(*image.ID))
+	}
+
+	tags, err := ensureTags(ctx, tx, names)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.ReplaceExisting {
+		tagIDExprs := make([]Expression, len(tags))
+		for i, tag := range tags {
+			tagIDExprs[i] = Int64(*tag.ID)
+		}
+		cond := ImageTags.ImageID.IN(imageIDExprs...)
+		if len(tagIDExprs) > 0 {
+			cond = cond.AND(ImageTags.TagID.NOT_IN(tagIDExprs...))
+		}
+		_, err := ImageTags.DELETE().WHERE(cond).ExecContext(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove existing image tags: %w", err)
+		}
+	}
+
+	if len(tags) > 0 {
+		now := types.UnixMilliNow()
+		rows := make([]model.ImageTags, 0, len(images)*len(tags))
+		for _, image := range images {
+			for _, tag := range tags {
+				rows = append(rows, model.ImageTags{
+					ImageID:   *image.ID,
+					TagID:     *tag.ID,
+					CreatedAt: now,
+				})
+			}
+		}
+		_, err = ImageTags.INSERT(ImageTags.AllColumns).
+			MODELS(rows).
+			ON_CONFLICT(ImageTags.ImageID, ImageTags.TagID).DO_NOTHING().
+			ExecContext(ctx, tx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign tags: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return &clawv1.AssignTagsResponse{
+		UpdatedCount: int32(len(images)),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package claw
+
+import (
+	"context"
+	"testing"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// imageTagNames returns the names of the tags of the image, sorted by name.
+func imageTagNames(t *testing.T, cl *Claw, imageID int64) []string {
+	t.Helper()
+	var tags []model.Tags
+	err := SELECT(Tags.AllColumns).
+		FROM(Tags.INNER_JOIN(ImageTags, ImageTags.TagID.EQ(Tags.ID))).
+		WHERE(ImageTags.ImageID.EQ(Int64(imageID))).
+		ORDER_BY(Tags.Name.ASC()).
+		QueryContext(context.Background(), cl.db, &tags)
+	require.NoError(t, err)
+	names := make([]string, len(tags))
+	for i, tag := range tags {
+		names[i] = tag.Name
+	}
+	return names
+}
+
+func TestAssignTags(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	first := insertTestImage(t, cl, "https://example.com/first.jpg")
+	second := insertTestImage(t, cl, "https://example.com/second.jpg")
+
+	resp, err := cl.AssignTags(ctx, &clawv1.AssignTagsRequest{
+		ImageIds: []int64{*first.ID, *second.ID, 9999},
+		Tags:     []string{" Landscape ", "landscape", "Night  Sky"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), resp.UpdatedCount, "missing images are ignored")
+	assert.Equal(t, []string{"landscape", "night sky"}, imageTagNames(t, cl, *first.ID))
+	assert.Equal(t, []string{"landscape", "night sky"}, imageTagNames(t, cl, *second.ID))
+
+	// Assigning again adds to the existing tags.
+	_, err = cl.AssignTags(ctx, &clawv1.AssignTagsRequest{
+		ImageIds: []int64{*first.ID},
+		Tags:     []string{"landscape", "forest"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"forest", "landscape", "night sky"}, imageTagNames(t, cl, *first.ID))
+
+	// Replacing removes the tags that are not in the request.
+	_, err = cl.AssignTags(ctx, &clawv1.AssignTagsRequest{
+		ImageIds:        []int64{*first.ID},
+		Tags:            []string{"forest"},
+		ReplaceExisting: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"forest"}, imageTagNames(t, cl, *first.ID))
+	assert.Equal(t, []string{"landscape", "night sky"}, imageTagNames(t, cl, *second.ID))
+}
+
+func TestListImages_TagMatch(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	both := insertTestImage(t, cl, "https://example.com/both.jpg")
+	forest := insertTestImage(t, cl, "https://example.com/forest.jpg")
+	insertTestImage(t, cl, "https://example.com/untagged.jpg")
+	_, err := cl.AssignTags(ctx, &clawv1.AssignTagsRequest{ImageIds: []int64{*both.ID}, Tags: []string{"forest", "night"}})
+	require.NoError(t, err)
+	_, err = cl.AssignTags(ctx, &clawv1.AssignTagsRequest{ImageIds: []int64{*forest.ID}, Tags: []string{"forest"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		tags  []string
+		match clawv1.TagMatch
+		want  []int64
+	}{
+		{"any", []string{"forest", "night"}, clawv1.TagMatch_TAG_MATCH_ANY, []int64{*both.ID, *forest.ID}},
+		{"all", []string{"forest", "night"}, clawv1.TagMatch_TAG_MATCH_ALL, []int64{*both.ID}},
+		{"all with single tag", []string{"Forest"}, clawv1.TagMatch_TAG_MATCH_ALL, []int64{*both.ID, *forest.ID}},
+		{"all with unknown tag", []string{"forest", "desert"}, clawv1.TagMatch_TAG_MATCH_ALL, []int64{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := cl.ListImages(ctx, &clawv1.ListImagesRequest{
+				Tags:     tt.tags,
+				TagMatch: tt.match,
+				Sorts:    []*clawv1.ListImagesRequest_Sort{{Field: clawv1.ImageField_IMAGE_FIELD_ID}},
+			})
+			require.NoError(t, err)
+			ids := []int64{}
+			for _, image := range resp.Images {
+				ids = append(ids, image.Id)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
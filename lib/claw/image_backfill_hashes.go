@@ -0,0 +1,311 @@
+package claw
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// BackfillHashesResult summarizes a BackfillImageHashes run.
+type BackfillHashesResult struct {
+	// Hashed is the number of images that got their content hash stored.
+	Hashed int
+	// Merged is the number of images merged into another image with identical content.
+	Merged int
+	// Missing is the number of images skipped because their file does not exist.
+	Missing int
+	// PerceptualHashed is the number of images that got their perceptual hashes stored.
+	PerceptualHashed int
+}
+
+// BackfillImageHashes computes the content hash of every image downloaded before content hashing was introduced.
+//
+// Images with identical content are merged into the image that was hashed first. The merged image's source and post
+// are kept as image references, and its tags, jobs, and device assignments are moved to the remaining image.
+//
+// Afterwards, perceptual hashes are computed for images that do not have them yet, except for images that
+// previously failed to decode.
+//
+// Merges are serialized with the downloads of this Claw only, so it refuses to run while a scheduler,
+// e.g. of a running server, uses the same database.
+func (s *Claw) BackfillImageHashes(ctx context.Context) (result BackfillHashesResult, err error) {
+	if err := s.checkSchedulerStopped(ctx); err != nil {
+		return result, err
+	}
+	var images []model.Images
+	err = SELECT(Images.AllColumns).
+		FROM(Images).
+		WHERE(Images.Sha256.EQ(String(""))).
+		ORDER_BY(Images.ID.ASC()).
+		QueryContext(otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller()), s.db, &images)
+	if err != nil {
+		return result, fmt.Errorf("failed to list images without hash: %w", err)
+	}
+
+	for _, image := range images {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		contentHash, err := hashFile(s.scheduler.absoluteImagePath(image.ImagePath))
+		if errors.Is(err, os.ErrNotExist) {
+			s.logger.WarnContext(ctx, "image file does not exist, skipping", "image_id", *image.ID, "path", image.ImagePath)
+			result.Missing++
+			continue
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to hash image %d: %w", *image.ID, err)
+		}
+
+		s.scheduler.imageMutex.Lock()
+		err = s.backfillImageHash(ctx, image, contentHash, &result)
+		s.scheduler.imageMutex.Unlock()
+		if err != nil {
+			return result, err
+		}
+	}
+
+	images = images[:0]
+	err = SELECT(Images.AllColumns).
+		FROM(Images).
+		WHERE(
+			Images.Dhash.IS_NULL().OR(Images.Phash.IS_NULL()).
+				AND(Images.PerceptualHashFailedAt.IS_NULL()),
+		).
+		ORDER_BY(Images.ID.ASC()).
+		QueryContext(otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller()), s.db, &images)
+	if err != nil {
+		return result, fmt.Errorf("failed to list images without perceptual hash: %w", err)
+	}
+	for _, image := range images {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		imagePath := s.scheduler.absoluteImagePath(image.ImagePath)
+		if _, err := s.scheduler.processPerceptualHash(ctx, image, imagePath); err != nil {
+			// Missing files are already reported above, and undecodable formats are not fatal.
+			s.logger.WarnContext(ctx, "failed to compute perceptual hash", "image_id", *image.ID, "path", image.ImagePath, "error", err)
+			continue
+		}
+		result.PerceptualHashed++
+	}
+	return result, nil
+}
+
+func (s *Claw) backfillImageHash(ctx context.Context, image model.Images, contentHash string, result *BackfillHashesResult) error {
+	duplicate, err := s.scheduler.findImageByHash(ctx, contentHash)
+	if err != nil {
+		return fmt.Errorf("failed to find image by content hash: %w", err)
+	}
+	if duplicate == nil {
+		_, err := Images.UPDATE(Images.Sha256).
+			SET(String(contentHash)).
+			WHERE(Images.ID.EQ(Int64(*image.ID))).
+			ExecContext(otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller()), s.db)
+		if err != nil {
+			return fmt.Errorf("failed to update hash of image %d: %w", *image.ID, err)
+		}
+		result.Hashed++
+		return nil
+	}
+
+	if err := s.mergeImage(ctx, image, *duplicate); err != nil {
+		return fmt.Errorf("failed to merge image %d into %d: %w", *image.ID, *duplicate.ID, err)
+	}
+	s.logger.InfoContext(ctx, "merged duplicate image", "image_id", *image.ID, "into_image_id", *duplicate.ID, "sha256", contentHash)
+	result.Merged++
+	return nil
+}
+
+// mergeImage moves everything pointing to from into the image into, then deletes from and its files.
+func (s *Claw) mergeImage(ctx context.Context, from, into model.Images) (err error) {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := types.UnixMilliNow()
+	references := []model.ImageReferences{{
+		SourceID:      from.SourceID,
+		DownloadURL:   from.DownloadURL,
+		Title:         from.Title,
+		PostAuthor:    from.PostAuthor,
+		PostAuthorURL: from.PostAuthorURL,
+		PostURL:       from.PostURL,
+		CreatedAt:     from.CreatedAt,
+	}}
+	var existingReferences []model.ImageReferences
+	err = SELECT(ImageReferences.AllColumns).
+		FROM(ImageReferences).
+		WHERE(ImageReferences.ImageID.EQ(Int64(*from.ID))).
+		QueryContext(ctx, tx, &existingReferences)
+	if err != nil {
+		return fmt.Errorf("failed to list image references: %w", err)
+	}
+	references = append(references, existingReferences...)
+	for _, reference := range references {
+		if reference.DownloadURL == into.DownloadURL {
+			continue
+		}
+		reference.ID = nil
+		reference.ImageID = *into.ID
+		_, err := ImageReferences.INSERT(ImageReferences.MutableColumns).
+			MODEL(reference).
+			ON_CONFLICT(ImageReferences.ImageID, ImageReferences.DownloadURL).DO_NOTHING().
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to add image reference: %w", err)
+		}
+	}
+
+	var tags []model.ImageTags
+	err = SELECT(ImageTags.AllColumns).
+		FROM(ImageTags).
+		WHERE(ImageTags.ImageID.EQ(Int64(*from.ID))).
+		QueryContext(ctx, tx, &tags)
+	if err != nil {
+		return fmt.Errorf("failed to list image tags: %w", err)
+	}
+	for _, tag := range tags {
+		tag.ImageID = *into.ID
+		_, err := ImageTags.INSERT(ImageTags.AllColumns).
+			MODEL(tag).
+			ON_CONFLICT(ImageTags.ImageID, ImageTags.TagID).DO_NOTHING().
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to move image tag: %w", err)
+		}
+	}
+
+	var jobImages []model.JobImages
+	err = SELECT(JobImages.AllColumns).
+		FROM(JobImages).
+		WHERE(JobImages.ImageID.EQ(Int64(*from.ID))).
+		QueryContext(ctx, tx, &jobImages)
+	if err != nil {
+		return fmt.Errorf("failed to list job images: %w", err)
+	}
+	for _, jobImage := range jobImages {
+		jobImage.ID = nil
+		jobImage.ImageID = *into.ID
+		_, err := JobImages.INSERT(JobImages.MutableColumns).
+			MODEL(jobImage).
+			ON_CONFLICT(JobImages.JobID, JobImages.ImageID, JobImages.DeviceID).DO_NOTHING().
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to move job image: %w", err)
+		}
+	}
+
+	var fromDevices, intoDevices []model.ImageDevices
+	err = SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices).
+		WHERE(ImageDevices.ImageID.EQ(Int64(*from.ID))).
+		QueryContext(ctx, tx, &fromDevices)
+	if err != nil {
+		return fmt.Errorf("failed to list image devices: %w", err)
+	}
+	err = SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices).
+		WHERE(ImageDevices.ImageID.EQ(Int64(*into.ID))).
+		QueryContext(ctx, tx, &intoDevices)
+	if err != nil {
+		return fmt.Errorf("failed to list image devices: %w", err)
+	}
+	assigned := make(map[int64]bool, len(intoDevices))
+	for _, device := range intoDevices {
+		assigned[device.DeviceID] = true
+	}
+	// Files that become redundant once the merge is committed.
+	removeFiles := []string{from.ThumbnailPath}
+	if from.ImagePath != into.ImagePath {
+		removeFiles = append(removeFiles, from.ImagePath)
+	}
+	for _, device := range fromDevices {
+		if assigned[device.DeviceID] {
+			// The device already has the same content.
+			removeFiles = append(removeFiles, device.Path)
+			continue
+		}
+		_, err := ImageDevices.UPDATE(ImageDevices.ImageID).
+			SET(Int64(*into.ID)).
+			WHERE(ImageDevices.ImageID.EQ(Int64(*from.ID)).AND(ImageDevices.DeviceID.EQ(Int64(device.DeviceID)))).
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to move device assignment: %w", err)
+		}
+	}
+
+	if from.IsFavorite.Bool() && !into.IsFavorite.Bool() {
+		_, err := Images.UPDATE(Images.IsFavorite, Images.UpdatedAt).
+			SET(types.Bool(true).Integer(), now).
+			WHERE(Images.ID.EQ(Int64(*into.ID))).
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to carry over favorite flag: %w", err)
+		}
+	}
+
+	// Foreign key enforcement is not guaranteed on every connection, so dependent rows are deleted explicitly.
+	if err := deleteImageRows(ctx, tx, *from.ID); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	for _, file := range removeFiles {
+		if file == "" {
+			continue
+		}
+		if err := os.Remove(s.scheduler.absoluteImagePath(file)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			s.logger.WarnContext(ctx, "failed to remove merged image file", "path", file, "error", err)
+		}
+	}
+	return nil
+}
+
+func deleteImageRows(ctx context.Context, tx *sql.Tx, imageID int64) error {
+	statements := []struct {
+		name string
+		stmt Statement
+	}{
+		{"image references", ImageReferences.DELETE().WHERE(ImageReferences.ImageID.EQ(Int64(imageID)))},
+		{"image tags", ImageTags.DELETE().WHERE(ImageTags.ImageID.EQ(Int64(imageID)))},
+		{"job images", JobImages.DELETE().WHERE(JobImages.ImageID.EQ(Int64(imageID)))},
+		{"image devices", ImageDevices.DELETE().WHERE(ImageDevices.ImageID.EQ(Int64(imageID)))},
+		{"image", Images.DELETE().WHERE(Images.ID.EQ(Int64(imageID)))},
+	}
+	for _, s := range statements {
+		if _, err := s.stmt.ExecContext(ctx, tx); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", s.name, err)
+		}
+	}
+	return nil
+}
+
+// hashFile returns the hex encoded SHA-256 of the file content.
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
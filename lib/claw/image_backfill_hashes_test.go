@@ -0,0 +1,195 @@
+package claw
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// writeTestFile writes content to the path relative to base dir.
+func writeTestFile(t *testing.T, cl *Claw, relativePath, content string) {
+	t.Helper()
+	absolutePath := cl.scheduler.absoluteImagePath(relativePath)
+	require.NoError(t, os.MkdirAll(filepath.Dir(absolutePath), 0o755))
+	require.NoError(t, os.WriteFile(absolutePath, []byte(content), 0o644))
+}
+
+func TestMergeImage(t *testing.T) {
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	ctx := context.Background()
+	into := insertTestImage(t, cl, "https://example.com/into.jpg")
+	from := insertTestImage(t, cl, "https://example.com/from.jpg")
+	from.ThumbnailPath = "thumbnails/from.jpg"
+	from.IsFavorite = true
+	_, err := Images.UPDATE(Images.ThumbnailPath, Images.IsFavorite).
+		SET(String(from.ThumbnailPath), types.Bool(true).Integer()).
+		WHERE(Images.ID.EQ(Int64(*from.ID))).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+	for _, file := range []string{into.ImagePath, from.ImagePath, from.ThumbnailPath} {
+		writeTestFile(t, cl, file, "image")
+	}
+
+	_, err = ImageReferences.INSERT(ImageReferences.MutableColumns).
+		MODEL(model.ImageReferences{
+			ImageID:     *from.ID,
+			SourceID:    from.SourceID,
+			DownloadURL: "https://mirror.example.com/from.jpg",
+			CreatedAt:   types.UnixMilliNow(),
+		}).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+	_, err = cl.AssignTags(ctx, &clawv1.AssignTagsRequest{ImageIds: []int64{*from.ID}, Tags: []string{"forest"}})
+	require.NoError(t, err)
+	_, err = cl.AssignTags(ctx, &clawv1.AssignTagsRequest{ImageIds: []int64{*into.ID}, Tags: []string{"night"}})
+	require.NoError(t, err)
+
+	// The shared device has both images, the moved device only the merged one.
+	shared := insertTestDevice(t, cl, "shared")
+	moved := insertTestDevice(t, cl, "moved")
+	imageDevices := []model.ImageDevices{
+		{ImageID: *into.ID, DeviceID: *shared.ID, Path: "devices/shared/into.jpg"},
+		{ImageID: *from.ID, DeviceID: *shared.ID, Path: "devices/shared/from.jpg"},
+		{ImageID: *from.ID, DeviceID: *moved.ID, Path: "devices/moved/from.jpg"},
+	}
+	for _, imageDevice := range imageDevices {
+		imageDevice.CreatedAt = types.UnixMilliNow()
+		_, err := ImageDevices.INSERT(ImageDevices.AllColumns).MODEL(imageDevice).ExecContext(ctx, cl.db)
+		require.NoError(t, err)
+		writeTestFile(t, cl, imageDevice.Path, "image")
+	}
+	job := insertTestJob(t, cl, from.SourceID, clawv1.JobStatus_JOB_STATUS_COMPLETED)
+	_, err = JobImages.INSERT(JobImages.MutableColumns).
+		MODEL(model.JobImages{
+			JobID:     *job.ID,
+			ImageID:   *from.ID,
+			DeviceID:  *moved.ID,
+			Action:    clawv1.JobAction_JOB_ACTION_DOWNLOAD.String(),
+			CreatedAt: types.UnixMilliNow(),
+		}).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+
+	require.NoError(t, cl.mergeImage(ctx, from, into))
+
+	var deleted model.Images
+	err = SELECT(Images.AllColumns).FROM(Images).WHERE(Images.ID.EQ(Int64(*from.ID))).QueryContext(ctx, cl.db, &deleted)
+	require.ErrorIs(t, err, qrm.ErrNoRows)
+
+	var merged model.Images
+	err = SELECT(Images.AllColumns).FROM(Images).WHERE(Images.ID.EQ(Int64(*into.ID))).QueryContext(ctx, cl.db, &merged)
+	require.NoError(t, err)
+	assert.True(t, merged.IsFavorite.Bool())
+	assert.Equal(t, []string{"forest", "night"}, imageTagNames(t, cl, *into.ID))
+
+	var references []model.ImageReferences
+	err = SELECT(ImageReferences.AllColumns).
+		FROM(ImageReferences).
+		WHERE(ImageReferences.ImageID.EQ(Int64(*into.ID))).
+		QueryContext(ctx, cl.db, &references)
+	require.NoError(t, err)
+	var referenceURLs []string
+	for _, reference := range references {
+		referenceURLs = append(referenceURLs, reference.DownloadURL)
+	}
+	assert.ElementsMatch(t, []string{"https://example.com/from.jpg", "https://mirror.example.com/from.jpg"}, referenceURLs)
+
+	var jobImages []model.JobImages
+	err = SELECT(JobImages.AllColumns).FROM(JobImages).WHERE(JobImages.JobID.EQ(Int64(*job.ID))).QueryContext(ctx, cl.db, &jobImages)
+	require.NoError(t, err)
+	require.Len(t, jobImages, 1)
+	assert.Equal(t, *into.ID, jobImages[0].ImageID)
+
+	var devices []model.ImageDevices
+	err = SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices).
+		WHERE(ImageDevices.ImageID.EQ(Int64(*into.ID))).
+		ORDER_BY(ImageDevices.DeviceID.ASC()).
+		QueryContext(ctx, cl.db, &devices)
+	require.NoError(t, err)
+	require.Len(t, devices, 2)
+	assert.Equal(t, "devices/shared/into.jpg", devices[0].Path)
+	assert.Equal(t, "devices/moved/from.jpg", devices[1].Path)
+
+	for file, exists := range map[string]bool{
+		into.ImagePath:            true,
+		"devices/shared/into.jpg": true,
+		"devices/moved/from.jpg":  true,
+		from.ImagePath:            false,
+		from.ThumbnailPath:        false,
+		"devices/shared/from.jpg": false,
+	} {
+		_, err := os.Stat(cl.scheduler.absoluteImagePath(file))
+		if exists {
+			assert.NoError(t, err, file)
+		} else {
+			assert.ErrorIs(t, err, os.ErrNotExist, file)
+		}
+	}
+}
+
+func TestBackfillImageHashes(t *testing.T) {
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	ctx := context.Background()
+	first := insertTestImage(t, cl, "https://example.com/first.jpg")
+	second := insertTestImage(t, cl, "https://example.com/second.jpg")
+	other := insertTestImage(t, cl, "https://example.com/other.jpg")
+	insertTestImage(t, cl, "https://example.com/missing.jpg")
+	writeTestFile(t, cl, first.ImagePath, "same")
+	writeTestFile(t, cl, second.ImagePath, "same")
+	writeTestFile(t, cl, other.ImagePath, "other")
+
+	result, err := cl.BackfillImageHashes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Hashed)
+	assert.Equal(t, 1, result.Merged)
+	assert.Equal(t, 1, result.Missing)
+
+	var images []model.Images
+	err = SELECT(Images.AllColumns).FROM(Images).WHERE(Images.Sha256.NOT_EQ(String(""))).ORDER_BY(Images.ID.ASC()).QueryContext(ctx, cl.db, &images)
+	require.NoError(t, err)
+	require.Len(t, images, 2)
+	assert.Equal(t, *first.ID, *images[0].ID)
+	assert.Equal(t, *other.ID, *images[1].ID)
+	existing, err := cl.scheduler.findImageByURL(ctx, second.DownloadURL)
+	require.NoError(t, err)
+	require.NotNil(t, existing)
+	assert.Equal(t, *first.ID, *existing.ID)
+}
+
+func TestBackfillImageHashes_SchedulerRunning(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+
+	stop := cl.scheduler.startHeartbeat(ctx)
+	_, err := cl.BackfillImageHashes(ctx)
+	require.ErrorIs(t, err, errSchedulerRunning)
+
+	stop()
+	_, err = cl.BackfillImageHashes(ctx)
+	require.NoError(t, err)
+
+	// A heartbeat left behind by a scheduler that exited without stopping does not block forever.
+	_, err = SchedulerHeartbeats.INSERT(SchedulerHeartbeats.AllColumns).
+		MODEL(model.SchedulerHeartbeats{
+			InstanceID: "crashed",
+			UpdatedAt:  types.NewUnixMilli(time.Now().Add(-heartbeatTimeout - time.Second)),
+		}).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+	_, err = cl.BackfillImageHashes(ctx)
+	require.NoError(t, err)
+}
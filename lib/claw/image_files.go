@@ -0,0 +1,139 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// ErrFileNotFound is returned when the requested image, thumbnail, or device file
+// is not known to the database or does not exist on disk.
+var ErrFileNotFound = errors.New("file not found")
+
+// OpenImageFile opens the downloaded file of the image with the given ID.
+//
+// The caller is responsible for closing the returned file.
+func (s *Claw) OpenImageFile(ctx context.Context, id int64) (*os.File, error) {
+	image, err := s.findImageFiles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.openInBaseDir(image.ImagePath)
+}
+
+// OpenThumbnailFile opens the thumbnail of the image with the given ID.
+//
+// Images without a thumbnail (e.g. downloaded before thumbnails were generated)
+// fall back to the original image file.
+//
+// The caller is responsible for closing the returned file.
+func (s *Claw) OpenThumbnailFile(ctx context.Context, id int64) (*os.File, error) {
+	image, err := s.findImageFiles(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if image.ThumbnailPath != "" {
+		f, err := s.openInBaseDir(image.ThumbnailPath)
+		if !errors.Is(err, ErrFileNotFound) {
+			return f, err
+		}
+	}
+	return s.openInBaseDir(image.ImagePath)
+}
+
+// OpenDeviceFile opens a file assigned to the device with the given slug. name is the path of the file
+// relative to the device directory, in forward slash form.
+//
+// Only files recorded as device assignments can be opened.
+//
+// The caller is responsible for closing the returned file.
+func (s *Claw) OpenDeviceFile(ctx context.Context, slug, name string) (*os.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, fmt.Errorf("%w: invalid path %q", ErrFileNotFound, name)
+	}
+	relativePath := path.Join("devices", slug, name)
+
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	var assignment model.ImageDevices
+	err := SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices.INNER_JOIN(Devices, Devices.ID.EQ(ImageDevices.DeviceID))).
+		WHERE(
+			Devices.Slug.EQ(String(slug)).
+				AND(ImageDevices.Path.EQ(String(relativePath))),
+		).
+		LIMIT(1).
+		QueryContext(ctx, s.db, &assignment)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("%w: no image assigned at %q", ErrFileNotFound, relativePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query device file %q: %w", relativePath, err)
+	}
+	return s.openInBaseDir(assignment.Path)
+}
+
+func (s *Claw) findImageFiles(ctx context.Context, id int64) (model.Images, error) {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	var image model.Images
+	err := SELECT(Images.AllColumns).
+		FROM(Images).
+		WHERE(Images.ID.EQ(Int64(id))).
+		QueryContext(ctx, s.db, &image)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return image, fmt.Errorf("%w: image %d does not exist", ErrFileNotFound, id)
+	}
+	if err != nil {
+		return image, fmt.Errorf("failed to get image %d: %w", id, err)
+	}
+	return image, nil
+}
+
+// openInBaseDir opens a regular file relative to the download base directory.
+//
+// The file is opened with os.OpenInRoot, so paths (including symlinks) that escape the base directory are rejected.
+func (s *Claw) openInBaseDir(relativePath string) (*os.File, error) {
+	if relativePath == "" {
+		return nil, fmt.Errorf("%w: empty path", ErrFileNotFound)
+	}
+	baseDir := s.config.Download.BaseDir
+	name := filepath.FromSlash(relativePath)
+	if filepath.IsAbs(name) {
+		// Paths stored before base_dir was normalized may still be absolute.
+		rel, err := filepath.Rel(baseDir, name)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q is outside of base directory", ErrFileNotFound, relativePath)
+		}
+		name = rel
+	}
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("%w: %q is outside of base directory", ErrFileNotFound, relativePath)
+	}
+
+	f, err := os.OpenInRoot(baseDir, name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %q does not exist", ErrFileNotFound, relativePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %q: %w", relativePath, err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("failed to stat %q: %w", relativePath, err)
+	}
+	if !info.Mode().IsRegular() {
+		_ = f.Close()
+		return nil, fmt.Errorf("%w: %q is not a regular file", ErrFileNotFound, relativePath)
+	}
+	return f, nil
+}
@@ -0,0 +1,103 @@
+package claw
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+func TestOpenDeviceFile(t *testing.T) {
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	ctx := context.Background()
+	image := insertTestImage(t, cl, "https://example.com/lake.jpg")
+	device := insertTestDevice(t, cl, "phone")
+	insertTestDevice(t, cl, "other")
+	_, err := ImageDevices.INSERT(ImageDevices.AllColumns).
+		MODEL(model.ImageDevices{
+			ImageID:   *image.ID,
+			DeviceID:  *device.ID,
+			Path:      "devices/phone/2025/lake.jpg",
+			CreatedAt: types.UnixMilliNow(),
+		}).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+	writeTestFile(t, cl, "devices/phone/2025/lake.jpg", "lake")
+	writeTestFile(t, cl, "devices/phone/unassigned.jpg", "unassigned")
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(cl.config.Download.BaseDir), "secret.txt"), []byte("secret"), 0o644))
+
+	tests := []struct {
+		name     string
+		slug     string
+		filename string
+		want     string
+	}{
+		{name: "assigned", slug: "phone", filename: "2025/lake.jpg", want: "lake"},
+		{name: "unassigned file", slug: "phone", filename: "unassigned.jpg"},
+		{name: "other device", slug: "other", filename: "2025/lake.jpg"},
+		{name: "parent directory", slug: "phone", filename: "../../secret.txt"},
+		{name: "dot segments", slug: "phone", filename: "2025/../2025/lake.jpg"},
+		{name: "absolute", slug: "phone", filename: "/devices/phone/2025/lake.jpg"},
+		{name: "empty", slug: "phone", filename: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := cl.OpenDeviceFile(ctx, tt.slug, tt.filename)
+			if tt.want == "" {
+				require.ErrorIs(t, err, ErrFileNotFound)
+				return
+			}
+			require.NoError(t, err)
+			defer f.Close()
+			content, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(content))
+		})
+	}
+}
+
+func TestOpenInBaseDir(t *testing.T) {
+	cl := newTestClaw(t)
+	root := t.TempDir()
+	cl.config.Download.BaseDir = filepath.Join(root, "base")
+	writeTestFile(t, cl, "images/lake.jpg", "lake")
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secret.txt"), []byte("secret"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret.txt"), cl.scheduler.absoluteImagePath("images/link.jpg")))
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "relative", path: "images/lake.jpg"},
+		{name: "absolute inside base dir", path: filepath.Join(cl.config.Download.BaseDir, "images", "lake.jpg")},
+		{name: "absolute outside base dir", path: filepath.Join(root, "secret.txt"), wantErr: true},
+		{name: "parent directory", path: "../secret.txt", wantErr: true},
+		{name: "symlink escaping base dir", path: "images/link.jpg", wantErr: true},
+		{name: "directory", path: "images", wantErr: true},
+		{name: "missing", path: "images/missing.jpg", wantErr: true},
+		{name: "empty", path: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := cl.openInBaseDir(tt.path)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer f.Close()
+			content, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, "lake", string(content))
+		})
+	}
+}
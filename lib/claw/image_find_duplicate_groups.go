@@ -0,0 +1,132 @@
+package claw
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// FindDuplicateGroups clusters near-duplicate images by the Hamming distance of their perceptual hashes
+//
+// Images are in the same group when they are within max distance of at least one other image in the group.
+func (s *Claw) FindDuplicateGroups(ctx context.Context, req *clawv1.FindDuplicateGroupsRequest) (*clawv1.FindDuplicateGroupsResponse, error) {
+	images, err := s.listPerceptuallyHashedImages(ctx, req.DeviceId, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	maxDistance := s.similarityMaxDistance(req.MaxDistance)
+	groups := newDisjointSet(len(images))
+	compared := 0
+	for _, bucket := range similarityBuckets(images, maxDistance) {
+		for a, i := range bucket {
+			for _, j := range bucket[a+1:] {
+				if compared++; compared%4096 == 0 && ctx.Err() != nil {
+					return nil, ctx.Err()
+				}
+				// Pairs sharing more than one band are seen again, and are skipped once grouped.
+				if groups.find(i) == groups.find(j) {
+					continue
+				}
+				if distance, ok := imageDistance(images[i], images[j]); ok && distance <= maxDistance {
+					groups.union(i, j)
+				}
+			}
+		}
+	}
+
+	members := make(map[int][]model.Images)
+	for i, image := range images {
+		root := groups.find(i)
+		members[root] = append(members[root], image)
+	}
+	clusters := make([][]model.Images, 0)
+	for _, cluster := range members {
+		if len(cluster) > 1 {
+			clusters = append(clusters, cluster)
+		}
+	}
+	// Largest groups first. Members are already ordered by ID, so ties are broken by the oldest image.
+	slices.SortFunc(clusters, func(a, b []model.Images) int {
+		if c := cmp.Compare(len(b), len(a)); c != 0 {
+			return c
+		}
+		return cmp.Compare(*a[0].ID, *b[0].ID)
+	})
+	if limit := similarityLimit(req.Limit); len(clusters) > limit {
+		clusters = clusters[:limit]
+	}
+
+	out := make([]*clawv1.FindDuplicateGroupsResponse_Group, len(clusters))
+	for i, cluster := range clusters {
+		group := &clawv1.FindDuplicateGroupsResponse_Group{
+			Images: make([]*clawv1.Image, len(cluster)),
+		}
+		for j, image := range cluster {
+			group.Images[j] = imageModelToProto(image)
+		}
+		out[i] = group
+	}
+	return &clawv1.FindDuplicateGroupsResponse{Groups: out}, nil
+}
+
+// similarityBuckets groups the indexes of images that share a band of their dHash, so only images in the same
+// bucket need to be compared. See similarityBands.
+func similarityBuckets(images []model.Images, maxDistance int) [][]int {
+	bands := similarityBands(maxDistance)
+	if bands == nil {
+		all := make([]int, len(images))
+		for i := range all {
+			all[i] = i
+		}
+		return [][]int{all}
+	}
+	var buckets [][]int
+	for _, band := range bands {
+		byValue := make(map[uint64][]int)
+		for i, image := range images {
+			if image.Dhash == nil {
+				continue
+			}
+			value := band.value(uint64(*image.Dhash))
+			byValue[value] = append(byValue[value], i)
+		}
+		for _, bucket := range byValue {
+			if len(bucket) > 1 {
+				buckets = append(buckets, bucket)
+			}
+		}
+	}
+	return buckets
+}
+
+// disjointSet is a union-find structure over indexes 0..n-1.
+type disjointSet struct {
+	parent []int
+}
+
+func newDisjointSet(n int) *disjointSet {
+	parent := make([]int, n)
+	for i := range parent {
+		parent[i] = i
+	}
+	return &disjointSet{parent: parent}
+}
+
+func (d *disjointSet) find(i int) int {
+	for d.parent[i] != i {
+		d.parent[i] = d.parent[d.parent[i]] // path halving
+		i = d.parent[i]
+	}
+	return i
+}
+
+func (d *disjointSet) union(a, b int) {
+	ra, rb := d.find(a), d.find(b)
+	if ra != rb {
+		d.parent[max(ra, rb)] = min(ra, rb)
+	}
+}
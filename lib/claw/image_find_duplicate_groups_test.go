@@ -0,0 +1,84 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// insertTestHashedImages inserts images in clusters of near-duplicates, with hashes of both signs.
+func insertTestHashedImages(t *testing.T, cl *Claw) []model.Images {
+	t.Helper()
+	ctx := context.Background()
+	rng := rand.New(rand.NewPCG(3, 4))
+	var images []model.Images
+	for cluster := range 30 {
+		dhash, phash := rng.Uint64(), rng.Uint64()
+		for variant := range rng.IntN(4) + 1 {
+			image := insertTestImage(t, cl, fmt.Sprintf("https://example.com/%d-%d.jpg", cluster, variant))
+			err := Images.UPDATE(Images.Dhash, Images.Phash).
+				SET(Int64(int64(flipBits(rng, dhash, 16))), Int64(int64(flipBits(rng, phash, 16)))).
+				WHERE(Images.ID.EQ(Int64(*image.ID))).
+				RETURNING(Images.AllColumns).
+				QueryContext(ctx, cl.db, &image)
+			require.NoError(t, err)
+			images = append(images, image)
+		}
+	}
+	return images
+}
+
+func TestFindDuplicateGroups(t *testing.T) {
+	cl := newTestClaw(t)
+	images := insertTestHashedImages(t, cl)
+
+	for _, maxDistance := range []uint32{0, 4, 8, 16, 64} {
+		// Compare every pair to know the expected groups.
+		want := newDisjointSet(len(images))
+		for i := range images {
+			for j := i + 1; j < len(images); j++ {
+				if distance, _ := imageDistance(images[i], images[j]); distance <= int(maxDistance) {
+					want.union(i, j)
+				}
+			}
+		}
+		wantGroups := make(map[int][]int64)
+		for i, image := range images {
+			wantGroups[want.find(i)] = append(wantGroups[want.find(i)], *image.ID)
+		}
+		var wantIDs [][]int64
+		for _, group := range wantGroups {
+			if len(group) > 1 {
+				wantIDs = append(wantIDs, group)
+			}
+		}
+
+		resp, err := cl.FindDuplicateGroups(context.Background(), &clawv1.FindDuplicateGroupsRequest{
+			MaxDistance: &maxDistance,
+			Limit:       Ptr(uint32(200)),
+		})
+		require.NoError(t, err)
+		var gotIDs [][]int64
+		for _, group := range resp.Groups {
+			ids := make([]int64, len(group.Images))
+			for i, image := range group.Images {
+				ids[i] = image.Id
+			}
+			gotIDs = append(gotIDs, ids)
+		}
+		if maxDistance == 8 {
+			require.NotEmpty(t, wantIDs, "the test images have near-duplicates")
+		}
+		assert.ElementsMatch(t, wantIDs, gotIDs, "max distance %d", maxDistance)
+		assert.True(t, slices.IsSortedFunc(gotIDs, func(a, b []int64) int { return len(b) - len(a) }), "largest groups first")
+	}
+}
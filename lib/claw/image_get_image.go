@@ -20,10 +20,10 @@ func (s *Claw) GetImage(ctx context.Context, req *clawv1.GetImageRequest) (*claw
 		Sources.AllColumns,
 	).
 		FROM(
-			Images.INNER_JOIN(ImageTags, ImageTags.ImageID.EQ(Int64(req.Id))).
-				INNER_JOIN(Tags, Tags.ID.EQ(ImageTags.TagID)).
-				INNER_JOIN(ImageDevices, ImageDevices.ImageID.EQ(Int64(req.Id))).
-				INNER_JOIN(Devices, Devices.ID.EQ(ImageDevices.DeviceID)).
+			Images.LEFT_JOIN(ImageTags, ImageTags.ImageID.EQ(Images.ID)).
+				LEFT_JOIN(Tags, Tags.ID.EQ(ImageTags.TagID)).
+				LEFT_JOIN(ImageDevices, ImageDevices.ImageID.EQ(Images.ID)).
+				LEFT_JOIN(Devices, Devices.ID.EQ(ImageDevices.DeviceID)).
 				INNER_JOIN(Sources, Sources.ID.EQ(Images.SourceID)),
 		).
 		WHERE(Images.ID.EQ(Int64(req.Id)))
@@ -16,9 +16,7 @@ import (
 func (s *Claw) ListImages(ctx context.Context, req *clawv1.ListImagesRequest) (*clawv1.ListImagesResponse, error) {
 	isReversed := req.Pagination != nil && req.Pagination.GetPrevToken() != 0
 	cond := Bool(true)
-	var from ReadableTable = Images.
-		INNER_JOIN(ImageTags, ImageTags.ImageID.EQ(Images.ID)).
-		INNER_JOIN(Tags, Tags.ID.EQ(ImageTags.TagID))
+	var from ReadableTable = Images
 
 	// Search filter
 	if search := req.GetSearch(); search != "" {
@@ -28,7 +26,11 @@ func (s *Claw) ListImages(ctx context.Context, req *clawv1.ListImagesRequest) (*
 				OR(Images.PostAuthor.LIKE(searchTerm)).
 				OR(Images.PostURL.LIKE(searchTerm)).
 				OR(Images.DownloadURL.LIKE(searchTerm)).
-				OR(Tags.Name.LIKE(searchTerm)),
+				OR(Images.ID.IN(
+					SELECT(ImageTags.ImageID).
+						FROM(ImageTags.INNER_JOIN(Tags, Tags.ID.EQ(ImageTags.TagID))).
+						WHERE(Tags.Name.LIKE(searchTerm)),
+				)),
 		)
 	}
 
@@ -43,8 +45,9 @@ func (s *Claw) ListImages(ctx context.Context, req *clawv1.ListImagesRequest) (*
 		cond = cond.AND(ImageDevices.DeviceID.EQ(Int64(*req.DeviceId)))
 	}
 
-	if len(req.Tags) > 0 {
-		cond = cond.AND(Tags.Name.IN(jetStringsExpr(req.Tags...)...))
+	if tags := normalizeTagNames(req.Tags); len(tags) > 0 {
+		matchAll := req.TagMatch == clawv1.TagMatch_TAG_MATCH_ALL
+		cond = cond.AND(Images.ID.IN(tagImageIDs(tags, matchAll)))
 	}
 
 	if req.IsFavorite != nil {
@@ -102,10 +105,8 @@ func (s *Claw) ListImages(ctx context.Context, req *clawv1.ListImagesRequest) (*
 
 	var out []struct {
 		model.Images
-		ImageDevices []model.ImageDevices
-		ImageTags    []model.ImageTags
 	}
-	err := SELECT(Images.AllColumns, Tags.AllColumns).
+	err := SELECT(Images.AllColumns).
 		FROM(from).
 		WHERE(cond).
 		ORDER_BY(sorts...).
@@ -0,0 +1,102 @@
+package claw
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// ListSimilarImages lists images that look similar to the requested image, closest first
+func (s *Claw) ListSimilarImages(ctx context.Context, req *clawv1.ListSimilarImagesRequest) (*clawv1.ListSimilarImagesResponse, error) {
+	var target model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Images.AllColumns).
+		FROM(Images).
+		WHERE(Images.ID.EQ(Int64(req.Id))).
+		QueryContext(ctx, s.db, &target)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("image %d not found", req.Id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get image: %w", err)
+	}
+	if target.Dhash == nil || target.Phash == nil {
+		return nil, fmt.Errorf("image %d has no perceptual hash yet. Run `claw backfill-hashes` to compute it", req.Id)
+	}
+
+	maxDistance := s.similarityMaxDistance(req.MaxDistance)
+	candidates, err := s.listPerceptuallyHashedImages(ctx, nil,
+		Images.ID.NOT_EQ(Int64(*target.ID)).AND(similarHashCondition(uint64(*target.Dhash), maxDistance)),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*clawv1.ListSimilarImagesResponse_Entry, 0)
+	for _, candidate := range candidates {
+		if distance, ok := imageDistance(target, candidate); ok && distance <= maxDistance {
+			entries = append(entries, &clawv1.ListSimilarImagesResponse_Entry{
+				Image:    imageModelToProto(candidate),
+				Distance: uint32(distance),
+			})
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b *clawv1.ListSimilarImagesResponse_Entry) int {
+		return cmp.Compare(a.Distance, b.Distance)
+	})
+	if limit := similarityLimit(req.Limit); len(entries) > limit {
+		entries = entries[:limit]
+	}
+
+	return &clawv1.ListSimilarImagesResponse{Entries: entries}, nil
+}
+
+// listPerceptuallyHashedImages lists all images with perceptual hashes, oldest first.
+// If deviceID is not nil, only images assigned to that device are listed.
+// If filter is not nil, only images matching it are listed.
+func (s *Claw) listPerceptuallyHashedImages(ctx context.Context, deviceID *int64, filter BoolExpression) ([]model.Images, error) {
+	var from ReadableTable = Images
+	cond := Images.Dhash.IS_NOT_NULL().AND(Images.Phash.IS_NOT_NULL())
+	if deviceID != nil {
+		from = Images.INNER_JOIN(ImageDevices, ImageDevices.ImageID.EQ(Images.ID))
+		cond = cond.AND(ImageDevices.DeviceID.EQ(Int64(*deviceID)))
+	}
+	if filter != nil {
+		cond = cond.AND(filter)
+	}
+
+	var images []model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Images.AllColumns).
+		FROM(from).
+		WHERE(cond).
+		ORDER_BY(Images.ID.ASC()).
+		QueryContext(ctx, s.db, &images)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w", err)
+	}
+	return images, nil
+}
+
+func (s *Claw) similarityMaxDistance(requested *uint32) int {
+	if requested != nil {
+		return int(*requested)
+	}
+	return s.config.Similarity.Threshold
+}
+
+func similarityLimit(requested *uint32) int {
+	if requested == nil || *requested == 0 {
+		return 50
+	}
+	return int(Clamp(*requested, 1, 200))
+}
@@ -0,0 +1,47 @@
+package claw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+func TestListSimilarImages(t *testing.T) {
+	cl := newTestClaw(t)
+	images := insertTestHashedImages(t, cl)
+
+	for _, maxDistance := range []uint32{0, 8, 16, 64} {
+		for _, target := range images {
+			want := make(map[int64]uint32)
+			for _, image := range images {
+				if distance, _ := imageDistance(target, image); *image.ID != *target.ID && distance <= int(maxDistance) {
+					want[*image.ID] = uint32(distance)
+				}
+			}
+
+			resp, err := cl.ListSimilarImages(context.Background(), &clawv1.ListSimilarImagesRequest{
+				Id:          *target.ID,
+				MaxDistance: &maxDistance,
+				Limit:       Ptr(uint32(200)),
+			})
+			require.NoError(t, err)
+			got := make(map[int64]uint32)
+			for i, entry := range resp.Entries {
+				got[entry.Image.Id] = entry.Distance
+				if i > 0 {
+					assert.LessOrEqual(t, resp.Entries[i-1].Distance, entry.Distance, "closest first")
+				}
+			}
+			assert.Equal(t, want, got, "image %d, max distance %d", *target.ID, maxDistance)
+		}
+	}
+
+	t.Run("image without perceptual hash", func(t *testing.T) {
+		image := insertTestImage(t, cl, "https://example.com/unhashed.jpg")
+		_, err := cl.ListSimilarImages(context.Background(), &clawv1.ListSimilarImagesRequest{Id: *image.ID})
+		assert.ErrorContains(t, err, "has no perceptual hash")
+	})
+}
@@ -0,0 +1,120 @@
+// Package imagehash implements perceptual hashes to find near-duplicate images.
+//
+// Unlike cryptographic hashes, similar looking images (re-encoded, resized, slightly recolored)
+// produce hashes with a small Hamming distance.
+package imagehash
+
+import (
+	"image"
+	"math"
+	"math/bits"
+	"slices"
+
+	"golang.org/x/image/draw"
+)
+
+// DHash computes the difference hash of the image.
+//
+// The image is scaled to 9x8 grayscale pixels, and each bit tells whether a pixel is brighter than its right neighbour.
+func DHash(img image.Image) uint64 {
+	gray := grayscale(img, 9, 8)
+	var hash uint64
+	for y := range 8 {
+		for x := range 8 {
+			hash <<= 1
+			if gray.GrayAt(x, y).Y > gray.GrayAt(x+1, y).Y {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// PHash computes the DCT based perceptual hash of the image.
+//
+// The image is scaled to 32x32 grayscale pixels and transformed with a 2D DCT. Each bit tells whether
+// one of the 8x8 lowest frequencies is above the median of those frequencies.
+//
+// The DC coefficient is left out of the median, but still takes the most significant bit so the hash
+// has 64 bits. It is the sum of all pixels, so the bit is set for every image but a black one and
+// practically never adds to the distance between two hashes.
+func PHash(img image.Image) uint64 {
+	const size = 32
+	gray := grayscale(img, size, size)
+	pixels := make([][]float64, size)
+	for y := range size {
+		pixels[y] = make([]float64, size)
+		for x := range size {
+			pixels[y][x] = float64(gray.GrayAt(x, y).Y)
+		}
+	}
+
+	freq := dct2D(pixels)
+	lows := make([]float64, 0, 64)
+	for v := range 8 {
+		for u := range 8 {
+			lows = append(lows, freq[v][u])
+		}
+	}
+	// The DC coefficient is the average brightness and would dominate the median.
+	// The remaining 63 frequencies have a single middle value.
+	sorted := slices.Clone(lows[1:])
+	slices.Sort(sorted)
+	median := sorted[len(sorted)/2]
+
+	var hash uint64
+	for _, f := range lows {
+		hash <<= 1
+		if f > median {
+			hash |= 1
+		}
+	}
+	return hash
+}
+
+// Distance returns the Hamming distance between two hashes, from 0 (identical) to 64.
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+func grayscale(img image.Image, width, height int) *image.Gray {
+	dst := image.NewGray(image.Rect(0, 0, width, height))
+	draw.BiLinear.Scale(dst, dst.Bounds(), img, img.Bounds(), draw.Src, nil)
+	return dst
+}
+
+// dct2D computes the type-II discrete cosine transform of a square matrix.
+func dct2D(in [][]float64) [][]float64 {
+	n := len(in)
+	cos := make([][]float64, n)
+	for k := range n {
+		cos[k] = make([]float64, n)
+		for i := range n {
+			cos[k][i] = math.Cos(math.Pi / float64(n) * (float64(i) + 0.5) * float64(k))
+		}
+	}
+	// Rows first, then columns.
+	rows := make([][]float64, n)
+	for y := range n {
+		rows[y] = make([]float64, n)
+		for u := range n {
+			var sum float64
+			for x := range n {
+				sum += in[y][x] * cos[u][x]
+			}
+			rows[y][u] = sum
+		}
+	}
+	out := make([][]float64, n)
+	for v := range n {
+		out[v] = make([]float64, n)
+		for u := range n {
+			var sum float64
+			for y := range n {
+				sum += rows[y][u] * cos[v][y]
+			}
+			out[v][u] = sum
+		}
+	}
+	return out
+}
@@ -0,0 +1,57 @@
+package imagehash
+
+import (
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/image/draw"
+)
+
+// gradient draws a diagonal gradient with a bright square, so the hashes have some structure to work with.
+func gradient(width, height int, squareX int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := range height {
+		for x := range width {
+			v := uint8((x*255/width + y*255/height) / 2)
+			img.Set(x, y, color.RGBA{R: v, G: v / 2, B: 255 - v, A: 255})
+		}
+	}
+	for y := height / 4; y < height/2; y++ {
+		for x := squareX; x < squareX+width/4; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	return img
+}
+
+func TestHashes(t *testing.T) {
+	original := gradient(640, 360, 64)
+
+	resized := image.NewRGBA(image.Rect(0, 0, 320, 180))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), original, original.Bounds(), draw.Src, nil)
+
+	different := gradient(640, 360, 400)
+	for y := range 360 {
+		for x := range 640 {
+			if (x/40+y/40)%2 == 0 {
+				different.Set(x, y, color.Black)
+			}
+		}
+	}
+
+	for name, hash := range map[string]func(image.Image) uint64{"dhash": DHash, "phash": PHash} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, hash(original), hash(original), "hash must be deterministic")
+			assert.LessOrEqual(t, Distance(hash(original), hash(resized)), 4, "resized copy must be near-duplicate")
+			assert.Greater(t, Distance(hash(original), hash(different)), 12, "different image must be far away")
+		})
+	}
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance(0xFF, 0xFF))
+	assert.Equal(t, 64, Distance(0, ^uint64(0)))
+	assert.Equal(t, 3, Distance(0b1010, 0b0101^0b1000))
+}
@@ -2,8 +2,10 @@ package claw
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
+	"github.com/go-jet/jet/v2/qrm"
 	. "github.com/go-jet/jet/v2/sqlite"
 	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
 	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
@@ -11,7 +13,10 @@ import (
 	"github.com/tigorlazuardi/claw/lib/claw/types"
 )
 
-// CancelJob cancels a running job
+// CancelJob cancels a pending or running job.
+//
+// The job is marked as cancelled first, then the running worker (if any) is stopped.
+// Finished jobs cannot be cancelled.
 func (s *Claw) CancelJob(ctx context.Context, req *clawv1.CancelJobRequest) (*clawv1.CancelJobResponse, error) {
 	nowMillis := types.UnixMilliNow()
 
@@ -20,15 +25,23 @@ func (s *Claw) CancelJob(ctx context.Context, req *clawv1.CancelJobRequest) (*cl
 			Status:     clawv1.JobStatus_JOB_STATUS_CANCELLED.String(),
 			FinishedAt: &nowMillis,
 		}).
-		WHERE(Jobs.ID.EQ(Int64(req.Id))).
+		WHERE(Jobs.ID.EQ(Int64(req.Id)).AND(Jobs.FinishedAt.IS_NULL())).
 		RETURNING(Jobs.AllColumns)
 
 	var jobRow model.Jobs
 	err := stmt.QueryContext(ctx, s.db, &jobRow)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("job %d not found or already finished", req.Id)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to cancel job: %w", err)
 	}
 
+	if s.scheduler.tracker.Cancel(req.Id, errJobCancelled) {
+		s.logger.InfoContext(ctx, "cancelled running job", "job_id", req.Id)
+	}
+	s.scheduler.notifyJobStatus(ctx, jobRow)
+
 	// Convert to protobuf
 	job := &clawv1.Job{
 		Id:        *jobRow.ID,
@@ -37,9 +50,7 @@ func (s *Claw) CancelJob(ctx context.Context, req *clawv1.CancelJobRequest) (*cl
 		CreatedAt: jobRow.CreatedAt.ToProto(),
 	}
 
-	if jobRow.ScheduleID != 0 {
-		job.ScheduleId = &jobRow.ScheduleID
-	}
+	job.ScheduleId = jobRow.ScheduleID
 	if jobRow.RunAt != nil {
 		job.RunAt = jobRow.RunAt.ToProto()
 	}
@@ -49,6 +60,11 @@ func (s *Claw) CancelJob(ctx context.Context, req *clawv1.CancelJobRequest) (*cl
 	if jobRow.Error != nil {
 		job.Error = jobRow.Error
 	}
+	job.Backfill = backfillProgress(jobRow)
+	job.Attempt = jobRow.Attempt
+	if jobRow.NextAttemptAt != nil {
+		job.NextAttemptAt = jobRow.NextAttemptAt.ToProto()
+	}
 
 	return &clawv1.CancelJobResponse{
 		Job: job,
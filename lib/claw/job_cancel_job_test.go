@@ -0,0 +1,123 @@
+package claw
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// blockingSource runs until its context is done. Only Name and Run are implemented.
+type blockingSource struct {
+	source.Source
+	started chan struct{}
+}
+
+func (b *blockingSource) Name() string { return "test.blocking.v1" }
+
+func (b *blockingSource) Run(ctx context.Context, _ source.Request) (source.Response, error) {
+	b.started <- struct{}{}
+	<-ctx.Done()
+	return source.Response{}, context.Cause(ctx)
+}
+
+func TestCancelJob(t *testing.T) {
+	cl := newTestClaw(t)
+	backend := &blockingSource{started: make(chan struct{}, 1)}
+	cl.scheduler.backends[backend.Name()] = backend
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES(backend.Name(), "Blocking", "").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	go cl.scheduler.consumeJobQueue(ctx)
+	cancelJob := func(job model.Jobs) {
+		t.Helper()
+		resp, err := cl.CancelJob(ctx, &clawv1.CancelJobRequest{Id: *job.ID})
+		require.NoError(t, err)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_CANCELLED, resp.Job.Status)
+		assert.NotNil(t, resp.Job.FinishedAt)
+	}
+	assertCancelled := func(job model.Jobs) {
+		t.Helper()
+		job = getTestJob(t, cl, *job.ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_CANCELLED.String(), job.Status)
+		assert.NotNil(t, job.FinishedAt)
+	}
+
+	t.Run("running job is stopped and removed from the tracker", func(t *testing.T) {
+		job := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_PENDING)
+		cl.scheduler.enqueueJobs([]model.Jobs{job})
+		select {
+		case <-backend.started:
+		case <-time.After(5 * time.Second):
+			t.Fatal("job did not start")
+		}
+		require.Equal(t, clawv1.JobStatus_JOB_STATUS_RUNNING.String(), getTestJob(t, cl, *job.ID).Status)
+
+		cancelJob(job)
+
+		assert.Eventually(t, func() bool { return !cl.scheduler.tracker.Exists(*job.ID) },
+			5*time.Second, 10*time.Millisecond, "job was not removed from the tracker")
+		assertCancelled(job)
+	})
+
+	t.Run("queued job is skipped when its turn comes", func(t *testing.T) {
+		job := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_PENDING)
+		// Tracked, but not started yet.
+		cl.scheduler.tracker.Add(*job.ID)
+
+		cancelJob(job)
+		assert.True(t, cl.scheduler.tracker.Exists(*job.ID), "queued jobs leave the tracker once the worker picks them up")
+
+		cl.scheduler.queue <- job
+		assert.Eventually(t, func() bool { return !cl.scheduler.tracker.Exists(*job.ID) },
+			5*time.Second, 10*time.Millisecond, "job was not removed from the tracker")
+		select {
+		case <-backend.started:
+			t.Fatal("cancelled job was run")
+		default:
+		}
+		assertCancelled(job)
+	})
+
+	t.Run("later status updates do not overwrite cancelled", func(t *testing.T) {
+		job := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_RUNNING)
+		cancelJob(job)
+
+		cl.scheduler.updateJobStatus(ctx, *job.ID, clawv1.JobStatus_JOB_STATUS_COMPLETED, updateJobStatusAttributes{
+			finishedAt: Ptr(types.UnixMilliNow()),
+		})
+		cl.scheduler.failJob(ctx, getTestJob(t, cl, *job.ID), &source.StatusError{StatusCode: http.StatusServiceUnavailable})
+		assertCancelled(job)
+	})
+
+	t.Run("finished job", func(t *testing.T) {
+		job := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_COMPLETED)
+		_, err := Jobs.UPDATE(Jobs.FinishedAt).SET(Int64(time.Now().UnixMilli())).
+			WHERE(Jobs.ID.EQ(Int64(*job.ID))).
+			ExecContext(ctx, cl.db)
+		require.NoError(t, err)
+
+		_, err = cl.CancelJob(ctx, &clawv1.CancelJobRequest{Id: *job.ID})
+		assert.ErrorContains(t, err, "already finished")
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), getTestJob(t, cl, *job.ID).Status)
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		_, err := cl.CancelJob(ctx, &clawv1.CancelJobRequest{Id: 999})
+		assert.ErrorContains(t, err, "not found")
+	})
+}
@@ -0,0 +1,78 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// CreateBackfillJob creates a job that goes through the entire history of a source page by page,
+// instead of only the last countback posts.
+//
+// The job waits config.Scheduler.BackfillPageDelay between pages, and stores its progress after every page,
+// so it continues where it stopped after a restart.
+func (s *Claw) CreateBackfillJob(ctx context.Context, req *clawv1.CreateBackfillJobRequest) (*clawv1.CreateBackfillJobResponse, error) {
+	var src model.Sources
+	err := SELECT(Sources.ID, Sources.Name).
+		FROM(Sources).
+		WHERE(Sources.ID.EQ(Int64(req.SourceId))).
+		QueryContext(ctx, s.db, &src)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("source %d not found", req.SourceId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get source: %w", err)
+	}
+	backend, ok := s.scheduler.backends[src.Name]
+	if !ok {
+		return nil, fmt.Errorf("no backend found for source name: %s", src.Name)
+	}
+	if _, ok := backend.(source.SourceBackfiller); !ok {
+		return nil, fmt.Errorf("source %s does not support backfilling", src.Name)
+	}
+
+	var jobRow model.Jobs
+	err = Jobs.INSERT(Jobs.SourceID, Jobs.Status, Jobs.CreatedAt, Jobs.Backfill).
+		MODEL(model.Jobs{
+			SourceID:  req.SourceId,
+			Status:    clawv1.JobStatus_JOB_STATUS_PENDING.String(),
+			CreatedAt: types.UnixMilliNow(),
+			Backfill:  1,
+		}).
+		RETURNING(Jobs.AllColumns).
+		QueryContext(ctx, s.db, &jobRow)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create backfill job: %w", err)
+	}
+
+	return &clawv1.CreateBackfillJobResponse{
+		Job: &clawv1.Job{
+			Id:        *jobRow.ID,
+			SourceId:  jobRow.SourceID,
+			Status:    clawv1.JobStatus(clawv1.JobStatus_value[jobRow.Status]),
+			CreatedAt: jobRow.CreatedAt.ToProto(),
+			Backfill:  backfillProgress(jobRow),
+			Attempt:   jobRow.Attempt,
+		},
+	}, nil
+}
+
+// backfillProgress returns the progress of a backfill job, or nil if the job is not a backfill job.
+func backfillProgress(job model.Jobs) *clawv1.BackfillProgress {
+	if job.Backfill == 0 {
+		return nil
+	}
+	return &clawv1.BackfillProgress{
+		PagesFetched: job.BackfillPages,
+		ImagesFound:  job.BackfillImages,
+		HasMore:      job.BackfillPages == 0 || job.BackfillPageToken != "",
+	}
+}
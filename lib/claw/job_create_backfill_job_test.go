@@ -0,0 +1,35 @@
+package claw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+func TestCreateBackfillJob(t *testing.T) {
+	cl, _, src, job := newTestBackfill(t)
+	ctx := context.Background()
+
+	assert.Equal(t, clawv1.JobStatus_JOB_STATUS_PENDING.String(), job.Status)
+	assert.Equal(t, int64(1), job.Backfill)
+	assert.Equal(t, src.ID, &job.SourceID)
+	assert.Equal(t, &clawv1.BackfillProgress{HasMore: true}, backfillProgress(job))
+
+	t.Run("unknown source", func(t *testing.T) {
+		_, err := cl.CreateBackfillJob(ctx, &clawv1.CreateBackfillJobRequest{SourceId: 999})
+		assert.ErrorContains(t, err, "source 999 not found")
+	})
+
+	t.Run("source without backfill support", func(t *testing.T) {
+		image := insertTestImage(t, cl, "https://example.com/lake.jpg")
+		_, err := cl.CreateBackfillJob(ctx, &clawv1.CreateBackfillJobRequest{SourceId: image.SourceID})
+		assert.ErrorContains(t, err, "does not support backfilling")
+	})
+
+	t.Run("regular jobs have no backfill progress", func(t *testing.T) {
+		assert.Nil(t, backfillProgress(model.Jobs{}))
+	})
+}
@@ -36,7 +36,7 @@ func (s *Claw) CreateJob(ctx context.Context, req *clawv1.CreateJobRequest) (*cl
 	// Only include schedule_id if provided
 	if req.ScheduleId != nil {
 		columns = append(columns, Jobs.ScheduleID)
-		jobModel.ScheduleID = *req.ScheduleId
+		jobModel.ScheduleID = req.ScheduleId
 	}
 
 	jobStmt := Jobs.INSERT(columns).MODEL(jobModel).RETURNING(Jobs.AllColumns)
@@ -82,9 +82,7 @@ func (s *Claw) CreateJob(ctx context.Context, req *clawv1.CreateJobRequest) (*cl
 		CreatedAt: jobRow.CreatedAt.ToProto(),
 	}
 
-	if jobRow.ScheduleID != 0 {
-		job.ScheduleId = &jobRow.ScheduleID
-	}
+	job.ScheduleId = jobRow.ScheduleID
 	if jobRow.RunAt != nil {
 		job.RunAt = jobRow.RunAt.ToProto()
 	}
@@ -94,6 +92,7 @@ func (s *Claw) CreateJob(ctx context.Context, req *clawv1.CreateJobRequest) (*cl
 	if jobRow.Error != nil {
 		job.Error = jobRow.Error
 	}
+	job.Attempt = jobRow.Attempt
 
 	// Add job images
 	for _, jobImageReq := range req.JobImages {
@@ -8,6 +8,7 @@ import (
 	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
 	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
 	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/otel"
 )
 
 // GetJob retrieves a job by ID
@@ -39,9 +40,7 @@ func (s *Claw) GetJob(ctx context.Context, req *clawv1.GetJobRequest) (*clawv1.G
 		CreatedAt: out.CreatedAt.ToProto(),
 	}
 
-	if out.ScheduleID != 0 {
-		job.ScheduleId = &out.ScheduleID
-	}
+	job.ScheduleId = out.ScheduleID
 	if out.RunAt != nil {
 		job.RunAt = out.RunAt.ToProto()
 	}
@@ -51,9 +50,80 @@ func (s *Claw) GetJob(ctx context.Context, req *clawv1.GetJobRequest) (*clawv1.G
 	if out.Error != nil {
 		job.Error = out.Error
 	}
+	job.Backfill = backfillProgress(out.Jobs)
+	job.Attempt = out.Attempt
+	if out.NextAttemptAt != nil {
+		job.NextAttemptAt = out.NextAttemptAt.ToProto()
+	}
+	if req.GetIncludeJobImages() {
+		for _, jobImageRow := range out.JobImages {
+			if jobImageRow.ID == nil {
+				continue
+			}
+			job.JobImages = append(job.JobImages, &clawv1.JobImage{
+				Id:        *jobImageRow.ID,
+				JobId:     jobImageRow.JobID,
+				ImageId:   jobImageRow.ImageID,
+				DeviceId:  jobImageRow.DeviceID,
+				Action:    clawv1.JobAction(clawv1.JobAction_value[jobImageRow.Action]),
+				CreatedAt: jobImageRow.CreatedAt.ToProto(),
+			})
+		}
+		failures, err := s.downloadFailureJobImages(ctx, *out.ID)
+		if err != nil {
+			return nil, err
+		}
+		job.JobImages = append(job.JobImages, failures[*out.ID]...)
+	}
 
 	return &clawv1.GetJobResponse{
 		Job: job,
 	}, nil
 }
 
+// downloadFailureJobImages returns the images of the jobs that failed to download as JOB_ACTION_FAILED job images,
+// grouped by job ID, one for every device the image was assigned to. The ID of these job images is the ID of the
+// download failure.
+func (s *Claw) downloadFailureJobImages(ctx context.Context, jobs ...int64) (map[int64][]*clawv1.JobImage, error) {
+	out := make(map[int64][]*clawv1.JobImage)
+	if len(jobs) == 0 {
+		return out, nil
+	}
+	jobIDs := make([]Expression, len(jobs))
+	for i, job := range jobs {
+		jobIDs[i] = Int64(job)
+	}
+	var rows []struct {
+		model.DownloadFailures
+		Devices []model.DownloadFailureDevices
+	}
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(DownloadFailures.AllColumns, DownloadFailureDevices.AllColumns).
+		FROM(DownloadFailures.INNER_JOIN(DownloadFailureDevices, DownloadFailureDevices.FailureID.EQ(DownloadFailures.ID))).
+		WHERE(DownloadFailures.JobID.IN(jobIDs...)).
+		ORDER_BY(DownloadFailures.ID.ASC()).
+		QueryContext(ctx, s.db, &rows)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get download failures: %w", err)
+	}
+
+	for _, row := range rows {
+		for _, device := range row.Devices {
+			jobImage := &clawv1.JobImage{
+				Id:          *row.ID,
+				JobId:       row.JobID,
+				DeviceId:    device.DeviceID,
+				Action:      clawv1.JobAction_JOB_ACTION_FAILED,
+				CreatedAt:   row.CreatedAt.ToProto(),
+				DownloadUrl: &row.DownloadURL,
+				Error:       &row.Error,
+				Attempts:    &row.Attempts,
+			}
+			if row.NextAttemptAt != nil {
+				jobImage.NextAttemptAt = row.NextAttemptAt.ToProto()
+			}
+			out[row.JobID] = append(out[row.JobID], jobImage)
+		}
+	}
+	return out, nil
+}
@@ -90,6 +90,19 @@ func (s *Claw) ListJobs(ctx context.Context, req *clawv1.ListJobsRequest) (*claw
 		return nil, fmt.Errorf("failed to list jobs: %w", err)
 	}
 
+	// Failures of all listed jobs are loaded at once.
+	var failures map[int64][]*clawv1.JobImage
+	if req.IncludeJobImages != nil && *req.IncludeJobImages {
+		jobIDs := make([]int64, len(jobRows))
+		for i, jobRow := range jobRows {
+			jobIDs[i] = *jobRow.ID
+		}
+		failures, err = s.downloadFailureJobImages(ctx, jobIDs...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Convert to protobuf
 	var jobs []*clawv1.Job
 	for _, jobRow := range jobRows {
@@ -100,9 +113,7 @@ func (s *Claw) ListJobs(ctx context.Context, req *clawv1.ListJobsRequest) (*claw
 			CreatedAt: jobRow.CreatedAt.ToProto(),
 		}
 
-		if jobRow.ScheduleID != 0 {
-			job.ScheduleId = &jobRow.ScheduleID
-		}
+		job.ScheduleId = jobRow.ScheduleID
 		if jobRow.RunAt != nil {
 			job.RunAt = jobRow.RunAt.ToProto()
 		}
@@ -112,6 +123,11 @@ func (s *Claw) ListJobs(ctx context.Context, req *clawv1.ListJobsRequest) (*claw
 		if jobRow.Error != nil {
 			job.Error = jobRow.Error
 		}
+		job.Backfill = backfillProgress(jobRow)
+		job.Attempt = jobRow.Attempt
+		if jobRow.NextAttemptAt != nil {
+			job.NextAttemptAt = jobRow.NextAttemptAt.ToProto()
+		}
 
 		// Load job images if requested
 		if req.IncludeJobImages != nil && *req.IncludeJobImages {
@@ -135,6 +151,7 @@ func (s *Claw) ListJobs(ctx context.Context, req *clawv1.ListJobsRequest) (*claw
 					CreatedAt: jobImageRow.CreatedAt.ToProto(),
 				})
 			}
+			job.JobImages = append(job.JobImages, failures[*jobRow.ID]...)
 		}
 
 		jobs = append(jobs, job)
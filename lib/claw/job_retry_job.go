@@ -43,17 +43,25 @@ func (s *Claw) RetryJob(ctx context.Context, req *clawv1.RetryJobRequest) (*claw
 
 	nowMillis := types.UnixMilliNow()
 
-	// Create new job
+	// Create new job. A retried backfill continues from the last page the original job fetched.
 	newJobStmt := Jobs.INSERT(
 		Jobs.SourceID,
 		Jobs.ScheduleID,
 		Jobs.Status,
 		Jobs.CreatedAt,
+		Jobs.Backfill,
+		Jobs.BackfillPageToken,
+		Jobs.BackfillPages,
+		Jobs.BackfillImages,
 	).MODEL(model.Jobs{
-		SourceID:   originalJob.SourceID,
-		ScheduleID: originalJob.ScheduleID,
-		Status:     clawv1.JobStatus_JOB_STATUS_PENDING.String(),
-		CreatedAt:  nowMillis,
+		SourceID:          originalJob.SourceID,
+		ScheduleID:        originalJob.ScheduleID,
+		Status:            clawv1.JobStatus_JOB_STATUS_PENDING.String(),
+		CreatedAt:         nowMillis,
+		Backfill:          originalJob.Backfill,
+		BackfillPageToken: originalJob.BackfillPageToken,
+		BackfillPages:     originalJob.BackfillPages,
+		BackfillImages:    originalJob.BackfillImages,
 	}).RETURNING(Jobs.AllColumns)
 
 	var newJobRow model.Jobs
@@ -97,9 +105,7 @@ func (s *Claw) RetryJob(ctx context.Context, req *clawv1.RetryJobRequest) (*claw
 		CreatedAt: newJobRow.CreatedAt.ToProto(),
 	}
 
-	if newJobRow.ScheduleID != 0 {
-		job.ScheduleId = &newJobRow.ScheduleID
-	}
+	job.ScheduleId = newJobRow.ScheduleID
 	if newJobRow.RunAt != nil {
 		job.RunAt = newJobRow.RunAt.ToProto()
 	}
@@ -109,6 +115,11 @@ func (s *Claw) RetryJob(ctx context.Context, req *clawv1.RetryJobRequest) (*claw
 	if newJobRow.Error != nil {
 		job.Error = newJobRow.Error
 	}
+	job.Backfill = backfillProgress(newJobRow)
+	job.Attempt = newJobRow.Attempt
+	if newJobRow.NextAttemptAt != nil {
+		job.NextAttemptAt = newJobRow.NextAttemptAt.ToProto()
+	}
 
 	// Add job images to response
 	for _, originalJobImage := range originalJobImages {
@@ -28,8 +28,9 @@ func (s *Claw) UpdateJob(ctx context.Context, req *clawv1.UpdateJobRequest) (*cl
 			updateModel.RunAt = &nowMillis
 		}
 
-		// Set finished_at when status changes to COMPLETED, FAILED, or CANCELLED
+		// Set finished_at when status changes to COMPLETED, COMPLETED_WITH_ERRORS, FAILED, or CANCELLED
 		if *req.Status == clawv1.JobStatus_JOB_STATUS_COMPLETED ||
+			*req.Status == clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS ||
 			*req.Status == clawv1.JobStatus_JOB_STATUS_FAILED ||
 			*req.Status == clawv1.JobStatus_JOB_STATUS_CANCELLED {
 			columns = append(columns, Jobs.FinishedAt)
@@ -61,9 +62,7 @@ func (s *Claw) UpdateJob(ctx context.Context, req *clawv1.UpdateJobRequest) (*cl
 		CreatedAt: jobRow.CreatedAt.ToProto(),
 	}
 
-	if jobRow.ScheduleID != 0 {
-		job.ScheduleId = &jobRow.ScheduleID
-	}
+	job.ScheduleId = jobRow.ScheduleID
 	if jobRow.RunAt != nil {
 		job.RunAt = jobRow.RunAt.ToProto()
 	}
@@ -73,6 +72,11 @@ func (s *Claw) UpdateJob(ctx context.Context, req *clawv1.UpdateJobRequest) (*cl
 	if jobRow.Error != nil {
 		job.Error = jobRow.Error
 	}
+	job.Backfill = backfillProgress(jobRow)
+	job.Attempt = jobRow.Attempt
+	if jobRow.NextAttemptAt != nil {
+		job.NextAttemptAt = jobRow.NextAttemptAt.ToProto()
+	}
 
 	return &clawv1.UpdateJobResponse{
 		Job: job,
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"slices"
@@ -28,6 +29,9 @@ import (
 
 const leastCommonMultiple = 720720 // LCM of 1 to 16
 
+// errJobCancelled is the cancellation cause of jobs cancelled by the user.
+var errJobCancelled = errors.New("job cancelled by user")
+
 type Doer interface {
 	Do(req *http.Request) (*http.Response, error)
 }
@@ -41,14 +45,19 @@ type scheduler struct {
 	imageSemaphore *semaphore.Weighted
 	wg             *sync.WaitGroup
 	reloadSignal   *broadcast.Relay[struct{}]
+	scheduleSignal *broadcast.Relay[struct{}]
 	logger         *slog.Logger
 	backends       map[string]source.Source
 	httpclient     Doer
+	webhooks       *webhookDispatcher
+	assignLocks    keyedMutex // serializes device assignments by image and device, and by device path
+	imageMutex     sync.Mutex // serializes content-hash lookups and inserts of new images
 }
 
 type imageQueue struct {
 	image   source.Image
 	devices []model.Devices
+	err     error // set if the image failed to download
 }
 
 func (scheduler *scheduler) start(baseContext context.Context) {
@@ -57,7 +66,14 @@ func (scheduler *scheduler) start(baseContext context.Context) {
 	}
 	scheduler.isRunning.Store(true)
 	defer scheduler.isRunning.Store(false)
+	// The heartbeat outlives baseContext until running jobs completed, since they may still download.
+	stopHeartbeat := scheduler.startHeartbeat(context.WithoutCancel(baseContext))
+	defer stopHeartbeat()
 	go scheduler.startPolling(baseContext)
+	go scheduler.startCron(baseContext)
+	go scheduler.startWatchers(baseContext)
+	go scheduler.startDownloadRetries(baseContext)
+	go scheduler.webhooks.Run(baseContext)
 	go scheduler.consumeJobQueue(baseContext)
 	scheduler.logger.Info("scheduler started")
 	<-baseContext.Done()
@@ -75,13 +91,20 @@ func (scheduler *scheduler) start(baseContext context.Context) {
 	case <-wait:
 		scheduler.logger.Info("scheduler shutdown complete")
 	}
+	for name, backend := range scheduler.backends {
+		if closer, ok := backend.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				scheduler.logger.Error("failed to close source backend", "source_name", name, "error", err)
+			}
+		}
+	}
 }
 
 func (scheduler *scheduler) startPolling(ctx context.Context) {
 	if jobs, _ := scheduler.getJobs(ctx); len(jobs) > 0 {
 		scheduler.enqueueJobs(jobs)
 	}
-	ticker := time.NewTicker(scheduler.config.Scheduler.PollInterval)
+	ticker := time.NewTicker(pollInterval(scheduler.config.Scheduler))
 	defer ticker.Stop()
 	reload := scheduler.reloadSignal.Listener(1)
 	defer reload.Close()
@@ -92,8 +115,8 @@ func (scheduler *scheduler) startPolling(ctx context.Context) {
 			scheduler.logger.DebugContext(ctx, "scheduler poller stopped")
 			return
 		case <-reload.Ch():
-			scheduler.logger.InfoContext(ctx, "reloading scheduler poll interval", "new_interval", scheduler.config.Scheduler.PollInterval)
-			ticker.Reset(scheduler.config.Scheduler.PollInterval)
+			scheduler.logger.InfoContext(ctx, "reloading scheduler poll interval", "new_interval", pollInterval(scheduler.config.Scheduler))
+			ticker.Reset(pollInterval(scheduler.config.Scheduler))
 		case <-ticker.C:
 			jobs, err := scheduler.getJobs(ctx)
 			if err != nil {
@@ -110,7 +133,9 @@ func (scheduler *scheduler) getJobs(ctx context.Context) ([]model.Jobs, error) {
 	defer span.End()
 
 	var jobs []model.Jobs
-	cond := Jobs.FinishedAt.IS_NULL()
+	// Jobs waiting to be retried are picked up once their backoff expired.
+	cond := Jobs.FinishedAt.IS_NULL().
+		AND(Jobs.NextAttemptAt.IS_NULL().OR(Jobs.NextAttemptAt.LT_EQ(Int64(time.Now().UnixMilli()))))
 	if runningIds := scheduler.tracker.List(); len(runningIds) > 0 {
 		expr := make([]Expression, len(runningIds))
 		for i, id := range runningIds {
@@ -162,13 +187,16 @@ func (scheduler *scheduler) consumeJobQueue(ctx context.Context) {
 				return
 			}
 			scheduler.wg.Add(1)
+			jobCtx, cancel := context.WithCancelCause(ctx)
+			scheduler.tracker.SetCancel(*job.ID, cancel)
 			go func(job model.Jobs) {
 				defer func() {
+					cancel(nil)
 					sem.Release(currentWeight)
 					scheduler.wg.Done()
 					scheduler.tracker.Remove(*job.ID)
 				}()
-				scheduler.executeJob(ctx, *job.ID)
+				scheduler.executeJob(jobCtx, *job.ID)
 			}(job)
 		}
 	}
@@ -176,19 +204,36 @@ func (scheduler *scheduler) consumeJobQueue(ctx context.Context) {
 
 func (scheduler *scheduler) executeJob(ctx context.Context, job int64) {
 	var (
-		src model.Sources
-		err error
+		jobRow model.Jobs
+		src    model.Sources
+		err    error
 	)
 	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = SELECT(Jobs.AllColumns).
+		FROM(Jobs).
+		WHERE(Jobs.ID.EQ(Int64(job))).
+		QueryContext(ctx, scheduler.claw.db, &jobRow)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to get job", "job_id", job, "error", err)
+		return
+	}
+	if jobRow.FinishedAt != nil {
+		// Job was cancelled or finished while waiting in queue.
+		scheduler.logger.InfoContext(ctx, "job already finished, skipping", "job_id", job, "status", jobRow.Status)
+		return
+	}
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
 	err = SELECT(Sources.AllColumns).
-		WHERE(Sources.ID.EQ(Int64(job))).
+		FROM(Sources).
+		WHERE(Sources.ID.EQ(Int64(jobRow.SourceID))).
 		QueryContext(ctx, scheduler.claw.db, &src)
 	if err != nil {
 		scheduler.logger.ErrorContext(ctx, "failed to get source for job", "job_id", job, "error", err)
 		return
 	}
 	ctx, span := otel.Start(ctx, otel.WithSpanStartOptions(trace.WithAttributes(
-		attribute.Int64("job.source.id", job),
+		attribute.Int64("job.id", job),
+		attribute.Int64("job.source.id", Deref(src.ID)),
 		attribute.String("job.source.name", src.Name),
 		attribute.String("job.source.display_name", src.DisplayName),
 		attribute.String("job.source.parameter", src.Parameter),
@@ -206,21 +251,29 @@ func (scheduler *scheduler) executeJob(ctx context.Context, job int64) {
 		})
 		return
 	}
+	runAt := types.UnixMilliNow()
 	scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_RUNNING, updateJobStatusAttributes{
-		runAt: Ptr(types.UnixMilliNow()),
+		runAt: Ptr(runAt),
 	})
+	scheduler.updateSourceLastRun(ctx, *src.ID, runAt)
 	scheduler.logger.InfoContext(ctx, "starting job", "job_id", job, "source_id", src.ID, "source_name", src.Name)
+	if jobRow.Backfill != 0 {
+		scheduler.executeBackfillJob(ctx, jobRow, src, backend)
+		return
+	}
 
 	resp, err := backend.Run(ctx, source.Request{
-		Parameter: src.Parameter,
-		Countback: int(src.Countback),
+		Parameter:         src.Parameter,
+		Countback:         int(src.Countback),
+		Cursor:            src.Cursor,
+		FilenameMaxLength: scheduler.config.Download.FilenameMaxLength,
 	})
+	if isJobCancelled(ctx) {
+		scheduler.logger.InfoContext(ctx, "job cancelled", "job_id", job)
+		return
+	}
 	if err != nil {
-		scheduler.logger.ErrorContext(ctx, "job failed", "job_id", job, "error", err)
-		scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
-			err:        err,
-			finishedAt: Ptr(types.UnixMilliNow()),
-		})
+		scheduler.failJob(ctx, jobRow, err)
 		return
 	}
 	if len(resp.Images) == 0 {
@@ -228,59 +281,151 @@ func (scheduler *scheduler) executeJob(ctx context.Context, job int64) {
 		scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_COMPLETED, updateJobStatusAttributes{
 			finishedAt: Ptr(types.UnixMilliNow()),
 		})
+		scheduler.updateSourceCursor(ctx, src, resp.Cursor)
 		return
 	}
+	completed, failed, err := scheduler.downloadImages(ctx, job, src, resp.Images)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to find devices to assign", "job_id", job, "error", err)
+		scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
+			err:        err,
+			finishedAt: Ptr(types.UnixMilliNow()),
+		})
+		return
+	}
+	if isJobCancelled(ctx) {
+		// Status is already set to cancelled by CancelJob.
+		scheduler.logger.InfoContext(ctx, "job cancelled", "job_id", job)
+		return
+	}
+	if ctx.Err() != nil {
+		// Shutting down. The job is left unfinished so it will be picked up again on next start.
+		return
+	}
+	// Failed images are retried from the download failures, so the cursor moves past them too.
+	scheduler.recordDownloadFailures(ctx, job, src, failed)
+	scheduler.logger.InfoContext(ctx, "job completed", "job_id", job, "images_processed", len(resp.Images), "images_failed", len(failed))
+	scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_COMPLETED, updateJobStatusAttributes{
+		finishedAt: Ptr(types.UnixMilliNow()),
+		collectedImages: slices.DeleteFunc(completed, func(queue imageQueue) bool {
+			return queue.image.DownloadURL == "" // filter out invalid data
+		}),
+		failedImages: failed,
+	})
+	scheduler.updateSourceCursor(ctx, src, resp.Cursor)
+}
+
+// downloadImages downloads the images to the devices they are assigned to, and returns the images that were
+// downloaded, and the images that failed to download. Failed images are logged and reported to webhooks.
+// Images interrupted by ctx are in neither.
+//
+// An error is returned only if devices could not be looked up.
+func (scheduler *scheduler) downloadImages(ctx context.Context, job int64, src model.Sources, images source.Images) (completed, failed []imageQueue, err error) {
 	wg := sync.WaitGroup{}
-	completed := make([]imageQueue, len(resp.Images))
-	for i, image := range resp.Images {
-		devices, err := scheduler.findDevicesToAssign(ctx, image)
+	mu := sync.Mutex{}
+	completed = make([]imageQueue, len(images))
+	for i, image := range images {
+		if ctx.Err() != nil {
+			break
+		}
+		devices, err := scheduler.findDevicesToAssign(ctx, *src.ID, image)
 		if err != nil {
-			scheduler.logger.ErrorContext(ctx, "failed to find devices to assign", "job_id", job, "error", err)
-			scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
-				err:        err,
-				finishedAt: Ptr(types.UnixMilliNow()),
-			})
-			return
+			if ctx.Err() != nil {
+				break
+			}
+			wg.Wait()
+			return nil, nil, err
 		}
 		if len(devices) == 0 {
 			scheduler.logger.InfoContext(ctx, "no devices found to assign image", "job_id", job, "image", image)
 			continue
 		}
-		wg.Add(1)
 		weight := leastCommonMultiple / min(int64(scheduler.config.Scheduler.DownloadWorkers), 16)
 		if err := scheduler.imageSemaphore.Acquire(ctx, weight); err != nil {
 			// context canceled
-			return
+			break
 		}
+		wg.Add(1)
 		go func(image source.Image, devices []model.Devices) {
 			defer wg.Done()
 			defer scheduler.imageSemaphore.Release(weight)
-			if err := scheduler.processDownload(ctx, image, devices, src.Name); err != nil {
+			if err := scheduler.processDownload(ctx, job, image, devices, src); err != nil {
 				scheduler.logger.ErrorContext(ctx, "failed to process image", "job_id", job, "image", image, "error", err)
+				if ctx.Err() == nil {
+					mu.Lock()
+					failed = append(failed, imageQueue{image: image, devices: devices, err: err})
+					mu.Unlock()
+					scheduler.webhooks.Dispatch(ctx, WebhookPayload{
+						Event:  WebhookEventImageFailed,
+						Job:    &WebhookJob{ID: job},
+						Source: webhookSourceFromModel(src),
+						Image:  webhookImageFromSource(image),
+						Error:  err.Error(),
+					})
+				}
 				return
 			}
 			completed[i] = imageQueue{image: image, devices: devices}
 		}(image, devices)
 	}
 	wg.Wait()
-	scheduler.logger.InfoContext(ctx, "job completed", "job_id", job, "images_processed", len(resp.Images))
-	scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_COMPLETED, updateJobStatusAttributes{
-		finishedAt: Ptr(types.UnixMilliNow()),
-		collectedImages: slices.DeleteFunc(completed, func(queue imageQueue) bool {
-			return queue.image.DownloadURL == "" // filter out invalid data
-		}),
-	})
+	return completed, failed, nil
+}
+
+// updateSourceCursor stores the cursor returned by the source, to be passed to its next run.
+//
+// Empty cursors keep the stored cursor. The cursor is not stored if the parameter of the source was
+// changed while the job was running, since it belongs to the old parameter.
+func (scheduler *scheduler) updateSourceCursor(ctx context.Context, src model.Sources, cursor string) {
+	if cursor == "" || cursor == src.Cursor {
+		return
+	}
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := Sources.
+		UPDATE(Sources.Cursor).
+		SET(String(cursor)).
+		WHERE(
+			Sources.ID.EQ(Int64(*src.ID)).
+				AND(Sources.Name.EQ(String(src.Name))).
+				AND(Sources.Parameter.EQ(String(src.Parameter))),
+		).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to update source cursor", "source_id", *src.ID, "error", err)
+	}
 }
 
-func (scheduler *scheduler) findDevicesToAssign(ctx context.Context, image source.Image) ([]model.Devices, error) {
+// updateSourceLastRun records when a job of the source started running.
+func (scheduler *scheduler) updateSourceLastRun(ctx context.Context, sourceID int64, runAt types.UnixMilli) {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := Sources.
+		UPDATE(Sources.LastRunAt).
+		SET(runAt).
+		WHERE(Sources.ID.EQ(Int64(sourceID))).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to update source last run", "source_id", sourceID, "error", err)
+	}
+}
+
+// pollInterval returns config.Scheduler.PollInterval, or the default if it is not positive.
+func pollInterval(cfg config.Scheduler) time.Duration {
+	if cfg.PollInterval <= 0 {
+		return config.DefaultScheduler().PollInterval
+	}
+	return cfg.PollInterval
+}
+
+func (scheduler *scheduler) findDevicesToAssign(ctx context.Context, sourceID int64, image source.Image) ([]model.Devices, error) {
 	ctx, span := otel.Start(ctx)
 	defer span.End()
 	imageRatio := float64(image.Width) / float64(image.Height)
 	cond := Devices.IsDisabled.EQ(Int(0)).
+		AND(DeviceSources.SourceID.EQ(Int64(sourceID))).
 		AND(
 			Float(imageRatio).BETWEEN(
-				CAST(Devices.Width).AS_REAL().DIV(CAST(Devices.Width).AS_REAL()).SUB(Devices.AspectRatioDifference),
-				CAST(Devices.Width).AS_REAL().DIV(CAST(Devices.Width).AS_REAL()).ADD(Devices.AspectRatioDifference),
+				CAST(Devices.Width).AS_REAL().DIV(CAST(Devices.Height).AS_REAL()).SUB(Devices.AspectRatioDifference),
+				CAST(Devices.Width).AS_REAL().DIV(CAST(Devices.Height).AS_REAL()).ADD(Devices.AspectRatioDifference),
 			),
 		).
 		AND(
@@ -290,10 +435,10 @@ func (scheduler *scheduler) findDevicesToAssign(ctx context.Context, image sourc
 			Devices.ImageMaxWidth.LT_EQ(Int(0)).OR(Devices.ImageMaxWidth.GT_EQ(Int(image.Width))),
 		).
 		AND(
-			Devices.ImageMinHeight.LT_EQ(Int(0)).OR(Devices.ImageMinWidth.LT_EQ(Int(image.Height))),
+			Devices.ImageMinHeight.LT_EQ(Int(0)).OR(Devices.ImageMinHeight.LT_EQ(Int(image.Height))),
 		).
 		AND(
-			Devices.ImageMaxHeight.LT_EQ(Int(0)).OR(Devices.ImageMaxWidth.GT_EQ(Int(image.Height))),
+			Devices.ImageMaxHeight.LT_EQ(Int(0)).OR(Devices.ImageMaxHeight.GT_EQ(Int(image.Height))),
 		).
 		AND(
 			Devices.ImageMinFileSize.LT_EQ(Int(0)).OR(Devices.ImageMinFileSize.LT_EQ(Int(image.Filesize))),
@@ -309,7 +454,7 @@ func (scheduler *scheduler) findDevicesToAssign(ctx context.Context, image sourc
 	var devices []model.Devices
 	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
 	err := SELECT(Devices.AllColumns).
-		FROM(Devices).
+		FROM(Devices.INNER_JOIN(DeviceSources, DeviceSources.DeviceID.EQ(Devices.ID))).
 		WHERE(cond).
 		QueryContext(ctx, scheduler.claw.db, &devices)
 	if err != nil {
@@ -324,13 +469,14 @@ type updateJobStatusAttributes struct {
 	finishedAt      *types.UnixMilli
 	collectedImages []imageQueue
 	failedImages    []imageQueue
+	// condition, if set, must hold for the job to be updated, e.g. to only update jobs of an expected status.
+	condition BoolExpression
 }
 
 func (scheduler *scheduler) updateJobStatus(ctx context.Context, job int64, status clawv1.JobStatus, attr updateJobStatusAttributes) {
-	// ContextCancelled error should only happens when the job is cancelled not by user.
-	//
-	// Graceful exits must not update the job status to failed.
-	if errors.Is(attr.err, context.Canceled) || errors.Is(attr.err, context.DeadlineExceeded) {
+	// Graceful exits must not update the job status to failed. The error alone does not tell, since timeouts
+	// of the source wrap context.DeadlineExceeded too, so the job context is checked instead.
+	if attr.err != nil && ctx.Err() != nil {
 		return
 	}
 	ctx, span := otel.Start(ctx)
@@ -343,9 +489,20 @@ func (scheduler *scheduler) updateJobStatus(ctx context.Context, job int64, stat
 		value.Error = Ptr(attr.err.Error())
 		col = append(col, Jobs.Status, Jobs.Error)
 	} else {
+		if status == clawv1.JobStatus_JOB_STATUS_COMPLETED && len(attr.failedImages) > 0 {
+			status = clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS
+		}
 		value.Status = status.String()
 		col = append(col, Jobs.Status)
 	}
+	if status == clawv1.JobStatus_JOB_STATUS_RUNNING {
+		// The job was waiting to be retried. The error of the failed attempt is kept until the job finishes.
+		col = append(col, Jobs.NextAttemptAt)
+	}
+	if status == clawv1.JobStatus_JOB_STATUS_COMPLETED || status == clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS {
+		// Clear the error of earlier failed attempts.
+		col = append(col, Jobs.Error)
+	}
 	if attr.finishedAt != nil {
 		value.FinishedAt = attr.finishedAt
 		col = append(col, Jobs.FinishedAt)
@@ -355,12 +512,29 @@ func (scheduler *scheduler) updateJobStatus(ctx context.Context, job int64, stat
 		col = append(col, Jobs.RunAt)
 	}
 	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
-	_, err := Jobs.
+	// Cancelled jobs are final and must not be overwritten by the still-winding-down worker.
+	cond := Jobs.ID.EQ(Int64(job)).
+		AND(Jobs.Status.NOT_EQ(String(clawv1.JobStatus_JOB_STATUS_CANCELLED.String())))
+	if attr.condition != nil {
+		cond = cond.AND(attr.condition)
+	}
+	var updated []model.Jobs
+	err := Jobs.
 		UPDATE(col).
 		MODEL(value).
-		WHERE(Jobs.ID.EQ(Int64(job))).
-		ExecContext(ctx, scheduler.claw.db)
+		WHERE(cond).
+		RETURNING(Jobs.AllColumns).
+		QueryContext(ctx, scheduler.claw.db, &updated)
 	if err != nil {
 		scheduler.logger.ErrorContext(ctx, "failed to update job status", "job_id", job, "error", err, "status", status.String())
+		return
+	}
+	if len(updated) > 0 {
+		scheduler.notifyJobStatus(ctx, updated[0])
 	}
 }
+
+// isJobCancelled reports whether the job context was cancelled by the user.
+func isJobCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), errJobCancelled)
+}
@@ -0,0 +1,344 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// defaultFilenameTemplate is used when device does not specify a filename template.
+const defaultFilenameTemplate = "{{ .SourceName }}_{{ .Filename }}"
+
+// deviceAssignment holds everything needed to assign a downloaded image to a device.
+type deviceAssignment struct {
+	job       int64
+	imageID   int64
+	imagePath string // absolute path to the image file in the images directory.
+	image     source.Image
+	device    model.Devices
+	source    model.Sources
+	action    clawv1.JobAction
+}
+
+// filenameTemplateData is the data available to device filename templates.
+type filenameTemplateData struct {
+	// ImageID is the image ID in the database.
+	ImageID int64
+	// Filename is the filename suggested by the source, including extension.
+	Filename string
+	// Name is Filename without extension.
+	Name string
+	// Ext is the extension of Filename, including the leading dot.
+	Ext string
+	// SourceName is the source kind, e.g. "claw.reddit.v1".
+	SourceName string
+	// SourceDisplayName is the user given name of the source.
+	SourceDisplayName string
+	// DeviceSlug is the slug of the device the image is assigned to.
+	DeviceSlug string
+	// Width is the image width in pixels.
+	Width int64
+	// Height is the image height in pixels.
+	Height int64
+	// Author is the author or uploader of the image.
+	Author string
+	// NSFW is whether the image is marked as not safe for work.
+	NSFW bool
+	// PostedAt is when the image was posted. Zero if unknown.
+	PostedAt time.Time
+}
+
+// processDeviceAssignment places the image into the device directory, records the assignment in image_devices,
+// and records the job action in job_images.
+//
+// Target path is rendered from device's filename template under <base_dir>/devices/<slug>/.
+// If the path is already taken by another image, the image ID is appended to the filename, which makes
+// the result deterministic for the same image.
+func (scheduler *scheduler) processDeviceAssignment(ctx context.Context, assignment deviceAssignment) (err error) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	deviceID := *assignment.device.ID
+	// The same image may be assigned to the same device by two jobs at once.
+	defer scheduler.assignLocks.Lock(fmt.Sprintf("assignment:%d:%d", assignment.imageID, deviceID))()
+
+	var existing []model.ImageDevices
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices).
+		WHERE(ImageDevices.ImageID.EQ(Int64(assignment.imageID)).AND(ImageDevices.DeviceID.EQ(Int64(deviceID)))).
+		QueryContext(ctx, scheduler.claw.db, &existing)
+	if err != nil {
+		return fmt.Errorf("failed to query existing device assignment: %w", err)
+	}
+	if len(existing) > 0 {
+		scheduler.logger.DebugContext(ctx, "image already assigned to device", "image_id", assignment.imageID, "device_id", deviceID, "path", existing[0].Path)
+		return nil
+	}
+
+	rendered, err := renderDeviceFilename(assignment, scheduler.config.Download.FilenameMaxLength)
+	if err != nil {
+		return err
+	}
+	deviceDir := path.Join("devices", assignment.device.Slug)
+	relativePath, created, unlockPath, err := scheduler.linkDeviceImage(ctx, assignment, deviceDir, rendered)
+	if err != nil {
+		return err
+	}
+	defer unlockPath()
+	absolutePath := filepath.Join(scheduler.config.Download.BaseDir, filepath.FromSlash(relativePath))
+	defer func() {
+		if err != nil && created {
+			_ = os.Remove(absolutePath)
+		}
+	}()
+
+	tx, err := scheduler.claw.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	now := types.UnixMilliNow()
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err = ImageDevices.
+		INSERT(ImageDevices.ImageID, ImageDevices.DeviceID, ImageDevices.Path, ImageDevices.CreatedAt).
+		MODEL(model.ImageDevices{
+			ImageID:   assignment.imageID,
+			DeviceID:  deviceID,
+			Path:      relativePath,
+			CreatedAt: now,
+		}).
+		ExecContext(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to insert image device: %w", err)
+	}
+
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err = JobImages.
+		INSERT(JobImages.JobID, JobImages.ImageID, JobImages.DeviceID, JobImages.Action, JobImages.CreatedAt).
+		MODEL(model.JobImages{
+			JobID:     assignment.job,
+			ImageID:   assignment.imageID,
+			DeviceID:  deviceID,
+			Action:    assignment.action.String(),
+			CreatedAt: now,
+		}).
+		ON_CONFLICT(JobImages.JobID, JobImages.ImageID, JobImages.DeviceID).
+		DO_NOTHING().
+		ExecContext(ctx, tx)
+	if err != nil {
+		return fmt.Errorf("failed to insert job image: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	scheduler.logger.InfoContext(ctx, "image assigned to device",
+		"job_id", assignment.job, "image_id", assignment.imageID, "device_id", deviceID, "device_slug", assignment.device.Slug, "path", relativePath)
+
+	payloadImage := webhookImageFromSource(assignment.image)
+	payloadImage.ID = assignment.imageID
+	payloadImage.Path = strings.TrimPrefix(assignment.imagePath, scheduler.config.Download.BaseDir+"/")
+	scheduler.webhooks.Dispatch(ctx, WebhookPayload{
+		Event:  WebhookEventImageAssigned,
+		Job:    &WebhookJob{ID: assignment.job},
+		Source: webhookSourceFromModel(assignment.source),
+		Image:  payloadImage,
+		Device: &WebhookDevice{
+			ID:   deviceID,
+			Slug: assignment.device.Slug,
+			Name: assignment.device.Name,
+			Path: relativePath,
+		},
+	})
+	return nil
+}
+
+// linkDeviceImage resolves a free path for the image in the device directory and hardlinks (or copies) the image there.
+//
+// Returned path is relative to the base directory using forward slashes. created reports whether a new file
+// was created, so the caller can clean up if recording the assignment fails.
+//
+// The returned path stays locked until unlock is called, so other workers rendering the same filename do not
+// take the path before the assignment is recorded.
+func (scheduler *scheduler) linkDeviceImage(ctx context.Context, assignment deviceAssignment, deviceDir, rendered string) (relativePath string, created bool, unlock func(), err error) {
+	candidates := []string{
+		path.Join(deviceDir, rendered),
+		path.Join(deviceDir, withFilenameSuffix(rendered, "_"+strconv.FormatInt(assignment.imageID, 10))),
+	}
+	for _, candidate := range candidates {
+		unlock := scheduler.assignLocks.Lock("path:" + candidate)
+		created, ok, err := scheduler.tryDevicePath(ctx, assignment, candidate)
+		if err != nil {
+			unlock()
+			return "", false, nil, err
+		}
+		if ok {
+			return candidate, created, unlock, nil
+		}
+		unlock()
+	}
+	return "", false, nil, fmt.Errorf("no free path for image %d in device %q: %q is already taken", assignment.imageID, assignment.device.Slug, candidates[0])
+}
+
+// tryDevicePath places the image at candidate, a path relative to the base directory, if the path is free.
+// ok is false if the path is taken by another image.
+func (scheduler *scheduler) tryDevicePath(ctx context.Context, assignment deviceAssignment, candidate string) (created, ok bool, err error) {
+	var owners []model.ImageDevices
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = SELECT(ImageDevices.AllColumns).
+		FROM(ImageDevices).
+		WHERE(ImageDevices.Path.EQ(String(candidate))).
+		QueryContext(ctx, scheduler.claw.db, &owners)
+	if err != nil && !errors.Is(err, qrm.ErrNoRows) {
+		return false, false, fmt.Errorf("failed to query image device path: %w", err)
+	}
+	if len(owners) > 0 {
+		return false, false, nil
+	}
+
+	target := filepath.Join(scheduler.config.Download.BaseDir, filepath.FromSlash(candidate))
+	targetInfo, err := os.Stat(target)
+	if err == nil {
+		sourceInfo, err := os.Stat(assignment.imagePath)
+		if err == nil && os.SameFile(sourceInfo, targetInfo) {
+			// Leftover hardlink of this very image, e.g. from an interrupted run.
+			return false, true, nil
+		}
+		return false, false, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return false, false, fmt.Errorf("failed to stat device image path: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return false, false, fmt.Errorf("failed to create device directory: %w", err)
+	}
+	if err := scheduler.moveToFinalLocation(ctx, assignment.imagePath, target); err != nil {
+		return false, false, fmt.Errorf("failed to place image in device directory: %w", err)
+	}
+	return true, true, nil
+}
+
+// keyedMutex locks by key, so work on different keys runs in parallel. The zero value is ready to use.
+type keyedMutex struct {
+	mu    sync.Mutex
+	locks map[string]*keyedMutexEntry
+}
+
+type keyedMutexEntry struct {
+	mu   sync.Mutex
+	refs int
+}
+
+// Lock locks key and returns the function that unlocks it.
+func (km *keyedMutex) Lock(key string) (unlock func()) {
+	km.mu.Lock()
+	if km.locks == nil {
+		km.locks = make(map[string]*keyedMutexEntry)
+	}
+	entry, ok := km.locks[key]
+	if !ok {
+		entry = &keyedMutexEntry{}
+		km.locks[key] = entry
+	}
+	entry.refs++
+	km.mu.Unlock()
+
+	entry.mu.Lock()
+	return func() {
+		entry.mu.Unlock()
+		km.mu.Lock()
+		defer km.mu.Unlock()
+		entry.refs--
+		if entry.refs == 0 {
+			delete(km.locks, key)
+		}
+	}
+}
+
+// renderDeviceFilename renders the device filename template into a clean relative path.
+func renderDeviceFilename(assignment deviceAssignment, maxLength int) (string, error) {
+	text := strings.TrimSpace(assignment.device.FilenameTemplate)
+	if text == "" {
+		text = defaultFilenameTemplate
+	}
+	tmpl, err := template.New("filename").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse filename template of device %q: %w", assignment.device.Slug, err)
+	}
+
+	ext := filepath.Ext(assignment.image.Filename)
+	data := filenameTemplateData{
+		ImageID:           assignment.imageID,
+		Filename:          assignment.image.Filename,
+		Name:              strings.TrimSuffix(assignment.image.Filename, ext),
+		Ext:               ext,
+		SourceName:        assignment.source.Name,
+		SourceDisplayName: assignment.source.DisplayName,
+		DeviceSlug:        assignment.device.Slug,
+		Width:             assignment.image.Width,
+		Height:            assignment.image.Height,
+		Author:            assignment.image.Author,
+		NSFW:              assignment.image.NSFW,
+		PostedAt:          assignment.image.PostedAt,
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render filename template of device %q: %w", assignment.device.Slug, err)
+	}
+
+	rendered := path.Clean(strings.ReplaceAll(strings.TrimSpace(sb.String()), `\`, "/"))
+	if rendered == "." || rendered == "" || strings.HasSuffix(sb.String(), "/") {
+		return "", fmt.Errorf("filename template of device %q rendered an empty filename", assignment.device.Slug)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(rendered)) {
+		return "", fmt.Errorf("filename template of device %q rendered %q which escapes the device directory", assignment.device.Slug, rendered)
+	}
+	dir, base := path.Split(rendered)
+	return dir + truncateFilename(base, maxLength), nil
+}
+
+// truncateFilename shortens the filename to maxLength bytes while keeping the extension.
+//
+// If maxLength is 0 or negative, the filename is returned as is.
+func truncateFilename(filename string, maxLength int) string {
+	if maxLength <= 0 || len(filename) <= maxLength {
+		return filename
+	}
+	ext := path.Ext(filename)
+	if len(ext) >= maxLength {
+		return filename[:maxLength]
+	}
+	name := strings.TrimSuffix(filename, ext)
+	cut := maxLength - len(ext)
+	// Avoid cutting in the middle of a multibyte character.
+	for cut > 0 && cut < len(name) && name[cut]&0xC0 == 0x80 {
+		cut--
+	}
+	return name[:cut] + ext
+}
+
+// withFilenameSuffix inserts suffix between the filename and its extension.
+func withFilenameSuffix(filename, suffix string) string {
+	ext := path.Ext(filename)
+	return strings.TrimSuffix(filename, ext) + suffix + ext
+}
@@ -0,0 +1,183 @@
+package claw
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+func TestRenderDeviceFilename(t *testing.T) {
+	assignment := deviceAssignment{
+		imageID: 42,
+		image: source.Image{
+			Filename: "lake.jpg",
+			Width:    1920,
+			Height:   1080,
+			Author:   "alice",
+			PostedAt: time.Date(2025, 3, 4, 0, 0, 0, 0, time.UTC),
+		},
+		device: model.Devices{Slug: "phone"},
+		source: model.Sources{Name: "claw.reddit.v1", DisplayName: "Wallpapers"},
+	}
+	tests := []struct {
+		name      string
+		template  string
+		maxLength int
+		want      string
+		wantErr   bool
+	}{
+		{name: "default template", want: "claw.reddit.v1_lake.jpg"},
+		{name: "fields", template: "{{ .DeviceSlug }}_{{ .ImageID }}_{{ .Width }}x{{ .Height }}{{ .Ext }}", want: "phone_42_1920x1080.jpg"},
+		{name: "subdirectory", template: `{{ .PostedAt.Format "2006/01" }}/{{ .Name }}{{ .Ext }}`, want: "2025/03/lake.jpg"},
+		{name: "backslashes are separators", template: `{{ .Author }}\{{ .Filename }}`, want: "alice/lake.jpg"},
+		{name: "cleaned", template: "./a//{{ .Filename }}", want: "a/lake.jpg"},
+		{name: "truncated keeps directory and extension", template: "dir/{{ .SourceDisplayName }}_{{ .Filename }}", maxLength: 10, want: "dir/Wallpa.jpg"},
+		{name: "parent directory", template: "../{{ .Filename }}", wantErr: true},
+		{name: "absolute", template: "/etc/{{ .Filename }}", wantErr: true},
+		{name: "empty", template: `{{ if .NSFW }}{{ .Filename }}{{ end }}`, wantErr: true},
+		{name: "directory only", template: "{{ .Author }}/", wantErr: true},
+		{name: "unknown field", template: "{{ .Unknown }}", wantErr: true},
+		{name: "invalid template", template: "{{ .Filename", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assignment := assignment
+			assignment.device.FilenameTemplate = tt.template
+			got, err := renderDeviceFilename(assignment, tt.maxLength)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTruncateFilename(t *testing.T) {
+	tests := []struct {
+		name      string
+		filename  string
+		maxLength int
+		want      string
+	}{
+		{"no limit", "landscape.jpg", 0, "landscape.jpg"},
+		{"short enough", "landscape.jpg", 13, "landscape.jpg"},
+		{"keeps extension", "landscape.jpg", 8, "land.jpg"},
+		{"no extension", "landscape", 4, "land"},
+		{"extension longer than limit", "a.verylongextension", 5, "a.ver"},
+		{"multibyte character is not split", "日本語.jpg", 8, "日.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, truncateFilename(tt.filename, tt.maxLength))
+		})
+	}
+}
+
+func TestWithFilenameSuffix(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{"lake.jpg", "lake_7.jpg"},
+		{"archive.tar.gz", "archive.tar_7.gz"},
+		{"lake", "lake_7"},
+		{"dir/lake.jpg", "dir/lake_7.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			assert.Equal(t, tt.want, withFilenameSuffix(tt.filename, "_7"))
+		})
+	}
+}
+
+func TestProcessDeviceAssignment_Collision(t *testing.T) {
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	ctx := context.Background()
+	device := insertTestDevice(t, cl, "phone")
+	device.FilenameTemplate = "{{ .Filename }}"
+	first := insertTestImage(t, cl, "https://example.com/a/lake.jpg")
+	second := insertTestImage(t, cl, "https://example.com/b/lake.jpg")
+	job := insertTestJob(t, cl, first.SourceID, clawv1.JobStatus_JOB_STATUS_RUNNING)
+	assign := func(image model.Images) {
+		t.Helper()
+		imagePath := filepath.Join(t.TempDir(), "lake.jpg")
+		require.NoError(t, os.WriteFile(imagePath, []byte("image "+strconv.FormatInt(*image.ID, 10)), 0o644))
+		err := cl.scheduler.processDeviceAssignment(ctx, deviceAssignment{
+			job:       *job.ID,
+			imageID:   *image.ID,
+			imagePath: imagePath,
+			image:     source.Image{Filename: "lake.jpg"},
+			device:    device,
+			action:    clawv1.JobAction_JOB_ACTION_DOWNLOAD,
+		})
+		require.NoError(t, err)
+	}
+	devicePath := func(image model.Images) string {
+		t.Helper()
+		var imageDevice model.ImageDevices
+		err := SELECT(ImageDevices.AllColumns).
+			FROM(ImageDevices).
+			WHERE(ImageDevices.ImageID.EQ(Int64(*image.ID)).AND(ImageDevices.DeviceID.EQ(Int64(*device.ID)))).
+			QueryContext(ctx, cl.db, &imageDevice)
+		require.NoError(t, err)
+		return imageDevice.Path
+	}
+
+	assign(first)
+	assign(second)
+	// Assigning again is a no-op, and does not move the image to another path.
+	assign(first)
+
+	assert.Equal(t, "devices/phone/lake.jpg", devicePath(first))
+	secondPath := "devices/phone/lake_" + strconv.FormatInt(*second.ID, 10) + ".jpg"
+	assert.Equal(t, secondPath, devicePath(second))
+	content, err := os.ReadFile(cl.scheduler.absoluteImagePath(secondPath))
+	require.NoError(t, err)
+	assert.Equal(t, "image "+strconv.FormatInt(*second.ID, 10), string(content))
+}
+
+func TestKeyedMutex(t *testing.T) {
+	var km keyedMutex
+
+	unlock := km.Lock("a")
+	// Other keys are not blocked.
+	km.Lock("b")()
+
+	locked := make(chan struct{})
+	go func() {
+		defer km.Lock("a")()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+		t.Fatal("second lock of the same key did not wait for the first")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-locked:
+	case <-time.After(time.Second):
+		t.Fatal("second lock of the same key was not acquired after unlock")
+	}
+
+	// Entries are removed once no one holds or waits for them.
+	assert.Eventually(t, func() bool {
+		km.mu.Lock()
+		defer km.mu.Unlock()
+		return len(km.locks) == 0
+	}, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,153 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// executeBackfillJob runs a backfill job, fetching one page at a time until the source has no more pages.
+//
+// The token of the next page and the progress are stored after every page, so when the job is picked up again
+// after a restart, or retried after a failure, it continues from the next page instead of starting over.
+// A page that was interrupted halfway is fetched again.
+func (scheduler *scheduler) executeBackfillJob(ctx context.Context, jobRow model.Jobs, src model.Sources, backend source.Source) {
+	job := *jobRow.ID
+	backfiller, ok := backend.(source.SourceBackfiller)
+	if !ok {
+		err := fmt.Errorf("source %s does not support backfilling", src.Name)
+		scheduler.logger.ErrorContext(ctx, "job failed", "job_id", job, "error", err)
+		scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
+			err:        err,
+			finishedAt: Ptr(types.UnixMilliNow()),
+		})
+		return
+	}
+
+	token := jobRow.BackfillPageToken
+	pages, images := jobRow.BackfillPages, jobRow.BackfillImages
+	// A retry of a backfill that already fetched its last page has nothing left to do.
+	for pages == 0 || token != "" {
+		if pages > jobRow.BackfillPages && !scheduler.waitBackfillPageDelay(ctx) {
+			// Cancelled by the user, or shutting down. The job continues from the stored token on next start.
+			return
+		}
+		resp, err := backfiller.RunPage(ctx, source.PageRequest{
+			Parameter:         src.Parameter,
+			PageToken:         token,
+			FilenameMaxLength: scheduler.config.Download.FilenameMaxLength,
+		})
+		if isJobCancelled(ctx) {
+			scheduler.logger.InfoContext(ctx, "job cancelled", "job_id", job)
+			return
+		}
+		if err != nil {
+			// Retries continue from this page, since the progress is stored after every page.
+			scheduler.failJob(ctx, jobRow, fmt.Errorf("failed to fetch page %d: %w", pages+1, err))
+			return
+		}
+		_, failed, err := scheduler.downloadImages(ctx, job, src, resp.Images)
+		if err != nil {
+			err = fmt.Errorf("failed to download images of page %d: %w", pages+1, err)
+			scheduler.logger.ErrorContext(ctx, "backfill page failed", "job_id", job, "error", err)
+			scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
+				err:        err,
+				finishedAt: Ptr(types.UnixMilliNow()),
+			})
+			return
+		}
+		if isJobCancelled(ctx) {
+			scheduler.logger.InfoContext(ctx, "job cancelled", "job_id", job)
+			return
+		}
+		if ctx.Err() != nil {
+			// Shutting down. The page is fetched again on next start.
+			return
+		}
+		scheduler.recordDownloadFailures(ctx, job, src, failed)
+
+		pages++
+		images += int64(len(resp.Images))
+		token = resp.NextPageToken
+		// Only consecutive failures count against the maximum number of attempts.
+		jobRow.Attempt = 1
+		if err := scheduler.updateBackfillProgress(ctx, job, token, pages, images); err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to update backfill progress", "job_id", job, "error", err)
+			scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
+				err:        err,
+				finishedAt: Ptr(types.UnixMilliNow()),
+			})
+			return
+		}
+		trace.SpanFromContext(ctx).AddEvent("backfill page fetched", trace.WithAttributes(
+			attribute.Int64("backfill.pages", pages),
+			attribute.Int64("backfill.images", images),
+		))
+		scheduler.logger.InfoContext(ctx, "backfill page fetched",
+			"job_id", job,
+			"pages_fetched", pages,
+			"page_images", len(resp.Images),
+			"images_found", images,
+			"has_more", token != "",
+		)
+	}
+
+	// Failures of earlier pages may have been recorded before a restart, so they are looked up instead of counted.
+	status := clawv1.JobStatus_JOB_STATUS_COMPLETED
+	if hasFailures, err := scheduler.hasDownloadFailures(ctx, job); err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to check download failures", "job_id", job, "error", err)
+	} else if hasFailures {
+		status = clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS
+	}
+	scheduler.logger.InfoContext(ctx, "backfill completed", "job_id", job, "pages_fetched", pages, "images_found", images, "status", status.String())
+	scheduler.updateJobStatus(ctx, job, status, updateJobStatusAttributes{
+		finishedAt: Ptr(types.UnixMilliNow()),
+	})
+}
+
+// waitBackfillPageDelay waits config.Scheduler.BackfillPageDelay before the next page is fetched.
+// Returns false if ctx is done before then.
+func (scheduler *scheduler) waitBackfillPageDelay(ctx context.Context) bool {
+	delay := scheduler.config.Scheduler.BackfillPageDelay
+	if delay <= 0 {
+		return ctx.Err() == nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// updateBackfillProgress stores the token of the next page and the progress of a backfill job,
+// and resets its attempts.
+func (scheduler *scheduler) updateBackfillProgress(ctx context.Context, job int64, token string, pages, images int64) error {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := Jobs.
+		UPDATE(Jobs.BackfillPageToken, Jobs.BackfillPages, Jobs.BackfillImages, Jobs.Attempt).
+		MODEL(model.Jobs{
+			BackfillPageToken: token,
+			BackfillPages:     pages,
+			BackfillImages:    images,
+			Attempt:           1,
+		}).
+		WHERE(Jobs.ID.EQ(Int64(job))).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		return fmt.Errorf("failed to update backfill progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package claw
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// fakeBackfiller serves pages keyed by their page token. Only Name and RunPage are implemented.
+type fakeBackfiller struct {
+	source.Source
+	pages map[string]source.PageResponse
+
+	mu sync.Mutex
+	// fail is the page token that fails once with a temporary error.
+	fail     string
+	requests []source.PageRequest
+}
+
+func (f *fakeBackfiller) Name() string { return "test.backfill.v1" }
+
+func (f *fakeBackfiller) RunPage(_ context.Context, request source.PageRequest) (source.PageResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.requests = append(f.requests, request)
+	if f.fail != "" && request.PageToken == f.fail {
+		f.fail = ""
+		return source.PageResponse{}, &source.StatusError{StatusCode: http.StatusServiceUnavailable}
+	}
+	return f.pages[request.PageToken], nil
+}
+
+func (f *fakeBackfiller) pageTokens() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	tokens := make([]string, 0, len(f.requests))
+	for _, request := range f.requests {
+		tokens = append(tokens, request.PageToken)
+	}
+	return tokens
+}
+
+// newTestBackfill creates a backfill job of a source backed by a fakeBackfiller with three pages.
+func newTestBackfill(t *testing.T) (*Claw, *fakeBackfiller, model.Sources, model.Jobs) {
+	t.Helper()
+	cl := newTestClaw(t)
+	cl.config.Scheduler.BackfillPageDelay = 0
+	backend := &fakeBackfiller{pages: map[string]source.PageResponse{
+		"": {
+			Images:        source.Images{{DownloadURL: "https://example.com/1.jpg"}, {DownloadURL: "https://example.com/2.jpg"}},
+			NextPageToken: "page-2",
+		},
+		"page-2": {
+			Images:        source.Images{{DownloadURL: "https://example.com/3.jpg"}},
+			NextPageToken: "page-3",
+		},
+		"page-3": {
+			Images: source.Images{{DownloadURL: "https://example.com/4.jpg"}},
+		},
+	}}
+	cl.scheduler.backends[backend.Name()] = backend
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES(backend.Name(), "Backfill", "all").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	resp, err := cl.CreateBackfillJob(ctx, &clawv1.CreateBackfillJobRequest{SourceId: *src.ID})
+	require.NoError(t, err)
+	return cl, backend, src, getTestJob(t, cl, resp.Job.Id)
+}
+
+func getTestJob(t *testing.T, cl *Claw, id int64) model.Jobs {
+	t.Helper()
+	var job model.Jobs
+	err := SELECT(Jobs.AllColumns).
+		FROM(Jobs).
+		WHERE(Jobs.ID.EQ(Int64(id))).
+		QueryContext(context.Background(), cl.db, &job)
+	require.NoError(t, err)
+	return job
+}
+
+func TestExecuteBackfillJob(t *testing.T) {
+	cl, backend, src, job := newTestBackfill(t)
+	cl.config.Download.FilenameMaxLength = 42
+
+	cl.scheduler.executeBackfillJob(context.Background(), job, src, backend)
+
+	assert.Equal(t, []string{"", "page-2", "page-3"}, backend.pageTokens())
+	for _, request := range backend.requests {
+		assert.Equal(t, "all", request.Parameter)
+		assert.Equal(t, 42, request.FilenameMaxLength)
+	}
+	job = getTestJob(t, cl, *job.ID)
+	assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), job.Status)
+	assert.NotNil(t, job.FinishedAt)
+	assert.Equal(t, int64(3), job.BackfillPages)
+	assert.Equal(t, int64(4), job.BackfillImages)
+	assert.Empty(t, job.BackfillPageToken)
+	assert.Equal(t, &clawv1.BackfillProgress{PagesFetched: 3, ImagesFound: 4, HasMore: false}, backfillProgress(job))
+}
+
+func TestExecuteBackfillJob_Resume(t *testing.T) {
+	cl, backend, src, job := newTestBackfill(t)
+	backend.fail = "page-2"
+	ctx := context.Background()
+
+	cl.scheduler.executeBackfillJob(ctx, job, src, backend)
+
+	// The failed page is retried later, and the progress of the pages before it is kept.
+	job = getTestJob(t, cl, *job.ID)
+	assert.Equal(t, clawv1.JobStatus_JOB_STATUS_PENDING.String(), job.Status)
+	assert.Nil(t, job.FinishedAt)
+	assert.Equal(t, int64(2), job.Attempt)
+	assert.Contains(t, Deref(job.Error), "failed to fetch page 2")
+	assert.Equal(t, int64(1), job.BackfillPages)
+	assert.Equal(t, int64(2), job.BackfillImages)
+	assert.Equal(t, "page-2", job.BackfillPageToken)
+	assert.Equal(t, &clawv1.BackfillProgress{PagesFetched: 1, ImagesFound: 2, HasMore: true}, backfillProgress(job))
+
+	cl.scheduler.executeBackfillJob(ctx, job, src, backend)
+
+	assert.Equal(t, []string{"", "page-2", "page-2", "page-3"}, backend.pageTokens())
+	job = getTestJob(t, cl, *job.ID)
+	assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), job.Status)
+	assert.Equal(t, int64(1), job.Attempt, "attempts are reset once a page is fetched")
+	assert.Equal(t, int64(3), job.BackfillPages)
+	assert.Equal(t, int64(4), job.BackfillImages)
+
+	t.Run("retry of a finished backfill fetches nothing", func(t *testing.T) {
+		cl.scheduler.executeBackfillJob(ctx, job, src, backend)
+		assert.Len(t, backend.pageTokens(), 4)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), getTestJob(t, cl, *job.ID).Status)
+	})
+}
+
+func TestExecuteBackfillJob_Shutdown(t *testing.T) {
+	cl, backend, src, job := newTestBackfill(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cl.scheduler.executeBackfillJob(ctx, job, src, backend)
+
+	// A page interrupted by a shutdown is not counted, and is fetched again on next start.
+	assert.Equal(t, []string{""}, backend.pageTokens())
+	job = getTestJob(t, cl, *job.ID)
+	assert.Equal(t, clawv1.JobStatus_JOB_STATUS_PENDING.String(), job.Status)
+	assert.Equal(t, int64(0), job.BackfillPages)
+}
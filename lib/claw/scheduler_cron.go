@@ -0,0 +1,258 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/adhocore/gronx"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/logger"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// cronEntry is a schedule of an enabled source.
+type cronEntry struct {
+	model.Schedules
+	Source model.Sources
+
+	next time.Time
+}
+
+// startCron turns schedules into jobs when their cron expressions are due.
+//
+// The timer is re-armed whenever schedules are changed (scheduleSignal) or config is reloaded.
+func (scheduler *scheduler) startCron(ctx context.Context) {
+	reload := scheduler.reloadSignal.Listener(1)
+	defer reload.Close()
+	rearm := scheduler.scheduleSignal.Listener(1)
+	defer rearm.Close()
+
+	scheduler.catchUp(ctx, time.Now())
+
+	for {
+		entries, err := scheduler.loadCronEntries(ctx, time.Now())
+		if err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to load schedules", "error", err)
+		}
+		var nextTick time.Time
+		for _, entry := range entries {
+			if nextTick.IsZero() || entry.next.Before(nextTick) {
+				nextTick = entry.next
+			}
+		}
+		// When nothing is scheduled, wake up every poll interval anyway in case
+		// the database was modified outside of claw.
+		wait := pollInterval(scheduler.config.Scheduler)
+		if !nextTick.IsZero() {
+			scheduler.logger.DebugContext(ctx, "next schedule armed", "next_tick", nextTick)
+			wait = time.Until(nextTick)
+		}
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			scheduler.logger.DebugContext(ctx, "scheduler cron stopped")
+			return
+		case <-reload.Ch():
+			scheduler.logger.InfoContext(ctx, "reloading schedules after config reload")
+		case <-rearm.Ch():
+			scheduler.logger.InfoContext(ctx, "reloading schedules after schedule changes")
+		case <-timer.C:
+			now := time.Now()
+			for _, entry := range entries {
+				if entry.next.After(now) {
+					continue
+				}
+				if _, err := scheduler.createScheduledJob(ctx, entry, entry.next, true); err != nil {
+					scheduler.logger.ErrorContext(ctx, "failed to create scheduled job", "schedule_id", *entry.ID, "source_id", entry.SourceID, "error", err)
+				}
+			}
+		}
+		timer.Stop()
+	}
+}
+
+// loadCronEntries loads schedules of enabled sources and computes their next tick after the given time.
+//
+// Schedules with invalid cron expressions are logged and skipped.
+func (scheduler *scheduler) loadCronEntries(ctx context.Context, after time.Time) ([]cronEntry, error) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	var entries []cronEntry
+	ctx = logger.ContextWithSkipLog(ctx)
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Schedules.AllColumns, Sources.AllColumns).
+		FROM(Schedules.INNER_JOIN(Sources, Sources.ID.EQ(Schedules.SourceID))).
+		WHERE(Sources.IsDisabled.EQ(Int(0))).
+		ORDER_BY(Schedules.ID.ASC()).
+		QueryContext(ctx, scheduler.claw.db, &entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query schedules: %w", err)
+	}
+	valid := entries[:0]
+	for _, entry := range entries {
+		if !gronx.IsValid(entry.Schedule) {
+			scheduler.logger.WarnContext(ctx, "skipping schedule with invalid cron expression", "schedule_id", *entry.ID, "source_id", entry.SourceID, "schedule", entry.Schedule)
+			continue
+		}
+		next, err := gronx.NextTickAfter(entry.Schedule, after, false)
+		if err != nil {
+			scheduler.logger.WarnContext(ctx, "skipping schedule without next tick", "schedule_id", *entry.ID, "source_id", entry.SourceID, "schedule", entry.Schedule, "error", err)
+			continue
+		}
+		entry.next = next
+		valid = append(valid, entry)
+	}
+	return valid, nil
+}
+
+// catchUp creates jobs for schedule ticks that were missed while claw was not running,
+// according to the configured catch up policy.
+func (scheduler *scheduler) catchUp(ctx context.Context, now time.Time) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	entries, err := scheduler.loadCronEntries(ctx, now)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to load schedules for catch up", "error", err)
+		return
+	}
+	policy := scheduler.config.Scheduler.CatchUp
+	limit := 1
+	if policy == config.CatchUpAll {
+		limit = max(scheduler.config.Scheduler.MaxCatchUpRuns, 1)
+	}
+	for _, entry := range entries {
+		reference, err := scheduler.lastScheduledRun(ctx, entry)
+		if err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to find last run of schedule", "schedule_id", *entry.ID, "error", err)
+			continue
+		}
+		missed := missedTicks(entry.Schedule, reference, now, limit)
+		if len(missed) == 0 {
+			continue
+		}
+		if policy == config.CatchUpSkip {
+			scheduler.logger.InfoContext(ctx, "skipping missed schedule runs", "schedule_id", *entry.ID, "source_id", entry.SourceID, "last_run", reference, "latest_missed", missed[len(missed)-1])
+			continue
+		}
+		scheduler.logger.InfoContext(ctx, "catching up missed schedule runs", "schedule_id", *entry.ID, "source_id", entry.SourceID, "policy", policy, "runs", len(missed))
+		for _, tick := range missed {
+			// With "all" policy, every missed run gets its own job even if previous ones are still pending.
+			if _, err := scheduler.createScheduledJob(ctx, entry, tick, policy != config.CatchUpAll); err != nil {
+				scheduler.logger.ErrorContext(ctx, "failed to create catch up job", "schedule_id", *entry.ID, "source_id", entry.SourceID, "error", err)
+				break
+			}
+		}
+	}
+}
+
+// lastScheduledRun returns the latest known point in time the schedule was handled.
+//
+// It is the latest of the last job created by the schedule, the source's last run,
+// and the schedule creation time.
+func (scheduler *scheduler) lastScheduledRun(ctx context.Context, entry cronEntry) (time.Time, error) {
+	reference := entry.CreatedAt.Time
+	if entry.Source.LastRunAt.After(reference) {
+		reference = entry.Source.LastRunAt.Time
+	}
+	var jobs []model.Jobs
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Jobs.AllColumns).
+		FROM(Jobs).
+		WHERE(Jobs.ScheduleID.EQ(Int64(*entry.ID))).
+		ORDER_BY(Jobs.CreatedAt.DESC()).
+		LIMIT(1).
+		QueryContext(ctx, scheduler.claw.db, &jobs)
+	if err != nil {
+		return reference, fmt.Errorf("failed to query last job of schedule: %w", err)
+	}
+	if len(jobs) > 0 && jobs[0].CreatedAt.After(reference) {
+		reference = jobs[0].CreatedAt.Time
+	}
+	return reference, nil
+}
+
+// missedTicks returns up to limit latest ticks of expr that fall after since and at or before now,
+// in chronological order.
+func missedTicks(expr string, since, now time.Time, limit int) []time.Time {
+	var ticks []time.Time
+	cursor, inclusive := now, true
+	for len(ticks) < limit {
+		tick, err := gronx.PrevTickBefore(expr, cursor, inclusive)
+		if err != nil || !tick.After(since) {
+			break
+		}
+		ticks = append(ticks, tick)
+		cursor, inclusive = tick, false
+	}
+	slices.Reverse(ticks)
+	return ticks
+}
+
+// createScheduledJob inserts a pending job for the given schedule entry at the given tick.
+//
+// The source's last run is recorded when the job starts running, not here, so it is not moved forward by
+// jobs that are still pending or never run.
+//
+// If skipIfPending is true and the source already has an unfinished job, no job is created
+// and nil job is returned.
+func (scheduler *scheduler) createScheduledJob(ctx context.Context, entry cronEntry, tick time.Time, skipIfPending bool) (*model.Jobs, error) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	tx, err := scheduler.claw.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if skipIfPending {
+		var pending []model.Jobs
+		ctx := otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+		err = SELECT(Jobs.ID).
+			FROM(Jobs).
+			WHERE(Jobs.SourceID.EQ(Int64(entry.SourceID)).AND(Jobs.FinishedAt.IS_NULL())).
+			LIMIT(1).
+			QueryContext(ctx, tx, &pending)
+		if err != nil {
+			return nil, fmt.Errorf("failed to query pending jobs of source: %w", err)
+		}
+		if len(pending) > 0 {
+			scheduler.logger.InfoContext(ctx, "source already has unfinished job, skipping scheduled run",
+				"schedule_id", *entry.ID, "source_id", entry.SourceID, "pending_job_id", *pending[0].ID)
+			return nil, nil
+		}
+	}
+
+	var job model.Jobs
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = Jobs.
+		INSERT(Jobs.SourceID, Jobs.ScheduleID, Jobs.Status, Jobs.CreatedAt).
+		MODEL(model.Jobs{
+			SourceID:   entry.SourceID,
+			ScheduleID: entry.ID,
+			Status:     clawv1.JobStatus_JOB_STATUS_PENDING.String(),
+			CreatedAt:  types.UnixMilliNow(),
+		}).
+		RETURNING(Jobs.AllColumns).
+		QueryContext(ctx, tx, &job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert scheduled job: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	scheduler.logger.InfoContext(ctx, "scheduled job created", "job_id", *job.ID, "schedule_id", *entry.ID, "source_id", entry.SourceID, "tick", tick)
+	return &job, nil
+}
@@ -0,0 +1,167 @@
+package claw
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+func TestMissedTicks(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name  string
+		expr  string
+		since time.Time
+		now   time.Time
+		limit int
+		want  []time.Time
+	}{
+		{"none missed", "0 * * * *", at(12, 10), at(12, 50), 5, nil},
+		{"one missed", "0 * * * *", at(11, 30), at(12, 30), 5, []time.Time{at(12, 0)}},
+		{"all missed in order", "0 * * * *", at(9, 15), at(12, 30), 5, []time.Time{at(10, 0), at(11, 0), at(12, 0)}},
+		{"limit keeps latest", "0 * * * *", at(9, 15), at(12, 30), 2, []time.Time{at(11, 0), at(12, 0)}},
+		{"tick at now is missed", "0 * * * *", at(11, 30), at(12, 0), 5, []time.Time{at(12, 0)}},
+		{"tick at since is not missed", "0 * * * *", at(11, 0), at(11, 30), 5, nil},
+		{"zero limit", "0 * * * *", at(9, 15), at(12, 30), 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, missedTicks(tt.expr, tt.since, tt.now, tt.limit))
+		})
+	}
+}
+
+// insertSchedule inserts an enabled source with an hourly schedule created at scheduleCreatedAt.
+func insertSchedule(t *testing.T, cl *Claw, scheduleCreatedAt, lastRunAt time.Time) model.Schedules {
+	t.Helper()
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter, Sources.LastRunAt).
+		MODEL(model.Sources{
+			Name:        "claw.script.v1",
+			DisplayName: "Script",
+			Parameter:   "hourly.js",
+			LastRunAt:   types.NewUnixMilli(lastRunAt),
+		}).
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	var schedule model.Schedules
+	err = Schedules.INSERT(Schedules.SourceID, Schedules.Schedule, Schedules.CreatedAt).
+		MODEL(model.Schedules{
+			SourceID:  *src.ID,
+			Schedule:  "0 * * * *",
+			CreatedAt: types.NewUnixMilli(scheduleCreatedAt),
+		}).
+		RETURNING(Schedules.AllColumns).
+		QueryContext(ctx, cl.db, &schedule)
+	require.NoError(t, err)
+	return schedule
+}
+
+func TestLastScheduledRun(t *testing.T) {
+	at := func(hour int) time.Time {
+		return time.Date(2025, 1, 1, hour, 0, 0, 0, time.UTC)
+	}
+	never := time.UnixMilli(0) // default of sources.last_run_at
+	tests := []struct {
+		name              string
+		scheduleCreatedAt time.Time
+		lastRunAt         time.Time
+		jobCreatedAt      time.Time // zero for no job
+		want              time.Time
+	}{
+		{"never run", at(9), never, time.Time{}, at(9)},
+		{"source run after schedule creation", at(9), at(10), time.Time{}, at(10)},
+		{"source run before schedule creation", at(9), at(8), time.Time{}, at(9)},
+		{"job created after source run", at(9), at(10), at(11), at(11)},
+		{"job created before source run", at(9), at(11), at(10), at(11)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestClaw(t)
+			ctx := context.Background()
+			schedule := insertSchedule(t, cl, tt.scheduleCreatedAt, tt.lastRunAt)
+			if !tt.jobCreatedAt.IsZero() {
+				_, err := Jobs.INSERT(Jobs.SourceID, Jobs.ScheduleID, Jobs.Status, Jobs.CreatedAt).
+					MODEL(model.Jobs{
+						SourceID:   schedule.SourceID,
+						ScheduleID: schedule.ID,
+						Status:     clawv1.JobStatus_JOB_STATUS_COMPLETED.String(),
+						CreatedAt:  types.NewUnixMilli(tt.jobCreatedAt),
+					}).
+					ExecContext(ctx, cl.db)
+				require.NoError(t, err)
+			}
+			entries, err := cl.scheduler.loadCronEntries(ctx, at(12))
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+
+			got, err := cl.scheduler.lastScheduledRun(ctx, entries[0])
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.UnixMilli(), got.UnixMilli())
+		})
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+	at := func(hour, minute int) time.Time {
+		return time.Date(2025, 1, 1, hour, minute, 0, 0, time.UTC)
+	}
+	// Ticks at 10:00, 11:00, and 12:00 are missed with the schedule created at 09:15, and only 12:00
+	// with the source last run at 11:30.
+	now := at(12, 30)
+	never := time.UnixMilli(0) // default of sources.last_run_at
+	tests := []struct {
+		name           string
+		policy         config.CatchUpPolicy
+		maxCatchUpRuns int
+		lastRunAt      time.Time
+		want           int
+	}{
+		{"skip", config.CatchUpSkip, 24, never, 0},
+		{"once", config.CatchUpOnce, 24, never, 1},
+		{"all", config.CatchUpAll, 24, never, 3},
+		{"all capped by max runs", config.CatchUpAll, 2, never, 2},
+		{"all since source last run", config.CatchUpAll, 24, at(11, 30), 1},
+		{"once with nothing missed", config.CatchUpOnce, 24, at(12, 10), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cl := newTestClaw(t)
+			cl.config.Scheduler.CatchUp = tt.policy
+			cl.config.Scheduler.MaxCatchUpRuns = tt.maxCatchUpRuns
+			schedule := insertSchedule(t, cl, at(9, 15), tt.lastRunAt)
+
+			cl.scheduler.catchUp(context.Background(), now)
+
+			var jobs []model.Jobs
+			err := SELECT(Jobs.AllColumns).
+				FROM(Jobs).
+				WHERE(Jobs.ScheduleID.EQ(Int64(*schedule.ID))).
+				QueryContext(context.Background(), cl.db, &jobs)
+			require.NoError(t, err)
+			assert.Len(t, jobs, tt.want)
+
+			// Last run is recorded when the jobs run, not when they are created.
+			var src model.Sources
+			err = SELECT(Sources.AllColumns).
+				FROM(Sources).
+				WHERE(Sources.ID.EQ(Int64(schedule.SourceID))).
+				QueryContext(context.Background(), cl.db, &src)
+			require.NoError(t, err)
+			assert.Equal(t, types.NewUnixMilli(tt.lastRunAt).UnixMilli(), src.LastRunAt.UnixMilli())
+		})
+	}
+}
@@ -2,61 +2,110 @@ package claw
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strings"
 
+	"github.com/go-jet/jet/v2/qrm"
 	. "github.com/go-jet/jet/v2/sqlite"
 	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
 	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
 	"github.com/tigorlazuardi/claw/lib/claw/source"
 	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
 )
 
 // processDownload downloads and processes an image for the given devices
-func (scheduler *scheduler) processDownload(ctx context.Context, image source.Image, devices []model.Devices, sourceName string) (err error) {
-	// Construct the image file path
-	imageDir := filepath.Join(scheduler.config.Download.BaseDir, "images", sourceName)
-	imagePath := filepath.Join(imageDir, image.Filename)
-
-	// Check if image already exists
-	shouldDownload, err := scheduler.shouldDownloadImage(imagePath)
+//
+// Images are deduplicated by download URL first, then by the SHA-256 of the downloaded content.
+// Content that is already in the library is not stored twice. The new source and post are
+// recorded as a reference of the existing image instead.
+func (scheduler *scheduler) processDownload(ctx context.Context, job int64, image source.Image, devices []model.Devices, src model.Sources) (err error) {
+	existing, err := scheduler.findImageByURL(ctx, image.DownloadURL)
 	if err != nil {
-		return fmt.Errorf("failed to check if image should be downloaded: %w", err)
+		return fmt.Errorf("failed to find existing image: %w", err)
 	}
 
-	if shouldDownload {
-		// Download image to temporary location first
-		tmpPath, err := scheduler.downloadImageToTemp(ctx, image)
+	var (
+		imageRow   model.Images
+		downloaded bool
+	)
+	if existing != nil {
+		shouldDownload, err := scheduler.shouldDownloadImage(scheduler.absoluteImagePath(existing.ImagePath))
 		if err != nil {
-			return fmt.Errorf("failed to download image: %w", err)
+			return fmt.Errorf("failed to check if image should be downloaded: %w", err)
 		}
-		defer os.Remove(tmpPath) // Clean up temp file
-
-		// Ensure image directory exists
-		if err := os.MkdirAll(imageDir, 0o755); err != nil {
-			return fmt.Errorf("failed to create image directory: %w", err)
+		if !shouldDownload {
+			imageRow = *existing
 		}
-
-		// Move from temp to final location
-		if err := scheduler.moveToFinalLocation(ctx, tmpPath, imagePath); err != nil {
-			return fmt.Errorf("failed to move image to final location: %w", err)
+	}
+	if imageRow.ID == nil {
+		imageRow, downloaded, err = scheduler.downloadImage(ctx, image, src, existing)
+		if err != nil {
+			return err
 		}
 	}
+	imageID := *imageRow.ID
+	imagePath := scheduler.absoluteImagePath(imageRow.ImagePath)
 
-	// Find or create image in database
-	imageID, err := scheduler.findOrCreateImage(ctx, image, sourceName, imagePath)
+	// Thumbnails are only for display purposes, so failing to create one should not fail the download.
+	if err := scheduler.processThumbnail(ctx, imageRow, image, imagePath); err != nil {
+		scheduler.logger.WarnContext(ctx, "failed to create thumbnail", "image_id", imageID, "error", err)
+	}
+	// Perceptual hashes only power near-duplicate detection, so they are not required either.
+	imageRow, err = scheduler.processPerceptualHash(ctx, imageRow, imagePath)
 	if err != nil {
-		return fmt.Errorf("failed to find or create image: %w", err)
+		scheduler.logger.WarnContext(ctx, "failed to compute perceptual hash", "image_id", imageID, "error", err)
+	}
+
+	action := clawv1.JobAction_JOB_ACTION_ASSIGN
+	if downloaded {
+		action = clawv1.JobAction_JOB_ACTION_DOWNLOAD
+		payloadImage := webhookImageFromSource(image)
+		payloadImage.ID = imageID
+		payloadImage.Path = imageRow.ImagePath
+		scheduler.webhooks.Dispatch(ctx, WebhookPayload{
+			Event:  WebhookEventImageDownloaded,
+			Job:    &WebhookJob{ID: job},
+			Source: webhookSourceFromModel(src),
+			Image:  payloadImage,
+		})
 	}
 
 	// Process devices and create hardlinks/copies
 	for _, device := range devices {
-		if err := scheduler.processDeviceAssignment(ctx, image, device, imagePath, sourceName, imageID); err != nil {
+		if scheduler.config.Similarity.SkipDeviceDuplicates && imageRow.Phash != nil {
+			duplicate, distance, err := scheduler.findNearDuplicateOnDevice(ctx, imageRow, *device.ID)
+			if err != nil {
+				scheduler.logger.ErrorContext(ctx, "failed to find near-duplicate on device",
+					"device_id", device.ID, "device_name", device.Name, "error", err)
+				continue
+			}
+			if duplicate != nil {
+				scheduler.logger.InfoContext(ctx, "skipping device assignment, device already has a near-duplicate",
+					"job_id", job, "image_id", imageID, "device_slug", device.Slug, "duplicate_image_id", *duplicate.ID, "distance", distance)
+				continue
+			}
+		}
+		assignment := deviceAssignment{
+			job:       job,
+			imageID:   imageID,
+			imagePath: imagePath,
+			image:     image,
+			device:    device,
+			source:    src,
+			action:    action,
+		}
+		if err := scheduler.processDeviceAssignment(ctx, assignment); err != nil {
 			scheduler.logger.ErrorContext(ctx, "failed to process device assignment",
 				"device_id", device.ID, "device_name", device.Name, "error", err)
 			continue
@@ -66,6 +115,127 @@ func (scheduler *scheduler) processDownload(ctx context.Context, image source.Im
 	return nil
 }
 
+// downloadImage downloads the image and stores it in the library.
+//
+// existing is the image row found by download URL whose file is missing or suspicious, if any.
+// The returned bool reports whether new content was stored. It is false when the content
+// turned out to be a duplicate of another image.
+func (scheduler *scheduler) downloadImage(ctx context.Context, image source.Image, src model.Sources, existing *model.Images) (model.Images, bool, error) {
+	file, err := scheduler.downloadImageToTemp(ctx, image, src)
+	if err != nil {
+		return model.Images{}, false, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer os.Remove(file.path) // Clean up temp file
+
+	scheduler.imageMutex.Lock()
+	defer scheduler.imageMutex.Unlock()
+
+	duplicate, err := scheduler.findImageByHash(ctx, file.sha256)
+	if err != nil {
+		return model.Images{}, false, fmt.Errorf("failed to find image by content hash: %w", err)
+	}
+
+	target := existing
+	if duplicate != nil {
+		if existing == nil || *existing.ID != *duplicate.ID {
+			if err := scheduler.addImageReference(ctx, *duplicate.ID, image, *src.ID); err != nil {
+				return model.Images{}, false, err
+			}
+		}
+		shouldDownload, err := scheduler.shouldDownloadImage(scheduler.absoluteImagePath(duplicate.ImagePath))
+		if err != nil {
+			return model.Images{}, false, fmt.Errorf("failed to check if image should be downloaded: %w", err)
+		}
+		if !shouldDownload {
+			scheduler.logger.InfoContext(ctx, "image content already exists in library",
+				"image_id", *duplicate.ID, "download_url", image.DownloadURL, "sha256", file.sha256)
+			return *duplicate, false, nil
+		}
+		// The duplicate's file is gone. Restore it with the content we just downloaded.
+		target = duplicate
+	}
+
+	if target != nil {
+		imagePath := scheduler.absoluteImagePath(target.ImagePath)
+		if err := scheduler.storeImageFile(ctx, file.path, imagePath); err != nil {
+			return model.Images{}, false, err
+		}
+		var updated model.Images
+		ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+		// The new file may decode where the old one did not, so its perceptual hashes are tried again.
+		err := Images.UPDATE(Images.Sha256, Images.PerceptualHashFailedAt, Images.UpdatedAt).
+			SET(String(file.sha256), NULL, types.UnixMilliNow()).
+			WHERE(Images.ID.EQ(Int64(*target.ID))).
+			RETURNING(Images.AllColumns).
+			QueryContext(ctx, scheduler.claw.db, &updated)
+		if err != nil {
+			return model.Images{}, false, fmt.Errorf("failed to update image hash: %w", err)
+		}
+		return updated, true, nil
+	}
+
+	relativePath, err := scheduler.freeImagePath(src.Name, image.Filename, file.sha256)
+	if err != nil {
+		return model.Images{}, false, err
+	}
+	if err := scheduler.storeImageFile(ctx, file.path, scheduler.absoluteImagePath(relativePath)); err != nil {
+		return model.Images{}, false, err
+	}
+	if image.Filesize == 0 {
+		image.Filesize = file.size
+	}
+	created, err := scheduler.createImage(ctx, image, *src.ID, relativePath, file.sha256)
+	if err != nil {
+		_ = os.Remove(scheduler.absoluteImagePath(relativePath))
+		return model.Images{}, false, fmt.Errorf("failed to create image: %w", err)
+	}
+	return created, true, nil
+}
+
+// storeImageFile moves the downloaded temp file to imagePath, replacing any existing file.
+//
+// The existing file is unlinked instead of overwritten, so device files hardlinked to it are left untouched.
+func (scheduler *scheduler) storeImageFile(ctx context.Context, tmpPath, imagePath string) error {
+	if err := os.MkdirAll(filepath.Dir(imagePath), 0o755); err != nil {
+		return fmt.Errorf("failed to create image directory: %w", err)
+	}
+	if err := os.Remove(imagePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale image file: %w", err)
+	}
+	if err := scheduler.moveToFinalLocation(ctx, tmpPath, imagePath); err != nil {
+		return fmt.Errorf("failed to move image to final location: %w", err)
+	}
+	return nil
+}
+
+// freeImagePath returns a path relative to base dir for new image content.
+//
+// Different images may share the same filename. When the default path is already taken,
+// a prefix of the content hash is appended to the filename.
+func (scheduler *scheduler) freeImagePath(sourceName, filename, contentHash string) (string, error) {
+	// Filenames come from sources, so one like "../../.bashrc" must not escape the image directory.
+	if !filepath.IsLocal(filename) || strings.ContainsAny(filename, `/\`) {
+		return "", fmt.Errorf("invalid image filename %q: must be a single path element", filename)
+	}
+	candidate := path.Join("images", sourceName, filename)
+	_, err := os.Stat(scheduler.absoluteImagePath(candidate))
+	if errors.Is(err, os.ErrNotExist) {
+		return candidate, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to stat image file: %w", err)
+	}
+	return path.Join("images", sourceName, withFilenameSuffix(filename, "_"+contentHash[:12])), nil
+}
+
+// absoluteImagePath converts a path relative to base dir, as stored in the database, to an absolute path.
+func (scheduler *scheduler) absoluteImagePath(relativePath string) string {
+	if filepath.IsAbs(relativePath) {
+		return relativePath
+	}
+	return filepath.Join(scheduler.config.Download.BaseDir, filepath.FromSlash(relativePath))
+}
+
 // shouldDownloadImage checks if an image should be downloaded
 func (scheduler *scheduler) shouldDownloadImage(imagePath string) (bool, error) {
 	info, err := os.Stat(imagePath)
@@ -91,34 +261,52 @@ func (scheduler *scheduler) shouldDownloadImage(imagePath string) (bool, error)
 	return false, nil
 }
 
-// downloadImageToTemp downloads an image to a temporary location
-func (scheduler *scheduler) downloadImageToTemp(ctx context.Context, image source.Image) (string, error) {
+// downloadedFile is an image downloaded to the temp directory.
+type downloadedFile struct {
+	path   string
+	sha256 string // hex encoded
+	size   int64
+}
+
+// downloadImageToTemp downloads an image to a temporary location and hashes its content
+//
+// The temp file is removed when the download fails or is cancelled.
+func (scheduler *scheduler) downloadImageToTemp(ctx context.Context, image source.Image, src model.Sources) (_ downloadedFile, err error) {
 	// Ensure temp directory exists
 	if err := os.MkdirAll(scheduler.config.Download.TmpDir, 0o755); err != nil {
-		return "", fmt.Errorf("failed to create temp directory: %w", err)
+		return downloadedFile{}, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+
+	if u, err := url.Parse(image.DownloadURL); err == nil && u.Scheme == "file" {
+		return scheduler.linkLocalFileToTemp(ctx, u, src)
 	}
 
 	// Create temp file
 	tmpFile, err := os.CreateTemp(scheduler.config.Download.TmpDir, "claw_download_*")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp file: %w", err)
+		return downloadedFile{}, fmt.Errorf("failed to create temp file: %w", err)
 	}
-	defer tmpFile.Close()
+	defer func() {
+		_ = tmpFile.Close()
+		if err != nil {
+			_ = os.Remove(tmpFile.Name())
+		}
+	}()
 
 	// Download image
 	req, err := http.NewRequestWithContext(ctx, "GET", image.DownloadURL, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create download request: %w", err)
+		return downloadedFile{}, fmt.Errorf("failed to create download request: %w", err)
 	}
 
 	resp, err := scheduler.httpclient.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to execute download request: %w", err)
+		return downloadedFile{}, fmt.Errorf("failed to execute download request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 300 {
-		return "", fmt.Errorf("download failed with status: %d", resp.StatusCode)
+		return downloadedFile{}, fmt.Errorf("download failed: %w", &source.StatusError{Service: "server", StatusCode: resp.StatusCode, RetryAfter: source.RetryAfter(resp.Header)})
 	}
 
 	// Create stall reader if monitoring is enabled
@@ -128,13 +316,75 @@ func (scheduler *scheduler) downloadImageToTemp(ctx context.Context, image sourc
 		reader = stallReader
 	}
 
-	// Copy response body to temp file
-	_, err = io.Copy(tmpFile, reader)
+	// Copy response body to temp file, hashing the content along the way
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmpFile, hash), reader)
+	if err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to download image data: %w", err)
+	}
+
+	return downloadedFile{
+		path:   tmpFile.Name(),
+		sha256: hex.EncodeToString(hash.Sum(nil)),
+		size:   size,
+	}, nil
+}
+
+// linkLocalFileToTemp hardlinks a file:// image into the temp directory, or copies it when hardlinking
+// is not possible, and hashes its content.
+//
+// Only sources implementing [source.SourceLocalFiles] may return local files, and only the files they allow.
+func (scheduler *scheduler) linkLocalFileToTemp(ctx context.Context, fileURL *url.URL, src model.Sources) (_ downloadedFile, err error) {
+	localPath := filepath.FromSlash(fileURL.Path)
+	if fileURL.Host != "" && fileURL.Host != "localhost" {
+		return downloadedFile{}, fmt.Errorf("file URL %s points to another host", fileURL)
+	}
+	localFiles, ok := scheduler.backends[src.Name].(source.SourceLocalFiles)
+	if !ok || !localFiles.AllowLocalFile(localPath) {
+		return downloadedFile{}, fmt.Errorf("source %s is not allowed to read local file %s", src.Name, localPath)
+	}
+	info, err := os.Stat(localPath)
+	if err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to stat local file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return downloadedFile{}, fmt.Errorf("local file %s is not a regular file", localPath)
+	}
+
+	// Reserve a unique name in the temp directory to link to.
+	tmpFile, err := os.CreateTemp(scheduler.config.Download.TmpDir, "claw_download_*")
 	if err != nil {
-		return "", fmt.Errorf("failed to download image data: %w", err)
+		return downloadedFile{}, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	_ = tmpFile.Close()
+	if err := os.Remove(tmpPath); err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to remove temp file: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	if err := scheduler.moveToFinalLocation(ctx, localPath, tmpPath); err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to import local file: %w", err)
 	}
 
-	return tmpFile.Name(), nil
+	f, err := os.Open(tmpPath)
+	if err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to open imported file: %w", err)
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return downloadedFile{}, fmt.Errorf("failed to hash imported file: %w", err)
+	}
+	return downloadedFile{
+		path:   tmpPath,
+		sha256: hex.EncodeToString(hash.Sum(nil)),
+		size:   size,
+	}, nil
 }
 
 // moveToFinalLocation moves a file from temp location to final location using hardlink or copy
@@ -151,7 +401,7 @@ func (scheduler *scheduler) moveToFinalLocation(ctx context.Context, srcPath, ds
 }
 
 // copyFile copies a file from src to dst
-func (scheduler *scheduler) copyFile(srcPath, dstPath string) error {
+func (scheduler *scheduler) copyFile(srcPath, dstPath string) (err error) {
 	src, err := os.Open(srcPath)
 	if err != nil {
 		return fmt.Errorf("failed to open source file: %w", err)
@@ -162,7 +412,12 @@ func (scheduler *scheduler) copyFile(srcPath, dstPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to create destination file: %w", err)
 	}
-	defer dst.Close()
+	defer func() {
+		_ = dst.Close()
+		if err != nil {
+			_ = os.Remove(dstPath) // do not leave partial files behind
+		}
+	}()
 
 	_, err = io.Copy(dst, src)
 	if err != nil {
@@ -172,52 +427,106 @@ func (scheduler *scheduler) copyFile(srcPath, dstPath string) error {
 	return nil
 }
 
-// findOrCreateImage finds an existing image or creates a new one in the database
-func (scheduler *scheduler) findOrCreateImage(ctx context.Context, image source.Image, sourceName string, imagePath string) (int64, error) {
-	// First, try to find existing image by download URL
-	var existingImage model.Images
+// findImageByURL finds the image downloaded from the given URL, either as the image's own
+// download URL or as one of its references. Returns nil if there is none.
+func (scheduler *scheduler) findImageByURL(ctx context.Context, downloadURL string) (*model.Images, error) {
+	var image model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
 	err := SELECT(Images.AllColumns).
-		WHERE(Images.DownloadURL.EQ(String(image.DownloadURL))).
-		QueryContext(ctx, scheduler.claw.db, &existingImage)
-
+		FROM(Images).
+		WHERE(Images.DownloadURL.EQ(String(downloadURL))).
+		ORDER_BY(Images.ID.ASC()).
+		LIMIT(1).
+		QueryContext(ctx, scheduler.claw.db, &image)
 	if err == nil {
-		// Image exists, update the image path and return ID
-		relativeImagePath := strings.TrimPrefix(imagePath, scheduler.config.Download.BaseDir+"/")
-		_, err = Images.UPDATE(Images.ImagePath, Images.UpdatedAt).
-			SET(String(relativeImagePath), types.UnixMilliNow()).
-			WHERE(Images.ID.EQ(Int64(*existingImage.ID))).
-			ExecContext(ctx, scheduler.claw.db)
-		if err != nil {
-			return 0, fmt.Errorf("failed to update image path: %w", err)
-		}
-		return *existingImage.ID, nil
+		return &image, nil
+	}
+	if !errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("failed to query image by download url: %w", err)
 	}
 
-	// Image doesn't exist, create new one
-	// First, get source ID for the source name
-	var source model.Sources
-	err = SELECT(Sources.AllColumns).
-		WHERE(Sources.Name.EQ(String(sourceName))).
-		QueryContext(ctx, scheduler.claw.db, &source)
+	err = SELECT(Images.AllColumns).
+		FROM(Images.INNER_JOIN(ImageReferences, ImageReferences.ImageID.EQ(Images.ID))).
+		WHERE(ImageReferences.DownloadURL.EQ(String(downloadURL))).
+		ORDER_BY(Images.ID.ASC()).
+		LIMIT(1).
+		QueryContext(ctx, scheduler.claw.db, &image)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to find source: %w", err)
+		return nil, fmt.Errorf("failed to query image by reference download url: %w", err)
 	}
+	return &image, nil
+}
 
-	nowMillis := types.UnixMilliNow()
-	relativeImagePath := strings.TrimPrefix(imagePath, scheduler.config.Download.BaseDir+"/")
+// findImageByHash finds the image with the given content hash. Returns nil if there is none.
+func (scheduler *scheduler) findImageByHash(ctx context.Context, contentHash string) (*model.Images, error) {
+	var image model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Images.AllColumns).
+		FROM(Images).
+		WHERE(Images.Sha256.EQ(String(contentHash))).
+		ORDER_BY(Images.ID.ASC()).
+		LIMIT(1).
+		QueryContext(ctx, scheduler.claw.db, &image)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image by hash: %w", err)
+	}
+	return &image, nil
+}
+
+// addImageReference records another source and post for an existing image.
+func (scheduler *scheduler) addImageReference(ctx context.Context, imageID int64, image source.Image, sourceID int64) error {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := ImageReferences.INSERT(
+		ImageReferences.ImageID,
+		ImageReferences.SourceID,
+		ImageReferences.DownloadURL,
+		ImageReferences.Title,
+		ImageReferences.PostAuthor,
+		ImageReferences.PostAuthorURL,
+		ImageReferences.PostURL,
+		ImageReferences.CreatedAt,
+	).MODEL(model.ImageReferences{
+		ImageID:       imageID,
+		SourceID:      sourceID,
+		DownloadURL:   image.DownloadURL,
+		Title:         image.Title,
+		PostAuthor:    image.Author,
+		PostAuthorURL: image.AuthorURL,
+		PostURL:       image.Website,
+		CreatedAt:     types.UnixMilliNow(),
+	}).
+		ON_CONFLICT(ImageReferences.ImageID, ImageReferences.DownloadURL).DO_NOTHING().
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		return fmt.Errorf("failed to add image reference: %w", err)
+	}
+	// The same content from another post may come with different tags.
+	return scheduler.addSourceTags(ctx, scheduler.claw.db, imageID, image.Tags)
+}
 
-	// Insert new image
+// createImage inserts a new image row. imagePath is relative to base dir.
+func (scheduler *scheduler) createImage(ctx context.Context, image source.Image, sourceID int64, imagePath, contentHash string) (model.Images, error) {
+	nowMillis := types.UnixMilliNow()
 	imageModel := model.Images{
-		SourceID:      *source.ID,
+		SourceID:      sourceID,
 		DownloadURL:   image.DownloadURL,
+		Title:         image.Title,
 		Width:         image.Width,
 		Height:        image.Height,
 		Filesize:      image.Filesize,
-		ImagePath:     relativeImagePath,
+		ImagePath:     imagePath,
 		PostAuthor:    image.Author,
 		PostAuthorURL: image.AuthorURL,
 		PostURL:       image.Website,
 		IsFavorite:    types.Bool(false),
+		IsNsfw:        types.Bool(image.NSFW),
+		Sha256:        contentHash,
 		CreatedAt:     nowMillis,
 		UpdatedAt:     nowMillis,
 	}
@@ -225,78 +534,63 @@ func (scheduler *scheduler) findOrCreateImage(ctx context.Context, image source.
 	stmt := Images.INSERT(
 		Images.SourceID,
 		Images.DownloadURL,
+		Images.Title,
 		Images.Width,
 		Images.Height,
 		Images.Filesize,
+		Images.ThumbnailPath,
 		Images.ImagePath,
 		Images.PostAuthor,
 		Images.PostAuthorURL,
 		Images.PostURL,
 		Images.IsFavorite,
+		Images.IsNsfw,
+		Images.Sha256,
 		Images.CreatedAt,
 		Images.UpdatedAt,
 	).MODEL(imageModel).
-		RETURNING(Images.ID)
+		RETURNING(Images.AllColumns)
 
-	var imageID int64
-	err = stmt.QueryContext(ctx, scheduler.claw.db, &imageID)
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	tx, err := scheduler.claw.db.BeginTx(ctx, nil)
 	if err != nil {
-		return 0, fmt.Errorf("failed to create image: %w", err)
+		return model.Images{}, fmt.Errorf("failed to begin transaction: %w", err)
 	}
+	defer tx.Rollback()
 
-	return imageID, nil
+	var created model.Images
+	if err := stmt.QueryContext(ctx, tx, &created); err != nil {
+		return model.Images{}, err
+	}
+	if err := scheduler.addSourceTags(ctx, tx, *created.ID, image.Tags); err != nil {
+		return model.Images{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return model.Images{}, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return created, nil
 }
 
-// processDeviceAssignment handles device assignment and creates hardlinks/copies
-func (scheduler *scheduler) processDeviceAssignment(ctx context.Context, image source.Image, device model.Devices, imagePath, sourceName string, imageID int64) error {
-	panic("this AI stuff is shit")
-	// // Generate filename template
-	// filenameTemplate := device.FilenameTemplate
-	// if filenameTemplate == "" {
-	// 	filenameTemplate = sourceName + "_" + image.Filename
-	// }
-	//
-	// pathTemplate := filepath.Join("devices", device.Slug, filenameTemplate)
-	//
-	// // Determine target directory
-	// var targetDir string
-	// if device.SaveDir == "" {
-	// 	targetDir = filepath.Join(scheduler.config.Download.BaseDir, "devices", device.Slug)
-	// } else {
-	// 	targetDir = device.SaveDir
-	// }
-	//
-	// targetPath := filepath.Join(targetDir, filenameTemplate)
-	//
-	// // Ensure target directory exists
-	// if err := os.MkdirAll(targetDir, 0o755); err != nil {
-	// 	return fmt.Errorf("failed to create device directory: %w", err)
-	// }
-	//
-	// // Try hardlink first, fallback to copy
-	// if err := os.Link(imagePath, targetPath); err != nil {
-	// 	if err := scheduler.copyFile(imagePath, targetPath); err != nil {
-	// 		return fmt.Errorf("failed to copy image to device location: %w", err)
-	// 	}
-	// }
-	//
-	// // Update ImagePaths table
-	// relativeDevicePath := strings.TrimPrefix(targetPath, scheduler.config.Download.BaseDir+"/")
-	//
-	// // Insert device path into ImagePaths table
-	// nowMillis := types.UnixMilliNow()
-	// _, err := ImagePaths.INSERT(
-	// 	ImagePaths.ImageID,
-	// 	ImagePaths.Path,
-	// 	ImagePaths.CreatedAt,
-	// ).VALUES(
-	// 	imageID,
-	// 	relativeDevicePath,
-	// 	nowMillis,
-	// ).ExecContext(ctx, scheduler.claw.db)
-	// if err != nil {
-	// 	return fmt.Errorf("failed to insert image path: %w", err)
-	// }
-	//
-	// return nil
+// addSourceTags attaches the tags provided by the source to the image, creating tags that do not exist yet.
+func (scheduler *scheduler) addSourceTags(ctx context.Context, db qrm.DB, imageID int64, names []string) error {
+	tags, err := ensureTags(ctx, db, normalizeTagNames(names))
+	if err != nil {
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+	now := types.UnixMilliNow()
+	rows := make([]model.ImageTags, len(tags))
+	for i, tag := range tags {
+		rows[i] = model.ImageTags{ImageID: imageID, TagID: *tag.ID, CreatedAt: now}
+	}
+	_, err = ImageTags.INSERT(ImageTags.AllColumns).
+		MODELS(rows).
+		ON_CONFLICT(ImageTags.ImageID, ImageTags.TagID).DO_NOTHING().
+		ExecContext(ctx, db)
+	if err != nil {
+		return fmt.Errorf("failed to add source tags: %w", err)
+	}
+	return nil
 }
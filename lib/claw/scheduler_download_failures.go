@@ -0,0 +1,273 @@
+package claw
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/logger"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// recordDownloadFailures stores the images that failed to download in a job, together with the devices they
+// were assigned to, so they are retried later by startDownloadRetries.
+//
+// Failures that are not retryable are stored too, so they are shown in the job, but are not retried.
+func (scheduler *scheduler) recordDownloadFailures(ctx context.Context, job int64, src model.Sources, failed []imageQueue) {
+	for _, queue := range failed {
+		if err := scheduler.recordDownloadFailure(ctx, job, src, queue); err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to record download failure",
+				"job_id", job, "download_url", queue.image.DownloadURL, "error", err)
+		}
+	}
+}
+
+func (scheduler *scheduler) recordDownloadFailure(ctx context.Context, job int64, src model.Sources, queue imageQueue) error {
+	image, err := json.Marshal(queue.image)
+	if err != nil {
+		return fmt.Errorf("failed to encode image: %w", err)
+	}
+	now := types.UnixMilliNow()
+	failure := model.DownloadFailures{
+		JobID:         job,
+		SourceID:      *src.ID,
+		DownloadURL:   queue.image.DownloadURL,
+		Image:         string(image),
+		Error:         queue.err.Error(),
+		Attempts:      1,
+		NextAttemptAt: scheduler.nextDownloadAttemptAt(1, queue.err),
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	tx, err := scheduler.claw.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = DownloadFailures.
+		INSERT(DownloadFailures.MutableColumns).
+		MODEL(failure).
+		RETURNING(DownloadFailures.ID).
+		QueryContext(ctx, tx, &failure)
+	if err != nil {
+		return fmt.Errorf("failed to insert download failure: %w", err)
+	}
+	devices := make([]model.DownloadFailureDevices, 0, len(queue.devices))
+	for _, device := range queue.devices {
+		devices = append(devices, model.DownloadFailureDevices{
+			FailureID: *failure.ID,
+			DeviceID:  *device.ID,
+		})
+	}
+	if len(devices) > 0 {
+		_, err = DownloadFailureDevices.
+			INSERT(DownloadFailureDevices.AllColumns).
+			MODELS(devices).
+			ExecContext(ctx, tx)
+		if err != nil {
+			return fmt.Errorf("failed to insert download failure devices: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// nextDownloadAttemptAt returns when a download that failed with err on the given attempt should be tried again,
+// or nil if it should not be tried again.
+func (scheduler *scheduler) nextDownloadAttemptAt(attempts int64, err error) *types.UnixMilli {
+	cfg := scheduler.config.Scheduler
+	if attempts >= int64(cfg.MaxAttempts) || !isRetryable(err) {
+		return nil
+	}
+	return Ptr(types.NewUnixMilli(time.Now().Add(retryDelay(cfg, attempts, err))))
+}
+
+// startDownloadRetries retries the download failures whose backoff expired every poll interval.
+func (scheduler *scheduler) startDownloadRetries(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval(scheduler.config.Scheduler))
+	defer ticker.Stop()
+	reload := scheduler.reloadSignal.Listener(1)
+	defer reload.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			scheduler.logger.DebugContext(ctx, "download retries stopped")
+			return
+		case <-reload.Ch():
+			ticker.Reset(pollInterval(scheduler.config.Scheduler))
+		case <-ticker.C:
+			if err := scheduler.retryDownloadFailures(ctx); err != nil {
+				scheduler.logger.ErrorContext(ctx, "failed to retry download failures", "error", err)
+			}
+		}
+	}
+}
+
+// retryDownloadFailures retries the download failures that are due, and waits for them to finish.
+func (scheduler *scheduler) retryDownloadFailures(ctx context.Context) error {
+	var failures []model.DownloadFailures
+	queryCtx := logger.ContextWithSkipLog(ctx)
+	queryCtx = otel.ContextWithDatabaseCaller(queryCtx, otel.CurrentCaller())
+	err := SELECT(DownloadFailures.AllColumns).
+		FROM(DownloadFailures).
+		WHERE(DownloadFailures.NextAttemptAt.LT_EQ(Int64(time.Now().UnixMilli()))).
+		ORDER_BY(DownloadFailures.NextAttemptAt.ASC()).
+		QueryContext(queryCtx, scheduler.claw.db, &failures)
+	if err != nil {
+		return fmt.Errorf("failed to query download failures: %w", err)
+	}
+
+	wg := sync.WaitGroup{}
+	weight := leastCommonMultiple / min(int64(scheduler.config.Scheduler.DownloadWorkers), 16)
+	for _, failure := range failures {
+		if err := scheduler.imageSemaphore.Acquire(ctx, weight); err != nil {
+			// context canceled
+			break
+		}
+		wg.Add(1)
+		scheduler.wg.Add(1)
+		go func(failure model.DownloadFailures) {
+			defer scheduler.wg.Done()
+			defer wg.Done()
+			defer scheduler.imageSemaphore.Release(weight)
+			scheduler.retryDownloadFailure(ctx, failure)
+		}(failure)
+	}
+	wg.Wait()
+	return nil
+}
+
+// retryDownloadFailure downloads the image of the failure again to the devices it was assigned to.
+//
+// The failure is removed once the image is downloaded, and the job is marked as completed once it has no
+// failures left. Otherwise the failure is scheduled for another attempt until config.Scheduler.MaxAttempts.
+func (scheduler *scheduler) retryDownloadFailure(ctx context.Context, failure model.DownloadFailures) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	var image source.Image
+	if err := json.Unmarshal([]byte(failure.Image), &image); err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to decode image of download failure", "failure_id", *failure.ID, "error", err)
+		scheduler.deleteDownloadFailure(ctx, failure)
+		return
+	}
+	var src model.Sources
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Sources.AllColumns).
+		FROM(Sources).
+		WHERE(Sources.ID.EQ(Int64(failure.SourceID))).
+		QueryContext(ctx, scheduler.claw.db, &src)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to get source of download failure", "failure_id", *failure.ID, "error", err)
+		return
+	}
+	var devices []model.Devices
+	err = SELECT(Devices.AllColumns).
+		FROM(Devices.INNER_JOIN(DownloadFailureDevices, DownloadFailureDevices.DeviceID.EQ(Devices.ID))).
+		WHERE(
+			DownloadFailureDevices.FailureID.EQ(Int64(*failure.ID)).
+				AND(Devices.IsDisabled.EQ(Int(0))),
+		).
+		QueryContext(ctx, scheduler.claw.db, &devices)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to get devices of download failure", "failure_id", *failure.ID, "error", err)
+		return
+	}
+	if len(devices) == 0 {
+		scheduler.logger.InfoContext(ctx, "no devices left for download failure, dropping it",
+			"failure_id", *failure.ID, "job_id", failure.JobID)
+		scheduler.deleteDownloadFailure(ctx, failure)
+		return
+	}
+
+	err = scheduler.processDownload(ctx, failure.JobID, image, devices, src)
+	if ctx.Err() != nil {
+		// Shutting down. The failure is retried again on next start.
+		return
+	}
+	if err == nil {
+		scheduler.logger.InfoContext(ctx, "download retry succeeded",
+			"failure_id", *failure.ID, "job_id", failure.JobID, "attempts", failure.Attempts+1)
+		scheduler.deleteDownloadFailure(ctx, failure)
+		return
+	}
+
+	failure.Attempts++
+	failure.Error = err.Error()
+	failure.NextAttemptAt = scheduler.nextDownloadAttemptAt(failure.Attempts, err)
+	failure.UpdatedAt = types.UnixMilliNow()
+	scheduler.logger.WarnContext(ctx, "download retry failed",
+		"failure_id", *failure.ID,
+		"job_id", failure.JobID,
+		"attempts", failure.Attempts,
+		"retrying", failure.NextAttemptAt != nil,
+		"error", err,
+	)
+	_, dbErr := DownloadFailures.
+		UPDATE(DownloadFailures.Error, DownloadFailures.Attempts, DownloadFailures.NextAttemptAt, DownloadFailures.UpdatedAt).
+		MODEL(failure).
+		WHERE(DownloadFailures.ID.EQ(Int64(*failure.ID))).
+		ExecContext(ctx, scheduler.claw.db)
+	if dbErr != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to update download failure", "failure_id", *failure.ID, "error", dbErr)
+	}
+	scheduler.webhooks.Dispatch(ctx, WebhookPayload{
+		Event:  WebhookEventImageFailed,
+		Job:    &WebhookJob{ID: failure.JobID},
+		Source: webhookSourceFromModel(src),
+		Image:  webhookImageFromSource(image),
+		Error:  err.Error(),
+	})
+}
+
+// deleteDownloadFailure removes the failure, and marks its job as completed if it was the last failure of the job.
+func (scheduler *scheduler) deleteDownloadFailure(ctx context.Context, failure model.DownloadFailures) {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := DownloadFailures.
+		DELETE().
+		WHERE(DownloadFailures.ID.EQ(Int64(*failure.ID))).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to delete download failure", "failure_id", *failure.ID, "error", err)
+		return
+	}
+	// Going through updateJobStatus notifies the webhooks of the status change.
+	scheduler.updateJobStatus(ctx, failure.JobID, clawv1.JobStatus_JOB_STATUS_COMPLETED, updateJobStatusAttributes{
+		condition: Jobs.Status.EQ(String(clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS.String())).
+			AND(NOT(EXISTS(
+				SELECT(DownloadFailures.ID).
+					FROM(DownloadFailures).
+					WHERE(DownloadFailures.JobID.EQ(Int64(failure.JobID))),
+			))),
+	})
+}
+
+// hasDownloadFailures reports whether the job has images that failed to download.
+func (scheduler *scheduler) hasDownloadFailures(ctx context.Context, job int64) (bool, error) {
+	var failures []model.DownloadFailures
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(DownloadFailures.ID).
+		FROM(DownloadFailures).
+		WHERE(DownloadFailures.JobID.EQ(Int64(job))).
+		LIMIT(1).
+		QueryContext(ctx, scheduler.claw.db, &failures)
+	if err != nil {
+		return false, fmt.Errorf("failed to query download failures: %w", err)
+	}
+	return len(failures) > 0, nil
+}
@@ -0,0 +1,183 @@
+package claw
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+func TestRecordDownloadFailures(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	image := insertTestImage(t, cl, "https://example.com/lake.jpg")
+	src := model.Sources{ID: &image.SourceID}
+	job := insertTestJob(t, cl, image.SourceID, clawv1.JobStatus_JOB_STATUS_RUNNING)
+	device := insertTestDevice(t, cl, "phone")
+
+	cl.scheduler.recordDownloadFailures(ctx, *job.ID, src, []imageQueue{
+		{
+			image:   source.Image{DownloadURL: "https://example.com/retryable.jpg"},
+			devices: []model.Devices{device},
+			err:     &source.StatusError{StatusCode: http.StatusServiceUnavailable},
+		},
+		{
+			image: source.Image{DownloadURL: "https://example.com/missing.jpg"},
+			err:   &source.StatusError{StatusCode: http.StatusNotFound},
+		},
+	})
+
+	failures := listDownloadFailures(t, cl, *job.ID)
+	require.Len(t, failures, 2)
+	retryable, missing := failures[0], failures[1]
+	assert.Equal(t, "https://example.com/retryable.jpg", retryable.DownloadURL)
+	assert.Equal(t, int64(1), retryable.Attempts)
+	assert.NotNil(t, retryable.NextAttemptAt)
+	var stored source.Image
+	require.NoError(t, json.Unmarshal([]byte(retryable.Image), &stored))
+	assert.Equal(t, "https://example.com/retryable.jpg", stored.DownloadURL)
+	var devices []model.DownloadFailureDevices
+	err := SELECT(DownloadFailureDevices.AllColumns).
+		FROM(DownloadFailureDevices).
+		WHERE(DownloadFailureDevices.FailureID.EQ(Int64(*retryable.ID))).
+		QueryContext(ctx, cl.db, &devices)
+	require.NoError(t, err)
+	require.Len(t, devices, 1)
+	assert.Equal(t, *device.ID, devices[0].DeviceID)
+
+	assert.Equal(t, "https://example.com/missing.jpg", missing.DownloadURL)
+	assert.Nil(t, missing.NextAttemptAt, "not retryable failures are not retried")
+
+	has, err := cl.scheduler.hasDownloadFailures(ctx, *job.ID)
+	require.NoError(t, err)
+	assert.True(t, has)
+}
+
+func TestRetryDownloadFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/unavailable.jpg" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte("image " + r.URL.Path))
+	}))
+	defer server.Close()
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	cl.config.Download.TmpDir = t.TempDir()
+	cl.config.Download.SanityCheck.Enabled = false // the test images are tiny
+	cl.config.Webhooks.JobFinished = []config.Webhook{{URL: "https://example.com/webhook"}}
+	ctx := context.Background()
+	image := insertTestImage(t, cl, "https://example.com/existing.jpg")
+	var src model.Sources
+	err := SELECT(Sources.AllColumns).FROM(Sources).WHERE(Sources.ID.EQ(Int64(image.SourceID))).QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	device := insertTestDevice(t, cl, "phone")
+	job := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS)
+	_, err = Jobs.UPDATE(Jobs.FinishedAt).SET(Int64(time.Now().UnixMilli())).
+		WHERE(Jobs.ID.EQ(Int64(*job.ID))).
+		ExecContext(ctx, cl.db)
+	require.NoError(t, err)
+	record := func(name string) model.DownloadFailures {
+		t.Helper()
+		cl.scheduler.recordDownloadFailures(ctx, *job.ID, src, []imageQueue{{
+			image:   source.Image{DownloadURL: server.URL + "/" + name, Filename: name},
+			devices: []model.Devices{device},
+			err:     &source.StatusError{StatusCode: http.StatusServiceUnavailable},
+		}})
+		failures := listDownloadFailures(t, cl, *job.ID)
+		return failures[len(failures)-1]
+	}
+	jobStatus := func() string {
+		t.Helper()
+		var row model.Jobs
+		err := SELECT(Jobs.AllColumns).FROM(Jobs).WHERE(Jobs.ID.EQ(Int64(*job.ID))).QueryContext(ctx, cl.db, &row)
+		require.NoError(t, err)
+		return row.Status
+	}
+	unavailable := record("unavailable.jpg")
+	lake := record("lake.jpg")
+
+	t.Run("failed retry is scheduled again", func(t *testing.T) {
+		cl.scheduler.retryDownloadFailure(ctx, unavailable)
+		failures := listDownloadFailures(t, cl, *job.ID)
+		require.Len(t, failures, 2)
+		assert.Equal(t, int64(2), failures[0].Attempts)
+		assert.Contains(t, failures[0].Error, "503")
+		assert.NotNil(t, failures[0].NextAttemptAt)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS.String(), jobStatus())
+	})
+
+	t.Run("successful retry keeps the job incomplete while failures remain", func(t *testing.T) {
+		cl.scheduler.retryDownloadFailure(ctx, lake)
+		failures := listDownloadFailures(t, cl, *job.ID)
+		require.Len(t, failures, 1)
+		assert.Equal(t, *unavailable.ID, *failures[0].ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED_WITH_ERRORS.String(), jobStatus())
+
+		var imageDevices []model.ImageDevices
+		err := SELECT(ImageDevices.AllColumns).
+			FROM(ImageDevices).
+			WHERE(ImageDevices.DeviceID.EQ(Int64(*device.ID))).
+			QueryContext(ctx, cl.db, &imageDevices)
+		require.NoError(t, err)
+		require.Len(t, imageDevices, 1)
+		assert.FileExists(t, cl.scheduler.absoluteImagePath(imageDevices[0].Path))
+	})
+
+	t.Run("deleting the last failure completes the job and notifies webhooks", func(t *testing.T) {
+		cl.scheduler.deleteDownloadFailure(ctx, unavailable)
+		assert.Empty(t, listDownloadFailures(t, cl, *job.ID))
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), jobStatus())
+
+		require.Len(t, cl.scheduler.webhooks.queue, 1)
+		delivery := <-cl.scheduler.webhooks.queue
+		assert.Equal(t, WebhookEventJobFinished, delivery.event)
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(delivery.payload, &payload))
+		require.NotNil(t, payload.Job)
+		assert.Equal(t, *job.ID, payload.Job.ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_COMPLETED.String(), payload.Job.Status)
+	})
+
+	t.Run("deleting failures does not complete jobs of other statuses", func(t *testing.T) {
+		failed := insertTestJob(t, cl, *src.ID, clawv1.JobStatus_JOB_STATUS_FAILED)
+		cl.scheduler.recordDownloadFailures(ctx, *failed.ID, src, []imageQueue{{
+			image: source.Image{DownloadURL: server.URL + "/failed.jpg"},
+			err:   errors.New("connection reset"),
+		}})
+		failures := listDownloadFailures(t, cl, *failed.ID)
+		require.Len(t, failures, 1)
+		cl.scheduler.deleteDownloadFailure(ctx, failures[0])
+
+		var row model.Jobs
+		err := SELECT(Jobs.AllColumns).FROM(Jobs).WHERE(Jobs.ID.EQ(Int64(*failed.ID))).QueryContext(ctx, cl.db, &row)
+		require.NoError(t, err)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_FAILED.String(), row.Status)
+		assert.Empty(t, cl.scheduler.webhooks.queue)
+	})
+}
+
+func listDownloadFailures(t *testing.T, cl *Claw, job int64) []model.DownloadFailures {
+	t.Helper()
+	var failures []model.DownloadFailures
+	err := SELECT(DownloadFailures.AllColumns).
+		FROM(DownloadFailures).
+		WHERE(DownloadFailures.JobID.EQ(Int64(job))).
+		ORDER_BY(DownloadFailures.ID.ASC()).
+		QueryContext(context.Background(), cl.db, &failures)
+	require.NoError(t, err)
+	return failures
+}
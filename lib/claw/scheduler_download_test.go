@@ -0,0 +1,131 @@
+package claw
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+func TestFreeImagePath(t *testing.T) {
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	taken := filepath.Join(cl.config.Download.BaseDir, "images", "src", "taken.jpg")
+	require.NoError(t, os.MkdirAll(filepath.Dir(taken), 0o755))
+	require.NoError(t, os.WriteFile(taken, []byte("image"), 0o644))
+	hash := "0123456789abcdef0123456789abcdef"
+
+	tests := []struct {
+		name     string
+		filename string
+		want     string
+		wantErr  bool
+	}{
+		{name: "free", filename: "free.jpg", want: "images/src/free.jpg"},
+		{name: "taken", filename: "taken.jpg", want: "images/src/taken_0123456789ab.jpg"},
+		{name: "parent traversal", filename: "../../../.bashrc", wantErr: true},
+		{name: "parent", filename: "..", wantErr: true},
+		{name: "absolute", filename: "/etc/passwd", wantErr: true},
+		{name: "subdirectory", filename: "dir/image.jpg", wantErr: true},
+		{name: "backslash", filename: `..\image.jpg`, wantErr: true},
+		{name: "empty", filename: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cl.scheduler.freeImagePath("src", tt.filename, hash)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDownloadImage_Deduplicates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/first.jpg", "/mirror.jpg":
+			_, _ = w.Write([]byte("same content"))
+		default:
+			_, _ = w.Write([]byte("other content"))
+		}
+	}))
+	defer server.Close()
+	cl := newTestClaw(t)
+	cl.config.Download.BaseDir = t.TempDir()
+	cl.config.Download.TmpDir = t.TempDir()
+	cl.config.Download.SanityCheck.Enabled = false // the test images are tiny
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES("claw.script.v1", "Script", "images.js").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	download := func(name string, existing *model.Images) (model.Images, bool) {
+		t.Helper()
+		image := source.Image{DownloadURL: server.URL + "/" + name, Filename: name, Website: "https://example.com/" + name}
+		row, stored, err := cl.scheduler.downloadImage(ctx, image, src, existing)
+		require.NoError(t, err)
+		return row, stored
+	}
+
+	first, stored := download("first.jpg", nil)
+	require.True(t, stored)
+	assert.FileExists(t, cl.scheduler.absoluteImagePath(first.ImagePath))
+
+	// Identical content from another URL is recorded as a reference instead of stored again.
+	mirror, stored := download("mirror.jpg", nil)
+	assert.False(t, stored)
+	assert.Equal(t, *first.ID, *mirror.ID)
+	var references []model.ImageReferences
+	err = SELECT(ImageReferences.AllColumns).
+		FROM(ImageReferences).
+		WHERE(ImageReferences.ImageID.EQ(Int64(*first.ID))).
+		QueryContext(ctx, cl.db, &references)
+	require.NoError(t, err)
+	require.Len(t, references, 1)
+	assert.Equal(t, server.URL+"/mirror.jpg", references[0].DownloadURL)
+	assert.Equal(t, "https://example.com/mirror.jpg", references[0].PostURL)
+
+	other, stored := download("other.jpg", nil)
+	assert.True(t, stored)
+	assert.NotEqual(t, *first.ID, *other.ID)
+
+	t.Run("existing image is found by download URL and by reference", func(t *testing.T) {
+		for url, want := range map[string]*int64{
+			server.URL + "/first.jpg":   first.ID,
+			server.URL + "/mirror.jpg":  first.ID,
+			server.URL + "/other.jpg":   other.ID,
+			server.URL + "/unknown.jpg": nil,
+		} {
+			existing, err := cl.scheduler.findImageByURL(ctx, url)
+			require.NoError(t, err)
+			if want == nil {
+				assert.Nil(t, existing, url)
+				continue
+			}
+			require.NotNil(t, existing, url)
+			assert.Equal(t, *want, *existing.ID, url)
+		}
+	})
+
+	t.Run("missing file of duplicate is restored", func(t *testing.T) {
+		require.NoError(t, os.Remove(cl.scheduler.absoluteImagePath(first.ImagePath)))
+		restored, stored := download("mirror.jpg", &first)
+		assert.True(t, stored)
+		assert.Equal(t, *first.ID, *restored.ID)
+		assert.FileExists(t, cl.scheduler.absoluteImagePath(first.ImagePath))
+	})
+}
@@ -0,0 +1,98 @@
+package claw
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+const (
+	// heartbeatInterval is how often a running scheduler updates its heartbeat.
+	heartbeatInterval = 10 * time.Second
+	// heartbeatTimeout is the age after which a heartbeat is considered left behind by a scheduler that exited
+	// without stopping.
+	heartbeatTimeout = 3 * heartbeatInterval
+)
+
+// errSchedulerRunning is returned by operations that must not run while a scheduler uses the same database.
+var errSchedulerRunning = errors.New("a scheduler is running on this database, stop the server first")
+
+// startHeartbeat reports the scheduler as running until the returned function is called.
+// The returned function removes the heartbeat, so other processes see the scheduler stopped right away.
+func (scheduler *scheduler) startHeartbeat(ctx context.Context) (stop func()) {
+	instanceID := rand.Text()
+	ctx, cancel := context.WithCancel(ctx)
+	beat := func() {
+		if err := scheduler.beat(ctx, instanceID); err != nil && ctx.Err() == nil {
+			scheduler.logger.ErrorContext(ctx, "failed to update scheduler heartbeat", "error", err)
+		}
+	}
+	// The first heartbeat is written before returning, so the scheduler is never running unnoticed.
+	beat()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(heartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				beat()
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+		ctx := otel.ContextWithDatabaseCaller(context.WithoutCancel(ctx), otel.CurrentCaller())
+		_, err := SchedulerHeartbeats.DELETE().
+			WHERE(SchedulerHeartbeats.InstanceID.EQ(String(instanceID))).
+			ExecContext(ctx, scheduler.claw.db)
+		if err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to remove scheduler heartbeat", "error", err)
+		}
+	}
+}
+
+func (scheduler *scheduler) beat(ctx context.Context, instanceID string) error {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	_, err := SchedulerHeartbeats.INSERT(SchedulerHeartbeats.AllColumns).
+		MODEL(model.SchedulerHeartbeats{
+			InstanceID: instanceID,
+			UpdatedAt:  types.UnixMilliNow(),
+		}).
+		ON_CONFLICT(SchedulerHeartbeats.InstanceID).
+		DO_UPDATE(SET(SchedulerHeartbeats.UpdatedAt.SET(SchedulerHeartbeats.EXCLUDED.UpdatedAt))).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		return fmt.Errorf("failed to upsert scheduler heartbeat: %w", err)
+	}
+	return nil
+}
+
+// checkSchedulerStopped returns errSchedulerRunning if a scheduler updated its heartbeat recently.
+func (s *Claw) checkSchedulerStopped(ctx context.Context) error {
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	var heartbeats []model.SchedulerHeartbeats
+	err := SELECT(SchedulerHeartbeats.AllColumns).
+		FROM(SchedulerHeartbeats).
+		WHERE(SchedulerHeartbeats.UpdatedAt.GT(Int64(time.Now().Add(-heartbeatTimeout).UnixMilli()))).
+		QueryContext(ctx, s.db, &heartbeats)
+	if err != nil {
+		return fmt.Errorf("failed to list scheduler heartbeats: %w", err)
+	}
+	if len(heartbeats) > 0 {
+		return errSchedulerRunning
+	}
+	return nil
+}
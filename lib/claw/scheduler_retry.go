@@ -0,0 +1,127 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// failJob marks the job as failed, or, if err may be temporary and the job has attempts left, puts the job back
+// in the queue to run again after a backoff. See config.Scheduler.MaxAttempts.
+func (scheduler *scheduler) failJob(ctx context.Context, jobRow model.Jobs, err error) {
+	job := *jobRow.ID
+	if ctx.Err() != nil {
+		// Shutting down, or cancelled by the user. See updateJobStatus.
+		//
+		// Errors wrapping context.DeadlineExceeded from timeouts of the source itself, e.g. a http.Client
+		// timeout, are not shutdowns, and go through isRetryable like any other error.
+		return
+	}
+	cfg := scheduler.config.Scheduler
+	if jobRow.Attempt >= int64(cfg.MaxAttempts) || !isRetryable(err) {
+		scheduler.logger.ErrorContext(ctx, "job failed", "job_id", job, "attempt", jobRow.Attempt, "error", err)
+		scheduler.updateJobStatus(ctx, job, clawv1.JobStatus_JOB_STATUS_FAILED, updateJobStatusAttributes{
+			err:        err,
+			finishedAt: Ptr(types.UnixMilliNow()),
+		})
+		return
+	}
+
+	backoff := retryDelay(cfg, jobRow.Attempt, err)
+	nextAttemptAt := types.NewUnixMilli(time.Now().Add(backoff))
+	scheduler.logger.WarnContext(ctx, "job failed, retrying later",
+		"job_id", job,
+		"attempt", jobRow.Attempt,
+		"max_attempts", cfg.MaxAttempts,
+		"backoff", backoff,
+		"error", err,
+	)
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	// Cancelled jobs are final and must not be put back in the queue.
+	_, dbErr := Jobs.
+		UPDATE(Jobs.Status, Jobs.Error, Jobs.Attempt, Jobs.NextAttemptAt).
+		MODEL(model.Jobs{
+			Status:        clawv1.JobStatus_JOB_STATUS_PENDING.String(),
+			Error:         Ptr(err.Error()),
+			Attempt:       jobRow.Attempt + 1,
+			NextAttemptAt: &nextAttemptAt,
+		}).
+		WHERE(
+			Jobs.ID.EQ(Int64(job)).
+				AND(Jobs.Status.NOT_EQ(String(clawv1.JobStatus_JOB_STATUS_CANCELLED.String()))),
+		).
+		ExecContext(ctx, scheduler.claw.db)
+	if dbErr != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to schedule job retry", "job_id", job, "error", dbErr)
+	}
+}
+
+// retryBackoff returns the wait time before the attempt after the given attempt.
+// The backoff doubles on every attempt, up to config.Scheduler.MaxRetryBackoff.
+func retryBackoff(cfg config.Scheduler, attempt int64) time.Duration {
+	backoff := cfg.RetryBackoff
+	if backoff <= 0 {
+		backoff = config.DefaultScheduler().RetryBackoff
+	}
+	maxBackoff := cfg.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = config.DefaultScheduler().MaxRetryBackoff
+	}
+	for i := int64(1); i < attempt && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// retryDelay returns the wait time before the attempt after the given attempt that failed with err.
+// This is the backoff, or the Retry-After of the response if it is longer, up to config.Scheduler.MaxRetryBackoff.
+func retryDelay(cfg config.Scheduler, attempt int64, err error) time.Duration {
+	backoff := retryBackoff(cfg, attempt)
+	var status *source.StatusError
+	if errors.As(err, &status) && status.RetryAfter > backoff {
+		maxBackoff := cfg.MaxRetryBackoff
+		if maxBackoff <= 0 {
+			maxBackoff = config.DefaultScheduler().MaxRetryBackoff
+		}
+		return max(min(status.RetryAfter, maxBackoff), backoff)
+	}
+	return backoff
+}
+
+// isRetryable reports whether an operation that failed with err may succeed when it is tried again later:
+// network errors, timeouts, stalled downloads, and 408, 429, or 5xx responses.
+//
+// Other errors, e.g. invalid parameters or 404 responses, will fail the same way again and are not retryable.
+func isRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var status *source.StatusError
+	if errors.As(err, &status) {
+		return status.Temporary()
+	}
+	var stall *StallError
+	if errors.As(err, &stall) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE)
+}
@@ -0,0 +1,136 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"syscall"
+	"testing"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", fmt.Errorf("failed to fetch: %w", &source.StatusError{Service: "Reddit API", StatusCode: 429}), true},
+		{"server error", &source.StatusError{Service: "Wallhaven API", StatusCode: 503}, true},
+		{"not found", &source.StatusError{Service: "Reddit API", StatusCode: 404}, false},
+		{"stalled download", fmt.Errorf("failed to download: %w", &StallError{Cause: "too slow"}), true},
+		{"dial error", &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}}, true},
+		{"connection reset", fmt.Errorf("failed to read response: %w", syscall.ECONNRESET), true},
+		{"unexpected EOF", fmt.Errorf("failed to decode response: %w", io.ErrUnexpectedEOF), true},
+		{"invalid parameter", errors.New(`invalid parameter "r/": subreddit cannot be empty`), false},
+		{"nil", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, isRetryable(tt.err))
+		})
+	}
+
+	// Shutdowns are handled before errors are classified, but a deadline is a timeout like any other.
+	assert.True(t, isRetryable(context.DeadlineExceeded))
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := config.Scheduler{RetryBackoff: time.Minute, MaxRetryBackoff: 5 * time.Minute}
+	assert.Equal(t, time.Minute, retryBackoff(cfg, 1))
+	assert.Equal(t, 2*time.Minute, retryBackoff(cfg, 2))
+	assert.Equal(t, 4*time.Minute, retryBackoff(cfg, 3))
+	assert.Equal(t, 5*time.Minute, retryBackoff(cfg, 4))
+	assert.Equal(t, 5*time.Minute, retryBackoff(cfg, 100))
+
+	defaults := config.DefaultScheduler()
+	assert.Equal(t, defaults.RetryBackoff, retryBackoff(config.Scheduler{}, 1))
+}
+
+func TestRetryDelay(t *testing.T) {
+	cfg := config.Scheduler{RetryBackoff: time.Minute, MaxRetryBackoff: 30 * time.Minute}
+	rateLimited := func(retryAfter time.Duration) error {
+		return fmt.Errorf("failed to fetch: %w", &source.StatusError{Service: "Reddit API", StatusCode: 429, RetryAfter: retryAfter})
+	}
+	assert.Equal(t, time.Minute, retryDelay(cfg, 1, rateLimited(0)))
+	assert.Equal(t, 2*time.Minute, retryDelay(cfg, 2, rateLimited(30*time.Second)))
+	assert.Equal(t, 10*time.Minute, retryDelay(cfg, 1, rateLimited(10*time.Minute)))
+	assert.Equal(t, 30*time.Minute, retryDelay(cfg, 1, rateLimited(24*time.Hour)))
+	assert.Equal(t, time.Minute, retryDelay(cfg, 1, io.ErrUnexpectedEOF))
+}
+
+func TestFailJob(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES("claw.script.v1", "Script", "timeout.js").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	newJob := func() model.Jobs {
+		var job model.Jobs
+		err := Jobs.INSERT(Jobs.SourceID, Jobs.Status, Jobs.CreatedAt).
+			MODEL(model.Jobs{
+				SourceID:  *src.ID,
+				Status:    clawv1.JobStatus_JOB_STATUS_RUNNING.String(),
+				CreatedAt: types.UnixMilliNow(),
+			}).
+			RETURNING(Jobs.AllColumns).
+			QueryContext(ctx, cl.db, &job)
+		require.NoError(t, err)
+		return job
+	}
+	getJob := func(id int64) model.Jobs {
+		var job model.Jobs
+		err := SELECT(Jobs.AllColumns).FROM(Jobs).WHERE(Jobs.ID.EQ(Int64(id))).QueryContext(ctx, cl.db, &job)
+		require.NoError(t, err)
+		return job
+	}
+	// A timeout of the source itself, e.g. the script timeout, while the scheduler is still running.
+	timeout := fmt.Errorf("script timeout.js did not finish within 1s: %w", context.DeadlineExceeded)
+
+	t.Run("retries wrapped deadline", func(t *testing.T) {
+		job := newJob()
+		cl.scheduler.failJob(ctx, job, timeout)
+		got := getJob(*job.ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_PENDING.String(), got.Status)
+		assert.Equal(t, int64(2), got.Attempt)
+		assert.NotNil(t, got.NextAttemptAt)
+		assert.Nil(t, got.FinishedAt)
+	})
+
+	t.Run("fails wrapped deadline after last attempt", func(t *testing.T) {
+		job := newJob()
+		job.Attempt = int64(cl.config.Scheduler.MaxAttempts)
+		cl.scheduler.failJob(ctx, job, timeout)
+		got := getJob(*job.ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_FAILED.String(), got.Status)
+		assert.NotNil(t, got.FinishedAt)
+		require.NotNil(t, got.Error)
+		assert.Contains(t, *got.Error, "did not finish")
+	})
+
+	t.Run("leaves job alone on shutdown", func(t *testing.T) {
+		job := newJob()
+		shutdown, cancel := context.WithCancel(ctx)
+		cancel()
+		cl.scheduler.failJob(shutdown, job, fmt.Errorf("failed to fetch: %w", shutdown.Err()))
+		got := getJob(*job.ID)
+		assert.Equal(t, clawv1.JobStatus_JOB_STATUS_RUNNING.String(), got.Status)
+		assert.Equal(t, int64(1), got.Attempt)
+	})
+}
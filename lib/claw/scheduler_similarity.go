@@ -0,0 +1,174 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/imagehash"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// errUndecodableImage is returned by perceptualHashFile for files that are not in a supported image format.
+var errUndecodableImage = errors.New("failed to decode image for perceptual hash")
+
+// processPerceptualHash computes and stores the perceptual hashes of the image if they are missing.
+//
+// Images that cannot be decoded are marked as failed, and are not decoded again until their file is
+// downloaded again.
+//
+// Returns the image row with the hashes filled in.
+func (scheduler *scheduler) processPerceptualHash(ctx context.Context, imageRow model.Images, imagePath string) (model.Images, error) {
+	if (imageRow.Dhash != nil && imageRow.Phash != nil) || imageRow.PerceptualHashFailedAt != nil {
+		return imageRow, nil
+	}
+	dhash, phash, err := perceptualHashFile(imagePath)
+	if errors.Is(err, errUndecodableImage) {
+		now := types.UnixMilliNow()
+		ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+		_, dbErr := Images.UPDATE(Images.PerceptualHashFailedAt).
+			SET(now).
+			WHERE(Images.ID.EQ(Int64(*imageRow.ID))).
+			ExecContext(ctx, scheduler.claw.db)
+		if dbErr != nil {
+			return imageRow, errors.Join(err, fmt.Errorf("failed to mark perceptual hash as failed: %w", dbErr))
+		}
+		imageRow.PerceptualHashFailedAt = &now
+		return imageRow, err
+	}
+	if err != nil {
+		return imageRow, err
+	}
+
+	var updated model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = Images.UPDATE(Images.Dhash, Images.Phash).
+		SET(Int64(int64(dhash)), Int64(int64(phash))).
+		WHERE(Images.ID.EQ(Int64(*imageRow.ID))).
+		RETURNING(Images.AllColumns).
+		QueryContext(ctx, scheduler.claw.db, &updated)
+	if err != nil {
+		return imageRow, fmt.Errorf("failed to store perceptual hashes: %w", err)
+	}
+	return updated, nil
+}
+
+// findNearDuplicateOnDevice finds the image assigned to the device that looks the most similar to imageRow,
+// within the configured similarity threshold. Returns nil if there is none.
+func (scheduler *scheduler) findNearDuplicateOnDevice(ctx context.Context, imageRow model.Images, deviceID int64) (*model.Images, int, error) {
+	var candidates []model.Images
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Images.AllColumns).
+		FROM(Images.INNER_JOIN(ImageDevices, ImageDevices.ImageID.EQ(Images.ID))).
+		WHERE(
+			ImageDevices.DeviceID.EQ(Int64(deviceID)).
+				AND(Images.ID.NOT_EQ(Int64(*imageRow.ID))).
+				AND(Images.Phash.IS_NOT_NULL()).
+				AND(Images.Dhash.IS_NOT_NULL()),
+		).
+		QueryContext(ctx, scheduler.claw.db, &candidates)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list images of device %d: %w", deviceID, err)
+	}
+
+	var (
+		closest  *model.Images
+		distance = scheduler.config.Similarity.Threshold + 1
+	)
+	for i, candidate := range candidates {
+		if d, ok := imageDistance(imageRow, candidate); ok && d < distance {
+			closest, distance = &candidates[i], d
+		}
+	}
+	return closest, distance, nil
+}
+
+// imageDistance returns the perceptual distance between two images, which is the larger of their
+// dHash and pHash Hamming distances. Requiring both hashes to agree keeps false positives low.
+//
+// ok is false if either image has no perceptual hashes.
+func imageDistance(a, b model.Images) (distance int, ok bool) {
+	if a.Dhash == nil || a.Phash == nil || b.Dhash == nil || b.Phash == nil {
+		return 0, false
+	}
+	return max(
+		imagehash.Distance(uint64(*a.Dhash), uint64(*b.Dhash)),
+		imagehash.Distance(uint64(*a.Phash), uint64(*b.Phash)),
+	), true
+}
+
+// hashBand is a range of bits of a perceptual hash.
+type hashBand struct {
+	shift, width int
+}
+
+func (band hashBand) value(hash uint64) uint64 {
+	if band.width >= 64 {
+		return hash
+	}
+	return hash >> band.shift & (1<<band.width - 1)
+}
+
+// similarityBands splits the 64 bits of a hash into maxDistance+1 bands. Hashes within maxDistance of each other
+// differ in at most maxDistance bits, so they are equal in at least one band. Only images that share a band of
+// their dHash need to be compared to find every near-duplicate.
+//
+// Returns nil if maxDistance is too large to rule out any image, so every image has to be compared.
+func similarityBands(maxDistance int) []hashBand {
+	if maxDistance >= 64 {
+		return nil
+	}
+	n := max(maxDistance, 0) + 1
+	bands := make([]hashBand, n)
+	shift := 0
+	for i := range bands {
+		width := 64 / n
+		if i < 64%n {
+			width++
+		}
+		bands[i] = hashBand{shift: shift, width: width}
+		shift += width
+	}
+	return bands
+}
+
+// similarHashCondition matches the images that share a band of their dHash with dhash, which includes every image
+// within maxDistance of it. See similarityBands.
+func similarHashCondition(dhash uint64, maxDistance int) BoolExpression {
+	bands := similarityBands(maxDistance)
+	if bands == nil {
+		return Bool(true)
+	}
+	conds := make([]BoolExpression, len(bands))
+	for i, band := range bands {
+		if band.width >= 64 {
+			conds[i] = Images.Dhash.EQ(Int64(int64(dhash)))
+			continue
+		}
+		conds[i] = Images.Dhash.
+			BIT_SHIFT_RIGHT(Int(int64(band.shift))).
+			BIT_AND(Int(int64(1<<band.width - 1))).
+			EQ(Int(int64(band.value(dhash))))
+	}
+	return OR(conds...)
+}
+
+// perceptualHashFile decodes the image file and computes its dHash and pHash.
+func perceptualHashFile(name string) (dhash, phash uint64, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to open image for perceptual hash: %w", err)
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: %w", errUndecodableImage, err)
+	}
+	return imagehash.DHash(img), imagehash.PHash(img), nil
+}
@@ -0,0 +1,88 @@
+package claw
+
+import (
+	"context"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+func TestProcessPerceptualHash_Undecodable(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	var src model.Sources
+	err := Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES("claw.script.v1", "Script", "images.js").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, cl.db, &src)
+	require.NoError(t, err)
+	var imageRow model.Images
+	err = Images.INSERT(Images.SourceID, Images.DownloadURL, Images.Width, Images.Height, Images.Filesize,
+		Images.ThumbnailPath, Images.ImagePath, Images.CreatedAt, Images.UpdatedAt).
+		MODEL(model.Images{
+			SourceID:    *src.ID,
+			DownloadURL: "https://example.com/broken.jpg",
+			ImagePath:   "images/broken.jpg",
+			CreatedAt:   types.UnixMilliNow(),
+			UpdatedAt:   types.UnixMilliNow(),
+		}).
+		RETURNING(Images.AllColumns).
+		QueryContext(ctx, cl.db, &imageRow)
+	require.NoError(t, err)
+	imagePath := filepath.Join(t.TempDir(), "broken.jpg")
+	require.NoError(t, os.WriteFile(imagePath, []byte("not an image"), 0o644))
+
+	updated, err := cl.scheduler.processPerceptualHash(ctx, imageRow, imagePath)
+	require.ErrorIs(t, err, errUndecodableImage)
+	require.NotNil(t, updated.PerceptualHashFailedAt)
+
+	var stored model.Images
+	err = SELECT(Images.AllColumns).FROM(Images).WHERE(Images.ID.EQ(Int64(*imageRow.ID))).QueryContext(ctx, cl.db, &stored)
+	require.NoError(t, err)
+	assert.NotNil(t, stored.PerceptualHashFailedAt)
+	assert.Nil(t, stored.Phash)
+
+	// The failure is remembered, so the file is not decoded again.
+	again, err := cl.scheduler.processPerceptualHash(ctx, stored, imagePath)
+	require.NoError(t, err)
+	assert.Nil(t, again.Phash)
+}
+
+func TestSimilarityBands(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	for _, maxDistance := range []int{0, 1, 8, 20, 63} {
+		bands := similarityBands(maxDistance)
+		require.Len(t, bands, maxDistance+1)
+		width := 0
+		for _, band := range bands {
+			assert.Equal(t, width, band.shift)
+			width += band.width
+		}
+		assert.Equal(t, 64, width, "bands cover every bit")
+
+		for range 1000 {
+			a := rng.Uint64()
+			b := flipBits(rng, a, maxDistance)
+			shared := slices.ContainsFunc(bands, func(band hashBand) bool { return band.value(a) == band.value(b) })
+			assert.True(t, shared, "hashes %064b and %064b within %d share no band", a, b, maxDistance)
+		}
+	}
+	assert.Nil(t, similarityBands(64), "every hash is within 64 of every other")
+}
+
+// flipBits flips up to n random bits of hash.
+func flipBits(rng *rand.Rand, hash uint64, n int) uint64 {
+	for range rng.IntN(n + 1) {
+		hash ^= 1 << rng.IntN(64)
+	}
+	return hash
+}
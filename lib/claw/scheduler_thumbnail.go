@@ -0,0 +1,205 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register GIF decoder
+	"image/jpeg"
+	_ "image/png" // register PNG decoder
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/otel"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+// maxThumbnailSourceSize limits how much is read from a source provided thumbnail URL.
+const maxThumbnailSourceSize = 20 << 20 // 20 MiB
+
+// processThumbnail creates a JPEG thumbnail for the image under <base_dir>/thumbnails and stores
+// its path in the database.
+//
+// The source's ThumbnailURL is used when given. If it's empty or cannot be fetched, the thumbnail
+// is generated from the downloaded image itself.
+//
+// An existing thumbnail is kept unless the image file changed after it was made, e.g. because the image
+// was downloaded again. The database is only updated when the thumbnail changed.
+func (scheduler *scheduler) processThumbnail(ctx context.Context, imageRow model.Images, image source.Image, imagePath string) error {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	imageID := *imageRow.ID
+	relativePath := path.Join("thumbnails", strconv.FormatInt(imageID, 10)+".jpg")
+	thumbnailPath := filepath.Join(scheduler.config.Download.BaseDir, filepath.FromSlash(relativePath))
+
+	stale, err := thumbnailStale(thumbnailPath, imagePath)
+	if err != nil {
+		return err
+	}
+	if !stale && imageRow.ThumbnailPath == relativePath {
+		return nil
+	}
+	if stale {
+		if err := os.MkdirAll(filepath.Dir(thumbnailPath), 0o755); err != nil {
+			return fmt.Errorf("failed to create thumbnail directory: %w", err)
+		}
+		if err := scheduler.writeThumbnail(ctx, image, imagePath, thumbnailPath); err != nil {
+			return err
+		}
+	}
+
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	// updated_at changes with the thumbnail, so clients caching thumbnails by it fetch the new one.
+	_, err = Images.UPDATE(Images.ThumbnailPath, Images.UpdatedAt).
+		SET(String(relativePath), types.UnixMilliNow()).
+		WHERE(Images.ID.EQ(Int64(imageID))).
+		ExecContext(ctx, scheduler.claw.db)
+	if err != nil {
+		return fmt.Errorf("failed to update thumbnail path: %w", err)
+	}
+	return nil
+}
+
+// thumbnailStale reports whether the thumbnail is missing, or older than the image file it was made from.
+func thumbnailStale(thumbnailPath, imagePath string) (bool, error) {
+	thumbnailInfo, err := os.Stat(thumbnailPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to stat thumbnail file: %w", err)
+	}
+	imageInfo, err := os.Stat(imagePath)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat image file: %w", err)
+	}
+	return imageInfo.ModTime().After(thumbnailInfo.ModTime()), nil
+}
+
+// writeThumbnail writes the thumbnail to a temporary file next to thumbnailPath, then renames it
+// so readers never see a partially written thumbnail.
+func (scheduler *scheduler) writeThumbnail(ctx context.Context, image source.Image, imagePath, thumbnailPath string) (err error) {
+	out, err := os.CreateTemp(filepath.Dir(thumbnailPath), ".thumbnail_*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary thumbnail file: %w", err)
+	}
+	defer func() {
+		_ = out.Close()
+		if err != nil {
+			_ = os.Remove(out.Name())
+		}
+	}()
+
+	opts := scheduler.config.Download.Thumbnail
+	generated := false
+	if image.ThumbnailURL != "" {
+		if err := scheduler.thumbnailFromURL(ctx, image.ThumbnailURL, out); err != nil {
+			scheduler.logger.WarnContext(ctx, "failed to use source thumbnail, generating from image instead",
+				"thumbnail_url", image.ThumbnailURL, "error", err)
+			if err := resetFile(out); err != nil {
+				return err
+			}
+		} else {
+			generated = true
+		}
+	}
+	if !generated {
+		in, err := os.Open(imagePath)
+		if err != nil {
+			return fmt.Errorf("failed to open image for thumbnail: %w", err)
+		}
+		defer in.Close()
+		if err := makeThumbnail(in, out, opts.MaxWidth, opts.MaxHeight, opts.Quality); err != nil {
+			return fmt.Errorf("failed to generate thumbnail from %q: %w", imagePath, err)
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return fmt.Errorf("failed to write thumbnail file: %w", err)
+	}
+	if err := os.Rename(out.Name(), thumbnailPath); err != nil {
+		return fmt.Errorf("failed to move thumbnail to final location: %w", err)
+	}
+	return nil
+}
+
+// thumbnailFromURL fetches the thumbnail provided by the source and re-encodes it
+// so it follows the configured thumbnail size and format.
+func (scheduler *scheduler) thumbnailFromURL(ctx context.Context, url string, out io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create thumbnail request: %w", err)
+	}
+	resp, err := scheduler.httpclient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to execute thumbnail request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("thumbnail download failed with status: %d", resp.StatusCode)
+	}
+	opts := scheduler.config.Download.Thumbnail
+	return makeThumbnail(io.LimitReader(resp.Body, maxThumbnailSourceSize), out, opts.MaxWidth, opts.MaxHeight, opts.Quality)
+}
+
+// makeThumbnail decodes a JPEG, PNG, GIF, or WebP image from r, scales it down to fit within maxWidth x maxHeight
+// while keeping the aspect ratio, and encodes it as JPEG to w.
+//
+// Images are never scaled up. Non-positive bounds and quality fall back to the defaults.
+// Transparent areas are rendered on a white background.
+func makeThumbnail(r io.Reader, w io.Writer, maxWidth, maxHeight, quality int) error {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+	if maxWidth <= 0 {
+		maxWidth = 480
+	}
+	if maxHeight <= 0 {
+		maxHeight = 960
+	}
+	if quality <= 0 || quality > 100 {
+		quality = 80
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return errors.New("image has no pixels")
+	}
+	scale := min(float64(maxWidth)/float64(width), float64(maxHeight)/float64(height), 1)
+	dstWidth := max(int(float64(width)*scale), 1)
+	dstHeight := max(int(float64(height)*scale), 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	if err := jpeg.Encode(w, dst, &jpeg.Options{Quality: quality}); err != nil {
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	return nil
+}
+
+func resetFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate thumbnail file: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind thumbnail file: %w", err)
+	}
+	return nil
+}
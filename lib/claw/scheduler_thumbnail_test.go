@@ -0,0 +1,82 @@
+package claw
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeTestPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		img.Set(x, 0, color.NRGBA{R: 255, A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestMakeThumbnail(t *testing.T) {
+	tests := []struct {
+		name                  string
+		width, height         int
+		wantWidth, wantHeight int
+		maxWidth, maxHeight   int
+	}{
+		{name: "landscape is bound by width", width: 1920, height: 1080, maxWidth: 480, maxHeight: 960, wantWidth: 480, wantHeight: 270},
+		{name: "portrait is bound by height", width: 1080, height: 3840, maxWidth: 480, maxHeight: 960, wantWidth: 270, wantHeight: 960},
+		{name: "small images are not upscaled", width: 160, height: 90, maxWidth: 480, maxHeight: 960, wantWidth: 160, wantHeight: 90},
+		{name: "zero bounds use defaults", width: 4800, height: 2400, wantWidth: 480, wantHeight: 240},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := makeThumbnail(bytes.NewReader(encodeTestPNG(t, tt.width, tt.height)), &out, tt.maxWidth, tt.maxHeight, 80)
+			require.NoError(t, err)
+
+			cfg, format, err := image.DecodeConfig(&out)
+			require.NoError(t, err)
+			assert.Equal(t, "jpeg", format)
+			assert.Equal(t, tt.wantWidth, cfg.Width)
+			assert.Equal(t, tt.wantHeight, cfg.Height)
+		})
+	}
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		err := makeThumbnail(bytes.NewReader([]byte("not an image")), &bytes.Buffer{}, 480, 960, 80)
+		assert.Error(t, err)
+	})
+}
+
+func TestThumbnailStale(t *testing.T) {
+	dir := t.TempDir()
+	imagePath := filepath.Join(dir, "image.png")
+	thumbnailPath := filepath.Join(dir, "thumbnail.jpg")
+	require.NoError(t, os.WriteFile(imagePath, []byte("image"), 0o644))
+
+	stale, err := thumbnailStale(thumbnailPath, imagePath)
+	require.NoError(t, err)
+	assert.True(t, stale, "missing thumbnail")
+
+	require.NoError(t, os.WriteFile(thumbnailPath, []byte("thumbnail"), 0o644))
+	past := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(imagePath, past, past))
+	stale, err = thumbnailStale(thumbnailPath, imagePath)
+	require.NoError(t, err)
+	assert.False(t, stale, "thumbnail newer than image")
+
+	future := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(imagePath, future, future))
+	stale, err = thumbnailStale(thumbnailPath, imagePath)
+	require.NoError(t, err)
+	assert.True(t, stale, "image replaced after thumbnail")
+}
@@ -1,13 +1,19 @@
 package claw
 
-import "sync"
+import (
+	"context"
+	"sync"
+)
 
+// tracker keeps track of jobs that are queued or running.
+//
+// Running jobs have their cancel function attached so they can be stopped by the user.
 type tracker struct {
 	sync.Map
 }
 
 func (qt *tracker) Add(jobID int64) {
-	qt.Store(jobID, struct{}{})
+	qt.Store(jobID, context.CancelCauseFunc(nil))
 }
 
 func (qt *tracker) Remove(jobID int64) {
@@ -19,6 +25,27 @@ func (qt *tracker) Exists(jobID int64) bool {
 	return exists
 }
 
+// SetCancel attaches the cancel function of a job that has started running.
+func (qt *tracker) SetCancel(jobID int64, cancel context.CancelCauseFunc) {
+	qt.Store(jobID, cancel)
+}
+
+// Cancel cancels the job with the given cause.
+//
+// Returns false if the job is not tracked or has not started running yet.
+func (qt *tracker) Cancel(jobID int64, cause error) bool {
+	value, ok := qt.Load(jobID)
+	if !ok {
+		return false
+	}
+	cancel, _ := value.(context.CancelCauseFunc)
+	if cancel == nil {
+		return false
+	}
+	cancel(cause)
+	return true
+}
+
 func (qt *tracker) List() []int64 {
 	var jobs []int64
 	qt.Range(func(key, value any) bool {
@@ -0,0 +1,192 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/lib/logger"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// sourceWatch is a running watcher of a source.
+type sourceWatch struct {
+	name      string
+	parameter string
+	cancel    context.CancelFunc
+	done      chan struct{}
+	err       error // set before done is closed
+}
+
+// startWatchers runs the watchers of enabled sources whose backends implement [source.SourceWatcher],
+// and creates a job for the source whenever its watcher notices new images.
+//
+// Watchers are synced with the sources whenever sources are changed (scheduleSignal), and every poll interval
+// in case the database was modified outside of claw. Watchers that failed are restarted then too.
+// All watchers are restarted when config is reloaded.
+func (scheduler *scheduler) startWatchers(ctx context.Context) {
+	reload := scheduler.reloadSignal.Listener(1)
+	defer reload.Close()
+	rearm := scheduler.scheduleSignal.Listener(1)
+	defer rearm.Close()
+
+	watches := map[int64]*sourceWatch{}
+	defer func() {
+		for _, watch := range watches {
+			watch.cancel()
+		}
+	}()
+
+	for {
+		if err := scheduler.syncWatchers(ctx, watches); err != nil {
+			scheduler.logger.ErrorContext(ctx, "failed to sync source watchers", "error", err)
+		}
+		timer := time.NewTimer(pollInterval(scheduler.config.Scheduler))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			scheduler.logger.DebugContext(ctx, "source watchers stopped")
+			return
+		case <-reload.Ch():
+			scheduler.logger.InfoContext(ctx, "restarting source watchers after config reload")
+			for id, watch := range watches {
+				watch.cancel()
+				delete(watches, id)
+			}
+		case <-rearm.Ch():
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// syncWatchers starts watchers for enabled sources that have none, and stops watchers of sources
+// that were disabled, deleted, or changed.
+func (scheduler *scheduler) syncWatchers(ctx context.Context, watches map[int64]*sourceWatch) error {
+	var sources []model.Sources
+	queryCtx := logger.ContextWithSkipLog(ctx)
+	queryCtx = otel.ContextWithDatabaseCaller(queryCtx, otel.CurrentCaller())
+	err := SELECT(Sources.AllColumns).
+		FROM(Sources).
+		WHERE(Sources.IsDisabled.EQ(Int(0))).
+		QueryContext(queryCtx, scheduler.claw.db, &sources)
+	if err != nil {
+		return fmt.Errorf("failed to query sources: %w", err)
+	}
+
+	enabled := make(map[int64]model.Sources, len(sources))
+	for _, src := range sources {
+		if _, ok := scheduler.backends[src.Name].(source.SourceWatcher); ok {
+			enabled[*src.ID] = src
+		}
+	}
+	for id, watch := range watches {
+		src, ok := enabled[id]
+		if ok && src.Name == watch.name && src.Parameter == watch.parameter && !watch.failed() {
+			continue
+		}
+		watch.cancel()
+		delete(watches, id)
+	}
+	for id, src := range enabled {
+		if _, ok := watches[id]; !ok {
+			watches[id] = scheduler.startWatcher(ctx, src)
+		}
+	}
+	return nil
+}
+
+// startWatcher runs the watcher of the source until the returned watch is cancelled.
+func (scheduler *scheduler) startWatcher(ctx context.Context, src model.Sources) *sourceWatch {
+	ctx, cancel := context.WithCancel(ctx)
+	watch := &sourceWatch{
+		name:      src.Name,
+		parameter: src.Parameter,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+	watcher := scheduler.backends[src.Name].(source.SourceWatcher)
+	go func() {
+		defer close(watch.done)
+		scheduler.logger.DebugContext(ctx, "starting source watcher", "source_id", *src.ID, "source_name", src.Name)
+		watch.err = watcher.Watch(ctx, src.Parameter, func() {
+			if _, err := scheduler.createWatchJob(ctx, src); err != nil {
+				scheduler.logger.ErrorContext(ctx, "failed to create job for watched source", "source_id", *src.ID, "error", err)
+			}
+		})
+		if watch.err != nil && ctx.Err() == nil {
+			scheduler.logger.ErrorContext(ctx, "source watcher stopped, restarting later", "source_id", *src.ID, "source_name", src.Name, "error", watch.err)
+		}
+	}()
+	return watch
+}
+
+// failed reports whether the watcher stopped with an error.
+func (watch *sourceWatch) failed() bool {
+	select {
+	case <-watch.done:
+		return watch.err != nil
+	default:
+		return false
+	}
+}
+
+// createWatchJob inserts a pending job for a source whose watcher noticed new images.
+//
+// If the source already has a job that has not started yet, that job will see the new images,
+// so no job is created and nil job is returned.
+func (scheduler *scheduler) createWatchJob(ctx context.Context, src model.Sources) (*model.Jobs, error) {
+	ctx, span := otel.Start(ctx)
+	defer span.End()
+
+	tx, err := scheduler.claw.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var pending []model.Jobs
+	queryCtx := otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = SELECT(Jobs.ID).
+		FROM(Jobs).
+		WHERE(
+			Jobs.SourceID.EQ(Int64(*src.ID)).
+				AND(Jobs.Status.EQ(String(clawv1.JobStatus_JOB_STATUS_PENDING.String()))).
+				AND(Jobs.FinishedAt.IS_NULL()),
+		).
+		LIMIT(1).
+		QueryContext(queryCtx, tx, &pending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query pending jobs of source: %w", err)
+	}
+	if len(pending) > 0 {
+		scheduler.logger.DebugContext(ctx, "watched source already has pending job", "source_id", *src.ID, "pending_job_id", *pending[0].ID)
+		return nil, nil
+	}
+
+	var job model.Jobs
+	queryCtx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err = Jobs.
+		INSERT(Jobs.SourceID, Jobs.Status, Jobs.CreatedAt).
+		MODEL(model.Jobs{
+			SourceID:  *src.ID,
+			Status:    clawv1.JobStatus_JOB_STATUS_PENDING.String(),
+			CreatedAt: types.UnixMilliNow(),
+		}).
+		RETURNING(Jobs.AllColumns).
+		QueryContext(queryCtx, tx, &job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to insert job: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	scheduler.logger.InfoContext(ctx, "watched source changed, job created", "job_id", *job.ID, "source_id", *src.ID)
+	return &job, nil
+}
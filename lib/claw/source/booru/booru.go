@@ -0,0 +1,178 @@
+package booru
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const (
+	DanbooruSourceName = "claw.danbooru.v1"
+	GelbooruSourceName = "claw.gelbooru.v1"
+	MoebooruSourceName = "claw.moebooru.v1"
+
+	userAgent = "claw/1.0"
+
+	// minScheduleGap is how far apart runs of schedules of the same source should be.
+	minScheduleGap = 2 * time.Minute
+)
+
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Config configures the site a booru source talks to.
+type Config struct {
+	// BaseURL of the site, e.g. https://danbooru.donmai.us. Empty means the default site of the API.
+	//
+	// Parameters that are a full URL to another site use that site instead.
+	BaseURL string
+	// Login is the username, or the numeric user ID for Gelbooru. Optional.
+	Login string
+	// APIKey is the API key, or the password hash for Moebooru. Optional.
+	APIKey string
+}
+
+var (
+	_ source.Source           = (*Booru)(nil)
+	_ source.SourceBackfiller = (*Booru)(nil)
+)
+
+// Booru is a source for a family of imageboards sharing the same API.
+//
+// Use [NewDanbooru], [NewGelbooru], or [NewMoebooru] to create one.
+type Booru struct {
+	source.UnimplementedSource
+
+	Client Doer
+	// Config returns the current configuration. It is called for every request, so configuration
+	// changes are picked up without restarting.
+	//
+	// Optional. If nil, the default site is used anonymously.
+	Config func() Config
+
+	flavor flavor
+}
+
+// NewDanbooru creates a source for Danbooru and sites running Danbooru, e.g. Safebooru (donmai.us).
+func NewDanbooru(client Doer, config func() Config) *Booru {
+	return &Booru{Client: client, Config: config, flavor: danbooru}
+}
+
+// NewGelbooru creates a source for Gelbooru and sites running Gelbooru, e.g. Safebooru (safebooru.org).
+func NewGelbooru(client Doer, config func() Config) *Booru {
+	return &Booru{Client: client, Config: config, flavor: gelbooru}
+}
+
+// NewMoebooru creates a source for sites running Moebooru, e.g. yande.re and Konachan.
+func NewMoebooru(client Doer, config func() Config) *Booru {
+	return &Booru{Client: client, Config: config, flavor: moebooru}
+}
+
+func (bo *Booru) currentConfig() Config {
+	var cfg Config
+	if bo.Config != nil {
+		cfg = bo.Config()
+	}
+	cfg.BaseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = bo.flavor.defaultBaseURL
+	}
+	return cfg
+}
+
+// Name returns the unique kind identifier for the source.
+func (bo *Booru) Name() string {
+	return bo.flavor.name
+}
+
+// DisplayName returns the human-readable name for the source.
+func (bo *Booru) DisplayName() string {
+	return bo.flavor.displayName
+}
+
+// Author returns the author name.
+func (bo *Booru) Author() string {
+	return "Claw"
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (bo *Booru) AuthorURL() string {
+	return "https://github.com/tigorlazuardi/claw"
+}
+
+func (bo *Booru) Description() string {
+	return fmt.Sprintf(`Fetches images matching a tag search from %s.
+
+Tags, dimensions, and file sizes are taken from the post metadata. Posts rated questionable or explicit are marked as NSFW.
+Videos, Flash, and Ugoira posts are skipped.`, bo.flavor.sites)
+}
+
+func (bo *Booru) RequireParameter() bool {
+	return true
+}
+
+func (bo *Booru) DefaultCountback() int {
+	return bo.flavor.pageSize * 2
+}
+
+// ParameterHelp returns the help string for the parameter.
+// Markdown formatting is supported, but any Javascript will be stripped.
+func (bo *Booru) ParameterHelp() string {
+	return fmt.Sprintf(`Supported parameter formats for %s:
+
+- A tag search, exactly as typed in the search box of the site, e.g. %s
+- A full URL to a search on the site, e.g. %s
+
+Tag searches use the site configured in the server configuration (default: %s).
+Full URLs may point to any site running the same software, e.g. a mirror or a self-hosted instance.
+
+Sites may limit how many tags can be searched at once without an account.
+Configure a login and API key in the server configuration to raise the limit.
+`, bo.flavor.name, "`"+bo.flavor.exampleTags+"`", bo.flavor.listURL(bo.flavor.defaultBaseURL, bo.flavor.exampleTags), bo.flavor.defaultBaseURL)
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+//
+// This is usually a very short string to show as a hint for the user.
+func (bo *Booru) ParameterPlaceholder() string {
+	return "Tags or search URL, e.g. " + bo.flavor.exampleTags
+}
+
+// HaveScheduleConflictCheck returns whether this source has custom schedule conflict check.
+func (bo *Booru) HaveScheduleConflictCheck() bool {
+	return true
+}
+
+// ScheduleConflictCheck warns when the next run is within minScheduleGap of runs of other schedules.
+//
+// Booru APIs are rate limited per IP address or account, and a run may request several pages
+// in quick succession, so runs close to each other may get throttled.
+func (bo *Booru) ScheduleConflictCheck(req source.ScheduleConflictCheckRequest) string {
+	s := &strings.Builder{}
+	for _, sch := range req.Schedules {
+		for _, scheduleRun := range sch.NextRuns {
+			diff := req.UserNextRun.Sub(scheduleRun).Abs()
+			if diff >= minScheduleGap {
+				continue
+			}
+			if s.Len() == 0 {
+				s.WriteString("⚠️ **Schedule Conflict Warning** ⚠️\n\n")
+				fmt.Fprintf(s, "The following schedules are too close to each other on the next run. This may cause rate limit issues with the %s API.\n\n", bo.flavor.displayName)
+				fmt.Fprintf(s, "Your next run is at %s (server adjusted time), which will conflict with:\n\n", req.UserNextRun.Format(time.RFC850))
+			}
+			fmt.Fprintf(s, "- Next schedule run at %s (difference: %s) from parameter %q.\n",
+				scheduleRun.Format(time.RFC850),
+				diff.String(),
+				sch.Source.Parameter,
+			)
+		}
+	}
+	if s.Len() > 0 {
+		fmt.Fprintf(s, "\nTo avoid the API rate limit issues, set your new schedule(s) at least %s apart from existing ones.\n", minScheduleGap)
+	}
+	return s.String()
+}
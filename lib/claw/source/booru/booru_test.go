@@ -0,0 +1,360 @@
+package booru
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		want  search
+	}{
+		{
+			name:  "tags",
+			param: "  Scenery   rating:general scenery ",
+			want:  search{BaseURL: "https://danbooru.donmai.us", Tags: "scenery rating:general"},
+		},
+		{
+			name:  "danbooru URL",
+			param: "https://danbooru.donmai.us/posts?tags=scenery+rating%3Ageneral&z=1",
+			want:  search{BaseURL: "https://danbooru.donmai.us", Tags: "scenery rating:general"},
+		},
+		{
+			name:  "gelbooru URL",
+			param: "https://Gelbooru.com/index.php?page=post&s=list&tags=sky",
+			want:  search{BaseURL: "https://gelbooru.com", Tags: "sky"},
+		},
+		{
+			name:  "self-hosted URL with port",
+			param: "http://booru.local:3000/post?tags=landscape",
+			want:  search{BaseURL: "http://booru.local:3000", Tags: "landscape"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearch(tt.param, "https://danbooru.donmai.us")
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	for _, param := range []string{"", "   ", "https://danbooru.donmai.us/posts", "https:///posts?tags=sky"} {
+		_, err := parseSearch(param, "https://danbooru.donmai.us")
+		assert.Error(t, err, "parameter %q", param)
+	}
+}
+
+// fakeBooru serves fixture responses and records the queries of the API requests.
+type fakeBooru struct {
+	body    string
+	status  int
+	queries []map[string]string
+	// auth is the basic auth of the requests, as "login:password".
+	auth []string
+}
+
+func (f *fakeBooru) start(t *testing.T, apiPath string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, apiPath, r.URL.Path)
+		assert.Equal(t, userAgent, r.UserAgent())
+		query := map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		f.queries = append(f.queries, query)
+		if login, password, ok := r.BasicAuth(); ok {
+			f.auth = append(f.auth, login+":"+password)
+		}
+		if f.status != 0 {
+			w.WriteHeader(f.status)
+		}
+		_, _ = w.Write([]byte(f.body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+const danbooruFixture = `[
+	{
+		"id": 101,
+		"created_at": "2024-05-01T10:00:00.000-04:00",
+		"image_width": 3840,
+		"image_height": 2160,
+		"file_size": 4096000,
+		"file_url": "https://cdn.donmai.us/original/aa/bb/aabb.png",
+		"preview_file_url": "https://cdn.donmai.us/180x180/aa/bb/aabb.jpg",
+		"rating": "g",
+		"tag_string": "1girl scenery sky",
+		"tag_string_artist": "some_artist"
+	},
+	{
+		"id": 102,
+		"created_at": "2024-05-01T09:00:00.000-04:00",
+		"image_width": 1920,
+		"image_height": 1080,
+		"file_size": 20000000,
+		"file_url": "https://cdn.donmai.us/original/cc/dd/ccdd.mp4",
+		"rating": "g",
+		"tag_string": "animated"
+	},
+	{
+		"id": 103,
+		"created_at": "2024-05-01T08:00:00.000-04:00",
+		"image_width": 1000,
+		"image_height": 1500,
+		"rating": "e",
+		"tag_string": "banned_artist"
+	},
+	{
+		"id": 104,
+		"created_at": "2024-05-01T07:00:00.000-04:00",
+		"image_width": 1200,
+		"image_height": 800,
+		"file_size": 300000,
+		"file_url": "https://cdn.donmai.us/original/ee/ff/eeff.jpg",
+		"rating": "q",
+		"tag_string": "scenery"
+	}
+]`
+
+func TestRun_Danbooru(t *testing.T) {
+	api := &fakeBooru{body: danbooruFixture}
+	baseURL := api.start(t, "/posts.json")
+	bo := NewDanbooru(http.DefaultClient, func() Config {
+		return Config{BaseURL: baseURL + "/", Login: "someone", APIKey: "secret"}
+	})
+
+	resp, err := bo.Run(context.Background(), source.Request{Parameter: "Scenery", Countback: 500})
+	require.NoError(t, err)
+
+	// The short page ends the run, even though countback is not used up.
+	require.Len(t, api.queries, 1)
+	assert.Equal(t, map[string]string{"tags": "scenery", "limit": "200", "page": "1"}, api.queries[0])
+	assert.Equal(t, []string{"someone:secret"}, api.auth)
+
+	// The video and the post without a file are skipped.
+	require.Len(t, resp.Images, 2)
+	first := resp.Images[0]
+	assert.Equal(t, "https://cdn.donmai.us/original/aa/bb/aabb.png", first.DownloadURL)
+	assert.Equal(t, "https://cdn.donmai.us/180x180/aa/bb/aabb.jpg", first.ThumbnailURL)
+	assert.Equal(t, int64(3840), first.Width)
+	assert.Equal(t, int64(2160), first.Height)
+	assert.Equal(t, int64(4096000), first.Filesize)
+	assert.Equal(t, []string{"1girl", "scenery", "sky"}, first.Tags)
+	assert.Equal(t, "some_artist", first.Author)
+	assert.Equal(t, baseURL+"/posts?tags=some_artist", first.AuthorURL)
+	assert.Equal(t, baseURL+"/posts/101", first.Website)
+	assert.Equal(t, "127.0.0.1_101.png", first.Filename)
+	assert.True(t, first.PostedAt.Equal(time.Date(2024, 5, 1, 14, 0, 0, 0, time.UTC)))
+	assert.False(t, first.NSFW)
+
+	assert.True(t, resp.Images[1].NSFW)
+}
+
+func TestRunPage_Danbooru(t *testing.T) {
+	api := &fakeBooru{body: danbooruFixture}
+	baseURL := api.start(t, "/posts.json")
+	bo := NewDanbooru(http.DefaultClient, func() Config { return Config{BaseURL: baseURL} })
+
+	resp, err := bo.RunPage(context.Background(), source.PageRequest{Parameter: "scenery", PageToken: "3"})
+	require.NoError(t, err)
+	require.Len(t, api.queries, 1)
+	assert.Equal(t, "3", api.queries[0]["page"])
+	assert.Equal(t, "200", api.queries[0]["limit"])
+	assert.Len(t, resp.Images, 2)
+	// The short page is the last page.
+	assert.Empty(t, resp.NextPageToken)
+
+	_, err = bo.RunPage(context.Background(), source.PageRequest{Parameter: "scenery", PageToken: "zero"})
+	require.ErrorContains(t, err, "invalid page token")
+}
+
+func TestRun_Gelbooru(t *testing.T) {
+	api := &fakeBooru{body: `{
+		"@attributes": {"limit": 2, "offset": 0, "count": 5},
+		"post": [
+			{
+				"id": 7,
+				"created_at": "Wed May 01 10:00:00 -0500 2024",
+				"width": 2560,
+				"height": 1440,
+				"file_url": "https://img3.gelbooru.com/images/aa/bb/aabb.jpg",
+				"preview_url": "https://img3.gelbooru.com/thumbnails/aa/bb/thumbnail_aabb.jpg",
+				"rating": "sensitive",
+				"tags": "sky  clouds",
+				"owner": "uploader"
+			},
+			{
+				"id": 6,
+				"created_at": "Wed May 01 09:00:00 -0500 2024",
+				"width": 800,
+				"height": 600,
+				"file_url": "https://img3.gelbooru.com/images/cc/dd/ccdd.jpeg",
+				"rating": "explicit",
+				"tags": "sky"
+			}
+		]
+	}`}
+	baseURL := api.start(t, "/index.php")
+	bo := NewGelbooru(http.DefaultClient, func() Config {
+		return Config{Login: "1234", APIKey: "secret"}
+	})
+
+	// Searches on other sites than the configured one (gelbooru.com) are sent without credentials.
+	resp, err := bo.Run(context.Background(), source.Request{
+		Parameter: baseURL + "/index.php?page=post&s=list&tags=sky",
+		Countback: 4,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, api.queries, 1)
+	assert.Equal(t, "0", api.queries[0]["pid"])
+	assert.Equal(t, "4", api.queries[0]["limit"])
+	assert.Equal(t, "dapi", api.queries[0]["page"])
+	assert.NotContains(t, api.queries[0], "user_id")
+	assert.NotContains(t, api.queries[0], "api_key")
+
+	require.Len(t, resp.Images, 2)
+	first := resp.Images[0]
+	assert.Equal(t, int64(2560), first.Width)
+	assert.Equal(t, int64(0), first.Filesize)
+	assert.Equal(t, []string{"sky", "clouds"}, first.Tags)
+	assert.Equal(t, "uploader", first.Author)
+	assert.Equal(t, baseURL+"/index.php?page=post&s=list&tags=user%3Auploader", first.AuthorURL)
+	assert.Equal(t, baseURL+"/index.php?page=post&s=view&id=7", first.Website)
+	assert.True(t, first.PostedAt.Equal(time.Date(2024, 5, 1, 15, 0, 0, 0, time.UTC)))
+	assert.False(t, first.NSFW, "sensitive is not NSFW")
+	assert.True(t, resp.Images[1].NSFW)
+}
+
+func TestRun_MoebooruPaging(t *testing.T) {
+	api := &fakeBooru{body: `[
+		{"id": 2, "created_at": 1714557600, "width": 1920, "height": 1080, "file_size": 1000, "file_url": "https://files.yande.re/image/a/yande.re%202.png", "rating": "s", "tags": "landscape", "author": "someone"},
+		{"id": 1, "created_at": 1714554000, "width": 1920, "height": 1080, "file_size": 2000, "file_url": "https://files.yande.re/image/b/yande.re%201.jpg", "rating": "q", "tags": "landscape"}
+	]`}
+	baseURL := api.start(t, "/post.json")
+	bo := NewMoebooru(http.DefaultClient, func() Config {
+		return Config{BaseURL: baseURL, Login: "someone", APIKey: "hash"}
+	})
+	bo.flavor.pageSize = 2
+
+	resp, err := bo.Run(context.Background(), source.Request{Parameter: "landscape", Countback: 5})
+	require.NoError(t, err)
+
+	// Every page is full, so the run stops when countback is used up: 2 + 2 + 1 posts.
+	require.Len(t, api.queries, 3)
+	for i, query := range api.queries {
+		assert.Equal(t, map[string]string{
+			"tags": "landscape", "limit": []string{"2", "2", "1"}[i], "page": []string{"1", "2", "3"}[i],
+			"login": "someone", "password_hash": "hash",
+		}, query)
+	}
+	require.Len(t, resp.Images, 6)
+	assert.Equal(t, int64(1000), resp.Images[0].Filesize)
+	assert.Equal(t, baseURL+"/post/show/2", resp.Images[0].Website)
+	assert.False(t, resp.Images[0].NSFW)
+	assert.True(t, resp.Images[1].NSFW)
+}
+
+func TestValidateTransformParameter(t *testing.T) {
+	t.Run("normalizes tags and URLs", func(t *testing.T) {
+		api := &fakeBooru{body: danbooruFixture}
+		baseURL := api.start(t, "/posts.json")
+		bo := NewDanbooru(http.DefaultClient, func() Config { return Config{BaseURL: baseURL} })
+
+		got, err := bo.ValidateTransformParameter(context.Background(), " Scenery  Sky ")
+		require.NoError(t, err)
+		assert.Equal(t, "scenery sky", got)
+		assert.Equal(t, "1", api.queries[0]["limit"])
+
+		got, err = bo.ValidateTransformParameter(context.Background(), baseURL+"/posts?tags=Scenery")
+		require.NoError(t, err)
+		assert.Equal(t, "scenery", got, "URLs to the configured site are normalized to tags")
+
+		bo.Config = nil
+		got, err = bo.ValidateTransformParameter(context.Background(), baseURL+"/posts?tags=Scenery")
+		require.NoError(t, err)
+		assert.Equal(t, baseURL+"/posts?tags=scenery", got)
+	})
+
+	t.Run("reports API errors", func(t *testing.T) {
+		api := &fakeBooru{
+			status: http.StatusUnprocessableEntity,
+			body:   `{"success":false,"error":"PostQuery::TagLimitError","message":"You cannot search for more than 2 tags at a time."}`,
+		}
+		baseURL := api.start(t, "/posts.json")
+		bo := NewDanbooru(http.DefaultClient, func() Config { return Config{BaseURL: baseURL} })
+
+		_, err := bo.ValidateTransformParameter(context.Background(), "a b c")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "You cannot search for more than 2 tags at a time.")
+	})
+
+	t.Run("rejects searches without posts", func(t *testing.T) {
+		api := &fakeBooru{body: `{"@attributes": {"limit": 1, "offset": 0, "count": 0}}`}
+		baseURL := api.start(t, "/index.php")
+		bo := NewGelbooru(http.DefaultClient, func() Config { return Config{BaseURL: baseURL} })
+
+		_, err := bo.ValidateTransformParameter(context.Background(), "no_such_tag")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "no posts found")
+	})
+}
+
+func TestScheduleConflictCheck(t *testing.T) {
+	bo := NewDanbooru(http.DefaultClient, nil)
+	next := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	req := source.ScheduleConflictCheckRequest{
+		UserNextRun: next,
+		Schedules: []source.Schedule{
+			{Source: model.Sources{Parameter: "sky"}, NextRuns: []time.Time{next.Add(-time.Minute), next.Add(time.Hour)}},
+			{Source: model.Sources{Parameter: "scenery"}, NextRuns: []time.Time{next.Add(5 * time.Minute)}},
+		},
+	}
+	got := bo.ScheduleConflictCheck(req)
+	assert.Contains(t, got, `from parameter "sky"`)
+	assert.NotContains(t, got, `"scenery"`)
+
+	req.Schedules = req.Schedules[1:]
+	assert.Empty(t, bo.ScheduleConflictCheck(req))
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestRun_CredentialsNotInErrors(t *testing.T) {
+	var requests []*http.Request
+	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		return nil, errors.New("connection refused")
+	})}
+	config := func() Config {
+		return Config{BaseURL: "https://booru.example", Login: "someone", APIKey: "secret"}
+	}
+	for _, bo := range []*Booru{NewDanbooru(client, config), NewGelbooru(client, config), NewMoebooru(client, config)} {
+		t.Run(bo.Name(), func(t *testing.T) {
+			requests = nil
+			_, err := bo.Run(context.Background(), source.Request{Parameter: "scenery", Countback: 10})
+			require.ErrorContains(t, err, "connection refused")
+			assert.NotContains(t, err.Error(), "secret")
+			// The credentials are still sent.
+			require.Len(t, requests, 1)
+			_, password, ok := requests[0].BasicAuth()
+			assert.True(t, ok && password == "secret" || strings.Contains(requests[0].URL.RawQuery, "secret"))
+		})
+	}
+}
@@ -0,0 +1,257 @@
+package booru
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// post is the metadata of a post, common to all booru APIs.
+type post struct {
+	ID         int64
+	FileURL    string
+	PreviewURL string
+	Width      int64
+	Height     int64
+	// Filesize in bytes. 0 if the API does not report it.
+	Filesize int64
+	// Rating as reported by the API, e.g. "s", "q", "general", or "explicit".
+	Rating string
+	Tags   []string
+	// Artists are the artist tags of the post. Only reported by Danbooru.
+	Artists []string
+	// Uploader is the name of the user who uploaded the post.
+	Uploader  string
+	CreatedAt time.Time
+}
+
+// flavor describes the API of a booru software.
+type flavor struct {
+	name           string
+	displayName    string
+	sites          string
+	defaultBaseURL string
+	exampleTags    string
+	// pageSize is the largest number of posts the API returns per page.
+	pageSize int
+	// basicAuth is set for APIs that take the credentials with HTTP basic auth. Otherwise postsURL adds them
+	// to the URL.
+	basicAuth bool
+
+	// postsURL returns the API URL of a page of posts matching tags. Pages start at 1.
+	// Credentials in cfg are added to the URL when set, unless basicAuth is set.
+	postsURL func(baseURL string, cfg Config, tags string, page, limit int) string
+	// decodePosts decodes a successful response of postsURL.
+	decodePosts func(baseURL string, body []byte) ([]post, error)
+	// postURL returns the URL of the web page of a post.
+	postURL func(baseURL string, id int64) string
+	// listURL returns the URL of the web page of a tag search.
+	listURL func(baseURL, tags string) string
+}
+
+// https://danbooru.donmai.us/wiki_pages/help:api
+var danbooru = flavor{
+	name:           DanbooruSourceName,
+	displayName:    "Danbooru",
+	sites:          "Danbooru or a site running Danbooru",
+	defaultBaseURL: "https://danbooru.donmai.us",
+	exampleTags:    "scenery rating:general",
+	pageSize:       200,
+	basicAuth:      true,
+	postsURL: func(baseURL string, _ Config, tags string, page, limit int) string {
+		q := url.Values{}
+		q.Set("tags", tags)
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("page", strconv.Itoa(page))
+		return baseURL + "/posts.json?" + q.Encode()
+	},
+	decodePosts: func(baseURL string, body []byte) ([]post, error) {
+		var resp []struct {
+			ID              int64     `json:"id"`
+			CreatedAt       time.Time `json:"created_at"`
+			ImageWidth      int64     `json:"image_width"`
+			ImageHeight     int64     `json:"image_height"`
+			FileSize        int64     `json:"file_size"`
+			FileURL         string    `json:"file_url"`
+			PreviewFileURL  string    `json:"preview_file_url"`
+			Rating          string    `json:"rating"`
+			TagString       string    `json:"tag_string"`
+			TagStringArtist string    `json:"tag_string_artist"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		posts := make([]post, 0, len(resp))
+		for _, p := range resp {
+			posts = append(posts, post{
+				ID:         p.ID,
+				FileURL:    p.FileURL,
+				PreviewURL: p.PreviewFileURL,
+				Width:      p.ImageWidth,
+				Height:     p.ImageHeight,
+				Filesize:   p.FileSize,
+				Rating:     p.Rating,
+				Tags:       strings.Fields(p.TagString),
+				Artists:    strings.Fields(p.TagStringArtist),
+				CreatedAt:  p.CreatedAt,
+			})
+		}
+		return posts, nil
+	},
+	postURL: func(baseURL string, id int64) string {
+		return fmt.Sprintf("%s/posts/%d", baseURL, id)
+	},
+	listURL: func(baseURL, tags string) string {
+		return baseURL + "/posts?tags=" + url.QueryEscape(tags)
+	},
+}
+
+// https://gelbooru.com/index.php?page=wiki&s=view&id=18780
+var gelbooru = flavor{
+	name:           GelbooruSourceName,
+	displayName:    "Gelbooru",
+	sites:          "Gelbooru or a site running Gelbooru",
+	defaultBaseURL: "https://gelbooru.com",
+	exampleTags:    "scenery rating:general",
+	pageSize:       100,
+	postsURL: func(baseURL string, cfg Config, tags string, page, limit int) string {
+		q := url.Values{}
+		q.Set("page", "dapi")
+		q.Set("s", "post")
+		q.Set("q", "index")
+		q.Set("json", "1")
+		q.Set("tags", tags)
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("pid", strconv.Itoa(page-1))
+		if cfg.Login != "" && cfg.APIKey != "" {
+			q.Set("user_id", cfg.Login)
+			q.Set("api_key", cfg.APIKey)
+		}
+		return baseURL + "/index.php?" + q.Encode()
+	},
+	decodePosts: func(baseURL string, body []byte) ([]post, error) {
+		type gelbooruPost struct {
+			ID         int64  `json:"id"`
+			CreatedAt  string `json:"created_at"`
+			Width      int64  `json:"width"`
+			Height     int64  `json:"height"`
+			FileURL    string `json:"file_url"`
+			PreviewURL string `json:"preview_url"`
+			Directory  string `json:"directory"`
+			Image      string `json:"image"`
+			Rating     string `json:"rating"`
+			Tags       string `json:"tags"`
+			Owner      string `json:"owner"`
+		}
+		var resp []gelbooruPost
+		body = bytes.TrimSpace(body)
+		switch {
+		case len(body) == 0:
+			// Older versions answer searches without results with an empty body.
+		case body[0] == '[':
+			// Older versions, e.g. safebooru.org, answer with a plain array.
+			if err := json.Unmarshal(body, &resp); err != nil {
+				return nil, err
+			}
+		default:
+			var wrapped struct {
+				Post []gelbooruPost `json:"post"`
+			}
+			if err := json.Unmarshal(body, &wrapped); err != nil {
+				return nil, err
+			}
+			resp = wrapped.Post
+		}
+
+		posts := make([]post, 0, len(resp))
+		for _, p := range resp {
+			fileURL := p.FileURL
+			if fileURL == "" && p.Directory != "" && p.Image != "" {
+				fileURL = fmt.Sprintf("%s/images/%s/%s", baseURL, p.Directory, p.Image)
+			}
+			createdAt, _ := time.Parse(time.RubyDate, p.CreatedAt)
+			posts = append(posts, post{
+				ID:         p.ID,
+				FileURL:    fileURL,
+				PreviewURL: p.PreviewURL,
+				Width:      p.Width,
+				Height:     p.Height,
+				Rating:     p.Rating,
+				Tags:       strings.Fields(p.Tags),
+				Uploader:   p.Owner,
+				CreatedAt:  createdAt,
+			})
+		}
+		return posts, nil
+	},
+	postURL: func(baseURL string, id int64) string {
+		return fmt.Sprintf("%s/index.php?page=post&s=view&id=%d", baseURL, id)
+	},
+	listURL: func(baseURL, tags string) string {
+		return baseURL + "/index.php?page=post&s=list&tags=" + url.QueryEscape(tags)
+	},
+}
+
+// https://yande.re/help/api
+var moebooru = flavor{
+	name:           MoebooruSourceName,
+	displayName:    "Moebooru",
+	sites:          "a site running Moebooru, e.g. yande.re or Konachan",
+	defaultBaseURL: "https://yande.re",
+	exampleTags:    "landscape rating:safe",
+	pageSize:       100,
+	postsURL: func(baseURL string, cfg Config, tags string, page, limit int) string {
+		q := url.Values{}
+		q.Set("tags", tags)
+		q.Set("limit", strconv.Itoa(limit))
+		q.Set("page", strconv.Itoa(page))
+		if cfg.Login != "" && cfg.APIKey != "" {
+			q.Set("login", cfg.Login)
+			q.Set("password_hash", cfg.APIKey)
+		}
+		return baseURL + "/post.json?" + q.Encode()
+	},
+	decodePosts: func(baseURL string, body []byte) ([]post, error) {
+		var resp []struct {
+			ID         int64  `json:"id"`
+			CreatedAt  int64  `json:"created_at"`
+			Width      int64  `json:"width"`
+			Height     int64  `json:"height"`
+			FileSize   int64  `json:"file_size"`
+			FileURL    string `json:"file_url"`
+			PreviewURL string `json:"preview_url"`
+			Rating     string `json:"rating"`
+			Tags       string `json:"tags"`
+			Author     string `json:"author"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return nil, err
+		}
+		posts := make([]post, 0, len(resp))
+		for _, p := range resp {
+			posts = append(posts, post{
+				ID:         p.ID,
+				FileURL:    p.FileURL,
+				PreviewURL: p.PreviewURL,
+				Width:      p.Width,
+				Height:     p.Height,
+				Filesize:   p.FileSize,
+				Rating:     p.Rating,
+				Tags:       strings.Fields(p.Tags),
+				Uploader:   p.Author,
+				CreatedAt:  time.Unix(p.CreatedAt, 0),
+			})
+		}
+		return posts, nil
+	},
+	postURL: func(baseURL string, id int64) string {
+		return fmt.Sprintf("%s/post/show/%d", baseURL, id)
+	},
+	listURL: func(baseURL, tags string) string {
+		return baseURL + "/post?tags=" + url.QueryEscape(tags)
+	},
+}
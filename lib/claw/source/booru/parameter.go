@@ -0,0 +1,90 @@
+package booru
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// search is a parsed booru parameter.
+type search struct {
+	// BaseURL of the site to search, without trailing slash.
+	BaseURL string
+	// Tags is the tag query, lowercased and separated by single spaces.
+	Tags string
+}
+
+// parseSearch parses a parameter that is either a tag query for the site at defaultBaseURL,
+// or a full URL to a tag search on any site.
+func parseSearch(param, defaultBaseURL string) (search, error) {
+	raw := strings.TrimSpace(param)
+	if raw == "" {
+		return search{}, errors.New("parameter cannot be empty")
+	}
+	if !strings.HasPrefix(raw, "http://") && !strings.HasPrefix(raw, "https://") {
+		return search{BaseURL: defaultBaseURL, Tags: normalizeTags(raw)}, nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return search{}, fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if u.Host == "" {
+		return search{}, fmt.Errorf("URL %q has no host", raw)
+	}
+	tags := normalizeTags(u.Query().Get("tags"))
+	if tags == "" {
+		return search{}, fmt.Errorf("URL %q is not a tag search, it must contain a tags= query", raw)
+	}
+	return search{BaseURL: u.Scheme + "://" + strings.ToLower(u.Host), Tags: tags}, nil
+}
+
+// normalizeTags lowercases the tags of a query and removes duplicates and extra whitespace.
+func normalizeTags(query string) string {
+	var tags []string
+	for _, tag := range strings.Fields(strings.ToLower(query)) {
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return strings.Join(tags, " ")
+}
+
+// parameter returns the normalized parameter of the search: only the tags for searches on the configured site,
+// and the URL of the search for other sites.
+func (se search) parameter(f flavor, cfg Config) string {
+	if se.onSite(cfg) {
+		return se.Tags
+	}
+	return f.listURL(se.BaseURL, se.Tags)
+}
+
+// onSite reports whether the search is on the configured site.
+func (se search) onSite(cfg Config) bool {
+	return strings.EqualFold(se.BaseURL, cfg.BaseURL)
+}
+
+// ValidateTransformParameter validates the tag query against the site and normalizes it.
+//
+// Accepted inputs are a tag query, e.g. "scenery rating:general", or a full URL to a tag search,
+// e.g. "https://danbooru.donmai.us/posts?tags=scenery". URLs to the configured site are normalized
+// to the tag query. The site must return at least one post for the query.
+func (bo *Booru) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	cfg := bo.currentConfig()
+	se, err := parseSearch(param, cfg.BaseURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s parameter: %w\n\n%s", bo.flavor.displayName, err, bo.ParameterHelp())
+	}
+
+	posts, err := bo.fetchPosts(ctx, cfg, se, 1, 1)
+	if err != nil {
+		return "", fmt.Errorf("failed to search %q on %s: %w", se.Tags, se.BaseURL, err)
+	}
+	if len(posts) == 0 {
+		return "", fmt.Errorf("no posts found for %q on %s, check the tags for typos", se.Tags, se.BaseURL)
+	}
+	return se.parameter(bo.flavor, cfg), nil
+}
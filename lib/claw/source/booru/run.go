@@ -0,0 +1,267 @@
+package booru
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// maxResponseSize limits how much of an API response is read.
+const maxResponseSize = 32 << 20
+
+// imageExtensions are the file types fetched. Videos, Flash, and Ugoira archives are skipped.
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif"}
+
+// Run runs the source to fetch image Metadata based on the given request.
+//
+// Countback is the number of posts to look through, starting from the newest post matching the tags.
+func (bo *Booru) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	cfg := bo.currentConfig()
+	se, err := parseSearch(request.Parameter, cfg.BaseURL)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+
+	countback := request.Countback
+	if countback <= 0 {
+		countback = bo.DefaultCountback()
+	}
+
+	var images source.Images
+	for page := 1; countback > 0; page++ {
+		limit := min(countback, bo.flavor.pageSize)
+		posts, err := bo.fetchPosts(ctx, cfg, se, page, limit)
+		if err != nil {
+			return source.Response{}, fmt.Errorf("failed to fetch %s posts: %w", bo.flavor.displayName, err)
+		}
+		for _, p := range posts {
+			if image, ok := bo.convertPost(se.BaseURL, p, request); ok {
+				images = append(images, image)
+			}
+		}
+
+		countback -= len(posts)
+		// A short page is the last page.
+		if len(posts) < limit {
+			break
+		}
+	}
+
+	return source.Response{Images: images}, nil
+}
+
+// RunPage fetches a page of posts for backfill jobs, starting from the newest post. Page tokens are page numbers.
+//
+// Some sites limit how deep searches can go, e.g. Danbooru does not list pages past 1000 for anonymous users,
+// so a backfill may not reach the oldest posts.
+func (bo *Booru) RunPage(ctx context.Context, request source.PageRequest) (source.PageResponse, error) {
+	cfg := bo.currentConfig()
+	se, err := parseSearch(request.Parameter, cfg.BaseURL)
+	if err != nil {
+		return source.PageResponse{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	page := 1
+	if request.PageToken != "" {
+		page, err = strconv.Atoi(request.PageToken)
+		if err != nil || page < 1 {
+			return source.PageResponse{}, fmt.Errorf("invalid page token %q", request.PageToken)
+		}
+	}
+
+	posts, err := bo.fetchPosts(ctx, cfg, se, page, bo.flavor.pageSize)
+	if err != nil {
+		return source.PageResponse{}, fmt.Errorf("failed to fetch %s posts: %w", bo.flavor.displayName, err)
+	}
+	req := source.Request{Parameter: request.Parameter, FilenameMaxLength: request.FilenameMaxLength}
+	var images source.Images
+	for _, p := range posts {
+		if image, ok := bo.convertPost(se.BaseURL, p, req); ok {
+			images = append(images, image)
+		}
+	}
+
+	var next string
+	// A short page is the last page.
+	if len(posts) >= bo.flavor.pageSize {
+		next = strconv.Itoa(page + 1)
+	}
+	return source.PageResponse{Images: images, NextPageToken: next}, nil
+}
+
+// credentialParams are the query parameters of the API URLs that hold secrets.
+var credentialParams = []string{"api_key", "password_hash"}
+
+// redactCredentials replaces the secrets in the query of rawURL.
+func redactCredentials(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "(invalid URL)"
+	}
+	q := u.Query()
+	redacted := false
+	for _, param := range credentialParams {
+		if q.Has(param) {
+			q.Set(param, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawURL
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// fetchPosts fetches a page of posts. Pages start at 1.
+//
+// Credentials are only sent to the configured site.
+func (bo *Booru) fetchPosts(ctx context.Context, cfg Config, se search, page, limit int) ([]post, error) {
+	if !se.onSite(cfg) {
+		cfg.Login, cfg.APIKey = "", ""
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, bo.flavor.postsURL(se.BaseURL, cfg, se.Tags, page, limit), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+	if bo.flavor.basicAuth && cfg.Login != "" && cfg.APIKey != "" {
+		req.SetBasicAuth(cfg.Login, cfg.APIKey)
+	}
+
+	resp, err := bo.Client.Do(req)
+	if err != nil {
+		// Errors of the client contain the URL, which may contain the credentials, and end up in job errors and logs.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = redactCredentials(urlErr.URL)
+		}
+		return nil, fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &source.StatusError{
+			Service:    bo.flavor.displayName + " API",
+			StatusCode: resp.StatusCode,
+			Message:    apiErrorMessage(body),
+			RetryAfter: source.RetryAfter(resp.Header),
+		}
+	}
+
+	posts, err := bo.flavor.decodePosts(se.BaseURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode response, is %s running %s? %w", se.BaseURL, bo.flavor.displayName, err)
+	}
+	return posts, nil
+}
+
+// apiErrorMessage extracts the error message from an error response body.
+func apiErrorMessage(body []byte) string {
+	var resp struct {
+		// Danbooru
+		Message string `json:"message"`
+		// Moebooru
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &resp); err == nil {
+		if msg := cmp.Or(resp.Message, resp.Reason); msg != "" {
+			return msg
+		}
+	}
+	msg := strings.TrimSpace(string(body))
+	if len(msg) > 200 || strings.HasPrefix(msg, "<") {
+		// Probably an HTML error page.
+		return "no error message"
+	}
+	return msg
+}
+
+// convertPost converts a post to a source.Image. Posts without a downloadable image are skipped.
+func (bo *Booru) convertPost(baseURL string, p post, request source.Request) (source.Image, bool) {
+	if p.FileURL == "" {
+		// Danbooru hides the files of some posts, e.g. banned artists, from anonymous users.
+		return source.Image{}, false
+	}
+	fileURL := absoluteURL(p.FileURL)
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return source.Image{}, false
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	if !slices.Contains(imageExtensions, ext) {
+		return source.Image{}, false
+	}
+
+	image := source.Image{
+		DownloadURL:  fileURL,
+		Width:        p.Width,
+		Height:       p.Height,
+		Filesize:     p.Filesize,
+		Website:      bo.flavor.postURL(baseURL, p.ID),
+		ThumbnailURL: absoluteURL(p.PreviewURL),
+		PostedAt:     p.CreatedAt,
+		Filename:     generateFilename(baseURL, p.ID, ext, request.FilenameMaxLength),
+		Tags:         p.Tags,
+		NSFW:         isNSFW(p.Rating),
+	}
+	switch {
+	case len(p.Artists) > 0:
+		image.Author = strings.Join(p.Artists, ", ")
+		image.AuthorURL = bo.flavor.listURL(baseURL, p.Artists[0])
+	case p.Uploader != "":
+		image.Author = p.Uploader
+		image.AuthorURL = bo.flavor.listURL(baseURL, "user:"+p.Uploader)
+	}
+	return image, true
+}
+
+// absoluteURL adds the scheme to protocol-relative URLs, which older Gelbooru versions return.
+func absoluteURL(u string) string {
+	if strings.HasPrefix(u, "//") {
+		return "https:" + u
+	}
+	return u
+}
+
+// isNSFW reports whether a rating is questionable or explicit.
+//
+// Danbooru and Moebooru report ratings as a single letter (g, s, q, e), Gelbooru as a word
+// (general, sensitive, questionable, explicit). Sensitive and safe are not NSFW.
+func isNSFW(rating string) bool {
+	switch strings.ToLower(rating) {
+	case "q", "e", "questionable", "explicit":
+		return true
+	}
+	return false
+}
+
+// generateFilename generates a filename using the format <host>_<post_id><ext>.
+func generateFilename(baseURL string, id int64, ext string, maxLength int) string {
+	host := baseURL
+	if u, err := url.Parse(baseURL); err == nil {
+		host = u.Hostname()
+	}
+	maxLength = source.FilenameMaxLength(maxLength)
+	name := fmt.Sprintf("%s_%d", host, id)
+	if len(name)+len(ext) > maxLength {
+		// The post ID is what makes the name unique, so the host is cut first.
+		name = name[min(len(name)+len(ext)-maxLength, len(name)):]
+	}
+	return name + ext
+}
@@ -0,0 +1,124 @@
+package feed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const (
+	SourceName = "claw.feed.v1"
+
+	userAgent = "claw/1.0"
+)
+
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+var _ source.Source = (*Feed)(nil)
+
+// Feed fetches images from RSS, Atom, and Media RSS feeds.
+type Feed struct {
+	source.UnimplementedSource
+
+	Client Doer
+}
+
+// Name returns the unique kind identifier for the source.
+func (fe *Feed) Name() string {
+	return SourceName
+}
+
+// DisplayName returns the human-readable name for the source.
+func (fe *Feed) DisplayName() string {
+	return "RSS / Atom Feed"
+}
+
+// Author returns the author name.
+func (fe *Feed) Author() string {
+	return "Claw"
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (fe *Feed) AuthorURL() string {
+	return "https://github.com/tigorlazuardi/claw"
+}
+
+func (fe *Feed) Description() string {
+	return `Fetches images from an RSS, Atom, or Media RSS feed.
+
+Images are taken from the media:content and enclosure elements of each item. Items without either
+use the first image in their content instead. Items rated "adult" in Media RSS are marked as NSFW.`
+}
+
+func (fe *Feed) RequireParameter() bool {
+	return true
+}
+
+// DefaultCountback returns the number of feed items to look through. Most feeds contain fewer items.
+func (fe *Feed) DefaultCountback() int {
+	return 50
+}
+
+const helpString = /*markdown*/
+`The URL of an RSS, Atom, or Media RSS feed, e.g. https://example.com/feed.xml
+
+Every image in an item is fetched:
+
+- media:content elements with an image type, including those in a media:group.
+- enclosure elements, or Atom links with rel="enclosure", with an image type.
+- The first image in the item content, if the item has none of the above.
+
+The link, author, and publish date of the item are used as the website, author, and post date of the images.
+Countback is the number of items to look through, newest first.
+`
+
+// ParameterHelp returns the help string for the parameter.
+// Markdown formatting is supported, but any Javascript will be stripped.
+func (fe *Feed) ParameterHelp() string {
+	return helpString
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+//
+// This is usually a very short string to show as a hint for the user.
+func (fe *Feed) ParameterPlaceholder() string {
+	return "Feed URL, e.g. https://example.com/feed.xml"
+}
+
+// ValidateTransformParameter checks that the parameter is the URL of a feed that can be fetched and parsed.
+func (fe *Feed) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	feedURL, err := parseFeedURL(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid feed parameter: %w\n\n%s", err, helpString)
+	}
+	if _, err := fe.fetchFeed(ctx, feedURL); err != nil {
+		return "", err
+	}
+	return feedURL.String(), nil
+}
+
+func parseFeedURL(param string) (*url.URL, error) {
+	raw := strings.TrimSpace(param)
+	if raw == "" {
+		return nil, errors.New("parameter cannot be empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid URL %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("%q must be an http:// or https:// URL", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("URL %q has no host", raw)
+	}
+	u.Fragment = ""
+	return u, nil
+}
@@ -0,0 +1,179 @@
+package feed
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const rssFixture = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:media="http://search.yahoo.com/mrss/"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:dc="http://purl.org/dc/elements/1.1/">
+<channel>
+	<title>Artist Gallery</title>
+	<item>
+		<title>Sunset &amp;amp; Sea</title>
+		<link>https://art.example.com/posts/1</link>
+		<dc:creator>Painter</dc:creator>
+		<pubDate>Wed, 01 May 2024 10:00:00 +0000</pubDate>
+		<category>landscape</category>
+		<category>sea</category>
+		<media:group>
+			<media:content url="https://cdn.example.com/1/full.png" medium="image" width="3840" height="2160" fileSize="5000000"/>
+			<media:content url="https://cdn.example.com/1/video.mp4" medium="video" width="1920" height="1080"/>
+			<media:thumbnail url="https://cdn.example.com/1/thumb.jpg"/>
+		</media:group>
+		<enclosure url="https://cdn.example.com/1/full.png" type="image/png" length="5000000"/>
+	</item>
+	<item>
+		<title>Night</title>
+		<link>https://art.example.com/posts/2</link>
+		<author>painter@example.com (Painter)</author>
+		<pubDate>Tue, 30 Apr 2024 10:00:00 GMT</pubDate>
+		<media:rating>adult</media:rating>
+		<enclosure url="https://cdn.example.com/2/download?id=2" type="image/jpeg" length="1234"/>
+		<enclosure url="https://cdn.example.com/2/sound.mp3" type="audio/mpeg" length="99"/>
+	</item>
+	<item>
+		<title>Sketch</title>
+		<link>https://art.example.com/posts/3</link>
+		<description>&lt;p&gt;New sketch!&lt;/p&gt;&lt;img src="/uploads/sketch.jpg" alt=""&gt;&lt;img src="/uploads/other.jpg"&gt;</description>
+	</item>
+	<item>
+		<title>Text only</title>
+		<link>https://art.example.com/posts/4</link>
+		<description>No images here.</description>
+	</item>
+</channel>
+</rss>`
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom" xmlns:media="http://search.yahoo.com/mrss/">
+	<title>Photo Blog</title>
+	<entry>
+		<title type="html">Mountains</title>
+		<link rel="alternate" href="https://photos.example.com/mountains"/>
+		<link rel="enclosure" type="image/webp" href="https://photos.example.com/img/mountains.webp" length="2048"/>
+		<author><name>Photographer</name><uri>https://photos.example.com/about</uri></author>
+		<published>2024-05-01T10:00:00Z</published>
+		<updated>2024-05-02T10:00:00Z</updated>
+		<category term="mountains"/>
+		<media:content url="https://photos.example.com/img/mountains-full.jpg" type="image/jpeg" width="6000" height="4000px"/>
+	</entry>
+	<entry>
+		<title>Lake</title>
+		<link href="https://photos.example.com/lake"/>
+		<updated>2024-04-01T10:00:00Z</updated>
+		<content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Lake</p><img src="img/lake.jpg"/></div></content>
+	</entry>
+</feed>`
+
+func serveFeed(t *testing.T, body string) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, userAgent, r.UserAgent())
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL + "/feed.xml"
+}
+
+func TestRun_RSS(t *testing.T) {
+	fe := &Feed{Client: http.DefaultClient}
+	feedURL := serveFeed(t, rssFixture)
+
+	resp, err := fe.Run(context.Background(), source.Request{Parameter: feedURL})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 3)
+
+	// media:content comes with dimensions, the duplicate enclosure and the video are skipped.
+	sunset := resp.Images[0]
+	assert.Equal(t, "https://cdn.example.com/1/full.png", sunset.DownloadURL)
+	assert.Equal(t, int64(3840), sunset.Width)
+	assert.Equal(t, int64(2160), sunset.Height)
+	assert.Equal(t, int64(5000000), sunset.Filesize)
+	assert.Equal(t, "https://cdn.example.com/1/thumb.jpg", sunset.ThumbnailURL)
+	assert.Equal(t, "https://art.example.com/posts/1", sunset.Website)
+	assert.Equal(t, "Painter", sunset.Author)
+	assert.Equal(t, "Sunset & Sea", sunset.Title)
+	assert.Equal(t, []string{"landscape", "sea"}, sunset.Tags)
+	assert.Equal(t, "127.0.0.1_full.png", sunset.Filename)
+	assert.True(t, sunset.PostedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+	assert.False(t, sunset.NSFW)
+
+	// Image enclosures, with the extension taken from the type.
+	night := resp.Images[1]
+	assert.Equal(t, "https://cdn.example.com/2/download?id=2", night.DownloadURL)
+	assert.Equal(t, int64(1234), night.Filesize)
+	assert.Equal(t, "Painter", night.Author)
+	assert.Equal(t, "127.0.0.1_download.jpg", night.Filename)
+	assert.True(t, night.NSFW)
+
+	// The first image of the content, relative to the item link.
+	sketch := resp.Images[2]
+	assert.Equal(t, "https://art.example.com/uploads/sketch.jpg", sketch.DownloadURL)
+	assert.Equal(t, "https://art.example.com/posts/3", sketch.Website)
+	assert.True(t, sketch.PostedAt.IsZero())
+}
+
+func TestRun_Atom(t *testing.T) {
+	fe := &Feed{Client: http.DefaultClient}
+	feedURL := serveFeed(t, atomFixture)
+
+	resp, err := fe.Run(context.Background(), source.Request{Parameter: feedURL})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 3)
+
+	full := resp.Images[0]
+	assert.Equal(t, "https://photos.example.com/img/mountains-full.jpg", full.DownloadURL)
+	assert.Equal(t, int64(6000), full.Width)
+	assert.Equal(t, int64(0), full.Height, "invalid dimensions are ignored")
+	assert.Equal(t, "Photographer", full.Author)
+	assert.Equal(t, "https://photos.example.com/about", full.AuthorURL)
+	assert.Equal(t, "https://photos.example.com/mountains", full.Website)
+	assert.Equal(t, []string{"mountains"}, full.Tags)
+	assert.True(t, full.PostedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)), "published is preferred over updated")
+
+	enclosure := resp.Images[1]
+	assert.Equal(t, "https://photos.example.com/img/mountains.webp", enclosure.DownloadURL)
+	assert.Equal(t, int64(2048), enclosure.Filesize)
+
+	lake := resp.Images[2]
+	assert.Equal(t, "https://photos.example.com/img/lake.jpg", lake.DownloadURL)
+	assert.Equal(t, "https://photos.example.com/lake", lake.Website)
+	assert.True(t, lake.PostedAt.Equal(time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)))
+}
+
+func TestRun_Countback(t *testing.T) {
+	fe := &Feed{Client: http.DefaultClient}
+	feedURL := serveFeed(t, rssFixture)
+
+	resp, err := fe.Run(context.Background(), source.Request{Parameter: feedURL, Countback: 1})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 1)
+	assert.Equal(t, "https://cdn.example.com/1/full.png", resp.Images[0].DownloadURL)
+}
+
+func TestValidateTransformParameter(t *testing.T) {
+	fe := &Feed{Client: http.DefaultClient}
+	feedURL := serveFeed(t, rssFixture)
+
+	got, err := fe.ValidateTransformParameter(context.Background(), "  "+feedURL+"#latest ")
+	require.NoError(t, err)
+	assert.Equal(t, feedURL, got)
+
+	_, err = fe.ValidateTransformParameter(context.Background(), "feed.xml")
+	assert.ErrorContains(t, err, "must be an http:// or https:// URL")
+
+	notFeed := serveFeed(t, `<html><body>Hello</body></html>`)
+	_, err = fe.ValidateTransformParameter(context.Background(), notFeed)
+	assert.ErrorContains(t, err, "is not an RSS or Atom feed")
+}
@@ -0,0 +1,353 @@
+package feed
+
+import (
+	"cmp"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+// document is an RSS 2.0, RSS 1.0 (RDF), or Atom feed. Only one of the item lists is filled.
+type document struct {
+	XMLName xml.Name
+	// Channel holds the items of RSS 2.0 feeds.
+	Channel struct {
+		Items []item `xml:"item"`
+	} `xml:"channel"`
+	// Items of RSS 1.0 feeds are siblings of the channel.
+	Items []item `xml:"item"`
+	// Entries of Atom feeds.
+	Entries []item `xml:"http://www.w3.org/2005/Atom entry"`
+}
+
+// item is an RSS item or an Atom entry.
+type item struct {
+	Title string `xml:"title"`
+	// Links are text in RSS, and href attributes in Atom.
+	Links   []link   `xml:"link"`
+	GUID    string   `xml:"guid"`
+	Authors []author `xml:"author"`
+	Creator string   `xml:"http://purl.org/dc/elements/1.1/ creator"`
+
+	PubDate   string `xml:"pubDate"`
+	Date      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Published string `xml:"http://www.w3.org/2005/Atom published"`
+	Updated   string `xml:"http://www.w3.org/2005/Atom updated"`
+
+	Description string      `xml:"description"`
+	Encoded     string      `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	Summary     atomContent `xml:"http://www.w3.org/2005/Atom summary"`
+	Content     atomContent `xml:"http://www.w3.org/2005/Atom content"`
+
+	Categories []category  `xml:"category"`
+	Enclosures []enclosure `xml:"enclosure"`
+
+	MediaContents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups     []mediaGroup     `xml:"http://search.yahoo.com/mrss/ group"`
+	MediaThumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	MediaRating     string           `xml:"http://search.yahoo.com/mrss/ rating"`
+}
+
+type link struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+	// Length is the file size of enclosure links.
+	Length string `xml:"length,attr"`
+	Text   string `xml:",chardata"`
+}
+
+type author struct {
+	// Name and URI of Atom authors.
+	Name string `xml:"name"`
+	URI  string `xml:"uri"`
+	// Text of RSS authors, usually "email@example.com (Name)".
+	Text string `xml:",chardata"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Text  string `xml:",chardata"`
+	Inner string `xml:",innerxml"`
+}
+
+// html returns the content as HTML. XHTML content is markup, other content is escaped text.
+func (ac atomContent) html() string {
+	if ac.Type == "xhtml" {
+		return ac.Inner
+	}
+	return ac.Text
+}
+
+type category struct {
+	// Term of Atom categories.
+	Term string `xml:"term,attr"`
+	Text string `xml:",chardata"`
+}
+
+type enclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+// Attributes are kept as strings, since invalid numbers would fail the whole feed.
+type mediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	Medium   string `xml:"medium,attr"`
+	Width    string `xml:"width,attr"`
+	Height   string `xml:"height,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	// Rating and Thumbnails may be set per content instead of per item.
+	Rating     string           `xml:"http://search.yahoo.com/mrss/ rating"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+}
+
+type mediaGroup struct {
+	Contents   []mediaContent   `xml:"http://search.yahoo.com/mrss/ content"`
+	Thumbnails []mediaThumbnail `xml:"http://search.yahoo.com/mrss/ thumbnail"`
+	Rating     string           `xml:"http://search.yahoo.com/mrss/ rating"`
+}
+
+type mediaThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// parseDocument parses a feed. Feeds in other encodings than UTF-8 are converted.
+func parseDocument(r io.Reader) (document, error) {
+	var doc document
+	decoder := xml.NewDecoder(r)
+	decoder.CharsetReader = charset.NewReaderLabel
+	// Feeds in the wild often contain HTML entities and unescaped ampersands.
+	decoder.Strict = false
+	decoder.Entity = xml.HTMLEntity
+	if err := decoder.Decode(&doc); err != nil {
+		return doc, err
+	}
+	switch doc.XMLName.Local {
+	case "rss", "RDF", "feed":
+		return doc, nil
+	default:
+		return doc, fmt.Errorf("<%s> is not an RSS or Atom feed", doc.XMLName.Local)
+	}
+}
+
+func (doc document) items() []item {
+	switch {
+	case len(doc.Entries) > 0:
+		return doc.Entries
+	case len(doc.Channel.Items) > 0:
+		return doc.Channel.Items
+	default:
+		return doc.Items
+	}
+}
+
+// media is an image found in a feed item.
+type media struct {
+	URL      string
+	Width    int64
+	Height   int64
+	Filesize int64
+	// Type is the mime type, if given by the feed.
+	Type      string
+	Thumbnail string
+	Adult     bool
+}
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".avif", ".bmp"}
+
+// isImage reports whether the media is an image, by its medium or mime type if given, or by its URL otherwise.
+func isImage(url, mimeType, medium string) bool {
+	switch {
+	case medium != "":
+		return medium == "image"
+	case mimeType != "":
+		return strings.HasPrefix(strings.ToLower(mimeType), "image/")
+	}
+	lower := strings.ToLower(url)
+	if i := strings.IndexAny(lower, "?#"); i >= 0 {
+		lower = lower[:i]
+	}
+	for _, ext := range imageExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// media returns the images of the item in document order, without duplicates.
+//
+// Media RSS contents come first, then enclosures. If the item has neither, the first image in its content is used.
+func (it item) media() []media {
+	var (
+		result []media
+		seen   = map[string]bool{}
+	)
+	add := func(m media) {
+		m.URL = strings.TrimSpace(m.URL)
+		if m.URL == "" || seen[m.URL] {
+			return
+		}
+		seen[m.URL] = true
+		result = append(result, m)
+	}
+
+	itemAdult := isAdult(it.MediaRating)
+	itemThumbnail := firstThumbnail(it.MediaThumbnails)
+	addContents := func(contents []mediaContent, thumbnail string, adult bool) {
+		for _, c := range contents {
+			if !isImage(c.URL, c.Type, c.Medium) {
+				continue
+			}
+			add(media{
+				URL:       c.URL,
+				Width:     parseInt(c.Width),
+				Height:    parseInt(c.Height),
+				Filesize:  parseInt(c.FileSize),
+				Type:      c.Type,
+				Thumbnail: cmp.Or(firstThumbnail(c.Thumbnails), thumbnail),
+				Adult:     adult || isAdult(c.Rating),
+			})
+		}
+	}
+	addContents(it.MediaContents, itemThumbnail, itemAdult)
+	for _, group := range it.MediaGroups {
+		addContents(group.Contents, cmp.Or(firstThumbnail(group.Thumbnails), itemThumbnail), itemAdult || isAdult(group.Rating))
+	}
+
+	for _, e := range it.Enclosures {
+		if isImage(e.URL, e.Type, "") {
+			add(media{URL: e.URL, Filesize: parseInt(e.Length), Type: e.Type, Thumbnail: itemThumbnail, Adult: itemAdult})
+		}
+	}
+	for _, l := range it.Links {
+		if l.Rel == "enclosure" && isImage(l.Href, l.Type, "") {
+			add(media{URL: l.Href, Filesize: parseInt(l.Length), Type: l.Type, Thumbnail: itemThumbnail, Adult: itemAdult})
+		}
+	}
+
+	if len(result) == 0 {
+		for _, content := range []string{it.Encoded, it.Content.html(), it.Description, it.Summary.html()} {
+			if src := firstImageSource(content); src != "" {
+				add(media{URL: src, Thumbnail: itemThumbnail, Adult: itemAdult})
+				break
+			}
+		}
+	}
+	return result
+}
+
+// website returns the URL of the web page of the item.
+func (it item) website() string {
+	for _, l := range it.Links {
+		// Atom links without rel are alternate links.
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return strings.TrimSpace(l.Href)
+		}
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+	}
+	if guid := strings.TrimSpace(it.GUID); strings.HasPrefix(guid, "http://") || strings.HasPrefix(guid, "https://") {
+		return guid
+	}
+	return ""
+}
+
+var rssAuthorPattern = regexp.MustCompile(`^\S+@\S+\s+\((.+)\)$`)
+
+// author returns the name and URL of the author of the item.
+func (it item) author() (name, uri string) {
+	if creator := strings.TrimSpace(it.Creator); creator != "" {
+		return creator, ""
+	}
+	for _, a := range it.Authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			return name, strings.TrimSpace(a.URI)
+		}
+		text := strings.TrimSpace(a.Text)
+		if m := rssAuthorPattern.FindStringSubmatch(text); m != nil {
+			return m[1], ""
+		}
+		if text != "" {
+			return text, ""
+		}
+	}
+	return "", ""
+}
+
+var dateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	time.DateOnly,
+}
+
+// postedAt returns when the item was published, or the zero time if unknown.
+func (it item) postedAt() time.Time {
+	for _, value := range []string{it.PubDate, it.Published, it.Date, it.Updated} {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func (it item) title() string {
+	return strings.TrimSpace(html.UnescapeString(it.Title))
+}
+
+func (it item) tags() []string {
+	var tags []string
+	for _, c := range it.Categories {
+		if tag := strings.TrimSpace(cmp.Or(c.Term, c.Text)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func isAdult(rating string) bool {
+	rating = strings.ToLower(strings.TrimSpace(rating))
+	// Media RSS ratings use the "simple" scheme by default ("adult" or "nonadult"),
+	// but may use others like "urn:mpaa" with values like "nc-17".
+	return rating == "adult" || rating == "nc-17" || rating == "x"
+}
+
+func firstThumbnail(thumbnails []mediaThumbnail) string {
+	for _, t := range thumbnails {
+		if u := strings.TrimSpace(t.URL); u != "" {
+			return u
+		}
+	}
+	return ""
+}
+
+func parseInt(s string) int64 {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,157 @@
+package feed
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// maxFeedSize limits how much of a feed is read.
+const maxFeedSize = 16 << 20
+
+// Run runs the source to fetch image Metadata based on the given request.
+//
+// Countback is the number of feed items to look through, in feed order.
+func (fe *Feed) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	feedURL, err := parseFeedURL(request.Parameter)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	doc, err := fe.fetchFeed(ctx, feedURL)
+	if err != nil {
+		return source.Response{}, err
+	}
+
+	countback := request.Countback
+	if countback <= 0 {
+		countback = fe.DefaultCountback()
+	}
+	items := doc.items()
+	items = items[:min(countback, len(items))]
+
+	var images source.Images
+	for _, it := range items {
+		images = append(images, convertItem(feedURL, it, request)...)
+	}
+	return source.Response{Images: images}, nil
+}
+
+func (fe *Feed) fetchFeed(ctx context.Context, feedURL *url.URL) (document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL.String(), nil)
+	if err != nil {
+		return document{}, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/xml;q=0.9, text/xml;q=0.8, */*;q=0.1")
+
+	resp, err := fe.Client.Do(req)
+	if err != nil {
+		return document{}, fmt.Errorf("failed to fetch feed %s: %w", feedURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return document{}, fmt.Errorf("failed to fetch feed %s: %w", feedURL, &source.StatusError{Service: "server", StatusCode: resp.StatusCode, RetryAfter: source.RetryAfter(resp.Header)})
+	}
+
+	doc, err := parseDocument(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return document{}, fmt.Errorf("failed to parse feed %s, is it an RSS or Atom feed? %w", feedURL, err)
+	}
+	return doc, nil
+}
+
+// convertItem converts the images of a feed item to source.Images.
+func convertItem(feedURL *url.URL, it item, request source.Request) source.Images {
+	website := resolveURL(feedURL, it.website())
+	base := feedURL
+	if u, err := url.Parse(website); err == nil && website != "" {
+		// Relative URLs in the item content are relative to the item page.
+		base = u
+	}
+	authorName, authorURL := it.author()
+	postedAt := it.postedAt()
+	title := it.title()
+	tags := it.tags()
+
+	var images source.Images
+	for _, m := range it.media() {
+		downloadURL := resolveURL(base, m.URL)
+		if !strings.HasPrefix(downloadURL, "http://") && !strings.HasPrefix(downloadURL, "https://") {
+			continue
+		}
+		images = append(images, source.Image{
+			DownloadURL:  downloadURL,
+			Width:        m.Width,
+			Height:       m.Height,
+			Filesize:     m.Filesize,
+			Author:       authorName,
+			AuthorURL:    authorURL,
+			Website:      website,
+			ThumbnailURL: resolveURL(base, m.Thumbnail),
+			PostedAt:     postedAt,
+			Filename:     generateFilename(feedURL, downloadURL, m.Type, request.FilenameMaxLength),
+			Title:        title,
+			Tags:         tags,
+			NSFW:         m.Adult,
+		})
+	}
+	return images
+}
+
+// resolveURL resolves ref against base. Invalid or empty refs are returned as is.
+func resolveURL(base *url.URL, ref string) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+// firstImageSource returns the src of the first <img> in an HTML fragment.
+func firstImageSource(fragment string) string {
+	if !strings.Contains(strings.ToLower(fragment), "<img") {
+		return ""
+	}
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			return ""
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			if token.DataAtom != atom.Img {
+				continue
+			}
+			for _, attr := range token.Attr {
+				if attr.Key == "src" && strings.TrimSpace(attr.Val) != "" && !strings.HasPrefix(attr.Val, "data:") {
+					return strings.TrimSpace(attr.Val)
+				}
+			}
+		}
+	}
+}
+
+// generateFilename generates a filename using the format <feed_host>_<image_filename>.
+//
+// If the image URL has no extension, it is taken from the mime type given by the feed.
+func generateFilename(feedURL *url.URL, imageURL, mimeType string, maxLength int) string {
+	name := source.URLFilename(imageURL)
+	if path.Ext(name) == "" && mimeType != "" {
+		if mtype := mimetype.Lookup(mimeType); mtype != nil {
+			name += mtype.Extension()
+		}
+	}
+	return source.SafeFilename(feedURL.Hostname(), name, maxLength)
+}
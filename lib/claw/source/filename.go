@@ -0,0 +1,54 @@
+package source
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// DefaultFilenameMaxLength is the length generated filenames are shortened to when Request.FilenameMaxLength is not set.
+const DefaultFilenameMaxLength = 100
+
+// FilenameMaxLength returns maxLength, or DefaultFilenameMaxLength if maxLength is not positive.
+func FilenameMaxLength(maxLength int) int {
+	if maxLength <= 0 {
+		return DefaultFilenameMaxLength
+	}
+	return maxLength
+}
+
+var unsafeFilenameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]+`)
+
+// URLFilename returns the last path segment of rawURL, or "image" if it has none.
+func URLFilename(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		if base := path.Base(u.Path); base != "." && base != "/" {
+			return base
+		}
+	}
+	return "image"
+}
+
+// SafeFilename generates a filename using the format <prefix>_<name>, with characters that are not safe
+// in filenames replaced by "_".
+//
+// Filenames longer than maxLength keep their end, where the extension and the most specific part of the name are.
+func SafeFilename(prefix, name string, maxLength int) string {
+	return SanitizeFilename(prefix+"_"+name, maxLength)
+}
+
+// SanitizeFilename replaces characters that are not safe in filenames by "_", so the result is a single path
+// element. Names made of dots only, like "..", are replaced entirely.
+//
+// Filenames longer than maxLength keep their end, where the extension and the most specific part of the name are.
+func SanitizeFilename(name string, maxLength int) string {
+	filename := unsafeFilenameChars.ReplaceAllString(name, "_")
+	if maxLength = FilenameMaxLength(maxLength); len(filename) > maxLength {
+		filename = filename[len(filename)-maxLength:]
+	}
+	if strings.Trim(filename, ".") == "" {
+		filename = strings.Repeat("_", len(filename))
+	}
+	return filename
+}
@@ -4,6 +4,8 @@ import "time"
 
 type Image struct {
 	// The actual URL to download the image.
+	//
+	// Sources implementing [SourceLocalFiles] may return file:// URLs.
 	DownloadURL string
 	// Width of image in pixels.
 	Width int64
@@ -24,6 +26,13 @@ type Image struct {
 	PostedAt time.Time
 	// Suggested filename for the image, without path.
 	Filename string
+	// Title of the post or the image. Optional.
+	Title string
+	// Tags provided by the source, e.g. the subreddit or booru tags. Optional.
+	//
+	// Tags are attached to the image when it is added to the library.
+	// Claw normalizes the names, so casing and duplicates do not matter.
+	Tags []string
 
 	// Whether the image is Not Safe For Work (NSFW).
 	NSFW bool
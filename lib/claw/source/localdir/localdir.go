@@ -0,0 +1,178 @@
+package localdir
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const (
+	SourceName = "claw.localdir.v1"
+
+	// watchSettleDelay is how long a watched directory must be quiet before a job is triggered,
+	// so files that are still being copied are complete when the job runs.
+	watchSettleDelay = 5 * time.Second
+)
+
+var (
+	_ source.Source           = (*LocalDir)(nil)
+	_ source.SourceLocalFiles = (*LocalDir)(nil)
+	_ source.SourceWatcher    = (*LocalDir)(nil)
+)
+
+// Config configures the LocalDir source.
+type Config struct {
+	// AllowedDirs restricts the directories that can be imported from. Subdirectories are allowed too.
+	//
+	// When empty, any directory readable by Claw is allowed.
+	AllowedDirs []string
+}
+
+// LocalDir imports images from a directory on the local filesystem.
+type LocalDir struct {
+	source.UnimplementedSource
+
+	// Config is called on every use, so config reloads are picked up. Optional.
+	Config func() Config
+
+	settleDelay time.Duration
+}
+
+func (lo *LocalDir) currentConfig() Config {
+	if lo.Config == nil {
+		return Config{}
+	}
+	return lo.Config()
+}
+
+// Name returns the unique kind identifier for the source.
+func (lo *LocalDir) Name() string {
+	return SourceName
+}
+
+// DisplayName returns the human-readable name for the source.
+func (lo *LocalDir) DisplayName() string {
+	return "Local Directory"
+}
+
+// Author returns the author name.
+func (lo *LocalDir) Author() string {
+	return "Claw"
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (lo *LocalDir) AuthorURL() string {
+	return "https://github.com/tigorlazuardi/claw"
+}
+
+func (lo *LocalDir) Description() string {
+	return `Imports images from a directory on the machine running Claw, e.g. an existing wallpaper collection.
+
+Dimensions are read from the image files. Files are hardlinked into the library when the directory
+is on the same filesystem, and copied otherwise. The directory can be watched, so images dropped
+into it are imported right away.`
+}
+
+func (lo *LocalDir) RequireParameter() bool {
+	return true
+}
+
+// DefaultCountback returns the number of newest files to import per run. Reading local files is cheap.
+func (lo *LocalDir) DefaultCountback() int {
+	return 1000
+}
+
+const helpString = /*markdown*/
+`The absolute path of a directory, e.g. /home/me/Pictures/Wallpapers
+
+Options can be added after a "?", like a URL query:
+
+- glob: Comma separated patterns of files to import, e.g. ?glob=*.jpg,*.png
+  Patterns are case-insensitive. Patterns with a "/" are matched against the path relative to the directory.
+  Default: all JPEG, PNG, GIF, WebP, BMP, and TIFF files.
+- recursive: Also import files in subdirectories, e.g. ?recursive=true
+- watch: Create a job whenever files are added to the directory, e.g. ?watch=true
+
+Options can be combined: /home/me/Pictures?recursive=true&watch=true
+
+Hidden files and directories are skipped. The names of subdirectories are added as tags.
+Countback is the number of files to import, most recently modified first.
+`
+
+// ParameterHelp returns the help string for the parameter.
+// Markdown formatting is supported, but any Javascript will be stripped.
+func (lo *LocalDir) ParameterHelp() string {
+	return helpString
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+//
+// This is usually a very short string to show as a hint for the user.
+func (lo *LocalDir) ParameterPlaceholder() string {
+	return "/path/to/wallpapers?recursive=true"
+}
+
+// ValidateTransformParameter checks that the parameter is an allowed directory with valid options,
+// and normalizes it.
+func (lo *LocalDir) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	opts, err := parseParameter(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid local directory parameter: %w\n\n%s", err, helpString)
+	}
+	if _, err := lo.resolveDir(opts.Dir); err != nil {
+		return "", fmt.Errorf("invalid local directory parameter: %w\n\n%s", err, helpString)
+	}
+	return opts.String(), nil
+}
+
+// AllowLocalFile reports whether the file is in one of the allowed directories.
+func (lo *LocalDir) AllowLocalFile(path string) bool {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return false
+	}
+	return lo.allowed(resolved)
+}
+
+// allowed reports whether the resolved path is in one of the allowed directories.
+func (lo *LocalDir) allowed(resolved string) bool {
+	allowedDirs := lo.currentConfig().AllowedDirs
+	if len(allowedDirs) == 0 {
+		return true
+	}
+	for _, dir := range allowedDirs {
+		dir, err := filepath.EvalSymlinks(expandHome(dir))
+		if err != nil {
+			continue
+		}
+		rel, err := filepath.Rel(dir, resolved)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveDir resolves symlinks of dir, and checks that it is an allowed directory.
+func (lo *LocalDir) resolveDir(dir string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("cannot access directory %q: %w", dir, err)
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", fmt.Errorf("cannot access directory %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("%q is not a directory", dir)
+	}
+	if !lo.allowed(resolved) {
+		return "", fmt.Errorf("directory %q is not in the allowed directories of the config (sources.localdir.allowed_dirs)", dir)
+	}
+	return resolved, nil
+}
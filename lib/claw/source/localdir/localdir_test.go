@@ -0,0 +1,195 @@
+package localdir
+
+import (
+	"context"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// writeImage writes a width x height PNG or JPEG, by extension, modified at the given time.
+func writeImage(t *testing.T, p string, width, height int, modTime time.Time) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if filepath.Ext(p) == ".png" {
+		require.NoError(t, png.Encode(f, img))
+	} else {
+		require.NoError(t, jpeg.Encode(f, img, nil))
+	}
+	require.NoError(t, f.Close())
+	require.NoError(t, os.Chtimes(p, modTime, modTime))
+}
+
+func TestParseParameter(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		param string
+		want  string
+	}{
+		{name: "directory", param: " /data/wallpapers/ ", want: "/data/wallpapers"},
+		{name: "file URL", param: "file:///data/my%20wallpapers", want: "/data/my wallpapers"},
+		{name: "home", param: "~/Pictures", want: filepath.Join(home, "Pictures")},
+		{
+			name:  "options",
+			param: "/data?watch&glob=*.jpg, landscape/*.png&recursive=1",
+			want:  "/data?glob=*.jpg,landscape/*.png&recursive=true&watch=true",
+		},
+		{name: "default options are left out", param: "/data?recursive=false&glob=", want: "/data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := parseParameter(tt.param)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, opts.String())
+		})
+	}
+
+	for name, param := range map[string]string{
+		"empty":          " ",
+		"relative":       "Pictures/wallpapers",
+		"remote file":    "file://server/share",
+		"unknown option": "/data?recurse=true",
+		"bad bool":       "/data?watch=sometimes",
+		"bad glob":       "/data?glob=[a-",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseParameter(param)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeImage(t, filepath.Join(dir, "old.png"), 40, 20, now.Add(-3*time.Hour))
+	writeImage(t, filepath.Join(dir, "new.jpg"), 30, 10, now.Add(-time.Hour))
+	writeImage(t, filepath.Join(dir, "Nature", "Mountains", "peak.PNG"), 16, 9, now.Add(-2*time.Hour))
+	writeImage(t, filepath.Join(dir, ".hidden", "secret.png"), 1, 1, now)
+	writeImage(t, filepath.Join(dir, ".dotfile.png"), 1, 1, now)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an image"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.png"), []byte("not an image"), 0o644))
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "broken.png"), now, now))
+
+	lo := &LocalDir{}
+
+	t.Run("top level only", func(t *testing.T) {
+		resp, err := lo.Run(context.Background(), source.Request{Parameter: dir})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 2, "broken and hidden images are skipped")
+
+		newest := resp.Images[0]
+		assert.Equal(t, fileURL(filepath.Join(dir, "new.jpg")), newest.DownloadURL)
+		assert.Equal(t, int64(30), newest.Width)
+		assert.Equal(t, int64(10), newest.Height)
+		info, err := os.Stat(filepath.Join(dir, "new.jpg"))
+		require.NoError(t, err)
+		assert.Equal(t, info.Size(), newest.Filesize)
+		assert.Equal(t, "new.jpg", newest.Filename)
+		assert.Equal(t, "new", newest.Title)
+		assert.Empty(t, newest.Tags)
+		assert.WithinDuration(t, now.Add(-time.Hour), newest.PostedAt, time.Millisecond)
+
+		assert.Equal(t, "old.png", resp.Images[1].Filename)
+	})
+
+	t.Run("recursive with countback", func(t *testing.T) {
+		resp, err := lo.Run(context.Background(), source.Request{Parameter: dir + "?recursive=true", Countback: 3})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 2, "countback includes the broken image")
+		assert.Equal(t, "new.jpg", resp.Images[0].Filename)
+		assert.Equal(t, "peak.PNG", resp.Images[1].Filename)
+		assert.Equal(t, []string{"Nature", "Mountains"}, resp.Images[1].Tags)
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		resp, err := lo.Run(context.Background(), source.Request{Parameter: dir + "?recursive&glob=*.png"})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 2)
+		assert.Equal(t, "peak.PNG", resp.Images[0].Filename)
+		assert.Equal(t, "old.png", resp.Images[1].Filename)
+
+		resp, err = lo.Run(context.Background(), source.Request{Parameter: dir + "?recursive&glob=nature/*/*"})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 1)
+		assert.Equal(t, "peak.PNG", resp.Images[0].Filename)
+	})
+}
+
+func TestAllowedDirs(t *testing.T) {
+	allowed := t.TempDir()
+	other := t.TempDir()
+	writeImage(t, filepath.Join(allowed, "inside.png"), 2, 2, time.Now())
+	writeImage(t, filepath.Join(other, "outside.png"), 2, 2, time.Now())
+	require.NoError(t, os.Symlink(filepath.Join(other, "outside.png"), filepath.Join(allowed, "link.png")))
+
+	lo := &LocalDir{Config: func() Config { return Config{AllowedDirs: []string{allowed}} }}
+
+	got, err := lo.ValidateTransformParameter(context.Background(), allowed+"/?recursive=1")
+	require.NoError(t, err)
+	assert.Equal(t, allowed+"?recursive=true", got)
+
+	_, err = lo.ValidateTransformParameter(context.Background(), other)
+	assert.ErrorContains(t, err, "not in the allowed directories")
+	_, err = lo.ValidateTransformParameter(context.Background(), filepath.Join(allowed, "inside.png"))
+	assert.ErrorContains(t, err, "is not a directory")
+
+	assert.True(t, lo.AllowLocalFile(filepath.Join(allowed, "inside.png")))
+	assert.False(t, lo.AllowLocalFile(filepath.Join(other, "outside.png")))
+	assert.False(t, lo.AllowLocalFile(filepath.Join(allowed, "link.png")), "symlinks are resolved")
+
+	resp, err := lo.Run(context.Background(), source.Request{Parameter: allowed})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 1, "symlinks out of the allowed directories are skipped")
+	assert.Equal(t, "inside.png", resp.Images[0].Filename)
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	lo := &LocalDir{settleDelay: 50 * time.Millisecond}
+
+	t.Run("returns right away without watch option", func(t *testing.T) {
+		err := lo.Watch(context.Background(), dir, func() { t.Error("unexpected notify") })
+		assert.NoError(t, err)
+	})
+
+	t.Run("notifies once for a burst of new images", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		notified := make(chan struct{}, 10)
+		done := make(chan error, 1)
+		go func() {
+			done <- lo.Watch(ctx, dir+"?watch=true&recursive=true", func() { notified <- struct{}{} })
+		}()
+		// Give the watcher time to start.
+		time.Sleep(100 * time.Millisecond)
+
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("ignored"), 0o644))
+		writeImage(t, filepath.Join(dir, "a.png"), 1, 1, time.Now())
+		writeImage(t, filepath.Join(dir, "sub", "b.jpg"), 1, 1, time.Now())
+
+		select {
+		case <-notified:
+		case <-time.After(5 * time.Second):
+			t.Fatal("watcher did not notify")
+		}
+		time.Sleep(200 * time.Millisecond)
+		assert.Empty(t, notified, "burst is debounced")
+
+		cancel()
+		assert.NoError(t, <-done)
+	})
+}
@@ -0,0 +1,138 @@
+package localdir
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// options are the parsed parameter of the source.
+type options struct {
+	// Dir is the absolute, cleaned path of the directory.
+	Dir string
+	// Patterns are the glob patterns of files to import. Empty means all supported images.
+	Patterns  []string
+	Recursive bool
+	Watch     bool
+}
+
+// parseParameter parses "<dir>[?glob=<patterns>&recursive=<bool>&watch=<bool>]".
+//
+// The directory may also be given as a file:// URL, or start with "~/".
+func parseParameter(param string) (options, error) {
+	raw := strings.TrimSpace(param)
+	if raw == "" {
+		return options{}, errors.New("parameter cannot be empty")
+	}
+	dir, query, _ := strings.Cut(raw, "?")
+	if strings.HasPrefix(dir, "file://") {
+		u, err := url.Parse(dir)
+		if err != nil {
+			return options{}, fmt.Errorf("invalid file URL %q: %w", dir, err)
+		}
+		if u.Host != "" && u.Host != "localhost" {
+			return options{}, fmt.Errorf("file URL %q must not have a host", dir)
+		}
+		dir = filepath.FromSlash(u.Path)
+	}
+	dir = expandHome(dir)
+	if !filepath.IsAbs(dir) {
+		return options{}, fmt.Errorf("%q is not an absolute path", dir)
+	}
+
+	opts := options{Dir: filepath.Clean(dir)}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return options{}, fmt.Errorf("invalid options %q: %w", query, err)
+	}
+	for key := range values {
+		value := values.Get(key)
+		switch key {
+		case "glob":
+			for pattern := range strings.SplitSeq(value, ",") {
+				pattern = strings.TrimSpace(pattern)
+				if pattern == "" {
+					continue
+				}
+				if _, err := path.Match(pattern, ""); err != nil {
+					return options{}, fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+				}
+				opts.Patterns = append(opts.Patterns, pattern)
+			}
+		case "recursive":
+			if opts.Recursive, err = parseBool(value); err != nil {
+				return options{}, fmt.Errorf("invalid recursive option %q: %w", value, err)
+			}
+		case "watch":
+			if opts.Watch, err = parseBool(value); err != nil {
+				return options{}, fmt.Errorf("invalid watch option %q: %w", value, err)
+			}
+		default:
+			return options{}, fmt.Errorf("unknown option %q, supported options are glob, recursive, and watch", key)
+		}
+	}
+	return opts, nil
+}
+
+// parseBool parses a boolean option. An option without value, like "?recursive", is true.
+func parseBool(value string) (bool, error) {
+	if value == "" {
+		return true, nil
+	}
+	return strconv.ParseBool(value)
+}
+
+// expandHome expands a leading "~/" to the home directory of the user running Claw.
+func expandHome(dir string) string {
+	if dir != "~" && !strings.HasPrefix(dir, "~/") {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return dir
+	}
+	return filepath.Join(home, dir[1:])
+}
+
+var queryEscaper = strings.NewReplacer("%", "%25", "&", "%26", "+", "%2B", "=", "%3D")
+
+// String returns the normalized parameter. Options with default values are left out.
+func (opts options) String() string {
+	var query []string
+	if len(opts.Patterns) > 0 {
+		query = append(query, "glob="+queryEscaper.Replace(strings.Join(opts.Patterns, ",")))
+	}
+	if opts.Recursive {
+		query = append(query, "recursive=true")
+	}
+	if opts.Watch {
+		query = append(query, "watch=true")
+	}
+	if len(query) == 0 {
+		return opts.Dir
+	}
+	return opts.Dir + "?" + strings.Join(query, "&")
+}
+
+// match reports whether the file at rel, the slash separated path relative to the directory,
+// should be imported. Patterns are matched case-insensitively.
+func (opts options) match(rel string) bool {
+	if len(opts.Patterns) == 0 {
+		return isSupportedImage(rel)
+	}
+	for _, pattern := range opts.Patterns {
+		name := path.Base(rel)
+		if strings.Contains(pattern, "/") {
+			name = rel
+		}
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(name)); ok {
+			return true
+		}
+	}
+	return false
+}
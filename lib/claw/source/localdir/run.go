@@ -0,0 +1,193 @@
+package localdir
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF decoder
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	_ "golang.org/x/image/bmp"  // register BMP decoder
+	_ "golang.org/x/image/tiff" // register TIFF decoder
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+var imageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp", ".bmp", ".tif", ".tiff"}
+
+func isSupportedImage(name string) bool {
+	return slices.Contains(imageExtensions, strings.ToLower(path.Ext(name)))
+}
+
+// file is a file in the directory that matches the options.
+type file struct {
+	// path is the absolute path.
+	path string
+	// rel is the slash separated path relative to the directory.
+	rel  string
+	info fs.FileInfo
+}
+
+// Run runs the source to fetch image Metadata based on the given request.
+//
+// Countback is the number of matching files to import, most recently modified first.
+// Files that cannot be decoded as images are skipped.
+func (lo *LocalDir) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	opts, err := parseParameter(request.Parameter)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	dir, err := lo.resolveDir(opts.Dir)
+	if err != nil {
+		return source.Response{}, err
+	}
+	files, err := lo.findFiles(ctx, dir, opts)
+	if err != nil {
+		return source.Response{}, err
+	}
+
+	countback := request.Countback
+	if countback <= 0 {
+		countback = lo.DefaultCountback()
+	}
+	slices.SortFunc(files, func(a, b file) int {
+		return cmp.Or(b.info.ModTime().Compare(a.info.ModTime()), strings.Compare(a.rel, b.rel))
+	})
+	files = files[:min(countback, len(files))]
+
+	var images source.Images
+	for _, f := range files {
+		if err := ctx.Err(); err != nil {
+			return source.Response{}, err
+		}
+		width, height, err := imageDimensions(f.path)
+		if err != nil {
+			continue
+		}
+		images = append(images, convertFile(f, width, height, request.FilenameMaxLength))
+	}
+	return source.Response{Images: images}, nil
+}
+
+// findFiles lists the files in dir that match the options. Hidden files and directories are skipped.
+//
+// Symlinks to files are followed as long as they point into an allowed directory.
+// Symlinks to directories are not followed.
+func (lo *LocalDir) findFiles(ctx context.Context, dir string, opts options) ([]file, error) {
+	var files []file
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == dir {
+				return err
+			}
+			// Unreadable files or subdirectories should not fail the whole run.
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if p == dir {
+			return nil
+		}
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			if !opts.Recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if !opts.match(rel) {
+			return nil
+		}
+		info, err := os.Stat(p)
+		if err != nil || !info.Mode().IsRegular() {
+			return nil
+		}
+		if d.Type()&fs.ModeSymlink != 0 && !lo.AllowLocalFile(p) {
+			return nil
+		}
+		files = append(files, file{path: p, rel: rel, info: info})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list directory %q: %w", dir, err)
+	}
+	return files, nil
+}
+
+// imageDimensions reads the dimensions of the image from its header, without decoding the whole image.
+func imageDimensions(p string) (width, height int64, err error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	cfg, _, err := image.DecodeConfig(f)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decode image %q: %w", p, err)
+	}
+	return int64(cfg.Width), int64(cfg.Height), nil
+}
+
+// convertFile converts a file to a source.Image. The subdirectories of the file are used as tags.
+func convertFile(f file, width, height int64, filenameMaxLength int) source.Image {
+	var tags []string
+	if dir := path.Dir(f.rel); dir != "." {
+		tags = strings.Split(dir, "/")
+	}
+	name := path.Base(f.rel)
+	return source.Image{
+		DownloadURL: fileURL(f.path),
+		Width:       width,
+		Height:      height,
+		Filesize:    f.info.Size(),
+		PostedAt:    f.info.ModTime().Truncate(time.Millisecond),
+		Filename:    generateFilename(name, filenameMaxLength),
+		Title:       strings.TrimSuffix(name, path.Ext(name)),
+		Tags:        tags,
+	}
+}
+
+// fileURL returns the file:// URL of an absolute path.
+func fileURL(p string) string {
+	p = filepath.ToSlash(p)
+	if !strings.HasPrefix(p, "/") {
+		// Windows paths like C:/Pictures become file:///C:/Pictures.
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// generateFilename returns the name of the file, shortened to maxLength.
+func generateFilename(name string, maxLength int) string {
+	maxLength = source.FilenameMaxLength(maxLength)
+	if len(name) <= maxLength {
+		return name
+	}
+	// Keep the extension, and the beginning of the name where people usually put the meaningful part.
+	ext := path.Ext(name)
+	if len(ext) >= maxLength {
+		return name[len(name)-maxLength:]
+	}
+	return name[:maxLength-len(ext)] + ext
+}
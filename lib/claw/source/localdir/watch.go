@@ -0,0 +1,111 @@
+package localdir
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watch watches the directory of the parameter for new or changed files when the watch option is set,
+// and calls notify once the directory has been quiet for a few seconds.
+//
+// In recursive mode, new subdirectories are watched too.
+func (lo *LocalDir) Watch(ctx context.Context, parameter string, notify func()) error {
+	opts, err := parseParameter(parameter)
+	if err != nil {
+		return fmt.Errorf("invalid parameter %q: %w", parameter, err)
+	}
+	if !opts.Watch {
+		return nil
+	}
+	dir, err := lo.resolveDir(opts.Dir)
+	if err != nil {
+		return err
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create file watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := addWatches(watcher, dir, opts.Recursive); err != nil {
+		return err
+	}
+
+	settle := cmp.Or(lo.settleDelay, watchSettleDelay)
+	timer := time.NewTimer(settle)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			return fmt.Errorf("failed to watch directory %q: %w", dir, err)
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) {
+				continue
+			}
+			if strings.HasPrefix(filepath.Base(event.Name), ".") {
+				continue
+			}
+			rel, err := filepath.Rel(dir, event.Name)
+			if err != nil {
+				continue
+			}
+			if opts.Recursive && event.Has(fsnotify.Create) && isDir(event.Name) {
+				// A directory moved into the watched tree may already contain images.
+				if err := addWatches(watcher, event.Name, true); err != nil {
+					return err
+				}
+				timer.Reset(settle)
+				continue
+			}
+			if opts.match(filepath.ToSlash(rel)) {
+				timer.Reset(settle)
+			}
+		case <-timer.C:
+			notify()
+		}
+	}
+}
+
+// addWatches watches dir, and its subdirectories when recursive. Hidden directories are skipped.
+func addWatches(watcher *fsnotify.Watcher, dir string, recursive bool) error {
+	if !recursive {
+		if err := watcher.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch directory %q: %w", dir, err)
+		}
+		return nil
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := watcher.Add(p); err != nil {
+			return fmt.Errorf("failed to watch directory %q: %w", p, err)
+		}
+		return nil
+	})
+}
+
+func isDir(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.IsDir()
+}
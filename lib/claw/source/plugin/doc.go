@@ -0,0 +1,65 @@
+// Package plugin runs sources as separate processes, so sources can be written in any language
+// without forking claw.
+//
+// A plugin is an executable configured in the "plugins" section of the config. Claw starts it when the
+// source is first used, and talks to it with JSON-RPC 2.0 messages over stdin and stdout. Every message
+// is a single JSON object. Newline separated messages are recommended. Anything written to stderr is
+// logged by claw, and the last lines are added to the error of failed calls, so they show up in the job.
+//
+// The plugin must answer the following methods. Calls may arrive concurrently, and responses may be
+// sent in any order. Return a JSON-RPC error object to fail a call. Its message is shown to the user.
+//
+// describe is called once after the plugin is started. The params are {"protocol": 1}.
+//
+//	{
+//	  "name": "acme.pixiv.v1",                 // required, must match the name in the config
+//	  "display_name": "Pixiv",
+//	  "description": "Fetches images from Pixiv.", // markdown
+//	  "author": "Acme",
+//	  "author_url": "https://example.com",
+//	  "require_parameter": true,
+//	  "parameter_help": "The ID of an artist.",  // markdown
+//	  "parameter_placeholder": "e.g. 12345",
+//	  "default_countback": 100,
+//	  "have_schedule_conflict_check": false,
+//	  "parameter_schema": null               // optional, see below
+//	}
+//
+// Plugins with multiple options can report a JSON Schema for an object as parameter_schema. The user then
+// fills in a form instead of a single string, and the parameter is a JSON object with compact JSON and
+// sorted keys, already validated against the schema.
+//
+// validate_parameter checks, and optionally normalizes, a parameter given by the user.
+//
+//	params: {"parameter": "https://www.pixiv.net/users/12345"}
+//	result: {"parameter": "12345"}
+//
+// schedule_conflict_check is only called if the plugin reports have_schedule_conflict_check.
+// The result is a warning for the user, or an empty string if there is no conflict.
+//
+//	params: {
+//	  "user_next_run": "2024-05-01T10:00:00Z",
+//	  "schedules": [{"source": {"id": 1, "name": "acme.pixiv.v1", "display_name": "My artist", "parameter": "12345", "countback": 100}, "next_runs": ["2024-05-01T10:01:00Z"]}]
+//	}
+//	result: {"warning": ""}
+//
+// run fetches image metadata. Plugins must not download the images themselves, see [source.Source].
+//
+//	params: {"parameter": "12345", "countback": 100, "filename_max_length": 100}
+//	result: {"images": [{
+//	  "download_url": "https://example.com/image.png", // required
+//	  "width": 1920, "height": 1080, "filesize": 123456,
+//	  "author": "artist", "author_url": "https://example.com/artist",
+//	  "website": "https://example.com/post/1", "thumbnail_url": "",
+//	  "posted_at": "2024-05-01T10:00:00Z", "filename": "acme_1.png",
+//	  "title": "Post title", "tags": ["landscape"], "nsfw": false
+//	}]}
+//
+// When a run is cancelled, claw sends a "cancel" notification with params {"id": <id of the run call>}.
+// The plugin should stop the run and answer it with an error. Plugins that do not support cancellation
+// may ignore the notification.
+//
+// Calls that exceed their timeout kill the plugin, since it is assumed to be stuck. Plugins that exit or
+// are killed are restarted on the next call. Plugins that keep crashing are restarted with an increasing delay.
+// Claw closes stdin of the plugin when it shuts down. The plugin should exit then.
+package plugin
@@ -0,0 +1,280 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const (
+	// minRestartDelay is the wait before restarting a plugin that crashed, doubled on every consecutive crash.
+	minRestartDelay = time.Second
+	maxRestartDelay = time.Minute
+	// stopGracePeriod is how long a plugin may take to exit after its stdin is closed.
+	stopGracePeriod = 5 * time.Second
+)
+
+var (
+	_ source.Source                = (*Plugin)(nil)
+	_ source.SourceParameterSchema = (*Plugin)(nil)
+	_ io.Closer                    = (*Plugin)(nil)
+)
+
+// Config configures a Plugin.
+type Config struct {
+	// Name is the source name the plugin implements.
+	Name string
+	// Command is the path to the plugin executable.
+	Command string
+	Args    []string
+	// Env is added to the environment of claw.
+	Env map[string]string
+	// Timeout limits describe, validate_parameter, and schedule_conflict_check calls.
+	Timeout time.Duration
+	// RunTimeout limits run calls.
+	RunTimeout time.Duration
+}
+
+// Plugin is a source implemented by an executable. See the package documentation for the protocol.
+//
+// The executable is started on first use, and restarted on the next use after it exits.
+type Plugin struct {
+	config Config
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	proc        *process
+	description description
+	described   bool
+	crashes     int
+	restartAt   time.Time
+	closed      bool
+}
+
+// New creates a plugin source. The executable is not started until the source is used.
+func New(config Config, logger *slog.Logger) *Plugin {
+	return &Plugin{config: config, logger: logger}
+}
+
+// Name returns the unique kind identifier for the source.
+func (pl *Plugin) Name() string {
+	return pl.config.Name
+}
+
+// describe returns the description reported by the plugin, starting it if needed.
+//
+// If the plugin cannot be started, a placeholder description explaining the error is returned,
+// so the source still shows up in the UI.
+func (pl *Plugin) describe() description {
+	ctx, cancel := context.WithTimeout(context.Background(), pl.config.Timeout)
+	defer cancel()
+	if _, err := pl.process(ctx); err != nil {
+		pl.mu.Lock()
+		defer pl.mu.Unlock()
+		if pl.described {
+			// Keep showing the last known description while the plugin is restarting.
+			return pl.description
+		}
+		return description{
+			Name:        pl.config.Name,
+			DisplayName: pl.config.Name,
+			Description: fmt.Sprintf("Plugin %s is not available: %s", pl.config.Command, err),
+		}
+	}
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return pl.description
+}
+
+// DisplayName returns the human-readable name for the source.
+func (pl *Plugin) DisplayName() string {
+	return pl.describe().DisplayName
+}
+
+// Description returns the human-readable description for the source.
+func (pl *Plugin) Description() string {
+	return pl.describe().Description
+}
+
+// Author returns the author name.
+func (pl *Plugin) Author() string {
+	return pl.describe().Author
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (pl *Plugin) AuthorURL() string {
+	return pl.describe().AuthorURL
+}
+
+func (pl *Plugin) RequireParameter() bool {
+	return pl.describe().RequireParameter
+}
+
+// ParameterHelp returns the help string for the parameter.
+func (pl *Plugin) ParameterHelp() string {
+	return pl.describe().ParameterHelp
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+func (pl *Plugin) ParameterPlaceholder() string {
+	return pl.describe().ParameterPlaceholder
+}
+
+// ParameterSchema returns the JSON Schema of the parameter reported by the plugin, if any.
+func (pl *Plugin) ParameterSchema() json.RawMessage {
+	schema := pl.describe().ParameterSchema
+	if string(schema) == "null" {
+		return nil
+	}
+	return schema
+}
+
+func (pl *Plugin) DefaultCountback() int {
+	return pl.describe().DefaultCountback
+}
+
+func (pl *Plugin) HaveScheduleConflictCheck() bool {
+	return pl.describe().HaveScheduleConflictCheck
+}
+
+// ScheduleConflictCheck asks the plugin for schedule conflicts. Failures are logged, and reported as no conflict.
+func (pl *Plugin) ScheduleConflictCheck(req source.ScheduleConflictCheckRequest) string {
+	ctx := context.Background()
+	var result scheduleConflictCheckResult
+	if err := pl.call(ctx, pl.config.Timeout, methodScheduleConflictCheck, scheduleConflictParams(req), &result); err != nil {
+		pl.logger.ErrorContext(ctx, "plugin failed to check schedule conflicts", "plugin", pl.config.Name, "error", err)
+		return ""
+	}
+	return result.Warning
+}
+
+// ValidateTransformParameter asks the plugin to validate and normalize the parameter.
+func (pl *Plugin) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	var result parameterMessage
+	if err := pl.call(ctx, pl.config.Timeout, methodValidateParameter, parameterMessage{Parameter: param}, &result); err != nil {
+		return "", err
+	}
+	return result.Parameter, nil
+}
+
+// Run asks the plugin for images. Images without download URL are dropped.
+func (pl *Plugin) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	var result runResult
+	params := runParams{
+		Parameter:         request.Parameter,
+		Countback:         request.Countback,
+		FilenameMaxLength: request.FilenameMaxLength,
+	}
+	if err := pl.call(ctx, pl.config.RunTimeout, methodRun, params, &result); err != nil {
+		return source.Response{}, fmt.Errorf("plugin %s failed to run: %w", pl.config.Name, err)
+	}
+	var images source.Images
+	for _, img := range result.Images {
+		if img.DownloadURL == "" {
+			pl.logger.WarnContext(ctx, "plugin returned image without download URL", "plugin", pl.config.Name)
+			continue
+		}
+		images = append(images, img.toSource())
+	}
+	return source.Response{Images: images}, nil
+}
+
+// call calls a method of the plugin, starting it if needed.
+func (pl *Plugin) call(ctx context.Context, timeout time.Duration, method string, params, result any) error {
+	proc, err := pl.process(ctx)
+	if err != nil {
+		return err
+	}
+	err = proc.call(ctx, timeout, method, params, result)
+	if proc.exited() {
+		pl.exited(proc)
+	} else if err == nil {
+		pl.mu.Lock()
+		pl.crashes = 0
+		pl.mu.Unlock()
+	}
+	return err
+}
+
+// process returns the running process, starting and describing it if it is not running.
+func (pl *Plugin) process(ctx context.Context) (*process, error) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.closed {
+		return nil, errors.New("plugin is closed")
+	}
+	if pl.proc != nil {
+		if !pl.proc.exited() {
+			return pl.proc, nil
+		}
+		pl.exitedLocked(pl.proc)
+	}
+	if wait := time.Until(pl.restartAt); wait > 0 {
+		return nil, fmt.Errorf("plugin %s crashed, restarting in %s", pl.config.Name, wait.Round(time.Second))
+	}
+
+	pl.logger.InfoContext(ctx, "starting plugin", "plugin", pl.config.Name, "command", pl.config.Command)
+	proc, err := startProcess(pl.config.Name, pl.config.Command, pl.config.Args, pl.config.Env, pl.logger)
+	if err != nil {
+		pl.crashedLocked()
+		return nil, err
+	}
+	var desc description
+	err = proc.call(ctx, pl.config.Timeout, methodDescribe, describeParams{Protocol: protocolVersion}, &desc)
+	if err == nil && desc.Name != pl.config.Name {
+		err = fmt.Errorf("plugin reports name %q, but is configured as %q", desc.Name, pl.config.Name)
+	}
+	if err != nil {
+		proc.stop(stopGracePeriod)
+		pl.crashedLocked()
+		return nil, fmt.Errorf("failed to describe plugin %s: %w", pl.config.Name, err)
+	}
+	pl.proc = proc
+	pl.description = desc
+	pl.described = true
+	return proc, nil
+}
+
+// exited handles a process that exited on its own or was killed.
+func (pl *Plugin) exited(proc *process) {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	pl.exitedLocked(proc)
+}
+
+func (pl *Plugin) exitedLocked(proc *process) {
+	if pl.proc != proc {
+		// Already handled.
+		return
+	}
+	pl.proc = nil
+	pl.logger.Warn("plugin exited", "plugin", pl.config.Name, "error", proc.exitErr)
+	pl.crashedLocked()
+}
+
+// crashedLocked delays the next start, increasing the delay on consecutive crashes.
+func (pl *Plugin) crashedLocked() {
+	delay := minRestartDelay << min(pl.crashes, 6)
+	pl.restartAt = time.Now().Add(min(delay, maxRestartDelay))
+	pl.crashes++
+}
+
+// Close stops the plugin. Its stdin is closed, and it is killed if it does not exit in time.
+func (pl *Plugin) Close() error {
+	pl.mu.Lock()
+	proc := pl.proc
+	pl.proc = nil
+	pl.closed = true
+	pl.mu.Unlock()
+	if proc != nil {
+		proc.stop(stopGracePeriod)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// fakePluginEnv makes the test binary act as a plugin, so tests do not need a separate executable.
+const fakePluginEnv = "CLAW_TEST_FAKE_PLUGIN"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(fakePluginEnv) == "1" {
+		runFakePlugin()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runFakePlugin answers calls over stdin and stdout until stdin is closed.
+func runFakePlugin() {
+	out := json.NewEncoder(os.Stdout)
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		var req struct {
+			ID     *int64          `json:"id"`
+			Method string          `json:"method"`
+			Params json.RawMessage `json:"params"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil || req.ID == nil {
+			continue
+		}
+		var param parameterMessage
+		_ = json.Unmarshal(req.Params, &param)
+		reply := map[string]any{"jsonrpc": "2.0", "id": *req.ID}
+		switch req.Method {
+		case methodDescribe:
+			reply["result"] = description{
+				Name:             os.Getenv("FAKE_PLUGIN_NAME"),
+				DisplayName:      "Fake",
+				RequireParameter: true,
+				DefaultCountback: 10,
+			}
+		case methodValidateParameter:
+			if param.Parameter == "" {
+				fmt.Fprintln(os.Stderr, "got empty parameter")
+				reply["error"] = rpcError{Code: 1, Message: "parameter is required"}
+			} else {
+				reply["result"] = parameterMessage{Parameter: strings.ToLower(param.Parameter)}
+			}
+		case methodRun:
+			switch param.Parameter {
+			case "crash":
+				fmt.Fprintln(os.Stderr, "something went wrong")
+				os.Exit(3)
+			case "hang":
+				time.Sleep(time.Minute)
+			}
+			reply["result"] = runResult{Images: []image{
+				{DownloadURL: "https://example.com/a.png", Width: 100, Height: 50, Tags: []string{"a"}},
+				{Title: "no download url"},
+			}}
+		}
+		_ = out.Encode(reply)
+	}
+}
+
+func newFakePlugin(t *testing.T, name string) *Plugin {
+	t.Helper()
+	executable, err := os.Executable()
+	require.NoError(t, err)
+	pl := New(Config{
+		Name:       "test.fake.v1",
+		Command:    executable,
+		Env:        map[string]string{fakePluginEnv: "1", "FAKE_PLUGIN_NAME": name},
+		Timeout:    5 * time.Second,
+		RunTimeout: time.Second,
+	}, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	t.Cleanup(func() { _ = pl.Close() })
+	return pl
+}
+
+func TestPlugin(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("describe", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.fake.v1")
+		assert.Equal(t, "Fake", pl.DisplayName())
+		assert.True(t, pl.RequireParameter())
+		assert.Equal(t, 10, pl.DefaultCountback())
+	})
+
+	t.Run("name mismatch", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.other.v1")
+		assert.Equal(t, "test.fake.v1", pl.DisplayName())
+		assert.Contains(t, pl.Description(), "not available")
+	})
+
+	t.Run("validate parameter", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.fake.v1")
+		param, err := pl.ValidateTransformParameter(ctx, "FOO")
+		require.NoError(t, err)
+		assert.Equal(t, "foo", param)
+
+		_, err = pl.ValidateTransformParameter(ctx, "")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "parameter is required")
+	})
+
+	t.Run("run", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.fake.v1")
+		resp, err := pl.Run(ctx, source.Request{Parameter: "foo", Countback: 10})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 1)
+		assert.Equal(t, "https://example.com/a.png", resp.Images[0].DownloadURL)
+		assert.Equal(t, []string{"a"}, resp.Images[0].Tags)
+	})
+
+	t.Run("crash and restart", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.fake.v1")
+		_, err := pl.Run(ctx, source.Request{Parameter: "crash"})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "something went wrong")
+
+		// Restarting is delayed after a crash.
+		_, err = pl.Run(ctx, source.Request{Parameter: "foo"})
+		require.Error(t, err)
+		pl.mu.Lock()
+		pl.restartAt = time.Time{}
+		pl.mu.Unlock()
+
+		_, err = pl.Run(ctx, source.Request{Parameter: "foo"})
+		require.NoError(t, err)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		pl := newFakePlugin(t, "test.fake.v1")
+		_, err := pl.Run(ctx, source.Request{Parameter: "hang"})
+		require.ErrorIs(t, err, errTimeout)
+	})
+}
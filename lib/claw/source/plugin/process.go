@@ -0,0 +1,269 @@
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxStderrLines is the number of stderr lines kept to be added to errors.
+const maxStderrLines = 20
+
+// errTimeout is returned by calls that exceeded their timeout.
+var errTimeout = errors.New("plugin did not respond in time")
+
+// process is a running plugin executable.
+type process struct {
+	name   string
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	logger *slog.Logger
+
+	writeMu sync.Mutex
+	nextID  atomic.Int64
+
+	mu      sync.Mutex
+	pending map[int64]*call
+	stderr  []string // last lines written to stderr
+
+	done    chan struct{} // closed when the process exited
+	exitErr error         // set before done is closed
+}
+
+// call is a request waiting for its response.
+type call struct {
+	ctx      context.Context
+	response chan response
+	// stderr holds the last lines written to stderr while the call was in flight.
+	stderr []string
+}
+
+// startProcess starts the plugin executable.
+func startProcess(name, command string, args []string, env map[string]string, logger *slog.Logger) (*process, error) {
+	cmd := exec.Command(command, args...)
+	cmd.Env = os.Environ()
+	for key, value := range env {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdin pipe: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start plugin %s: %w", command, err)
+	}
+
+	proc := &process{
+		name:    name,
+		cmd:     cmd,
+		stdin:   stdin,
+		logger:  logger,
+		pending: map[int64]*call{},
+		done:    make(chan struct{}),
+	}
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		proc.readResponses(stdout)
+	}()
+	go func() {
+		defer readers.Done()
+		proc.readStderr(stderr)
+	}()
+	go func() {
+		// Wait must only be called after the pipes are read to the end.
+		readers.Wait()
+		err := cmd.Wait()
+		proc.mu.Lock()
+		proc.exitErr = err
+		if proc.exitErr == nil {
+			proc.exitErr = errors.New("plugin exited")
+		}
+		proc.mu.Unlock()
+		close(proc.done)
+	}()
+	return proc, nil
+}
+
+// readResponses dispatches responses to their calls. Invalid output kills the process,
+// since there is no way to tell which call it belongs to.
+func (proc *process) readResponses(stdout io.Reader) {
+	decoder := json.NewDecoder(stdout)
+	for {
+		var resp response
+		if err := decoder.Decode(&resp); err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+				proc.logger.Error("plugin wrote invalid JSON-RPC message to stdout, killing it", "plugin", proc.name, "error", err)
+				proc.kill()
+				// Drain stdout, so the process does not block on writes until it is gone.
+				_, _ = io.Copy(io.Discard, stdout)
+			}
+			return
+		}
+		if resp.ID == nil {
+			// Notifications from plugins are not part of the protocol.
+			continue
+		}
+		proc.mu.Lock()
+		c, ok := proc.pending[*resp.ID]
+		delete(proc.pending, *resp.ID)
+		proc.mu.Unlock()
+		if !ok {
+			proc.logger.Warn("plugin responded to unknown or timed out call", "plugin", proc.name, "id", *resp.ID)
+			continue
+		}
+		c.response <- resp
+	}
+}
+
+// readStderr logs stderr lines, and keeps the last ones for error messages.
+//
+// Lines are logged with the context of the latest call in flight, so lines written
+// during a job run are logged with the job.
+func (proc *process) readStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		ctx := context.Background()
+		proc.mu.Lock()
+		proc.stderr = appendLine(proc.stderr, line)
+		var latest int64 = -1
+		for id, c := range proc.pending {
+			c.stderr = appendLine(c.stderr, line)
+			if id > latest {
+				latest, ctx = id, c.ctx
+			}
+		}
+		proc.mu.Unlock()
+		proc.logger.WarnContext(ctx, "plugin stderr", "plugin", proc.name, "line", line)
+	}
+}
+
+func appendLine(lines []string, line string) []string {
+	lines = append(lines, line)
+	if len(lines) > maxStderrLines {
+		lines = lines[len(lines)-maxStderrLines:]
+	}
+	return lines
+}
+
+// call sends a request, and decodes the result into result.
+//
+// When ctx is cancelled, a cancel notification is sent to the plugin. When timeout is exceeded,
+// the process is killed.
+func (proc *process) call(ctx context.Context, timeout time.Duration, method string, params, result any) error {
+	id := proc.nextID.Add(1)
+	c := &call{ctx: ctx, response: make(chan response, 1)}
+	proc.mu.Lock()
+	proc.pending[id] = c
+	proc.mu.Unlock()
+	defer func() {
+		proc.mu.Lock()
+		delete(proc.pending, id)
+		proc.mu.Unlock()
+	}()
+
+	if err := proc.send(request{JSONRPC: "2.0", ID: &id, Method: method, Params: params}); err != nil {
+		return proc.callStderr(fmt.Errorf("failed to send %s request to plugin: %w", method, err), c)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case resp := <-c.response:
+		if resp.Error != nil {
+			return proc.callStderr(errors.New(resp.Error.Message), c)
+		}
+		if result == nil {
+			return nil
+		}
+		if err := json.Unmarshal(resp.Result, result); err != nil {
+			return fmt.Errorf("plugin returned invalid %s result: %w", method, err)
+		}
+		return nil
+	case <-ctx.Done():
+		_ = proc.send(request{JSONRPC: "2.0", Method: methodCancel, Params: cancelParams{ID: id}})
+		return ctx.Err()
+	case <-timer.C:
+		proc.logger.ErrorContext(ctx, "plugin call timed out, killing it", "plugin", proc.name, "method", method, "timeout", timeout)
+		proc.kill()
+		return proc.callStderr(fmt.Errorf("%s: %w after %s", method, errTimeout, timeout), c)
+	case <-proc.done:
+		// All of stderr has been read when the process exited, including what was written before the call.
+		proc.mu.Lock()
+		lines := proc.stderr
+		proc.mu.Unlock()
+		return withStderr(fmt.Errorf("plugin exited during %s: %w", method, proc.exitErr), lines)
+	}
+}
+
+func (proc *process) send(req request) error {
+	b, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	proc.writeMu.Lock()
+	defer proc.writeMu.Unlock()
+	_, err = proc.stdin.Write(append(b, '\n'))
+	return err
+}
+
+// callStderr adds the stderr lines written during the call to err.
+func (proc *process) callStderr(err error, c *call) error {
+	proc.mu.Lock()
+	lines := c.stderr
+	proc.mu.Unlock()
+	return withStderr(err, lines)
+}
+
+func withStderr(err error, lines []string) error {
+	if len(lines) == 0 {
+		return err
+	}
+	return fmt.Errorf("%w\n\nplugin stderr:\n%s", err, strings.Join(lines, "\n"))
+}
+
+// exited reports whether the process has exited.
+func (proc *process) exited() bool {
+	select {
+	case <-proc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (proc *process) kill() {
+	_ = proc.cmd.Process.Kill()
+}
+
+// stop closes stdin of the process, and kills it if it does not exit within the grace period.
+func (proc *process) stop(grace time.Duration) {
+	_ = proc.stdin.Close()
+	select {
+	case <-proc.done:
+	case <-time.After(grace):
+		proc.kill()
+		<-proc.done
+	}
+}
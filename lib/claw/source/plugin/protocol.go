@@ -0,0 +1,152 @@
+package plugin
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// protocolVersion is sent to the plugin in the describe call.
+const protocolVersion = 1
+
+const (
+	methodDescribe              = "describe"
+	methodValidateParameter     = "validate_parameter"
+	methodScheduleConflictCheck = "schedule_conflict_check"
+	methodRun                   = "run"
+	methodCancel                = "cancel"
+)
+
+type request struct {
+	JSONRPC string `json:"jsonrpc"`
+	// ID is nil for notifications.
+	ID     *int64 `json:"id,omitempty"`
+	Method string `json:"method"`
+	Params any    `json:"params,omitempty"`
+}
+
+type response struct {
+	ID     *int64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type describeParams struct {
+	Protocol int `json:"protocol"`
+}
+
+type description struct {
+	Name                      string `json:"name"`
+	DisplayName               string `json:"display_name"`
+	Description               string `json:"description"`
+	Author                    string `json:"author"`
+	AuthorURL                 string `json:"author_url"`
+	RequireParameter          bool   `json:"require_parameter"`
+	ParameterHelp             string `json:"parameter_help"`
+	ParameterPlaceholder      string `json:"parameter_placeholder"`
+	DefaultCountback          int    `json:"default_countback"`
+	HaveScheduleConflictCheck bool   `json:"have_schedule_conflict_check"`
+	// ParameterSchema is null for plugins with a string parameter.
+	ParameterSchema json.RawMessage `json:"parameter_schema,omitempty"`
+}
+
+type parameterMessage struct {
+	Parameter string `json:"parameter"`
+}
+
+type scheduleConflictCheckParams struct {
+	UserNextRun time.Time  `json:"user_next_run"`
+	Schedules   []schedule `json:"schedules"`
+}
+
+type schedule struct {
+	Source   scheduleSource `json:"source"`
+	NextRuns []time.Time    `json:"next_runs"`
+}
+
+type scheduleSource struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Parameter   string `json:"parameter"`
+	Countback   int64  `json:"countback"`
+}
+
+type scheduleConflictCheckResult struct {
+	Warning string `json:"warning"`
+}
+
+type runParams struct {
+	Parameter         string `json:"parameter"`
+	Countback         int    `json:"countback"`
+	FilenameMaxLength int    `json:"filename_max_length"`
+}
+
+type runResult struct {
+	Images []image `json:"images"`
+}
+
+type image struct {
+	DownloadURL  string    `json:"download_url"`
+	Width        int64     `json:"width"`
+	Height       int64     `json:"height"`
+	Filesize     int64     `json:"filesize"`
+	Author       string    `json:"author"`
+	AuthorURL    string    `json:"author_url"`
+	Website      string    `json:"website"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	PostedAt     time.Time `json:"posted_at"`
+	Filename     string    `json:"filename"`
+	Title        string    `json:"title"`
+	Tags         []string  `json:"tags"`
+	NSFW         bool      `json:"nsfw"`
+}
+
+type cancelParams struct {
+	ID int64 `json:"id"`
+}
+
+func (img image) toSource() source.Image {
+	return source.Image{
+		DownloadURL:  img.DownloadURL,
+		Width:        img.Width,
+		Height:       img.Height,
+		Filesize:     img.Filesize,
+		Author:       img.Author,
+		AuthorURL:    img.AuthorURL,
+		Website:      img.Website,
+		ThumbnailURL: img.ThumbnailURL,
+		PostedAt:     img.PostedAt,
+		Filename:     img.Filename,
+		Title:        img.Title,
+		Tags:         img.Tags,
+		NSFW:         img.NSFW,
+	}
+}
+
+func scheduleConflictParams(req source.ScheduleConflictCheckRequest) scheduleConflictCheckParams {
+	params := scheduleConflictCheckParams{UserNextRun: req.UserNextRun, Schedules: []schedule{}}
+	for _, sched := range req.Schedules {
+		var id int64
+		if sched.Source.ID != nil {
+			id = *sched.Source.ID
+		}
+		params.Schedules = append(params.Schedules, schedule{
+			Source: scheduleSource{
+				ID:          id,
+				Name:        sched.Source.Name,
+				DisplayName: sched.Source.DisplayName,
+				Parameter:   sched.Source.Parameter,
+				Countback:   sched.Source.Countback,
+			},
+			NextRuns: sched.NextRuns,
+		})
+	}
+	return params
+}
@@ -0,0 +1,263 @@
+package reddit
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultUserAgent = "claw/1.0"
+
+	defaultPublicBaseURL = "https://reddit.com"
+	defaultOAuthBaseURL  = "https://oauth.reddit.com"
+	defaultTokenURL      = "https://www.reddit.com/api/v1/access_token"
+
+	installedClientGrant = "https://oauth.reddit.com/grants/installed_client"
+
+	// maxRateLimitRetries is how many times a rate limited request is retried after pausing.
+	maxRateLimitRetries = 3
+	// defaultRateLimitPause is used when Reddit responds with 429 without telling when to retry.
+	defaultRateLimitPause = time.Minute
+	// tokenRefreshMargin refreshes access tokens this long before they expire.
+	tokenRefreshMargin = time.Minute
+)
+
+// Config configures how claw.reddit.v1 talks to Reddit.
+//
+// Without a ClientID, the public JSON endpoints of reddit.com are used anonymously.
+// With a ClientID, requests go to oauth.reddit.com with an access token of the app:
+//
+//   - With Username and Password, the "script" app authenticates as that user (password grant).
+//   - With only ClientSecret, the "script" app authenticates as itself (client credentials grant).
+//   - With only ClientID, the "installed app" authenticates as itself (installed client grant).
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+	UserAgent    string
+}
+
+func (c Config) userAgent() string {
+	if c.UserAgent != "" {
+		return c.UserAgent
+	}
+	return defaultUserAgent
+}
+
+type accessTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Error       string `json:"error"`
+}
+
+func (re *Reddit) currentConfig() Config {
+	if re.Config == nil {
+		return Config{}
+	}
+	return re.Config()
+}
+
+// get sends a GET request to the Reddit API. path is relative to the API root, e.g. "r/wallpapers.json".
+//
+// When Reddit reports the rate limit is used up, further requests are paused until the limit resets
+// instead of failing. Rate limited (429) requests are retried after the pause as well.
+//
+// The caller is responsible for closing the response body.
+func (re *Reddit) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	cfg := re.currentConfig()
+	for attempt := 0; ; attempt++ {
+		if err := re.waitForRateLimit(ctx); err != nil {
+			return nil, err
+		}
+		req, err := re.newAPIRequest(ctx, cfg, path, query)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := re.Client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		re.updateRateLimit(resp.Header)
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries:
+			re.pause(retryAfter(resp.Header))
+		case resp.StatusCode == http.StatusUnauthorized && cfg.ClientID != "" && attempt == 0:
+			// The token may have been revoked before it expired. Get a new one and try again.
+			re.invalidateToken()
+		default:
+			return resp, nil
+		}
+		_, _ = io.Copy(io.Discard, resp.Body)
+		_ = resp.Body.Close()
+	}
+}
+
+func (re *Reddit) newAPIRequest(ctx context.Context, cfg Config, path string, query url.Values) (*http.Request, error) {
+	baseURL := cmp.Or(re.publicBaseURL, defaultPublicBaseURL)
+	var token string
+	if cfg.ClientID != "" {
+		baseURL = cmp.Or(re.oauthBaseURL, defaultOAuthBaseURL)
+		var err error
+		token, err = re.accessToken(ctx, cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := url.Parse(baseURL + "/" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	if len(query) > 0 {
+		u.RawQuery = query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	// Reddit requires a User-Agent and blocks generic ones.
+	req.Header.Set("User-Agent", cfg.userAgent())
+	if token != "" {
+		req.Header.Set("Authorization", "bearer "+token)
+	}
+	return req, nil
+}
+
+// accessToken returns a valid access token for the app, requesting a new one if the cached token
+// is missing, about to expire, or was issued for different credentials.
+//
+// The token is requested without holding re.mu, so a slow token endpoint does not block rate limit
+// checks of other requests. Concurrent refreshes may each request a token; the last one is kept.
+func (re *Reddit) accessToken(ctx context.Context, cfg Config) (string, error) {
+	re.mu.Lock()
+	if re.token != "" && re.tokenConfig == cfg && time.Until(re.tokenExpiry) > tokenRefreshMargin {
+		token := re.token
+		re.mu.Unlock()
+		return token, nil
+	}
+	re.mu.Unlock()
+
+	token, expiry, err := re.requestAccessToken(ctx, cfg)
+	if err != nil {
+		return "", err
+	}
+
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.token = token
+	re.tokenConfig = cfg
+	re.tokenExpiry = expiry
+	return token, nil
+}
+
+// requestAccessToken requests a new access token from Reddit, and returns it with its expiry time.
+func (re *Reddit) requestAccessToken(ctx context.Context, cfg Config) (string, time.Time, error) {
+	form := url.Values{}
+	switch {
+	case cfg.Username != "":
+		form.Set("grant_type", "password")
+		form.Set("username", cfg.Username)
+		form.Set("password", cfg.Password)
+	case cfg.ClientSecret != "":
+		form.Set("grant_type", "client_credentials")
+	default:
+		form.Set("grant_type", installedClientGrant)
+		form.Set("device_id", "DO_NOT_TRACK_THIS_DEVICE")
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cmp.Or(re.tokenURL, defaultTokenURL), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create access token request: %w", err)
+	}
+	req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", cfg.userAgent())
+
+	resp, err := re.Client.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to request Reddit access token: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("failed to request Reddit access token: Reddit returned status %d, check the client ID and secret", resp.StatusCode)
+	}
+	var body accessTokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode Reddit access token response: %w", err)
+	}
+	// Wrong usernames or passwords are reported with status 200 and an error field.
+	if body.Error != "" {
+		return "", time.Time{}, fmt.Errorf("failed to request Reddit access token: %s", body.Error)
+	}
+	if body.AccessToken == "" {
+		return "", time.Time{}, errors.New("failed to request Reddit access token: response does not contain a token")
+	}
+
+	return body.AccessToken, time.Now().Add(time.Duration(body.ExpiresIn) * time.Second), nil
+}
+
+func (re *Reddit) invalidateToken() {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	re.token = ""
+}
+
+// updateRateLimit pauses further requests until the rate limit resets when Reddit reports
+// that no requests are remaining in the current window.
+func (re *Reddit) updateRateLimit(header http.Header) {
+	remaining, err := strconv.ParseFloat(header.Get("X-Ratelimit-Remaining"), 64)
+	if err != nil || remaining >= 1 {
+		return
+	}
+	reset, err := strconv.ParseFloat(header.Get("X-Ratelimit-Reset"), 64)
+	if err != nil {
+		return
+	}
+	re.pause(time.Duration(reset * float64(time.Second)))
+}
+
+// pause delays all further requests for d.
+func (re *Reddit) pause(d time.Duration) {
+	re.mu.Lock()
+	defer re.mu.Unlock()
+	if until := time.Now().Add(d); until.After(re.pausedUntil) {
+		re.pausedUntil = until
+	}
+}
+
+func (re *Reddit) waitForRateLimit(ctx context.Context) error {
+	re.mu.Lock()
+	wait := time.Until(re.pausedUntil)
+	re.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// retryAfter returns how long to wait before retrying a rate limited request.
+func retryAfter(header http.Header) time.Duration {
+	for _, key := range []string{"Retry-After", "X-Ratelimit-Reset"} {
+		if seconds, err := strconv.ParseFloat(header.Get(key), 64); err == nil && seconds > 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+	return defaultRateLimitPause
+}
@@ -0,0 +1,192 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// fakeRedditAPI is an httptest stand-in for the Reddit token endpoint and OAuth API.
+type fakeRedditAPI struct {
+	tokens    atomic.Int32
+	listings  atomic.Int32
+	expiresIn int64
+	// rateLimited is the number of listing requests to reject with 429 before succeeding.
+	rateLimited atomic.Int32
+	// exhausted makes the first listing response report that the rate limit is used up.
+	exhausted atomic.Bool
+	// tokenGate, if set, holds token requests until it is closed.
+	tokenGate chan struct{}
+}
+
+func (f *fakeRedditAPI) handler(t *testing.T) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/v1/access_token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		assert.True(t, ok)
+		assert.Equal(t, "client-id", id)
+		assert.Equal(t, "client-secret", secret)
+		assert.Equal(t, "claw-test/1.0", r.UserAgent())
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "password", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "someone", r.PostForm.Get("username"))
+		assert.Equal(t, "hunter2", r.PostForm.Get("password"))
+
+		if f.tokenGate != nil {
+			<-f.tokenGate
+		}
+		n := f.tokens.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "token-" + string(rune('0'+n)),
+			"token_type":   "bearer",
+			"expires_in":   f.expiresIn,
+		})
+	})
+	mux.HandleFunc("GET /r/wallpapers/top.json", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "bearer token-"+string(rune('0'+f.tokens.Load())), r.Header.Get("Authorization"))
+		assert.Equal(t, "claw-test/1.0", r.UserAgent())
+		assert.Equal(t, "week", r.URL.Query().Get("t"))
+
+		if f.rateLimited.Load() > 0 {
+			f.rateLimited.Add(-1)
+			w.Header().Set("Retry-After", "0.2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if f.listings.Add(1) == 1 && f.exhausted.Load() {
+			w.Header().Set("X-Ratelimit-Remaining", "0.0")
+			w.Header().Set("X-Ratelimit-Reset", "0.5")
+		} else {
+			w.Header().Set("X-Ratelimit-Remaining", "99.0")
+			w.Header().Set("X-Ratelimit-Reset", "300")
+		}
+		_, _ = w.Write([]byte(`{"data":{"after":null,"children":[{"kind":"t3","data":{"id":"abc","title":"Lake","url":"https://i.redd.it/lake.jpg","post_hint":"image","subreddit":"wallpapers","permalink":"/r/wallpapers/comments/abc/lake/"}}]}}`))
+	})
+	return mux
+}
+
+func newOAuthTestReddit(t *testing.T, api *fakeRedditAPI) *Reddit {
+	srv := httptest.NewServer(api.handler(t))
+	t.Cleanup(srv.Close)
+	return &Reddit{
+		Client: srv.Client(),
+		Config: func() Config {
+			return Config{
+				ClientID:     "client-id",
+				ClientSecret: "client-secret",
+				Username:     "someone",
+				Password:     "hunter2",
+				UserAgent:    "claw-test/1.0",
+			}
+		},
+		oauthBaseURL: srv.URL,
+		tokenURL:     srv.URL + "/api/v1/access_token",
+	}
+}
+
+func TestRedditOAuth_TokenReuseAndRefresh(t *testing.T) {
+	request := source.Request{Parameter: "r/wallpapers/top?t=week", Countback: 1}
+
+	t.Run("token is reused until it expires", func(t *testing.T) {
+		api := &fakeRedditAPI{expiresIn: 3600}
+		reddit := newOAuthTestReddit(t, api)
+
+		for range 3 {
+			resp, err := reddit.Run(context.Background(), request)
+			require.NoError(t, err)
+			require.Len(t, resp.Images, 1)
+			assert.Equal(t, "https://i.redd.it/lake.jpg", resp.Images[0].DownloadURL)
+		}
+		assert.Equal(t, int32(1), api.tokens.Load())
+		assert.Equal(t, int32(3), api.listings.Load())
+	})
+
+	t.Run("token about to expire is refreshed", func(t *testing.T) {
+		// Tokens expiring within the refresh margin are refreshed before every request.
+		api := &fakeRedditAPI{expiresIn: 30}
+		reddit := newOAuthTestReddit(t, api)
+
+		for range 2 {
+			_, err := reddit.Run(context.Background(), request)
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), api.tokens.Load())
+	})
+}
+
+func TestRedditOAuth_TokenRequestDoesNotBlock(t *testing.T) {
+	api := &fakeRedditAPI{expiresIn: 3600, tokenGate: make(chan struct{})}
+	reddit := newOAuthTestReddit(t, api)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := reddit.accessToken(context.Background(), reddit.Config())
+		done <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	// Rate limit updates of other requests go through while the token request is pending.
+	paused := make(chan struct{})
+	go func() {
+		reddit.pause(0)
+		close(paused)
+	}()
+	select {
+	case <-paused:
+	case <-time.After(time.Second):
+		t.Fatal("pause was blocked by the pending token request")
+	}
+
+	close(api.tokenGate)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(1), api.tokens.Load())
+}
+
+func TestRedditOAuth_RateLimit(t *testing.T) {
+	request := source.Request{Parameter: "r/wallpapers/top?t=week", Countback: 1}
+
+	t.Run("pauses when rate limit is used up", func(t *testing.T) {
+		api := &fakeRedditAPI{expiresIn: 3600}
+		api.exhausted.Store(true)
+		reddit := newOAuthTestReddit(t, api)
+
+		_, err := reddit.Run(context.Background(), request)
+		require.NoError(t, err)
+
+		start := time.Now()
+		_, err = reddit.Run(context.Background(), request)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+	})
+
+	t.Run("retries after 429", func(t *testing.T) {
+		api := &fakeRedditAPI{expiresIn: 3600}
+		api.rateLimited.Store(2)
+		reddit := newOAuthTestReddit(t, api)
+
+		start := time.Now()
+		resp, err := reddit.Run(context.Background(), request)
+		require.NoError(t, err)
+		assert.Len(t, resp.Images, 1)
+		assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	})
+
+	t.Run("pause is cut short by context cancellation", func(t *testing.T) {
+		api := &fakeRedditAPI{expiresIn: 3600}
+		reddit := newOAuthTestReddit(t, api)
+		reddit.pause(time.Hour)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		_, err := reddit.Run(ctx, request)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
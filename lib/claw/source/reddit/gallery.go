@@ -0,0 +1,106 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// maxThumbnailWidth is the widest preview picked as the thumbnail of a gallery item.
+const maxThumbnailWidth = 1080
+
+// resolveCrosspost replaces the media of a crosspost with the media of the original post.
+//
+// Everything else, like the title, author, and permalink, is kept from the crosspost,
+// since that is where the user found the image.
+func (re *Reddit) resolveCrosspost(post RedditPostData) RedditPostData {
+	if len(post.CrosspostParentList) == 0 {
+		return post
+	}
+	parent := post.CrosspostParentList[0]
+	post.URL = parent.URL
+	post.PostHint = parent.PostHint
+	post.Preview = parent.Preview
+	post.IsGallery = parent.IsGallery
+	post.GalleryData = parent.GalleryData
+	post.MediaMetadata = parent.MediaMetadata
+	post.Over18 = post.Over18 || parent.Over18
+	post.CrosspostParentList = nil
+	return post
+}
+
+// convertGalleryPost converts every still image of a gallery post to its own source.Image.
+//
+// Items are returned in gallery order. Animated items and items Reddit reports as
+// not (yet) valid, e.g. still processing or removed, are skipped.
+func (re *Reddit) convertGalleryPost(ctx context.Context, post RedditPostData, request source.Request) source.Images {
+	if post.GalleryData == nil {
+		return nil
+	}
+
+	var images source.Images
+	for _, item := range post.GalleryData.Items {
+		media, ok := post.MediaMetadata[item.MediaID]
+		if !ok || media.Status != "valid" || media.Kind != "Image" {
+			continue
+		}
+		downloadURL := galleryMediaURL(item.MediaID, media)
+		if downloadURL == "" {
+			continue
+		}
+
+		title := html.UnescapeString(post.Title)
+		if caption := strings.TrimSpace(html.UnescapeString(item.Caption)); caption != "" {
+			title = fmt.Sprintf("%s - %s", title, caption)
+		}
+
+		images = append(images, source.Image{
+			DownloadURL:  downloadURL,
+			Width:        int64(media.Source.Width),
+			Height:       int64(media.Source.Height),
+			Title:        title,
+			Tags:         re.postTags(post),
+			Author:       post.Author,
+			AuthorURL:    fmt.Sprintf("https://reddit.com/u/%s", post.Author),
+			Website:      fmt.Sprintf("https://reddit.com%s", post.Permalink),
+			ThumbnailURL: galleryThumbnailURL(media),
+			PostedAt:     time.Unix(int64(post.CreatedUTC), 0),
+			Filename:     re.generateFilename(ctx, post.ID, downloadURL, request),
+			NSFW:         post.Over18,
+		})
+	}
+	return images
+}
+
+// galleryMediaURL returns the full resolution i.redd.it URL of a gallery item.
+//
+// The source URL in the metadata points to preview.redd.it, which is signed and may be re-encoded,
+// so the original is requested from i.redd.it using the media ID and mime type instead.
+func galleryMediaURL(mediaID string, media RedditMediaMetadata) string {
+	ext := strings.TrimPrefix(media.MimeType, "image/")
+	switch ext {
+	case "jpg", "jpeg":
+		ext = "jpg"
+	case "png", "gif", "webp":
+	default:
+		// Unknown type, fall back to the preview URL.
+		return html.UnescapeString(media.Source.URL)
+	}
+	return fmt.Sprintf("https://i.redd.it/%s.%s", mediaID, ext)
+}
+
+// galleryThumbnailURL returns the largest preview that is not wider than maxThumbnailWidth.
+func galleryThumbnailURL(media RedditMediaMetadata) string {
+	var thumbnail string
+	for _, preview := range media.Previews {
+		if preview.Width > maxThumbnailWidth {
+			break
+		}
+		thumbnail = preview.URL
+	}
+	return html.UnescapeString(thumbnail)
+}
@@ -0,0 +1,250 @@
+package reddit
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// listingKind is the kind of Reddit listing a parameter points to.
+type listingKind int
+
+const (
+	listingSubreddit listingKind = iota
+	listingUser
+	listingMulti
+	listingSearch
+)
+
+var (
+	namePattern = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+	subredditSorts = []string{"hot", "new", "top", "rising"}
+	userSorts      = []string{"hot", "new", "top"}
+	searchSorts    = []string{"relevance", "hot", "top", "new", "comments"}
+	timeWindows    = []string{"hour", "day", "week", "month", "year", "all"}
+)
+
+// listing is a parsed claw.reddit.v1 parameter.
+//
+// The normalized form of a listing, as returned by [listing.String], is one of:
+//
+//	r/<subreddit>[+<subreddit>...][/<sort>][?t=<window>]
+//	r/<subreddit>[+<subreddit>...]/search?q=<query>[&sort=<sort>][&t=<window>]
+//	u/<user>[/<sort>][?t=<window>]
+//	user/<user>/m/<multireddit>[/<sort>][?t=<window>]
+type listing struct {
+	Kind listingKind
+	// Subreddits for listingSubreddit and listingSearch.
+	Subreddits []string
+	// User for listingUser and listingMulti.
+	User string
+	// Multi is the multireddit name for listingMulti.
+	Multi string
+	// Sort is the listing sort. Empty means Reddit's default ("hot", or "relevance" for searches).
+	Sort string
+	// Time is the time window for "top" listings and searches. Empty means Reddit's default.
+	Time string
+	// Query is the search query for listingSearch.
+	Query string
+}
+
+// parseListing parses a parameter in normalized form, shorthand form, or as a full Reddit URL.
+func parseListing(param string) (listing, error) {
+	var l listing
+	raw := strings.TrimSpace(param)
+	if raw == "" {
+		return l, errors.New("parameter cannot be empty")
+	}
+
+	var (
+		path  string
+		query url.Values
+	)
+	if strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return l, fmt.Errorf("invalid Reddit URL %q: %w", raw, err)
+		}
+		host := strings.ToLower(u.Hostname())
+		if host != "reddit.com" && !strings.HasSuffix(host, ".reddit.com") {
+			return l, fmt.Errorf("%q is not a Reddit URL", raw)
+		}
+		path, query = u.Path, u.Query()
+	} else {
+		p, rawQuery, _ := strings.Cut(raw, "?")
+		q, err := url.ParseQuery(rawQuery)
+		if err != nil {
+			return l, fmt.Errorf("invalid query %q: %w", rawQuery, err)
+		}
+		path, query = p, q
+	}
+
+	path = strings.Trim(path, "/")
+	path = strings.TrimSuffix(path, ".json")
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 {
+		return l, errors.New("parameter must start with r/, u/, or user/")
+	}
+
+	var sortSegment string
+	switch segments[0] {
+	case "r":
+		l.Kind = listingSubreddit
+		for sub := range strings.SplitSeq(segments[1], "+") {
+			if !namePattern.MatchString(sub) {
+				return l, fmt.Errorf("invalid subreddit name %q", sub)
+			}
+			l.Subreddits = append(l.Subreddits, sub)
+		}
+		switch len(segments) {
+		case 2:
+		case 3:
+			if segments[2] == "search" {
+				l.Kind = listingSearch
+			} else {
+				sortSegment = segments[2]
+			}
+		default:
+			return l, fmt.Errorf("unsupported subreddit path %q", path)
+		}
+	case "u", "user":
+		l.Kind = listingUser
+		l.User = segments[1]
+		if !namePattern.MatchString(l.User) {
+			return l, fmt.Errorf("invalid username %q", l.User)
+		}
+		rest := segments[2:]
+		if len(rest) >= 2 && rest[0] == "m" {
+			l.Kind = listingMulti
+			l.Multi = rest[1]
+			if !namePattern.MatchString(l.Multi) {
+				return l, fmt.Errorf("invalid multireddit name %q", l.Multi)
+			}
+			rest = rest[2:]
+		}
+		if len(rest) > 0 && rest[0] == "submitted" && l.Kind == listingUser {
+			// Reddit's own URL for user posts, e.g. /user/spez/submitted/?sort=top&t=week.
+			rest = rest[1:]
+		}
+		switch len(rest) {
+		case 0:
+		case 1:
+			sortSegment = rest[0]
+		default:
+			return l, fmt.Errorf("unsupported user path %q", path)
+		}
+	default:
+		return l, errors.New("parameter must start with r/, u/, or user/")
+	}
+
+	l.Sort = strings.ToLower(query.Get("sort"))
+	if sortSegment != "" {
+		l.Sort = strings.ToLower(sortSegment)
+	}
+	if l.Sort == "hot" && (l.Kind == listingSubreddit || l.Kind == listingMulti) {
+		// Hot is the default of subreddits and multireddits, but not of users.
+		l.Sort = ""
+	}
+	l.Time = strings.ToLower(query.Get("t"))
+	l.Query = strings.TrimSpace(query.Get("q"))
+	return l, l.validate()
+}
+
+func (l listing) validate() error {
+	sorts := subredditSorts
+	switch l.Kind {
+	case listingUser:
+		sorts = userSorts
+	case listingSearch:
+		sorts = searchSorts
+		if l.Query == "" {
+			return errors.New("search requires a query, e.g. r/wallpapers/search?q=mountains")
+		}
+	}
+	if l.Sort != "" && !slices.Contains(sorts, l.Sort) {
+		return fmt.Errorf("unsupported sort %q, must be one of: %s", l.Sort, strings.Join(sorts, ", "))
+	}
+	if l.Time != "" {
+		if !slices.Contains(timeWindows, l.Time) {
+			return fmt.Errorf("unsupported time window t=%q, must be one of: %s", l.Time, strings.Join(timeWindows, ", "))
+		}
+		if l.Kind != listingSearch && l.Sort != "top" {
+			return errors.New("time window t= is only supported for top listings and searches")
+		}
+	}
+	return nil
+}
+
+// base returns the listing's subreddits, user, or multireddit without sort, time window, or search.
+func (l listing) base() string {
+	switch l.Kind {
+	case listingUser:
+		return "u/" + l.User
+	case listingMulti:
+		return "user/" + l.User + "/m/" + l.Multi
+	default:
+		return "r/" + strings.Join(l.Subreddits, "+")
+	}
+}
+
+// chronological reports whether the listing is sorted newest first. The overview of a user is sorted by new
+// unless another sort is given.
+func (l listing) chronological() bool {
+	return l.Sort == "new" || (l.Kind == listingUser && l.Sort == "")
+}
+
+// String returns the normalized form of the listing.
+func (l listing) String() string {
+	path := l.base()
+	query := url.Values{}
+	if l.Kind == listingSearch {
+		path += "/search"
+		query.Set("q", l.Query)
+		if l.Sort != "" {
+			query.Set("sort", l.Sort)
+		}
+	}
+	if l.Kind != listingSearch && l.Sort != "" {
+		path += "/" + l.Sort
+	}
+	if l.Time != "" {
+		query.Set("t", l.Time)
+	}
+	if len(query) > 0 {
+		return path + "?" + query.Encode()
+	}
+	return path
+}
+
+// apiPath returns the path and query of the JSON API endpoint of the listing.
+func (l listing) apiPath() (string, url.Values) {
+	query := url.Values{}
+	if l.Time != "" {
+		query.Set("t", l.Time)
+	}
+	switch l.Kind {
+	case listingSearch:
+		query.Set("q", l.Query)
+		query.Set("restrict_sr", "1")
+		if l.Sort != "" {
+			query.Set("sort", l.Sort)
+		}
+		return "r/" + strings.Join(l.Subreddits, "+") + "/search.json", query
+	case listingUser:
+		if l.Sort == "" {
+			return "user/" + l.User + ".json", query
+		}
+		query.Set("sort", l.Sort)
+		return "user/" + l.User + "/submitted.json", query
+	}
+
+	path := l.base()
+	if l.Sort != "" {
+		path += "/" + l.Sort
+	}
+	return path + ".json", query
+}
@@ -0,0 +1,114 @@
+package reddit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseListing(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		normalized string
+		apiURL     string
+	}{
+		{"subreddit", "r/wallpapers", "r/wallpapers", "r/wallpapers.json"},
+		{"subreddit with leading slash", "/r/wallpapers/", "r/wallpapers", "r/wallpapers.json"},
+		{"explicit hot", "r/wallpapers/hot", "r/wallpapers", "r/wallpapers.json"},
+		{"new", "r/wallpapers/new", "r/wallpapers/new", "r/wallpapers/new.json"},
+		{"rising", "r/wallpapers/rising.json", "r/wallpapers/rising", "r/wallpapers/rising.json"},
+		{"top of the week", "r/wallpapers/top?t=week", "r/wallpapers/top?t=week", "r/wallpapers/top.json?t=week"},
+		{"top URL", "https://www.reddit.com/r/wallpapers/top/?t=WEEK", "r/wallpapers/top?t=week", "r/wallpapers/top.json?t=week"},
+		{"old reddit URL", "https://old.reddit.com/r/wallpapers/new/", "r/wallpapers/new", "r/wallpapers/new.json"},
+		{"multireddit", "r/wallpapers+earthporn+spaceporn", "r/wallpapers+earthporn+spaceporn", "r/wallpapers+earthporn+spaceporn.json"},
+		{"multireddit top", "r/wallpapers+earthporn/top?t=month", "r/wallpapers+earthporn/top?t=month", "r/wallpapers+earthporn/top.json?t=month"},
+		{"user", "u/spez", "u/spez", "user/spez.json"},
+		{"user long form", "user/spez", "u/spez", "user/spez.json"},
+		{"user top", "u/spez/top?t=all", "u/spez/top?t=all", "user/spez/submitted.json?sort=top&t=all"},
+		{"user hot", "u/spez/hot", "u/spez/hot", "user/spez/submitted.json?sort=hot"},
+		{"user submitted URL", "https://reddit.com/user/spez/submitted/?sort=top&t=year", "u/spez/top?t=year", "user/spez/submitted.json?sort=top&t=year"},
+		{"user multireddit", "user/someone/m/scenery", "user/someone/m/scenery", "user/someone/m/scenery.json"},
+		{"user multireddit short", "u/someone/m/scenery/top?t=day", "user/someone/m/scenery/top?t=day", "user/someone/m/scenery/top.json?t=day"},
+		{"search", "r/wallpapers/search?q=mountain lake", "r/wallpapers/search?q=mountain+lake", "r/wallpapers/search.json?q=mountain+lake&restrict_sr=1"},
+		{"search sorted", "https://www.reddit.com/r/wallpapers/search/?q=city&sort=top&t=year&restrict_sr=1", "r/wallpapers/search?q=city&sort=top&t=year", "r/wallpapers/search.json?q=city&restrict_sr=1&sort=top&t=year"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := parseListing(tt.input)
+			require.NoError(t, err)
+			assert.Equal(t, tt.normalized, l.String())
+
+			path, query := l.apiPath()
+			apiURL := path
+			if len(query) > 0 {
+				apiURL += "?" + query.Encode()
+			}
+			assert.Equal(t, tt.apiURL, apiURL)
+
+			// The normalized form must parse to the same listing.
+			again, err := parseListing(l.String())
+			require.NoError(t, err)
+			assert.Equal(t, l, again)
+		})
+	}
+}
+
+func TestParseListing_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"empty", "", "cannot be empty"},
+		{"no prefix", "wallpapers", "must start with"},
+		{"unknown sort", "r/wallpapers/best", "unsupported sort"},
+		{"rising for users", "u/spez/rising", "unsupported sort"},
+		{"time window without top", "r/wallpapers/new?t=week", "only supported for top"},
+		{"unknown time window", "r/wallpapers/top?t=decade", "unsupported time window"},
+		{"search without query", "r/wallpapers/search", "requires a query"},
+		{"empty multireddit member", "r/wallpapers++earthporn", "invalid subreddit name"},
+		{"not reddit", "https://example.com/r/wallpapers", "not a Reddit URL"},
+		{"too deep", "r/wallpapers/top/extra", "unsupported subreddit path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseListing(tt.input)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+// echoDoer answers every request with 200 OK, as if every subreddit and user exists with the requested casing.
+type echoDoer struct {
+	requested []string
+}
+
+func (d *echoDoer) Do(req *http.Request) (*http.Response, error) {
+	d.requested = append(d.requested, req.URL.String())
+	return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: req}, nil
+}
+
+func TestValidateTransformParameter_Listings(t *testing.T) {
+	doer := &echoDoer{}
+	reddit := &Reddit{Client: doer}
+
+	got, err := reddit.ValidateTransformParameter(context.Background(), "https://www.reddit.com/r/wallpapers+earthporn/top/?t=week")
+	require.NoError(t, err)
+	assert.Equal(t, "r/wallpapers+earthporn/top?t=week", got)
+	assert.Equal(t, []string{"https://reddit.com/r/wallpapers.json", "https://reddit.com/r/earthporn.json"}, doer.requested)
+
+	got, err = reddit.ValidateTransformParameter(context.Background(), "user/someone/m/scenery")
+	require.NoError(t, err)
+	assert.Equal(t, "user/someone/m/scenery", got)
+
+	_, err = reddit.ValidateTransformParameter(context.Background(), "r/wallpapers/best")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid Reddit parameter format")
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tigorlazuardi/claw/lib/claw/source"
@@ -18,19 +19,38 @@ type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
 
-var _ source.Source = (*Reddit)(nil)
+var (
+	_ source.Source           = (*Reddit)(nil)
+	_ source.SourceBackfiller = (*Reddit)(nil)
+)
 
 type Reddit struct {
 	source.UnimplementedSource
 
 	Client Doer
+	// Config returns the current configuration. It is called for every request, so configuration
+	// changes are picked up without restarting.
+	//
+	// Optional. If nil, the public endpoints are used anonymously.
+	Config func() Config
+
+	// Endpoints, replaced in tests. Empty means the real Reddit endpoints.
+	publicBaseURL string
+	oauthBaseURL  string
+	tokenURL      string
+
+	mu          sync.Mutex
+	token       string
+	tokenConfig Config
+	tokenExpiry time.Time
+	pausedUntil time.Time
 }
 
 // HaveScheduleConflictCheck returns whether this source has custom schedule conflict check.
 // Usually to avoid schedule too close to each other causing rate limit issues.
 //
 // If true, Claw will call [ScheduleConflictCheck] to check for schedule conflicts.
-func (re Reddit) HaveScheduleConflictCheck() bool {
+func (re *Reddit) HaveScheduleConflictCheck() bool {
 	return true
 }
 
@@ -87,15 +107,17 @@ func getClockDelta(t1, t2 time.Time) time.Duration {
 	return dur
 }
 
-func (re Reddit) Description() string {
-	return `Fetches images from a Reddit user or subreddit.`
+func (re *Reddit) Description() string {
+	return `Fetches images from a Reddit user or subreddit.
+
+Every image of a gallery post is fetched as its own image, and crossposts are resolved to the original post's images.`
 }
 
-func (re Reddit) RequireParameter() bool {
+func (re *Reddit) RequireParameter() bool {
 	return true
 }
 
-func (re Reddit) DefaultCountback() int {
+func (re *Reddit) DefaultCountback() int {
 	return 300
 }
 
@@ -108,6 +130,21 @@ const helpString = /*markdown*/
 - /r/{subreddit}
 - /user/{user} (will be normalized to /u/{user})
 - /user/{user}.json (will be normalized to /u/{user})
+
+Listings can be sorted and combined:
+
+- r/{subreddit}/{sort}, where sort is one of hot (default), new, top, or rising.
+- r/{subreddit}/top?t={window}, where window is one of hour, day, week, month, year, or all. e.g. r/wallpapers/top?t=week
+- r/{a}+{b}+{c} to combine multiple subreddits, e.g. r/wallpapers+earthporn/top?t=week
+- u/{user}/{sort}, where sort is one of hot, new (default), or top.
+- user/{user}/m/{multireddit}[/{sort}] for a user's multireddit.
+- r/{subreddit}/search?q={query} to search within the subreddit.
+  Optionally add &sort= (relevance, hot, top, new, comments) and &t={window}.
+
+Full Reddit URLs to any of the above are accepted as well.
+
+Listings sorted by new (including u/{user} without a sort) only fetch posts newer than the last run,
+so countback only limits how far back the first run goes.
 `
 
 // ParameterHelp returns the help string for the parameter.
@@ -120,7 +157,7 @@ func (re *Reddit) ParameterHelp() string {
 //
 // This is usually a very short string to show as a hint for the user.
 func (re *Reddit) ParameterPlaceholder() string {
-	return `Subreddit name or username, e.g. /r/wallpapers or /u/spez`
+	return `Subreddit, user, or listing, e.g. /r/wallpapers, /u/spez, or r/wallpapers/top?t=week`
 }
 
 // Name returns the unique kind identifier for the source.
@@ -145,12 +182,12 @@ func (re *Reddit) ParameterPlaceholder() string {
 // Names are tied heavily to parameters. If your parameter schema changes,
 // you should also change the name (e.g. bump the version) to avoid
 // compatibility issues.
-func (re Reddit) Name() string {
+func (re *Reddit) Name() string {
 	return SourceName
 }
 
 // DisplayName returns the human-readable name for the source.
-func (re Reddit) DisplayName() string {
+func (re *Reddit) DisplayName() string {
 	return "Reddit"
 }
 
@@ -179,7 +216,8 @@ var (
 //   - Full URL to a subreddit, e.g. https://reddit.com/r/wallpapers
 //   - Also accept shorthand expression: r/wallpapers.
 //   - claw.reddit.v1 also tries to match casing.
-//   - If parameter is a user (e.g. u/somebody) -> it will be normalized to user/somebody.
+//   - If parameter is a user (e.g. user/somebody) -> it will be normalized to u/somebody.
+//   - Sorted listings, multireddits, and searches are normalized to the forms documented in [listing].
 //
 // The error message (the .Error() method) must be user friendly and contain all necessary information
 // to fix the parameter.
@@ -209,47 +247,53 @@ func (re *Reddit) ValidateTransformParameter(ctx context.Context, param string)
 		return re.validateAndNormalizeCasing(ctx, normalized)
 	}
 
-	// If no patterns match, return error with helpful message
-	return "", fmt.Errorf("invalid Reddit parameter format. Supported patterns:\n" +
-		"- https://reddit.com/u/<user>\n" +
-		"- https://reddit.com/u/<user>.json\n" +
-		"- https://reddit.com/user/<user>\n" +
-		"- https://reddit.com/user/<user>.json\n" +
-		"- https://reddit.com/r/<subreddit>\n" +
-		"- https://reddit.com/r/<subreddit>.json\n" +
-		"- /u/<user>\n" +
-		"- /u/<user>.json\n" +
-		"- /r/<subreddit>\n" +
-		"- /r/<subreddit>.json\n" +
-		"- /user/<user> (will be normalized to /u/<user>)\n" +
-		"- /user/<user>.json (will be normalized to /u/<user>)\n" +
-		"\nBracketed means they are optional.",
-	)
+	// Extended listings: sorts, time windows, multireddits, and searches.
+	l, err := parseListing(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid Reddit parameter format: %w\n\n%s", err, helpString)
+	}
+	switch l.Kind {
+	case listingSubreddit, listingSearch:
+		for i, sub := range l.Subreddits {
+			if l.Subreddits[i], err = re.normalizeNameCasing(ctx, "r", sub); err != nil {
+				return "", err
+			}
+		}
+	case listingUser, listingMulti:
+		if l.User, err = re.normalizeNameCasing(ctx, "u", l.User); err != nil {
+			return "", err
+		}
+	}
+	return l.String(), nil
+}
+
+// normalizeNameCasing validates a single subreddit (prefix "r") or user (prefix "u") against Reddit API
+// and returns the name with proper casing.
+func (re *Reddit) normalizeNameCasing(ctx context.Context, prefix, name string) (string, error) {
+	normalized, err := re.validateAndNormalizeCasing(ctx, prefix+"/"+name)
+	if err != nil {
+		return "", err
+	}
+	normalized = strings.TrimPrefix(normalized, "/")
+	return strings.TrimPrefix(normalized, prefix+"/"), nil
 }
 
 // validateAndNormalizeCasing validates the parameter against Reddit API and normalizes casing
 func (re *Reddit) validateAndNormalizeCasing(ctx context.Context, param string) (string, error) {
-	// Construct the JSON API URL
-	jsonURL := "https://reddit.com/" + param + ".json"
-
-	// Create HTTP request
-	req, err := http.NewRequestWithContext(ctx, "GET", jsonURL, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+	// Users are served under /user/ by both the public and the OAuth API.
+	requestPath := param + ".json"
+	if user, ok := strings.CutPrefix(param, "u/"); ok {
+		requestPath = "user/" + user + ".json"
 	}
 
-	// Set User-Agent header (Reddit requires this)
-	req.Header.Set("User-Agent", "claw/1.0")
-
-	// Make the request
-	resp, err := re.Client.Do(req)
+	resp, err := re.get(ctx, requestPath, nil)
 	if err != nil {
 		return "", fmt.Errorf("failed to validate parameter against Reddit API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	// Handle redirects by checking the final URL
-	if resp.Request.URL.String() != jsonURL {
+	if resp.Request.URL.Path != "/"+requestPath {
 		// Parse the redirected URL to get the proper casing
 		finalURL := resp.Request.URL.String()
 		parsedURL, err := url.Parse(finalURL)
@@ -69,7 +69,7 @@ func TestUserPatternRegex(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			matches := userPattern.FindStringSubmatch(tt.input)
-			
+
 			if tt.match {
 				require.Len(t, matches, 3, "Expected match with 3 groups for input %q", tt.input)
 				assert.Equal(t, tt.wantType, matches[1], "Expected type %q for input %q", tt.wantType, tt.input)
@@ -83,10 +83,10 @@ func TestUserPatternRegex(t *testing.T) {
 
 func TestSubredditPatternRegex(t *testing.T) {
 	tests := []struct {
-		name        string
-		input       string
+		name          string
+		input         string
 		wantSubreddit string
-		match       bool
+		match         bool
 	}{
 		// Basic subreddit patterns
 		{"/r/subreddit", "/r/testsubreddit", "testsubreddit", true},
@@ -127,7 +127,7 @@ func TestSubredditPatternRegex(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			matches := subredditPattern.FindStringSubmatch(tt.input)
-			
+
 			if tt.match {
 				require.Len(t, matches, 2, "Expected match with 2 groups for input %q", tt.input)
 				assert.Equal(t, tt.wantSubreddit, matches[1], "Expected subreddit %q for input %q", tt.wantSubreddit, tt.input)
@@ -164,7 +164,7 @@ func TestPatternSpecificCases(t *testing.T) {
 	t.Run("both http and https work", func(t *testing.T) {
 		httpMatch := userPattern.FindStringSubmatch("http://reddit.com/u/testuser")
 		httpsMatch := userPattern.FindStringSubmatch("https://reddit.com/u/testuser")
-		
+
 		assert.Len(t, httpMatch, 3, "Expected match for http URL")
 		assert.Len(t, httpsMatch, 3, "Expected match for https URL")
 	})
@@ -184,7 +184,7 @@ func TestPatternSpecificCases(t *testing.T) {
 	t.Run("special characters in usernames/subreddits", func(t *testing.T) {
 		validChars := []string{"test_user", "test-user", "user123", "123user", "a", "A"}
 		invalidChars := []string{"test user", "test.user", "test@user", "test#user", "test!user"}
-		
+
 		for _, valid := range validChars {
 			matches := userPattern.FindStringSubmatch("/u/" + valid)
 			assert.Len(t, matches, 3, "Expected valid username %q to match", valid)
@@ -195,4 +195,4 @@ func TestPatternSpecificCases(t *testing.T) {
 			assert.Empty(t, matches, "Expected invalid username %q to not match", invalid)
 		}
 	})
-}
\ No newline at end of file
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"net/http"
 	"net/url"
 	"path/filepath"
@@ -27,6 +28,8 @@ type RedditData struct {
 }
 
 type RedditPost struct {
+	// Kind is "t3" for posts. Overviews of users also list comments, whose kind is "t1".
+	Kind string         `json:"kind"`
 	Data RedditPostData `json:"data"`
 }
 
@@ -39,7 +42,9 @@ type RedditPostData struct {
 	CreatedUTC float64 `json:"created_utc"`
 	PostHint   string  `json:"post_hint"`
 	Subreddit  string  `json:"subreddit"`
-	Preview    *struct {
+	// LinkFlairText is the post flair, e.g. "Desktop" or "Mobile". Empty if the post has no flair.
+	LinkFlairText string `json:"link_flair_text"`
+	Preview       *struct {
 		Images []struct {
 			Source struct {
 				URL    string `json:"url"`
@@ -49,6 +54,42 @@ type RedditPostData struct {
 		} `json:"images"`
 	} `json:"preview"`
 	Over18 bool `json:"over_18"`
+
+	// IsGallery is true for multi-image posts. The images are listed in GalleryData and described in MediaMetadata.
+	IsGallery     bool                           `json:"is_gallery"`
+	GalleryData   *RedditGalleryData             `json:"gallery_data"`
+	MediaMetadata map[string]RedditMediaMetadata `json:"media_metadata"`
+
+	// CrosspostParentList contains the original post if this post is a crosspost.
+	CrosspostParentList []RedditPostData `json:"crosspost_parent_list"`
+}
+
+type RedditGalleryData struct {
+	Items []struct {
+		MediaID string `json:"media_id"`
+		Caption string `json:"caption"`
+	} `json:"items"`
+}
+
+// RedditMediaMetadata describes a single media item of a gallery post.
+type RedditMediaMetadata struct {
+	ID string `json:"id"`
+	// Status is "valid" for media that can be displayed.
+	Status string `json:"status"`
+	// Kind is "Image" for still images and "AnimatedImage" for GIFs.
+	Kind string `json:"e"`
+	// MimeType is e.g. "image/jpg" or "image/png".
+	MimeType string `json:"m"`
+	// Source is the full resolution image.
+	Source RedditMediaSource `json:"s"`
+	// Previews are downscaled versions of the image, smallest first.
+	Previews []RedditMediaSource `json:"p"`
+}
+
+type RedditMediaSource struct {
+	Width  int    `json:"x"`
+	Height int    `json:"y"`
+	URL    string `json:"u"`
 }
 
 // Run runs the source to fetch image Metadata based on the given request.
@@ -64,6 +105,17 @@ func (re *Reddit) Run(ctx context.Context, request source.Request) (source.Respo
 		countback = 300
 	}
 
+	l, err := parseListing(request.Parameter)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	// Only listings sorted by date can stop at the last seen post. Other listings reorder posts all the time,
+	// so older posts may show up before newer ones.
+	var seen, newest int64
+	if l.chronological() {
+		seen, _ = parsePostID(request.Cursor)
+	}
+
 	var allImages source.Images
 	var next string
 
@@ -77,58 +129,100 @@ func (re *Reddit) Run(ctx context.Context, request source.Request) (source.Respo
 			return source.Response{}, fmt.Errorf("failed to fetch Reddit posts: %w", err)
 		}
 
-		// Convert posts to images
-		images := re.filterAndConvertPosts(ctx, posts, request)
-		allImages = append(allImages, images...)
-
 		// Update countback and next token
 		countback -= len(posts)
 		next = nextToken
 
+		if l.chronological() {
+			var reachedSeen bool
+			posts, reachedSeen = unseenPosts(posts, seen)
+			for _, post := range posts {
+				if id, ok := parsePostID(post.ID); ok && id > newest {
+					newest = id
+				}
+			}
+			if reachedSeen {
+				next = ""
+			}
+		}
+
+		// Convert posts to images
+		images := re.filterAndConvertPosts(ctx, posts, request)
+		allImages = append(allImages, images...)
+
 		// Stop if no more pages or no next token
 		if next == "" {
 			break
 		}
 	}
 
-	return source.Response{Images: allImages}, nil
+	resp := source.Response{Images: allImages}
+	if newest > 0 {
+		resp.Cursor = strconv.FormatInt(newest, 36)
+	}
+	return resp, nil
+}
+
+// RunPage fetches a page of up to 100 posts for backfill jobs. Page tokens are the "after" tokens of Reddit.
+//
+// Reddit only lists the newest 1000 posts of a listing, so a backfill cannot go further back than that.
+func (re *Reddit) RunPage(ctx context.Context, request source.PageRequest) (source.PageResponse, error) {
+	posts, next, err := re.fetchRedditPosts(ctx, request.Parameter, 100, request.PageToken)
+	if err != nil {
+		return source.PageResponse{}, fmt.Errorf("failed to fetch Reddit posts: %w", err)
+	}
+	images := re.filterAndConvertPosts(ctx, posts, source.Request{
+		Parameter:         request.Parameter,
+		FilenameMaxLength: request.FilenameMaxLength,
+	})
+	return source.PageResponse{Images: images, NextPageToken: next}, nil
+}
+
+// parsePostID parses the base 36 ID of a Reddit post. Post IDs increase over time, so they can be compared
+// to tell which post is newer.
+func parsePostID(id string) (int64, bool) {
+	n, err := strconv.ParseInt(id, 36, 64)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n, true
+}
+
+// unseenPosts returns the posts newer than the seen post ID, and whether a post that was already seen was reached.
+// Posts must be sorted newest first.
+func unseenPosts(posts []RedditPostData, seen int64) ([]RedditPostData, bool) {
+	if seen <= 0 {
+		return posts, false
+	}
+	for i, post := range posts {
+		if id, ok := parsePostID(post.ID); ok && id <= seen {
+			return posts[:i], true
+		}
+	}
+	return posts, false
 }
 
 // fetchRedditPosts fetches posts from Reddit API
 func (re *Reddit) fetchRedditPosts(ctx context.Context, param string, limit int, after string) ([]RedditPostData, string, error) {
-	// Build URL
-	baseURL := fmt.Sprintf("https://reddit.com/%s.json", param)
-	u, err := url.Parse(baseURL)
+	l, err := parseListing(param)
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to parse URL: %w", err)
+		return nil, "", fmt.Errorf("invalid parameter %q: %w", param, err)
 	}
 
-	// Add query parameters
-	q := u.Query()
+	apiPath, q := l.apiPath()
 	q.Set("limit", strconv.Itoa(limit))
 	if after != "" {
 		q.Set("after", after)
 	}
-	u.RawQuery = q.Encode()
-
-	// Create request
-	req, err := http.NewRequestWithContext(ctx, "GET", u.String(), nil)
-	if err != nil {
-		return nil, "", fmt.Errorf("failed to create request: %w", err)
-	}
 
-	// Set User-Agent header (Reddit requires this)
-	req.Header.Set("User-Agent", "claw/1.0")
-
-	// Make request
-	resp, err := re.Client.Do(req)
+	resp, err := re.get(ctx, apiPath, q)
 	if err != nil {
 		return nil, "", fmt.Errorf("failed to make request: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return nil, "", fmt.Errorf("Reddit API returned status %d", resp.StatusCode)
+		return nil, "", &source.StatusError{Service: "Reddit API", StatusCode: resp.StatusCode, RetryAfter: source.RetryAfter(resp.Header)}
 	}
 
 	// Parse response
@@ -140,6 +234,10 @@ func (re *Reddit) fetchRedditPosts(ctx context.Context, param string, limit int,
 	// Extract post data
 	var posts []RedditPostData
 	for _, child := range redditResp.Data.Children {
+		// Comments have IDs of their own, which would move the cursor past posts that were not seen yet.
+		if child.Kind != "t3" {
+			continue
+		}
 		posts = append(posts, child.Data)
 	}
 
@@ -157,6 +255,13 @@ func (re *Reddit) filterAndConvertPosts(ctx context.Context, posts []RedditPostD
 	var images source.Images
 
 	for _, post := range posts {
+		post = re.resolveCrosspost(post)
+
+		if post.IsGallery {
+			images = append(images, re.convertGalleryPost(ctx, post, request)...)
+			continue
+		}
+
 		// Check if post is an image
 		if !re.isImagePost(ctx, post) {
 			continue
@@ -220,11 +325,13 @@ func (re *Reddit) isImagePost(ctx context.Context, post RedditPostData) bool {
 func (re *Reddit) convertPostToImage(ctx context.Context, post RedditPostData, request source.Request) *source.Image {
 	image := &source.Image{
 		DownloadURL: post.URL,
+		Title:       html.UnescapeString(post.Title),
+		Tags:        re.postTags(post),
 		Author:      post.Author,
 		AuthorURL:   fmt.Sprintf("https://reddit.com/u/%s", post.Author),
 		Website:     fmt.Sprintf("https://reddit.com%s", post.Permalink),
 		PostedAt:    time.Unix(int64(post.CreatedUTC), 0),
-		Filename:    re.generateFilename(ctx, post, request),
+		Filename:    re.generateFilename(ctx, post.ID, post.URL, request),
 		NSFW:        post.Over18,
 	}
 
@@ -245,26 +352,46 @@ func (re *Reddit) convertPostToImage(ctx context.Context, post RedditPostData, r
 	return image
 }
 
-// generateFilename generates a filename for the Reddit post using the format:
+// postTags returns the tags of the post: the subreddit, the flair, and "nsfw" for posts marked as over 18.
+func (re *Reddit) postTags(post RedditPostData) []string {
+	var tags []string
+	if post.Subreddit != "" {
+		tags = append(tags, post.Subreddit)
+	}
+	if flair := strings.TrimSpace(html.UnescapeString(post.LinkFlairText)); flair != "" {
+		tags = append(tags, flair)
+	}
+	if post.Over18 {
+		tags = append(tags, "nsfw")
+	}
+	return tags
+}
+
+// generateFilename generates a filename for the image of a Reddit post using the format:
 // <parameter>_<post_id>_<detected_image_filename>.<ext>
-func (re *Reddit) generateFilename(ctx context.Context, post RedditPostData, request source.Request) string {
+func (re *Reddit) generateFilename(ctx context.Context, postID, imageURL string, request source.Request) string {
 	// Extract filename from URL
-	imageName := re.extractImageNameFromURL(post.URL)
+	imageName := re.extractImageNameFromURL(imageURL)
 	if imageName == "" {
 		imageName = "reddit_image"
 	}
 
 	// Get extension, either from URL or detect via MIME type
-	ext := re.getFileExtension(ctx, post.URL)
+	ext := re.getFileExtension(ctx, imageURL)
+
+	// Sorts, time windows, and search queries are left out of the filename.
+	prefix := request.Parameter
+	if l, err := parseListing(prefix); err == nil {
+		prefix = l.base()
+	}
+	// Listings like "user/<user>/m/<multi>" must not create directories.
+	prefix = strings.ReplaceAll(prefix, "/", "_")
 
 	// Generate initial filename
-	filename := fmt.Sprintf("%s_%s_%s%s", request.Parameter, post.ID, imageName, ext)
+	filename := fmt.Sprintf("%s_%s_%s%s", prefix, postID, imageName, ext)
 
 	// Apply filename length limit
-	maxLength := request.FilenameMaxLength
-	if maxLength <= 0 {
-		maxLength = 100 // Default value
-	}
+	maxLength := source.FilenameMaxLength(request.FilenameMaxLength)
 
 	if len(filename) > maxLength {
 		// Calculate how much space we need for extension
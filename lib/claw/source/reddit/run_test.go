@@ -2,11 +2,16 @@ package reddit
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
 )
 
 // MockHTTPClient is a mock implementation of the Doer interface
@@ -50,11 +55,11 @@ func TestIsImgurImageValid(t *testing.T) {
 	ctx := context.Background()
 
 	tests := []struct {
-		name           string
-		url            string
-		statusCode     int
-		expectedValid  bool
-		expectError    bool
+		name          string
+		url           string
+		statusCode    int
+		expectedValid bool
+		expectError   bool
 	}{
 		{"valid image", "https://i.imgur.com/valid.jpg", 200, true, false},
 		{"deleted image", "https://i.imgur.com/deleted.jpg", 404, false, false},
@@ -156,4 +161,292 @@ func TestIsImagePost_WithImgurFiltering(t *testing.T) {
 			}
 		})
 	}
-}
\ No newline at end of file
+}
+
+func TestConvertPostToImage_TitleAndTags(t *testing.T) {
+	reddit := &Reddit{}
+	ctx := context.Background()
+
+	tests := []struct {
+		name          string
+		post          RedditPostData
+		expectedTitle string
+		expectedTags  []string
+	}{
+		{
+			name: "subreddit only",
+			post: RedditPostData{
+				ID:        "abc",
+				Title:     "Mountains &amp; lakes",
+				URL:       "https://i.redd.it/abc.jpg",
+				Subreddit: "wallpapers",
+			},
+			expectedTitle: "Mountains & lakes",
+			expectedTags:  []string{"wallpapers"},
+		},
+		{
+			name: "flair and nsfw",
+			post: RedditPostData{
+				ID:            "def",
+				Title:         "Night city",
+				URL:           "https://i.redd.it/def.png",
+				Subreddit:     "wallpaper",
+				LinkFlairText: " Desktop ",
+				Over18:        true,
+			},
+			expectedTitle: "Night city",
+			expectedTags:  []string{"wallpaper", "Desktop", "nsfw"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			image := reddit.convertPostToImage(ctx, tt.post, source.Request{Parameter: "r/" + tt.post.Subreddit})
+			assert.Equal(t, tt.expectedTitle, image.Title)
+			assert.Equal(t, tt.expectedTags, image.Tags)
+		})
+	}
+}
+
+// listingFixture is a trimmed down subreddit listing with a gallery post, a crosspost of a single image,
+// and a crosspost of a gallery.
+const listingFixture = `{
+  "data": {
+    "after": null,
+    "children": [
+      {
+        "kind": "t3",
+        "data": {
+          "id": "gal1",
+          "title": "Autumn set",
+          "url": "https://www.reddit.com/gallery/gal1",
+          "author": "alice",
+          "permalink": "/r/wallpapers/comments/gal1/autumn_set/",
+          "created_utc": 1700000000,
+          "subreddit": "wallpapers",
+          "is_gallery": true,
+          "gallery_data": {
+            "items": [
+              {"media_id": "aaa111", "id": 1, "caption": "Forest"},
+              {"media_id": "bbb222", "id": 2},
+              {"media_id": "ccc333", "id": 3},
+              {"media_id": "ddd444", "id": 4}
+            ]
+          },
+          "media_metadata": {
+            "aaa111": {
+              "status": "valid", "e": "Image", "m": "image/jpg", "id": "aaa111",
+              "s": {"x": 3840, "y": 2160, "u": "https://preview.redd.it/aaa111.jpg?width=3840&amp;format=pjpg&amp;s=x"},
+              "p": [
+                {"x": 108, "y": 60, "u": "https://preview.redd.it/aaa111.jpg?width=108&amp;s=a"},
+                {"x": 1080, "y": 607, "u": "https://preview.redd.it/aaa111.jpg?width=1080&amp;s=b"},
+                {"x": 2160, "y": 1215, "u": "https://preview.redd.it/aaa111.jpg?width=2160&amp;s=c"}
+              ]
+            },
+            "bbb222": {
+              "status": "valid", "e": "Image", "m": "image/png", "id": "bbb222",
+              "s": {"x": 2560, "y": 1440, "u": "https://preview.redd.it/bbb222.png?width=2560&amp;s=x"}
+            },
+            "ccc333": {
+              "status": "valid", "e": "AnimatedImage", "m": "image/gif", "id": "ccc333",
+              "s": {"x": 500, "y": 500, "gif": "https://i.redd.it/ccc333.gif"}
+            },
+            "ddd444": {"status": "unprocessed", "e": "Image", "m": "image/jpg", "id": "ddd444"}
+          }
+        }
+      },
+      {
+        "kind": "t3",
+        "data": {
+          "id": "xp1",
+          "title": "Found this gem",
+          "url": "/r/earthporn/comments/orig1/lake/",
+          "author": "bob",
+          "permalink": "/r/wallpapers/comments/xp1/found_this_gem/",
+          "created_utc": 1700000100,
+          "subreddit": "wallpapers",
+          "crosspost_parent_list": [
+            {
+              "id": "orig1",
+              "title": "Lake",
+              "url": "https://i.redd.it/lake.jpg",
+              "author": "carol",
+              "permalink": "/r/earthporn/comments/orig1/lake/",
+              "post_hint": "image",
+              "subreddit": "EarthPorn",
+              "over_18": true,
+              "preview": {"images": [{"source": {"url": "https://preview.redd.it/lake.jpg?s=1&amp;a=2", "width": 4000, "height": 3000}}]}
+            }
+          ]
+        }
+      },
+      {
+        "kind": "t3",
+        "data": {
+          "id": "xp2",
+          "title": "Crossposted gallery",
+          "url": "/r/other/comments/gal2/",
+          "author": "dave",
+          "permalink": "/r/wallpapers/comments/xp2/crossposted_gallery/",
+          "created_utc": 1700000200,
+          "subreddit": "wallpapers",
+          "crosspost_parent_list": [
+            {
+              "id": "gal2",
+              "url": "https://www.reddit.com/gallery/gal2",
+              "is_gallery": true,
+              "gallery_data": {"items": [{"media_id": "eee555", "id": 1}]},
+              "media_metadata": {
+                "eee555": {"status": "valid", "e": "Image", "m": "image/webp", "id": "eee555", "s": {"x": 1920, "y": 1080, "u": "https://preview.redd.it/eee555.webp?s=1"}}
+              }
+            }
+          ]
+        }
+      },
+      {
+        "kind": "t3",
+        "data": {
+          "id": "txt1",
+          "title": "Discussion",
+          "url": "https://www.reddit.com/r/wallpapers/comments/txt1/discussion/",
+          "author": "erin",
+          "permalink": "/r/wallpapers/comments/txt1/discussion/",
+          "subreddit": "wallpapers",
+          "post_hint": "self"
+        }
+      }
+    ]
+  }
+}`
+
+func TestGenerateFilename(t *testing.T) {
+	reddit := &Reddit{}
+	ctx := context.Background()
+
+	tests := []struct {
+		parameter string
+		expected  string
+	}{
+		{"wallpapers", "wallpapers_abc_abc.jpg"},
+		{"r/wallpapers/top?t=week", "r_wallpapers_abc_abc.jpg"},
+		{"u/alice", "u_alice_abc_abc.jpg"},
+		{"user/alice/m/scenery/new", "user_alice_m_scenery_abc_abc.jpg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.parameter, func(t *testing.T) {
+			filename := reddit.generateFilename(ctx, "abc", "https://i.redd.it/abc.jpg", source.Request{Parameter: tt.parameter})
+			assert.Equal(t, tt.expected, filename)
+		})
+	}
+}
+
+func TestFilterAndConvertPosts_GalleryAndCrosspost(t *testing.T) {
+	reddit := &Reddit{}
+	ctx := context.Background()
+
+	var listing RedditResponse
+	require.NoError(t, json.Unmarshal([]byte(listingFixture), &listing))
+	var posts []RedditPostData
+	for _, child := range listing.Data.Children {
+		posts = append(posts, child.Data)
+	}
+
+	images := reddit.filterAndConvertPosts(ctx, posts, source.Request{Parameter: "wallpapers"})
+	require.Len(t, images, 4)
+
+	// Gallery items, in gallery order. Animated and unprocessed items are skipped.
+	assert.Equal(t, "https://i.redd.it/aaa111.jpg", images[0].DownloadURL)
+	assert.Equal(t, int64(3840), images[0].Width)
+	assert.Equal(t, int64(2160), images[0].Height)
+	assert.Equal(t, "Autumn set - Forest", images[0].Title)
+	assert.Equal(t, "https://preview.redd.it/aaa111.jpg?width=1080&s=b", images[0].ThumbnailURL)
+	assert.Equal(t, "https://reddit.com/r/wallpapers/comments/gal1/autumn_set/", images[0].Website)
+	assert.Equal(t, "wallpapers_gal1_aaa111.jpg", images[0].Filename)
+
+	assert.Equal(t, "https://i.redd.it/bbb222.png", images[1].DownloadURL)
+	assert.Equal(t, int64(2560), images[1].Width)
+	assert.Equal(t, "Autumn set", images[1].Title)
+	assert.Empty(t, images[1].ThumbnailURL)
+
+	// Crosspost of a single image resolves to the original media, but keeps the crosspost's post.
+	assert.Equal(t, "https://i.redd.it/lake.jpg", images[2].DownloadURL)
+	assert.Equal(t, int64(4000), images[2].Width)
+	assert.Equal(t, "Found this gem", images[2].Title)
+	assert.Equal(t, "bob", images[2].Author)
+	assert.Equal(t, "https://reddit.com/r/wallpapers/comments/xp1/found_this_gem/", images[2].Website)
+	assert.True(t, images[2].NSFW)
+	assert.Equal(t, []string{"wallpapers", "nsfw"}, images[2].Tags)
+
+	// Crosspost of a gallery.
+	assert.Equal(t, "https://i.redd.it/eee555.webp", images[3].DownloadURL)
+	assert.Equal(t, int64(1920), images[3].Width)
+	assert.Equal(t, int64(1080), images[3].Height)
+	assert.Equal(t, "dave", images[3].Author)
+}
+
+func TestRun_Cursor(t *testing.T) {
+	post := func(id string) string {
+		return `{"kind":"t3","data":{"id":"` + id + `","title":"Post ` + id + `","url":"https://i.redd.it/` + id + `.jpg","post_hint":"image","subreddit":"wallpapers"}}`
+	}
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	listing := func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if r.URL.Query().Get("after") == "" {
+			_, _ = w.Write([]byte(`{"data":{"after":"t3_1b","children":[` + post("1c") + `,` + post("1b") + `]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"after":null,"children":[` + post("1a") + `,` + post("19") + `]}}`))
+	}
+	mux.HandleFunc("GET /r/wallpapers/new.json", listing)
+	mux.HandleFunc("GET /r/wallpapers.json", listing)
+	mux.HandleFunc("GET /user/spez.json", func(w http.ResponseWriter, r *http.Request) {
+		// Comments of the overview have IDs of their own, newer than the posts.
+		comment := `{"kind":"t1","data":{"id":"k9zz","body":"Nice"}}`
+		_, _ = w.Write([]byte(`{"data":{"after":null,"children":[` + comment + `,` + post("1c") + `,` + post("1b") + `]}}`))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	reddit := &Reddit{Client: srv.Client(), publicBaseURL: srv.URL}
+
+	t.Run("first run is capped by countback", func(t *testing.T) {
+		requests.Store(0)
+		resp, err := reddit.Run(context.Background(), source.Request{Parameter: "r/wallpapers/new", Countback: 10})
+		require.NoError(t, err)
+		assert.Len(t, resp.Images, 4)
+		assert.Equal(t, "1c", resp.Cursor)
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("stops at the last seen post", func(t *testing.T) {
+		requests.Store(0)
+		resp, err := reddit.Run(context.Background(), source.Request{Parameter: "r/wallpapers/new", Countback: 10, Cursor: "1b"})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 1)
+		assert.Equal(t, "https://i.redd.it/1c.jpg", resp.Images[0].DownloadURL)
+		assert.Equal(t, "1c", resp.Cursor)
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("nothing new keeps the cursor", func(t *testing.T) {
+		resp, err := reddit.Run(context.Background(), source.Request{Parameter: "r/wallpapers/new", Countback: 10, Cursor: "1c"})
+		require.NoError(t, err)
+		assert.Empty(t, resp.Images)
+		assert.Empty(t, resp.Cursor)
+	})
+
+	t.Run("cursor is ignored for listings not sorted by new", func(t *testing.T) {
+		resp, err := reddit.Run(context.Background(), source.Request{Parameter: "r/wallpapers", Countback: 10, Cursor: "1c"})
+		require.NoError(t, err)
+		assert.Len(t, resp.Images, 4)
+		assert.Empty(t, resp.Cursor)
+	})
+
+	t.Run("comments of user overviews do not move the cursor", func(t *testing.T) {
+		resp, err := reddit.Run(context.Background(), source.Request{Parameter: "u/spez", Countback: 10, Cursor: "1b"})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 1)
+		assert.Equal(t, "https://i.redd.it/1c.jpg", resp.Images[0].DownloadURL)
+		assert.Equal(t, "1c", resp.Cursor)
+	})
+}
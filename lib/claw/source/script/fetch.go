@@ -0,0 +1,198 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+
+	"github.com/dop251/goja"
+)
+
+const (
+	userAgent = "claw/1.0"
+	// maxResponseSize limits how much of a response body is read.
+	maxResponseSize = 16 << 20
+	maxRedirects    = 10
+)
+
+// errPrivateAddress is returned when a script fetches from a private address while
+// config.Script.AllowPrivateNetworks is off.
+var errPrivateAddress = errors.New("fetching from loopback, private, or link-local addresses is not allowed, see sources.script.allow_private_networks")
+
+// newClient creates the HTTP client of a runtime, which enforces the fetch restrictions in cfg.
+func newClient(cfg Config) *http.Client {
+	dialer := &net.Dialer{}
+	if !cfg.AllowPrivateNetworks {
+		// Checked after DNS resolution, so host names resolving to private addresses are caught too.
+		dialer.Control = func(_, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+				return errPrivateAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			return checkURL(cfg, req.URL)
+		},
+	}
+}
+
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified()
+}
+
+// checkURL checks that scripts may fetch from u.
+func checkURL(cfg Config, u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("%q must be an http:// or https:// URL", u)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("URL %q has no host", u)
+	}
+	if len(cfg.AllowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, allowed := range cfg.AllowedHosts {
+		allowed = strings.ToLower(strings.TrimSpace(allowed))
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return nil
+		}
+	}
+	return fmt.Errorf("host %q is not allowed, see sources.script.allowed_hosts", host)
+}
+
+// checkEmittedURL checks that the scheduler may download from the URL of an emitted image.
+//
+// Unlike fetch, the download does not go through the dialer of the script client, so the host is resolved here
+// to check that it is not a private address.
+func (vm *runtime) checkEmittedURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	if err := checkURL(vm.cfg, u); err != nil {
+		return err
+	}
+	if vm.cfg.AllowPrivateNetworks {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(vm.ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve %q: %w", u.Hostname(), err)
+	}
+	for _, addr := range addrs {
+		if isPrivate(addr.IP) {
+			return errPrivateAddress
+		}
+	}
+	return nil
+}
+
+// fetchOptions are the options of fetch(url, options).
+type fetchOptions struct {
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Body    string            `json:"body"`
+}
+
+// fetch implements fetch(url, options). Unlike fetch in browsers it is synchronous, and reads the whole body.
+// Responses with error statuses are returned, not thrown, so scripts check response.ok.
+func (vm *runtime) fetch(call goja.FunctionCall) goja.Value {
+	rawURL := call.Argument(0).String()
+	var opts fetchOptions
+	if arg := call.Argument(1); !goja.IsUndefined(arg) && !goja.IsNull(arg) {
+		if err := vm.ExportTo(arg, &opts); err != nil {
+			vm.throw(fmt.Errorf("invalid fetch options: %w", err))
+		}
+	}
+	if vm.fetches >= vm.cfg.MaxFetches {
+		vm.throw(fmt.Errorf("script made more than %d fetches, see sources.script.max_fetches", vm.cfg.MaxFetches))
+	}
+	vm.fetches++
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		vm.throw(fmt.Errorf("invalid URL %q: %w", rawURL, err))
+	}
+	if err := checkURL(vm.cfg, u); err != nil {
+		vm.throw(err)
+	}
+	method := strings.ToUpper(opts.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if opts.Body != "" {
+		body = strings.NewReader(opts.Body)
+	}
+	req, err := http.NewRequestWithContext(vm.ctx, method, u.String(), body)
+	if err != nil {
+		vm.throw(fmt.Errorf("failed to create request: %w", err))
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, value := range opts.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := vm.client.Do(req)
+	if err != nil {
+		vm.throw(fmt.Errorf("failed to fetch %s: %w", u, err))
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize+1))
+	if err != nil {
+		vm.throw(fmt.Errorf("failed to read response of %s: %w", u, err))
+	}
+	if len(content) > maxResponseSize {
+		vm.throw(fmt.Errorf("response of %s is larger than %d bytes", u, maxResponseSize))
+	}
+	return vm.response(resp, string(content))
+}
+
+// response creates the object returned by fetch.
+func (vm *runtime) response(resp *http.Response, body string) goja.Value {
+	obj := vm.NewObject()
+	headers := vm.NewObject()
+	for key := range resp.Header {
+		_ = headers.Set(strings.ToLower(key), resp.Header.Get(key))
+	}
+	finalURL := resp.Request.URL.String()
+	_ = obj.Set("status", resp.StatusCode)
+	_ = obj.Set("ok", resp.StatusCode >= 200 && resp.StatusCode < 300)
+	_ = obj.Set("url", finalURL)
+	_ = obj.Set("headers", headers)
+	_ = obj.Set("text", func() string {
+		return body
+	})
+	_ = obj.Set("json", func() goja.Value {
+		parse, _ := goja.AssertFunction(vm.Get("JSON").ToObject(vm.Runtime).Get("parse"))
+		value, err := parse(goja.Undefined(), vm.ToValue(body))
+		if err != nil {
+			vm.throw(fmt.Errorf("response of %s is not valid JSON: %w", finalURL, err))
+		}
+		return value
+	})
+	_ = obj.Set("html", func() goja.Value {
+		return vm.parseHTML(body, finalURL)
+	})
+	return obj
+}
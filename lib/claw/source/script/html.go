@@ -0,0 +1,117 @@
+package script
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/dop251/goja"
+)
+
+// parseHTML implements parseHTML(text, baseURL). baseURL is used by selection.url to resolve relative URLs,
+// and defaults to the base element of the document.
+func (vm *runtime) parseHTML(text, baseURL string) goja.Value {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		vm.throw(fmt.Errorf("failed to parse HTML: %w", err))
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		vm.throw(fmt.Errorf("invalid base URL %q: %w", baseURL, err))
+	}
+	if href, ok := doc.Find("base[href]").First().Attr("href"); ok {
+		if u, err := url.Parse(href); err == nil {
+			base = base.ResolveReference(u)
+		}
+	}
+	return vm.selection(doc.Selection, base)
+}
+
+// selection wraps a goquery selection in a jQuery-like object.
+func (vm *runtime) selection(sel *goquery.Selection, base *url.URL) *goja.Object {
+	obj := vm.NewObject()
+	wrap := func(sel *goquery.Selection) *goja.Object {
+		return vm.selection(sel, base)
+	}
+	// each calls fn for every element, until fn returns false.
+	each := func(fn goja.Callable, collect func(goja.Value)) {
+		sel.EachWithBreak(func(i int, s *goquery.Selection) bool {
+			result, err := fn(goja.Undefined(), wrap(s), vm.ToValue(i))
+			if err != nil {
+				vm.rethrow(err)
+			}
+			if collect != nil {
+				collect(result)
+				return true
+			}
+			return !result.StrictEquals(vm.ToValue(false))
+		})
+	}
+	callback := func(value goja.Value) goja.Callable {
+		fn, ok := goja.AssertFunction(value)
+		if !ok {
+			vm.throw(fmt.Errorf("expected a function, got %s", value))
+		}
+		return fn
+	}
+
+	_ = obj.Set("length", sel.Length())
+	_ = obj.Set("find", func(selector string) *goja.Object {
+		return wrap(sel.Find(selector))
+	})
+	_ = obj.Set("first", func() *goja.Object {
+		return wrap(sel.First())
+	})
+	_ = obj.Set("last", func() *goja.Object {
+		return wrap(sel.Last())
+	})
+	_ = obj.Set("eq", func(i int) *goja.Object {
+		return wrap(sel.Eq(i))
+	})
+	_ = obj.Set("parent", func() *goja.Object {
+		return wrap(sel.Parent())
+	})
+	_ = obj.Set("children", func() *goja.Object {
+		return wrap(sel.Children())
+	})
+	_ = obj.Set("text", func() string {
+		return strings.TrimSpace(sel.Text())
+	})
+	_ = obj.Set("html", func() string {
+		html, err := sel.Html()
+		if err != nil {
+			vm.throw(fmt.Errorf("failed to render HTML: %w", err))
+		}
+		return html
+	})
+	_ = obj.Set("attr", func(name string) goja.Value {
+		value, ok := sel.Attr(name)
+		if !ok {
+			return goja.Null()
+		}
+		return vm.ToValue(value)
+	})
+	_ = obj.Set("url", func(name string) goja.Value {
+		value, ok := sel.Attr(name)
+		if !ok || strings.TrimSpace(value) == "" {
+			return goja.Null()
+		}
+		u, err := url.Parse(strings.TrimSpace(value))
+		if err != nil {
+			return goja.Null()
+		}
+		return vm.ToValue(base.ResolveReference(u).String())
+	})
+	_ = obj.Set("each", func(fn goja.Value) {
+		each(callback(fn), nil)
+	})
+	_ = obj.Set("map", func(fn goja.Value) *goja.Object {
+		var results []any
+		each(callback(fn), func(result goja.Value) {
+			results = append(results, result)
+		})
+		return vm.NewArray(results...)
+	})
+	return obj
+}
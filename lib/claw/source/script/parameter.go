@@ -0,0 +1,43 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// validScriptName keeps script names inside the scripts directory.
+var validScriptName = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// parameter is a parsed source parameter: "<script>?key=value&key2=value2".
+type parameter struct {
+	Script string
+	Params url.Values
+}
+
+func parseParameter(param string) (parameter, error) {
+	raw := strings.TrimSpace(param)
+	if raw == "" {
+		return parameter{}, errors.New("parameter cannot be empty")
+	}
+	name, query, _ := strings.Cut(raw, "?")
+	name = strings.TrimSuffix(name, scriptExt)
+	if !validScriptName.MatchString(name) {
+		return parameter{}, fmt.Errorf("invalid script name %q, only letters, digits, '_', '-', and '.' are allowed", name)
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return parameter{}, fmt.Errorf("invalid script parameters %q: %w", query, err)
+	}
+	return parameter{Script: name, Params: values}, nil
+}
+
+// String returns the canonical form of the parameter, with the script parameters sorted by key.
+func (pa parameter) String() string {
+	if len(pa.Params) == 0 {
+		return pa.Script
+	}
+	return pa.Script + "?" + pa.Params.Encode()
+}
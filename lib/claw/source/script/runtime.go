@@ -0,0 +1,230 @@
+package script
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// runtime is a JavaScript runtime for a single validate or run call of a script.
+//
+// Runtimes are not reused, so scripts cannot keep state between runs.
+type runtime struct {
+	*goja.Runtime
+
+	ctx    context.Context
+	cfg    Config
+	logger *slog.Logger
+	name   string
+	emit   func(source.Image)
+	client *http.Client
+	// fetches is the number of fetch calls made so far.
+	fetches int
+}
+
+// newRuntime creates a runtime with the script API installed. emit is nil when images are not wanted,
+// which makes the emit function throw.
+//
+// The runtime is interrupted when ctx is done.
+func newRuntime(ctx context.Context, cfg Config, logger *slog.Logger, name string, emit func(source.Image)) (*runtime, error) {
+	vm := &runtime{
+		Runtime: goja.New(),
+		ctx:     ctx,
+		cfg:     cfg,
+		logger:  logger,
+		name:    name,
+		emit:    emit,
+		client:  newClient(cfg),
+	}
+	console := vm.NewObject()
+	for method, level := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"log":   slog.LevelInfo,
+		"info":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+	} {
+		if err := console.Set(method, vm.console(level)); err != nil {
+			return nil, fmt.Errorf("failed to set up console.%s: %w", method, err)
+		}
+	}
+	for name, value := range map[string]any{
+		"console":    console,
+		"emit":       vm.emitImage,
+		"fetch":      vm.fetch,
+		"parseHTML":  vm.parseHTML,
+		"resolveURL": vm.resolveURL,
+	} {
+		if err := vm.Set(name, value); err != nil {
+			return nil, fmt.Errorf("failed to set up %s: %w", name, err)
+		}
+	}
+	context.AfterFunc(ctx, func() {
+		vm.Interrupt(ctx.Err())
+	})
+	return vm, nil
+}
+
+// run runs the top level code of the script, which defines its functions.
+func (vm *runtime) run(prg *goja.Program) error {
+	if _, err := vm.RunProgram(prg); err != nil {
+		return vm.wrapError(err)
+	}
+	return nil
+}
+
+// call calls a function defined by the script. If the script does not define the function,
+// an error is returned only if the function is required.
+func (vm *runtime) call(function string, required bool, args ...goja.Value) (goja.Value, error) {
+	fn, ok := goja.AssertFunction(vm.Get(function))
+	if !ok {
+		if required {
+			return nil, fmt.Errorf("script %s does not define a %s function", vm.name, function)
+		}
+		return goja.Undefined(), nil
+	}
+	result, err := fn(goja.Undefined(), args...)
+	if err != nil {
+		return nil, vm.wrapError(err)
+	}
+	return result, nil
+}
+
+func (vm *runtime) wrapError(err error) error {
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if errors.Is(vm.ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("script %s did not finish within %s: %w", vm.name, vm.cfg.Timeout, vm.ctx.Err())
+		}
+		return fmt.Errorf("script %s was stopped: %w", vm.name, vm.ctx.Err())
+	}
+	return fmt.Errorf("script %s failed: %w", vm.name, err)
+}
+
+// throw throws err as a JavaScript exception. It must only be called from functions called by scripts.
+func (vm *runtime) throw(err error) {
+	panic(vm.NewGoError(err))
+}
+
+// rethrow throws an error returned by a script callback, so the script can catch it like any other exception.
+func (vm *runtime) rethrow(err error) {
+	var exception *goja.Exception
+	if errors.As(err, &exception) {
+		panic(exception.Value())
+	}
+	// The runtime was interrupted. Interrupt it again, so the script cannot catch it.
+	vm.Interrupt(vm.ctx.Err())
+	vm.throw(err)
+}
+
+// paramsObject returns the script parameters as an object. Only the first value of repeated keys is used.
+func (vm *runtime) paramsObject(params parameter) goja.Value {
+	obj := vm.NewObject()
+	for key, values := range params.Params {
+		if len(values) > 0 {
+			_ = obj.Set(key, values[0])
+		}
+	}
+	return obj
+}
+
+func (vm *runtime) console(level slog.Level) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		parts := make([]string, 0, len(call.Arguments))
+		for _, arg := range call.Arguments {
+			parts = append(parts, vm.format(arg))
+		}
+		vm.logger.Log(vm.ctx, level, strings.Join(parts, " "), "script", vm.name)
+		return goja.Undefined()
+	}
+}
+
+// format formats a value for logging. Objects are formatted as JSON.
+func (vm *runtime) format(value goja.Value) string {
+	if obj, ok := value.(*goja.Object); ok {
+		if _, isFunc := goja.AssertFunction(obj); !isFunc {
+			if b, err := json.Marshal(obj.Export()); err == nil {
+				return string(b)
+			}
+		}
+	}
+	return value.String()
+}
+
+// image is an image emitted by a script.
+type image struct {
+	DownloadURL  string    `json:"download_url"`
+	Width        int64     `json:"width"`
+	Height       int64     `json:"height"`
+	Filesize     int64     `json:"filesize"`
+	Author       string    `json:"author"`
+	AuthorURL    string    `json:"author_url"`
+	Website      string    `json:"website"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	PostedAt     time.Time `json:"posted_at"`
+	Filename     string    `json:"filename"`
+	Title        string    `json:"title"`
+	Tags         []string  `json:"tags"`
+	NSFW         bool      `json:"nsfw"`
+}
+
+func (vm *runtime) emitImage(call goja.FunctionCall) goja.Value {
+	if vm.emit == nil {
+		vm.throw(errors.New("emit can only be called from run"))
+	}
+	// Going through JSON converts Dates and RFC 3339 strings to time.Time alike.
+	b, err := json.Marshal(call.Argument(0).Export())
+	if err != nil {
+		vm.throw(fmt.Errorf("invalid image: %w", err))
+	}
+	var img image
+	if err := json.Unmarshal(b, &img); err != nil {
+		vm.throw(fmt.Errorf("invalid image %s: %w", b, err))
+	}
+	// The scheduler downloads these with its own client, so they get the same restrictions as fetch.
+	if err := vm.checkEmittedURL(img.DownloadURL); err != nil {
+		vm.throw(fmt.Errorf("invalid image %s: download_url: %w", b, err))
+	}
+	if img.ThumbnailURL != "" {
+		if err := vm.checkEmittedURL(img.ThumbnailURL); err != nil {
+			vm.throw(fmt.Errorf("invalid image %s: thumbnail_url: %w", b, err))
+		}
+	}
+	vm.emit(source.Image{
+		DownloadURL:  img.DownloadURL,
+		Width:        img.Width,
+		Height:       img.Height,
+		Filesize:     img.Filesize,
+		Author:       img.Author,
+		AuthorURL:    img.AuthorURL,
+		Website:      img.Website,
+		ThumbnailURL: img.ThumbnailURL,
+		PostedAt:     img.PostedAt,
+		Filename:     img.Filename,
+		Title:        img.Title,
+		Tags:         img.Tags,
+		NSFW:         img.NSFW,
+	})
+	return goja.Undefined()
+}
+
+func (vm *runtime) resolveURL(base, ref string) string {
+	baseURL, err := url.Parse(base)
+	if err != nil {
+		vm.throw(fmt.Errorf("invalid base URL %q: %w", base, err))
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		vm.throw(fmt.Errorf("invalid URL %q: %w", ref, err))
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
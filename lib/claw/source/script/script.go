@@ -0,0 +1,297 @@
+package script
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const SourceName = "claw.script.v1"
+
+const (
+	scriptExt         = ".js"
+	defaultTimeout    = 5 * time.Minute
+	defaultMaxFetches = 100
+)
+
+var _ source.Source = (*Script)(nil)
+
+// Config configures the script source. See config.Script for the meaning of the fields.
+type Config struct {
+	Dir                  string
+	AllowedHosts         []string
+	AllowPrivateNetworks bool
+	Timeout              time.Duration
+	MaxFetches           int
+}
+
+// Script runs JavaScript files from a directory as sources.
+//
+// Scripts are compiled on first use, and compiled again whenever the file changes,
+// so edits take effect on the next run without restarting claw.
+type Script struct {
+	source.UnimplementedSource
+
+	Config func() Config
+	// Logger receives the console calls of scripts. slog.Default() is used when nil.
+	Logger *slog.Logger
+
+	mu       sync.Mutex
+	programs map[string]*program
+}
+
+// program is a compiled script.
+type program struct {
+	modTime time.Time
+	size    int64
+	program *goja.Program
+}
+
+// Name returns the unique kind identifier for the source.
+func (sc *Script) Name() string {
+	return SourceName
+}
+
+// DisplayName returns the human-readable name for the source.
+func (sc *Script) DisplayName() string {
+	return "Script"
+}
+
+// Author returns the author name.
+func (sc *Script) Author() string {
+	return "Claw"
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (sc *Script) AuthorURL() string {
+	return "https://github.com/tigorlazuardi/claw"
+}
+
+func (sc *Script) Description() string {
+	return `Runs a JavaScript file from the scripts directory of claw to find images.
+
+Scripts can fetch pages and APIs, parse JSON and HTML, and emit the images they find.
+Use this to support a small site without writing a plugin.`
+}
+
+func (sc *Script) RequireParameter() bool {
+	return true
+}
+
+// DefaultCountback is passed to scripts as request.countback when the source has no countback.
+func (sc *Script) DefaultCountback() int {
+	return 50
+}
+
+const helpString = /*markdown*/
+`The name of a script in the scripts directory, without ".js", optionally followed by parameters
+for the script, e.g. ` + "`mysite?tag=landscape&sort=new`" + `.
+
+A script defines a ` + "`run(request)`" + ` function, and calls ` + "`emit(image)`" + ` for every image it finds:
+
+` + "```js" + `
+function run(request) {
+  // request.params holds the parameters, e.g. {tag: "landscape", sort: "new"}.
+  const page = fetch("https://example.com/tag/" + encodeURIComponent(request.params.tag)).html();
+  page.find(".post").each((post, i) => {
+    if (i >= request.countback) return false; // stops the loop
+    emit({
+      download_url: post.find("a.download").url("href"), // required
+      title: post.find(".title").text(),
+      website: post.find("a.permalink").url("href"),
+      tags: post.find(".tag").map((tag) => tag.text()),
+    });
+  });
+}
+
+// Optional. Throw an error to reject the parameters when the source is saved.
+function validate(params) {
+  if (!params.tag) throw new Error("tag is required");
+}
+` + "```" + `
+
+The image fields are download_url, width, height, filesize, author, author_url, website, thumbnail_url,
+posted_at (a Date or RFC 3339 string), filename, title, tags, and nsfw.
+download_url and thumbnail_url must be URLs that fetch is allowed to fetch from.
+
+Available functions:
+
+- ` + "`fetch(url, {method, headers, body})`" + ` returns ` + "`{status, ok, url, headers, text(), json(), html()}`" + `.
+  Only http and https URLs are allowed, and the number of fetches per run is limited.
+- ` + "`parseHTML(text, baseURL)`" + ` returns a selection with find, first, last, eq, parent, children, length,
+  text, html, attr, url (an attribute resolved to an absolute URL), each, and map.
+- ` + "`resolveURL(base, ref)`" + ` resolves a relative URL.
+- ` + "`console.log`" + `, ` + "`console.warn`" + `, and ` + "`console.error`" + ` write to the job logs.
+`
+
+// ParameterHelp returns the help string for the parameter, and lists the available scripts.
+// Markdown formatting is supported, but any Javascript will be stripped.
+func (sc *Script) ParameterHelp() string {
+	cfg := sc.config()
+	names, err := listScripts(cfg.Dir)
+	if err != nil {
+		return helpString + fmt.Sprintf("\nFailed to list scripts in `%s`: %s\n", cfg.Dir, err)
+	}
+	if len(names) == 0 {
+		return helpString + fmt.Sprintf("\nThere are no scripts in `%s` yet.\n", cfg.Dir)
+	}
+	return helpString + fmt.Sprintf("\nAvailable scripts in `%s`: `%s`\n", cfg.Dir, strings.Join(names, "`, `"))
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+//
+// This is usually a very short string to show as a hint for the user.
+func (sc *Script) ParameterPlaceholder() string {
+	return "Script name and parameters, e.g. mysite?tag=landscape"
+}
+
+// ValidateTransformParameter checks that the script exists and compiles, and runs the validate
+// function of the script if it has one.
+func (sc *Script) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	params, err := parseParameter(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid script parameter: %w\n\n%s", err, helpString)
+	}
+	cfg := sc.config()
+	prg, err := sc.load(cfg.Dir, params.Script)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	vm, err := newRuntime(ctx, cfg, sc.logger(), params.Script, nil)
+	if err != nil {
+		return "", err
+	}
+	if err := vm.run(prg); err != nil {
+		return "", err
+	}
+	if _, ok := goja.AssertFunction(vm.Get("run")); !ok {
+		return "", fmt.Errorf("script %s does not define a run function", params.Script)
+	}
+	if _, err := vm.call("validate", false, vm.paramsObject(params)); err != nil {
+		return "", err
+	}
+	return params.String(), nil
+}
+
+// Run runs the script and returns the images it emitted.
+func (sc *Script) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	params, err := parseParameter(request.Parameter)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	cfg := sc.config()
+	prg, err := sc.load(cfg.Dir, params.Script)
+	if err != nil {
+		return source.Response{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+	var images source.Images
+	vm, err := newRuntime(ctx, cfg, sc.logger(), params.Script, func(img source.Image) {
+		// Filenames chosen by scripts become paths under the images directory, so they are sanitized too.
+		if img.Filename = source.SanitizeFilename(img.Filename, request.FilenameMaxLength); img.Filename == "" {
+			img.Filename = source.SafeFilename(params.Script, source.URLFilename(img.DownloadURL), request.FilenameMaxLength)
+		}
+		images = append(images, img)
+	})
+	if err != nil {
+		return source.Response{}, err
+	}
+	if err := vm.run(prg); err != nil {
+		return source.Response{}, err
+	}
+	countback := request.Countback
+	if countback <= 0 {
+		countback = sc.DefaultCountback()
+	}
+	filenameMaxLength := source.FilenameMaxLength(request.FilenameMaxLength)
+	req := vm.NewObject()
+	_ = req.Set("params", vm.paramsObject(params))
+	_ = req.Set("countback", countback)
+	_ = req.Set("filename_max_length", filenameMaxLength)
+	if _, err := vm.call("run", true, req); err != nil {
+		return source.Response{}, err
+	}
+	return source.Response{Images: images}, nil
+}
+
+func (sc *Script) logger() *slog.Logger {
+	if sc.Logger == nil {
+		return slog.Default()
+	}
+	return sc.Logger
+}
+
+func (sc *Script) config() Config {
+	cfg := sc.Config()
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.MaxFetches <= 0 {
+		cfg.MaxFetches = defaultMaxFetches
+	}
+	return cfg
+}
+
+// load returns the compiled script, compiling it again if the file changed since it was last compiled.
+func (sc *Script) load(dir, name string) (*goja.Program, error) {
+	path := filepath.Join(dir, name+scriptExt)
+	stat, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("script %q not found in %s", name, dir)
+		}
+		return nil, fmt.Errorf("failed to stat script %s: %w", path, err)
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if prg, ok := sc.programs[path]; ok && prg.modTime.Equal(stat.ModTime()) && prg.size == stat.Size() {
+		return prg.program, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script %s: %w", path, err)
+	}
+	compiled, err := goja.Compile(name+scriptExt, string(content), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile script %s: %w", path, err)
+	}
+	if sc.programs == nil {
+		sc.programs = map[string]*program{}
+	}
+	sc.programs[path] = &program{modTime: stat.ModTime(), size: stat.Size(), program: compiled}
+	return compiled, nil
+}
+
+// listScripts returns the names of the scripts in dir.
+func listScripts(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), scriptExt)
+		if ok && !entry.IsDir() && validScriptName.MatchString(name) {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names, nil
+}
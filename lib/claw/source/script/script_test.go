@@ -0,0 +1,252 @@
+package script
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const galleryPage = `<html><body>
+<div class="post"><a class="download" href="/images/one.png">one</a><span class="title"> First </span><span class="tag">a</span><span class="tag">b</span></div>
+<div class="post"><a class="download" href="https://cdn.example.com/two.jpg">two</a><span class="title">Second</span></div>
+<div class="post"><span class="title">No image</span></div>
+</body></html>`
+
+func newTestScript(t *testing.T, cfg Config) (*Script, string) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg.Dir = dir
+	return &Script{Config: func() Config { return cfg }}, dir
+}
+
+func writeScript(t *testing.T, dir, name, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name+scriptExt), []byte(content), 0o644))
+}
+
+func TestParseParameter(t *testing.T) {
+	tests := []struct {
+		param   string
+		want    string
+		wantErr bool
+	}{
+		{param: "mysite", want: "mysite"},
+		{param: " mysite.js ", want: "mysite"},
+		{param: "mysite?tag=b&sort=new", want: "mysite?sort=new&tag=b"},
+		{param: "", wantErr: true},
+		{param: "../secret", wantErr: true},
+		{param: "dir/mysite", wantErr: true},
+		{param: "mysite?tag=%zz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.param, func(t *testing.T) {
+			got, err := parseParameter(tt.param)
+			if tt.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.String())
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gallery":
+			_, _ = w.Write([]byte(galleryPage))
+		case "/api":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`{"posts": [{"url": "https://example.com/api.png", "date": "2024-05-01T10:00:00Z", "w": 1920}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	sc, dir := newTestScript(t, Config{AllowPrivateNetworks: true})
+	var logs bytes.Buffer
+	sc.Logger = slog.New(slog.NewTextHandler(&logs, nil))
+	writeScript(t, dir, "gallery", `
+function run(request) {
+  const page = fetch(request.params.base + "/gallery").html();
+  page.find(".post").each((post, i) => {
+    const url = post.find("a.download").url("href");
+    if (url === null) return;
+    emit({
+      download_url: url,
+      title: post.find(".title").text(),
+      tags: post.find(".tag").map((tag) => tag.text()),
+    });
+  });
+  const data = fetch(request.params.base + "/api").json();
+  for (const post of data.posts) {
+    emit({download_url: post.url, posted_at: post.date, width: post.w, filename: "api.png"});
+  }
+  if (!fetch(request.params.base + "/missing").ok) {
+    console.log("missing page", {count: request.countback});
+  }
+}
+`)
+
+	param, err := sc.ValidateTransformParameter(context.Background(), "gallery?base="+server.URL)
+	require.NoError(t, err)
+
+	resp, err := sc.Run(context.Background(), source.Request{Parameter: param, Countback: 10})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 3)
+
+	assert.Equal(t, server.URL+"/images/one.png", resp.Images[0].DownloadURL)
+	assert.Equal(t, "First", resp.Images[0].Title)
+	assert.Equal(t, []string{"a", "b"}, resp.Images[0].Tags)
+	assert.Equal(t, "gallery_one.png", resp.Images[0].Filename)
+	assert.Equal(t, "https://cdn.example.com/two.jpg", resp.Images[1].DownloadURL)
+	assert.Empty(t, resp.Images[1].Tags)
+	assert.Equal(t, "api.png", resp.Images[2].Filename)
+	assert.Equal(t, int64(1920), resp.Images[2].Width)
+	assert.True(t, resp.Images[2].PostedAt.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)))
+	assert.Contains(t, logs.String(), `msg="missing page {\"count\":10}" script=gallery`)
+}
+
+func TestRun_EmittedFilename(t *testing.T) {
+	tests := []struct {
+		filename string
+		want     string
+	}{
+		{filename: "", want: "names_image.jpg"},
+		{filename: "cover.jpg", want: "cover.jpg"},
+		{filename: "../../../.bashrc", want: ".._.._.._.bashrc"},
+		{filename: "..", want: "__"},
+		{filename: "/etc/passwd", want: "_etc_passwd"},
+		{filename: `dir\image.jpg`, want: "dir_image.jpg"},
+	}
+	// Emitted URLs are not resolved when private networks are allowed, so the test does not need DNS.
+	sc, dir := newTestScript(t, Config{AllowPrivateNetworks: true})
+	writeScript(t, dir, "names", `
+function run(request) {
+  emit({download_url: "https://example.com/image.jpg", filename: request.params.filename});
+}
+`)
+	for _, tt := range tests {
+		t.Run(tt.filename, func(t *testing.T) {
+			param := "names?filename=" + url.QueryEscape(tt.filename)
+			resp, err := sc.Run(context.Background(), source.Request{Parameter: param})
+			require.NoError(t, err)
+			require.Len(t, resp.Images, 1)
+			assert.Equal(t, tt.want, resp.Images[0].Filename)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	sc, dir := newTestScript(t, Config{})
+	writeScript(t, dir, "strict", `
+function validate(params) {
+  if (!params.tag) throw new Error("tag is required");
+}
+function run(request) {}
+`)
+	writeScript(t, dir, "norun", `function validate(params) {}`)
+	writeScript(t, dir, "broken", `function run( {`)
+
+	_, err := sc.ValidateTransformParameter(context.Background(), "strict?tag=a")
+	require.NoError(t, err)
+
+	_, err = sc.ValidateTransformParameter(context.Background(), "strict")
+	require.ErrorContains(t, err, "tag is required")
+
+	_, err = sc.ValidateTransformParameter(context.Background(), "norun")
+	require.ErrorContains(t, err, "does not define a run function")
+
+	_, err = sc.ValidateTransformParameter(context.Background(), "broken")
+	require.ErrorContains(t, err, "failed to compile")
+
+	_, err = sc.ValidateTransformParameter(context.Background(), "missing")
+	require.ErrorContains(t, err, "not found")
+
+	assert.Contains(t, sc.ParameterHelp(), "`broken`, `norun`, `strict`")
+}
+
+func TestFetchRestrictions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	run := func(t *testing.T, cfg Config, body string) error {
+		sc, dir := newTestScript(t, cfg)
+		writeScript(t, dir, "fetcher", "function run(request) {\n"+body+"\n}")
+		_, err := sc.Run(context.Background(), source.Request{Parameter: "fetcher"})
+		return err
+	}
+
+	t.Run("private networks", func(t *testing.T) {
+		err := run(t, Config{}, `fetch("`+server.URL+`")`)
+		require.ErrorContains(t, err, "private")
+	})
+	t.Run("scheme", func(t *testing.T) {
+		err := run(t, Config{AllowPrivateNetworks: true}, `fetch("file:///etc/passwd")`)
+		require.ErrorContains(t, err, "http:// or https://")
+	})
+	t.Run("allowed hosts", func(t *testing.T) {
+		err := run(t, Config{AllowPrivateNetworks: true, AllowedHosts: []string{"example.com"}}, `fetch("`+server.URL+`")`)
+		require.ErrorContains(t, err, "not allowed")
+	})
+	t.Run("emitted private urls", func(t *testing.T) {
+		err := run(t, Config{}, `emit({download_url: "http://127.0.0.1/image.jpg"})`)
+		require.ErrorContains(t, err, "private")
+		err = run(t, Config{}, `emit({download_url: "http://[::1]/image.jpg"})`)
+		require.ErrorContains(t, err, "private")
+		err = run(t, Config{}, `emit({download_url: "http://169.254.169.254/latest/meta-data/"})`)
+		require.ErrorContains(t, err, "private")
+	})
+	t.Run("emitted urls outside allowed hosts", func(t *testing.T) {
+		cfg := Config{AllowPrivateNetworks: true, AllowedHosts: []string{"example.com"}}
+		err := run(t, cfg, `emit({download_url: "https://other.com/image.jpg"})`)
+		require.ErrorContains(t, err, "not allowed")
+		err = run(t, cfg, `emit({download_url: "https://cdn.example.com/image.jpg", thumbnail_url: "http://other.com/thumb.jpg"})`)
+		require.ErrorContains(t, err, "thumbnail_url")
+		require.NoError(t, run(t, cfg, `emit({download_url: "https://cdn.example.com/image.jpg"})`))
+	})
+	t.Run("max fetches", func(t *testing.T) {
+		err := run(t, Config{AllowPrivateNetworks: true, MaxFetches: 2}, `for (let i = 0; i < 3; i++) fetch("`+server.URL+`")`)
+		require.ErrorContains(t, err, "more than 2 fetches")
+	})
+	t.Run("timeout", func(t *testing.T) {
+		err := run(t, Config{Timeout: 100 * time.Millisecond}, `try { while (true) {} } catch (e) {}`)
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestHotReload(t *testing.T) {
+	// Emitted URLs are not resolved when private networks are allowed, so the test does not need DNS.
+	sc, dir := newTestScript(t, Config{AllowPrivateNetworks: true})
+	writeScript(t, dir, "reload", `function run(request) { emit({download_url: "https://example.com/old.png"}) }`)
+	resp, err := sc.Run(context.Background(), source.Request{Parameter: "reload"})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 1)
+	assert.True(t, strings.HasSuffix(resp.Images[0].DownloadURL, "old.png"))
+
+	writeScript(t, dir, "reload", `function run(request) { emit({download_url: "https://example.com/new.png"}) }`)
+	// Make sure the change is noticed on file systems with coarse modification times.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "reload"+scriptExt), later, later))
+
+	resp, err = sc.Run(context.Background(), source.Request{Parameter: "reload"})
+	require.NoError(t, err)
+	require.Len(t, resp.Images, 1)
+	assert.True(t, strings.HasSuffix(resp.Images[0].DownloadURL, "new.png"))
+}
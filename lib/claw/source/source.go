@@ -130,8 +130,21 @@ type Request struct {
 	// FilenameMaxLength is the maximum allowed length for generated filenames including the extension.
 	// If 0 or negative, the Source should use its own default value.
 	FilenameMaxLength int
+	// Cursor is the Response.Cursor of the last successful run of this source, or empty on the first run
+	// and after the parameter was changed.
+	//
+	// Sources that support it should stop looking further back once they reach the content the cursor points at,
+	// since that content was already seen. Countback still caps how far back to look, so the first run does not
+	// go through the entire history.
+	Cursor string
 }
 
 type Response struct {
 	Images Images
+	// Cursor points at the newest content seen in this run, in a format only the source understands,
+	// e.g. the ID of the newest post.
+	//
+	// Claw stores it after the job completed, and passes it back as Request.Cursor in the next run.
+	// Leave it empty to keep the stored cursor, e.g. when no content was found.
+	Cursor string
 }
@@ -0,0 +1,32 @@
+package source
+
+import "context"
+
+// SourceBackfiller is an optional interface for sources that can go through their entire history,
+// one page at a time, for backfill jobs.
+//
+// Claw stores the page token after every page, so a backfill continues from the last page it fetched
+// after a restart or failure.
+type SourceBackfiller interface {
+	// RunPage fetches the images of a single page. The first page has an empty PageToken.
+	//
+	// RunPage should return an error if the parameter cannot be backfilled, e.g. a listing in random order.
+	RunPage(ctx context.Context, request PageRequest) (PageResponse, error)
+}
+
+type PageRequest struct {
+	// Parameter is the parameter of the source.
+	Parameter string
+	// PageToken is the PageResponse.NextPageToken of the previous page, or empty for the first page.
+	PageToken string
+	// FilenameMaxLength is the maximum allowed length for generated filenames including the extension.
+	// If 0 or negative, the Source should use its own default value.
+	FilenameMaxLength int
+}
+
+type PageResponse struct {
+	Images Images
+	// NextPageToken is the token of the next page, in a format only the source understands.
+	// Empty when this was the last page.
+	NextPageToken string
+}
@@ -0,0 +1,54 @@
+package source
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StatusError is returned by sources when a website or API responds with an unexpected HTTP status.
+//
+// Claw retries jobs that failed with a temporary status, e.g. 429 or 5xx, so sources should return (or wrap)
+// a StatusError instead of a plain error for unexpected statuses.
+type StatusError struct {
+	// Service describes what responded, e.g. "Reddit API".
+	Service    string
+	StatusCode int
+	// Message is the error message in the response, if any.
+	Message string
+	// RetryAfter is how long the service asked to wait before trying again, if it sent a Retry-After header.
+	// See [RetryAfter].
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s returned status %d: %s", e.Service, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s returned status %d", e.Service, e.StatusCode)
+}
+
+// Temporary reports whether the request may succeed when it is tried again later.
+func (e *StatusError) Temporary() bool {
+	return e.StatusCode == http.StatusRequestTimeout ||
+		e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= 500
+}
+
+// RetryAfter returns the wait time in the Retry-After header, either in seconds or as an HTTP date.
+// Returns 0 if the header is missing, invalid, or in the past.
+func RetryAfter(header http.Header) time.Duration {
+	value := strings.TrimSpace(header.Get("Retry-After"))
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(max(seconds, 0) * float64(time.Second))
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0)
+	}
+	return 0
+}
@@ -0,0 +1,13 @@
+package source
+
+// SourceLocalFiles is an optional interface for sources that return files on the local filesystem,
+// using file:// download URLs.
+//
+// Claw only reads local files for sources implementing this interface. file:// URLs returned by
+// other sources fail to download, so a source cannot be used to read arbitrary files on the host.
+//
+// Local files are hardlinked into the library when possible, and copied otherwise.
+type SourceLocalFiles interface {
+	// AllowLocalFile reports whether the file at the given absolute path may be read for this source.
+	AllowLocalFile(path string) bool
+}
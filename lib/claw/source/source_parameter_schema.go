@@ -0,0 +1,20 @@
+package source
+
+import "encoding/json"
+
+// SourceParameterSchema is an optional interface for sources whose parameter is a JSON object
+// with multiple options, instead of a single string.
+//
+// The schema is shown to the user as a form. Claw validates parameters against the schema before
+// they are passed to ValidateTransformParameter, and stores them as compact JSON with sorted keys,
+// so the same options always result in the same parameter string.
+//
+// Run receives the stored JSON as Request.Parameter.
+type SourceParameterSchema interface {
+	// ParameterSchema returns a JSON Schema (draft 2020-12) for the parameter. The root must be
+	// an object schema.
+	//
+	// Return nil if the source takes a plain string parameter after all,
+	// e.g. a plugin that does not report a schema.
+	ParameterSchema() json.RawMessage
+}
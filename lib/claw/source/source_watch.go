@@ -0,0 +1,18 @@
+package source
+
+import "context"
+
+// SourceWatcher is an optional interface for sources that can notice new images on their own,
+// e.g. by watching a directory, instead of only finding them when a job runs.
+//
+// Claw calls Watch for every enabled source of this kind, and creates a job for the source
+// whenever notify is called.
+type SourceWatcher interface {
+	// Watch watches for new images of the given parameter until ctx is cancelled.
+	//
+	// Implementations should debounce notify, so a burst of changes creates a single job.
+	//
+	// Watch must return nil right away if the parameter does not ask for watching.
+	// When an error is returned, Claw logs it and calls Watch again later.
+	Watch(ctx context.Context, parameter string, notify func()) error
+}
@@ -0,0 +1,161 @@
+package wallhaven
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	flagsPattern      = regexp.MustCompile(`^[01]{3}$`)
+	resolutionPattern = regexp.MustCompile(`^[1-9][0-9]*x[1-9][0-9]*$`)
+	colorPattern      = regexp.MustCompile(`^[0-9a-f]{6}$`)
+
+	filters   = []string{"q", "categories", "purity", "atleast", "resolutions", "ratios", "colors", "sorting", "order", "topRange"}
+	sortings  = []string{"date_added", "relevance", "random", "views", "favorites", "toplist", "hot"}
+	orders    = []string{"desc", "asc"}
+	topRanges = []string{"1d", "3d", "1w", "1M", "3M", "6M", "1y"}
+
+	// pageSortings are the listing pages of the website and the sorting they use.
+	pageSortings = map[string]string{
+		"":        "",
+		"search":  "",
+		"latest":  "date_added",
+		"toplist": "toplist",
+		"hot":     "hot",
+		"random":  "random",
+	}
+
+	// ignoredFilters are accepted in URLs, but not kept in the parameter.
+	ignoredFilters = []string{"page", "seed"}
+)
+
+// parseSearch parses a parameter into the query of the search API.
+//
+// The parameter is either empty, a search URL, the query part of a search URL, or a search query.
+func parseSearch(param string) (url.Values, error) {
+	raw := strings.TrimSpace(param)
+	var query url.Values
+	switch {
+	case raw == "":
+		return url.Values{}, nil
+	case strings.HasPrefix(raw, "http://") || strings.HasPrefix(raw, "https://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URL %q: %w", raw, err)
+		}
+		if host := strings.ToLower(u.Hostname()); host != "wallhaven.cc" && host != "www.wallhaven.cc" {
+			return nil, fmt.Errorf("%q is not a Wallhaven URL", raw)
+		}
+		page := strings.Trim(u.Path, "/")
+		sorting, ok := pageSortings[page]
+		if !ok {
+			return nil, fmt.Errorf("%q is not a Wallhaven search, latest, top, hot, or random page", raw)
+		}
+		query = u.Query()
+		if sorting != "" && query.Get("sorting") == "" {
+			query.Set("sorting", sorting)
+		}
+	case strings.Contains(raw, "="):
+		var err error
+		query, err = url.ParseQuery(strings.TrimPrefix(raw, "?"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid search filters %q: %w", raw, err)
+		}
+	default:
+		query = url.Values{"q": {raw}}
+	}
+
+	search := url.Values{}
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		value := strings.TrimSpace(query.Get(key))
+		if slices.Contains(ignoredFilters, key) || value == "" {
+			continue
+		}
+		if key == "colors" {
+			value = strings.ToLower(strings.TrimPrefix(value, "#"))
+		}
+		if err := validateFilter(key, value); err != nil {
+			return nil, err
+		}
+		search.Set(key, value)
+	}
+	if search.Has("topRange") && search.Get("sorting") != "toplist" {
+		return nil, errors.New("topRange is only supported with sorting=toplist")
+	}
+	return search, nil
+}
+
+func validateFilter(key, value string) error {
+	switch key {
+	case "q":
+		return nil
+	case "categories", "purity":
+		if !flagsPattern.MatchString(value) || value == "000" {
+			return fmt.Errorf("%s=%q must be three digits of 0 or 1 with at least one 1, e.g. 100", key, value)
+		}
+	case "atleast":
+		if !resolutionPattern.MatchString(value) {
+			return fmt.Errorf("atleast=%q must be a resolution, e.g. 1920x1080", value)
+		}
+	case "resolutions":
+		for res := range strings.SplitSeq(value, ",") {
+			if !resolutionPattern.MatchString(res) {
+				return fmt.Errorf("resolutions=%q must be a comma separated list of resolutions, e.g. 1920x1080,2560x1440", value)
+			}
+		}
+	case "ratios":
+		for ratio := range strings.SplitSeq(value, ",") {
+			if ratio != "landscape" && ratio != "portrait" && !resolutionPattern.MatchString(ratio) {
+				return fmt.Errorf("ratios=%q must be a comma separated list of ratios, e.g. 16x9,21x9, or landscape or portrait", value)
+			}
+		}
+	case "colors":
+		if !colorPattern.MatchString(value) {
+			return fmt.Errorf("colors=%q must be a color hex code without #, e.g. 336600", value)
+		}
+	case "sorting":
+		return validateEnum(key, value, sortings)
+	case "order":
+		return validateEnum(key, value, orders)
+	case "topRange":
+		return validateEnum(key, value, topRanges)
+	default:
+		return fmt.Errorf("unsupported search filter %q, must be one of: %s", key, strings.Join(filters, ", "))
+	}
+	return nil
+}
+
+func validateEnum(key, value string, allowed []string) error {
+	if !slices.Contains(allowed, value) {
+		return fmt.Errorf("unsupported %s=%q, must be one of: %s", key, value, strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// ValidateTransformParameter validates the search and normalizes it to the query part of a search URL,
+// e.g. "https://wallhaven.cc/toplist?topRange=1w" becomes "sorting=toplist&topRange=1w".
+//
+// The search is sent to Wallhaven once to check the API key when NSFW purity is requested.
+func (wa *Wallhaven) ValidateTransformParameter(ctx context.Context, param string) (transformed string, err error) {
+	search, err := parseSearch(param)
+	if err != nil {
+		return "", fmt.Errorf("invalid Wallhaven parameter: %w\n\n%s", err, helpString)
+	}
+
+	cfg := wa.currentConfig()
+	if strings.HasSuffix(search.Get("purity"), "1") {
+		if cfg.APIKey == "" {
+			return "", errors.New("searching NSFW wallpapers (purity=xx1) requires a Wallhaven API key in the server configuration")
+		}
+		if _, err := wa.searchPage(ctx, cfg, search, 1, ""); err != nil {
+			return "", fmt.Errorf("failed to search Wallhaven: %w", err)
+		}
+	}
+	return search.Encode(), nil
+}
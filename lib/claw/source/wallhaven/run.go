@@ -0,0 +1,268 @@
+package wallhaven
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// Wallhaven API response structures. See https://wallhaven.cc/help/api.
+type searchResponse struct {
+	Data []wallpaper `json:"data"`
+	Meta struct {
+		CurrentPage int `json:"current_page"`
+		LastPage    int `json:"last_page"`
+		// Seed of random sorting. Must be passed to the next pages to get consistent results.
+		Seed *string `json:"seed"`
+	} `json:"meta"`
+}
+
+type wallpaper struct {
+	ID  string `json:"id"`
+	URL string `json:"url"`
+	// Purity is "sfw", "sketchy", or "nsfw".
+	Purity string `json:"purity"`
+	// Category is "general", "anime", or "people".
+	Category   string `json:"category"`
+	DimensionX int64  `json:"dimension_x"`
+	DimensionY int64  `json:"dimension_y"`
+	FileSize   int64  `json:"file_size"`
+	// CreatedAt is formatted as "2006-01-02 15:04:05" in UTC.
+	CreatedAt string `json:"created_at"`
+	// Path is the URL of the full image.
+	Path   string `json:"path"`
+	Thumbs struct {
+		Large string `json:"large"`
+	} `json:"thumbs"`
+}
+
+type wallpaperResponse struct {
+	Data struct {
+		Uploader struct {
+			Username string `json:"username"`
+		} `json:"uploader"`
+		Tags []struct {
+			Name string `json:"name"`
+		} `json:"tags"`
+	} `json:"data"`
+}
+
+// Run runs the source to fetch image Metadata based on the given request.
+//
+// Countback is the number of wallpapers to fetch. Wallhaven returns a fixed number of wallpapers per page,
+// so pages are fetched until Countback is reached or the search has no more results.
+func (wa *Wallhaven) Run(ctx context.Context, request source.Request) (source.Response, error) {
+	search, err := parseSearch(request.Parameter)
+	if err != nil {
+		return source.Response{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	cfg := wa.currentConfig()
+
+	countback := request.Countback
+	if countback <= 0 {
+		countback = wa.DefaultCountback()
+	}
+
+	var (
+		images source.Images
+		seed   string
+	)
+	for page := 1; countback > 0; page++ {
+		resp, err := wa.searchPage(ctx, cfg, search, page, seed)
+		if err != nil {
+			return source.Response{}, fmt.Errorf("failed to fetch Wallhaven wallpapers: %w", err)
+		}
+
+		for _, w := range resp.Data[:min(countback, len(resp.Data))] {
+			image := wa.convertWallpaper(w)
+			if cfg.FetchTags {
+				if err := wa.addWallpaperDetails(ctx, cfg, w.ID, &image); err != nil {
+					return source.Response{}, fmt.Errorf("failed to fetch tags of wallpaper %s: %w", w.ID, err)
+				}
+			}
+			images = append(images, image)
+		}
+
+		countback -= len(resp.Data)
+		if resp.Meta.Seed != nil {
+			seed = *resp.Meta.Seed
+		}
+		if len(resp.Data) == 0 || page >= resp.Meta.LastPage {
+			break
+		}
+	}
+
+	return source.Response{Images: images}, nil
+}
+
+// RunPage fetches a page of search results for backfill jobs. Page tokens are page numbers.
+//
+// Searches in random order cannot be backfilled, since they have no end.
+func (wa *Wallhaven) RunPage(ctx context.Context, request source.PageRequest) (source.PageResponse, error) {
+	search, err := parseSearch(request.Parameter)
+	if err != nil {
+		return source.PageResponse{}, fmt.Errorf("invalid parameter %q: %w", request.Parameter, err)
+	}
+	if search.Get("sorting") == "random" {
+		return source.PageResponse{}, errors.New("searches sorted by random cannot be backfilled")
+	}
+	page := 1
+	if request.PageToken != "" {
+		page, err = strconv.Atoi(request.PageToken)
+		if err != nil || page < 1 {
+			return source.PageResponse{}, fmt.Errorf("invalid page token %q", request.PageToken)
+		}
+	}
+	cfg := wa.currentConfig()
+
+	resp, err := wa.searchPage(ctx, cfg, search, page, "")
+	if err != nil {
+		return source.PageResponse{}, fmt.Errorf("failed to fetch Wallhaven wallpapers: %w", err)
+	}
+	var images source.Images
+	for _, w := range resp.Data {
+		image := wa.convertWallpaper(w)
+		if cfg.FetchTags {
+			if err := wa.addWallpaperDetails(ctx, cfg, w.ID, &image); err != nil {
+				return source.PageResponse{}, fmt.Errorf("failed to fetch tags of wallpaper %s: %w", w.ID, err)
+			}
+		}
+		images = append(images, image)
+	}
+
+	var next string
+	if len(resp.Data) > 0 && page < resp.Meta.LastPage {
+		next = strconv.Itoa(page + 1)
+	}
+	return source.PageResponse{Images: images, NextPageToken: next}, nil
+}
+
+// searchPage fetches a page of search results. Pages start at 1.
+func (wa *Wallhaven) searchPage(ctx context.Context, cfg Config, search url.Values, page int, seed string) (searchResponse, error) {
+	query := url.Values{}
+	maps.Copy(query, search)
+	query.Set("page", strconv.Itoa(page))
+	if seed != "" {
+		query.Set("seed", seed)
+	}
+
+	var resp searchResponse
+	err := wa.get(ctx, cfg, "/api/v1/search", query, &resp)
+	return resp, err
+}
+
+// addWallpaperDetails adds the tags and uploader of a wallpaper to the image.
+func (wa *Wallhaven) addWallpaperDetails(ctx context.Context, cfg Config, id string, image *source.Image) error {
+	var resp wallpaperResponse
+	if err := wa.get(ctx, cfg, "/api/v1/w/"+url.PathEscape(id), nil, &resp); err != nil {
+		return err
+	}
+	for _, tag := range resp.Data.Tags {
+		image.Tags = append(image.Tags, tag.Name)
+	}
+	if username := resp.Data.Uploader.Username; username != "" {
+		image.Author = username
+		image.AuthorURL = cmp.Or(wa.baseURL, defaultBaseURL) + "/user/" + url.PathEscape(username)
+	}
+	return nil
+}
+
+// get sends a GET request to the Wallhaven API and decodes the JSON response into v.
+//
+// Rate limited (429) requests are retried after pausing, since Wallhaven only allows 45 calls per minute.
+func (wa *Wallhaven) get(ctx context.Context, cfg Config, apiPath string, query url.Values, v any) error {
+	u := cmp.Or(wa.baseURL, defaultBaseURL) + apiPath
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("User-Agent", userAgent)
+		if cfg.APIKey != "" {
+			// The header keeps the key out of logged URLs.
+			req.Header.Set("X-API-Key", cfg.APIKey)
+		}
+
+		resp, err := wa.Client.Do(req)
+		if err != nil {
+			return fmt.Errorf("failed to make request: %w", err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusOK:
+			defer resp.Body.Close()
+			if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+				return fmt.Errorf("failed to decode response: %w", err)
+			}
+			return nil
+		case resp.StatusCode == http.StatusTooManyRequests && attempt < maxRateLimitRetries:
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+			if err := wa.sleep(ctx, cmp.Or(source.RetryAfter(resp.Header), wa.rateLimitPause, defaultRateLimitPause)); err != nil {
+				return err
+			}
+		case resp.StatusCode == http.StatusUnauthorized:
+			_ = resp.Body.Close()
+			return errors.New("Wallhaven rejected the API key, check the API key in the server configuration")
+		default:
+			_ = resp.Body.Close()
+			return &source.StatusError{Service: "Wallhaven API", StatusCode: resp.StatusCode, RetryAfter: source.RetryAfter(resp.Header)}
+		}
+	}
+}
+
+func (wa *Wallhaven) sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// convertWallpaper converts a search result to a source.Image.
+func (wa *Wallhaven) convertWallpaper(w wallpaper) source.Image {
+	postedAt, _ := time.ParseInLocation(time.DateTime, w.CreatedAt, time.UTC)
+	var tags []string
+	if w.Category != "" {
+		tags = append(tags, w.Category)
+	}
+	if w.Purity != "sfw" && w.Purity != "" {
+		tags = append(tags, w.Purity)
+	}
+
+	image := source.Image{
+		DownloadURL:  w.Path,
+		Width:        w.DimensionX,
+		Height:       w.DimensionY,
+		Filesize:     w.FileSize,
+		Website:      w.URL,
+		ThumbnailURL: w.Thumbs.Large,
+		PostedAt:     postedAt,
+		Tags:         tags,
+		// Sketchy wallpapers are suggestive, so they are kept off devices that do not allow NSFW as well.
+		NSFW: w.Purity == "sketchy" || w.Purity == "nsfw",
+	}
+	// Full images are named wallhaven-<id>.<ext>, which is unique and short enough.
+	if u, err := url.Parse(w.Path); err == nil {
+		image.Filename = path.Base(u.Path)
+	}
+	return image
+}
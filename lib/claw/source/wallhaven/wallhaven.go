@@ -0,0 +1,133 @@
+package wallhaven
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+const (
+	SourceName = "claw.wallhaven.v1"
+
+	defaultBaseURL = "https://wallhaven.cc"
+	userAgent      = "claw/1.0"
+
+	// maxRateLimitRetries is how many times a rate limited request is retried after pausing.
+	maxRateLimitRetries = 3
+	// defaultRateLimitPause is how long to wait after a 429. Wallhaven allows 45 API calls per minute.
+	defaultRateLimitPause = time.Minute
+)
+
+type Doer interface {
+	Do(*http.Request) (*http.Response, error)
+}
+
+// Config configures how claw.wallhaven.v1 talks to Wallhaven.
+type Config struct {
+	// APIKey of a Wallhaven account. Required to search NSFW wallpapers.
+	APIKey string
+	// FetchTags fetches the tags and uploader of every wallpaper, which are not part of search results.
+	//
+	// This costs one extra API call per wallpaper, so runs are slowed down by the rate limit.
+	FetchTags bool
+}
+
+var (
+	_ source.Source           = (*Wallhaven)(nil)
+	_ source.SourceBackfiller = (*Wallhaven)(nil)
+)
+
+type Wallhaven struct {
+	source.UnimplementedSource
+
+	Client Doer
+	// Config returns the current configuration. It is called for every run, so configuration
+	// changes are picked up without restarting.
+	//
+	// Optional. If nil, Wallhaven is used anonymously.
+	Config func() Config
+
+	// Replaced in tests. Empty or zero means the real values.
+	baseURL        string
+	rateLimitPause time.Duration
+}
+
+func (wa *Wallhaven) currentConfig() Config {
+	if wa.Config == nil {
+		return Config{}
+	}
+	return wa.Config()
+}
+
+// Name returns the unique kind identifier for the source.
+func (wa *Wallhaven) Name() string {
+	return SourceName
+}
+
+// DisplayName returns the human-readable name for the source.
+func (wa *Wallhaven) DisplayName() string {
+	return "Wallhaven"
+}
+
+// Author returns the author name.
+func (wa *Wallhaven) Author() string {
+	return "Claw"
+}
+
+// AuthorURL returns where the Author can be found or contacted.
+func (wa *Wallhaven) AuthorURL() string {
+	return "https://github.com/tigorlazuardi/claw"
+}
+
+func (wa *Wallhaven) Description() string {
+	return `Fetches wallpapers from a Wallhaven search.
+
+Resolutions and file sizes are taken from the search results. Wallpapers with sketchy or NSFW purity are marked as NSFW.
+Searching NSFW wallpapers requires an API key in the server configuration.`
+}
+
+// RequireParameter returns false, an empty parameter fetches the latest wallpapers.
+func (wa *Wallhaven) RequireParameter() bool {
+	return false
+}
+
+func (wa *Wallhaven) DefaultCountback() int {
+	return 72
+}
+
+const helpString = /*markdown*/
+`Supported parameter formats for claw.wallhaven.v1:
+
+- Empty, to fetch the latest wallpapers.
+- A search query, e.g. mountains or #landscape.
+- A full URL to a search, e.g. https://wallhaven.cc/search?q=mountains&categories=100&purity=100&atleast=2560x1440&sorting=toplist&topRange=1M
+- The URL of the latest, top, hot, or random page, e.g. https://wallhaven.cc/toplist?topRange=1w
+- The query part of a search URL, e.g. q=mountains&ratios=16x9,16x10&sorting=views
+
+Supported search filters:
+
+- q: the search query.
+- categories: three digits for general, anime, and people, e.g. 100 for general only.
+- purity: three digits for SFW, sketchy, and NSFW, e.g. 110 for SFW and sketchy. NSFW requires an API key.
+- atleast: the minimum resolution, e.g. 1920x1080.
+- resolutions: exact resolutions, e.g. 1920x1080,2560x1440.
+- ratios: aspect ratios, e.g. 16x9,21x9, or landscape or portrait.
+- colors: a color hex code without #, e.g. 336600.
+- sorting: date_added (default), relevance, random, views, favorites, toplist, or hot.
+- order: desc (default) or asc.
+- topRange: time range for toplist sorting: 1d, 3d, 1w, 1M (default), 3M, 6M, or 1y.
+`
+
+// ParameterHelp returns the help string for the parameter.
+// Markdown formatting is supported, but any Javascript will be stripped.
+func (wa *Wallhaven) ParameterHelp() string {
+	return helpString
+}
+
+// ParameterPlaceholder returns the placeholder string for the parameter.
+//
+// This is usually a very short string to show as a hint for the user.
+func (wa *Wallhaven) ParameterPlaceholder() string {
+	return `Search query or URL, e.g. mountains or https://wallhaven.cc/toplist?topRange=1w`
+}
@@ -0,0 +1,227 @@
+package wallhaven
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+func TestParseSearch(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		want  string
+	}{
+		{name: "empty", param: "  ", want: ""},
+		{name: "query", param: "mountain lake", want: "q=mountain+lake"},
+		{name: "tag query", param: "#landscape", want: "q=%23landscape"},
+		{
+			name:  "search URL",
+			param: "https://wallhaven.cc/search?q=mountains&categories=100&purity=100&atleast=2560x1440&sorting=toplist&topRange=1M&order=desc&page=3",
+			want:  "atleast=2560x1440&categories=100&order=desc&purity=100&q=mountains&sorting=toplist&topRange=1M",
+		},
+		{name: "toplist page", param: "https://wallhaven.cc/toplist?topRange=1w", want: "sorting=toplist&topRange=1w"},
+		{name: "latest page", param: "https://wallhaven.cc/latest", want: "sorting=date_added"},
+		{name: "random page keeps explicit sorting", param: "https://wallhaven.cc/random?sorting=views", want: "sorting=views"},
+		{
+			name:  "query part of URL",
+			param: "?ratios=16x9,21x9,landscape&resolutions=1920x1080,2560x1440&colors=%23336600",
+			want:  "colors=336600&ratios=16x9%2C21x9%2Clandscape&resolutions=1920x1080%2C2560x1440",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSearch(tt.param)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.Encode())
+		})
+	}
+}
+
+func TestParseSearch_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"other site":             "https://example.com/search?q=a",
+		"wallpaper page":         "https://wallhaven.cc/w/abc123",
+		"unknown filter":         "q=a&purty=100",
+		"purity digits":          "purity=12",
+		"no category":            "categories=000",
+		"bad atleast":            "atleast=1920",
+		"bad ratio":              "ratios=16:9",
+		"bad sorting":            "sorting=newest",
+		"topRange without top":   "topRange=1w",
+		"topRange unknown range": "sorting=toplist&topRange=2w",
+	}
+	for name, param := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseSearch(param)
+			assert.Error(t, err)
+		})
+	}
+}
+
+// fakeWallhaven serves total wallpapers, perPage per page, and records the API calls.
+type fakeWallhaven struct {
+	total       int
+	perPage     int
+	searches    []map[string]string
+	details     atomic.Int32
+	rateLimited atomic.Int32
+	apiKey      string
+}
+
+func (f *fakeWallhaven) start(t *testing.T) string {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if f.rateLimited.Load() > 0 {
+			f.rateLimited.Add(-1)
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if r.Header.Get("X-API-Key") != f.apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		query := map[string]string{}
+		for k := range r.URL.Query() {
+			query[k] = r.URL.Query().Get(k)
+		}
+		f.searches = append(f.searches, query)
+
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		lastPage := (f.total + f.perPage - 1) / f.perPage
+		var data []map[string]any
+		for i := (page - 1) * f.perPage; i < min(page*f.perPage, f.total); i++ {
+			purity := []string{"sfw", "sketchy", "nsfw"}[i%3]
+			data = append(data, map[string]any{
+				"id":          fmt.Sprintf("id%d", i),
+				"url":         fmt.Sprintf("https://wallhaven.cc/w/id%d", i),
+				"purity":      purity,
+				"category":    "general",
+				"dimension_x": 3840,
+				"dimension_y": 2160,
+				"file_size":   1000 + i,
+				"created_at":  "2024-05-01 10:00:00",
+				"path":        fmt.Sprintf("https://w.wallhaven.cc/full/id/wallhaven-id%d.jpg", i),
+				"thumbs":      map[string]string{"large": fmt.Sprintf("https://th.wallhaven.cc/lg/id/id%d.jpg", i)},
+			})
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": data,
+			"meta": map[string]any{"current_page": page, "last_page": lastPage, "seed": "abc123"},
+		})
+	})
+	mux.HandleFunc("GET /api/v1/w/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.details.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]any{
+				"uploader": map[string]any{"username": "uploader"},
+				"tags":     []map[string]any{{"name": "mountain"}, {"name": "lake"}},
+			},
+		})
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestRun(t *testing.T) {
+	t.Run("pages until countback is reached", func(t *testing.T) {
+		api := &fakeWallhaven{total: 10, perPage: 4}
+		wa := &Wallhaven{Client: http.DefaultClient, baseURL: api.start(t)}
+
+		resp, err := wa.Run(context.Background(), source.Request{Parameter: "sorting=random", Countback: 6})
+		require.NoError(t, err)
+
+		require.Len(t, resp.Images, 6)
+		require.Len(t, api.searches, 2)
+		assert.Equal(t, map[string]string{"sorting": "random", "page": "1"}, api.searches[0])
+		assert.Equal(t, map[string]string{"sorting": "random", "page": "2", "seed": "abc123"}, api.searches[1])
+
+		first := resp.Images[0]
+		assert.Equal(t, "https://w.wallhaven.cc/full/id/wallhaven-id0.jpg", first.DownloadURL)
+		assert.Equal(t, "wallhaven-id0.jpg", first.Filename)
+		assert.Equal(t, "https://wallhaven.cc/w/id0", first.Website)
+		assert.Equal(t, "https://th.wallhaven.cc/lg/id/id0.jpg", first.ThumbnailURL)
+		assert.Equal(t, int64(3840), first.Width)
+		assert.Equal(t, int64(2160), first.Height)
+		assert.Equal(t, int64(1000), first.Filesize)
+		assert.Equal(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), first.PostedAt)
+		assert.Equal(t, []string{"general"}, first.Tags)
+		assert.Empty(t, first.Author, "uploader is only known when fetching tags")
+
+		assert.False(t, resp.Images[0].NSFW)
+		assert.True(t, resp.Images[1].NSFW, "sketchy")
+		assert.True(t, resp.Images[2].NSFW, "nsfw")
+		assert.Equal(t, []string{"general", "sketchy"}, resp.Images[1].Tags)
+	})
+
+	t.Run("stops at the last page", func(t *testing.T) {
+		api := &fakeWallhaven{total: 5, perPage: 4}
+		wa := &Wallhaven{Client: http.DefaultClient, baseURL: api.start(t)}
+
+		resp, err := wa.Run(context.Background(), source.Request{Parameter: "mountains", Countback: 100})
+		require.NoError(t, err)
+		assert.Len(t, resp.Images, 5)
+		assert.Len(t, api.searches, 2)
+	})
+
+	t.Run("fetches tags and sends API key", func(t *testing.T) {
+		api := &fakeWallhaven{total: 3, perPage: 24, apiKey: "secret"}
+		wa := &Wallhaven{
+			Client:  http.DefaultClient,
+			Config:  func() Config { return Config{APIKey: "secret", FetchTags: true} },
+			baseURL: api.start(t),
+		}
+
+		resp, err := wa.Run(context.Background(), source.Request{Parameter: "purity=111", Countback: 2})
+		require.NoError(t, err)
+		require.Len(t, resp.Images, 2)
+		assert.Equal(t, int32(2), api.details.Load())
+		assert.Equal(t, []string{"general", "mountain", "lake"}, resp.Images[0].Tags)
+		assert.Equal(t, "uploader", resp.Images[0].Author)
+		assert.Equal(t, wa.baseURL+"/user/uploader", resp.Images[0].AuthorURL)
+	})
+
+	t.Run("retries after 429", func(t *testing.T) {
+		api := &fakeWallhaven{total: 1, perPage: 24}
+		api.rateLimited.Store(2)
+		wa := &Wallhaven{Client: http.DefaultClient, baseURL: api.start(t), rateLimitPause: time.Millisecond}
+
+		resp, err := wa.Run(context.Background(), source.Request{Countback: 10})
+		require.NoError(t, err)
+		assert.Len(t, resp.Images, 1)
+	})
+}
+
+func TestValidateTransformParameter(t *testing.T) {
+	api := &fakeWallhaven{total: 1, perPage: 24, apiKey: "secret"}
+	baseURL := api.start(t)
+
+	wa := &Wallhaven{Client: http.DefaultClient, baseURL: baseURL}
+	got, err := wa.ValidateTransformParameter(context.Background(), "https://wallhaven.cc/hot?purity=110")
+	require.NoError(t, err)
+	assert.Equal(t, "purity=110&sorting=hot", got)
+	assert.Empty(t, api.searches, "SFW searches are not sent to Wallhaven")
+
+	_, err = wa.ValidateTransformParameter(context.Background(), "purity=001")
+	require.ErrorContains(t, err, "requires a Wallhaven API key")
+
+	wa.Config = func() Config { return Config{APIKey: "wrong"} }
+	_, err = wa.ValidateTransformParameter(context.Background(), "purity=001")
+	require.ErrorContains(t, err, "rejected the API key")
+
+	wa.Config = func() Config { return Config{APIKey: "secret"} }
+	got, err = wa.ValidateTransformParameter(context.Background(), "purity=001")
+	require.NoError(t, err)
+	assert.Equal(t, "purity=001", got)
+}
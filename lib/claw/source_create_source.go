@@ -12,6 +12,11 @@ import (
 
 // CreateSource creates a new source with optional schedules
 func (s *Claw) CreateSource(ctx context.Context, req *clawv1.CreateSourceRequest) (*clawv1.CreateSourceResponse, error) {
+	parameter, err := s.normalizeParameter(req.Name, req.Parameter)
+	if err != nil {
+		return nil, err
+	}
+
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to begin transaction: %w", err)
@@ -32,7 +37,7 @@ func (s *Claw) CreateSource(ctx context.Context, req *clawv1.CreateSourceRequest
 	).VALUES(
 		req.Name,
 		req.DisplayName,
-		req.Parameter,
+		parameter,
 		req.Countback,
 		types.Bool(req.IsDisabled),
 		nowMillis,
@@ -79,6 +84,7 @@ func (s *Claw) CreateSource(ctx context.Context, req *clawv1.CreateSourceRequest
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
+	s.RearmSchedules()
 
 	source := &clawv1.Source{
 		Name:        sourceRow.Name,
@@ -25,5 +25,9 @@ func (s *Claw) DeleteSource(ctx context.Context, req *clawv1.DeleteSourceRequest
 		return nil, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
+	if rowsAffected > 0 {
+		s.RearmSchedules()
+	}
+
 	return &clawv1.DeleteSourceResponse{Success: rowsAffected > 0}, nil
 }
@@ -24,6 +24,7 @@ func (claw *Claw) ListAvailableSources(ctx context.Context, req *clawv1.ListAvai
 			Description:               backend.Description(),
 			DefaultCountback:          int32(backend.DefaultCountback()),
 			HaveScheduleConflictCheck: backend.HaveScheduleConflictCheck(),
+			ParameterSchema:           string(parameterSchema(backend)),
 		}
 		availableSources = append(availableSources, availableSource)
 	}
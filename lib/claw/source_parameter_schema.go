@@ -0,0 +1,80 @@
+package claw
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+// parameterSchema returns the JSON Schema of the parameter of the backend, or nil if the backend
+// takes a plain string parameter.
+func parameterSchema(backend source.Source) json.RawMessage {
+	if schemaSource, ok := backend.(source.SourceParameterSchema); ok {
+		return schemaSource.ParameterSchema()
+	}
+	return nil
+}
+
+// normalizeParameter validates the parameter of a source whose backend has a parameter schema, and
+// returns it as compact JSON with sorted keys, so sources with the same options are found by
+// UNIQUE(name, parameter).
+//
+// Parameters of other sources, empty parameters, and parameters of unknown sources are returned unchanged.
+func (claw *Claw) normalizeParameter(sourceName, parameter string) (string, error) {
+	backend, ok := claw.scheduler.backends[sourceName]
+	if !ok || strings.TrimSpace(parameter) == "" {
+		return parameter, nil
+	}
+	schema := parameterSchema(backend)
+	if len(schema) == 0 {
+		return parameter, nil
+	}
+
+	compiled, err := compileParameterSchema(sourceName, schema)
+	if err != nil {
+		return "", err
+	}
+	value, err := jsonschema.UnmarshalJSON(strings.NewReader(parameter))
+	if err != nil {
+		return "", fmt.Errorf("parameter of %s must be a JSON object: %w", sourceName, err)
+	}
+	if _, ok := value.(map[string]any); !ok {
+		return "", fmt.Errorf("parameter of %s must be a JSON object", sourceName)
+	}
+	if err := compiled.Validate(value); err != nil {
+		var validationErr *jsonschema.ValidationError
+		if errors.As(err, &validationErr) {
+			// The first line only names the schema URL. The lines after it list what is wrong.
+			_, details, _ := strings.Cut(validationErr.Error(), "\n")
+			return "", fmt.Errorf("invalid parameter:\n%s", details)
+		}
+		return "", fmt.Errorf("invalid parameter: %w", err)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode parameter: %w", err)
+	}
+	return string(normalized), nil
+}
+
+func compileParameterSchema(sourceName string, schema json.RawMessage) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("source %s has an invalid parameter schema: %w", sourceName, err)
+	}
+	url := "https://claw.invalid/sources/" + sourceName + ".schema.json"
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, fmt.Errorf("source %s has an invalid parameter schema: %w", sourceName, err)
+	}
+	compiled, err := compiler.Compile(url)
+	if err != nil {
+		return nil, fmt.Errorf("source %s has an invalid parameter schema: %w", sourceName, err)
+	}
+	return compiled, nil
+}
@@ -0,0 +1,96 @@
+package claw
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+)
+
+type schemaSource struct {
+	source.UnimplementedSource
+}
+
+func (schemaSource) Name() string {
+	return "test.schema.v1"
+}
+
+func (schemaSource) ParameterSchema() json.RawMessage {
+	return json.RawMessage(`{
+		"type": "object",
+		"properties": {
+			"query": {"type": "string", "minLength": 1},
+			"sort": {"type": "string", "enum": ["new", "top"], "default": "new"},
+			"nsfw": {"type": "boolean"}
+		},
+		"required": ["query"],
+		"additionalProperties": false
+	}`)
+}
+
+func (schemaSource) ValidateTransformParameter(ctx context.Context, param string) (string, error) {
+	return param, nil
+}
+
+func TestValidateSourceParametersSchema(t *testing.T) {
+	claw := &Claw{
+		scheduler: &scheduler{
+			backends: map[string]source.Source{
+				"test.schema.v1": schemaSource{},
+			},
+		},
+	}
+
+	tests := []struct {
+		name          string
+		parameter     string
+		expected      string
+		expectedError string
+	}{
+		{
+			name:      "keys are sorted and whitespace removed",
+			parameter: `{ "sort": "top", "query": "cat", "nsfw": false }`,
+			expected:  `{"nsfw":false,"query":"cat","sort":"top"}`,
+		},
+		{
+			name:          "missing required property",
+			parameter:     `{"sort": "top"}`,
+			expectedError: "query",
+		},
+		{
+			name:          "value not in enum",
+			parameter:     `{"query": "cat", "sort": "old"}`,
+			expectedError: "sort",
+		},
+		{
+			name:          "not an object",
+			parameter:     `"cat"`,
+			expectedError: "must be a JSON object",
+		},
+		{
+			name:          "invalid JSON",
+			parameter:     `query=cat`,
+			expectedError: "must be a JSON object",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := claw.ValidateSourceParameters(context.Background(), &clawv1.ValidateSourceParametersRequest{
+				SourceName: "test.schema.v1",
+				Parameter:  tt.parameter,
+			})
+			if tt.expectedError != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resp.TransformedParameter)
+		})
+	}
+}
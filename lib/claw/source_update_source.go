@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
 	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
 	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
 	"github.com/tigorlazuardi/claw/lib/claw/types"
@@ -19,14 +20,30 @@ func (s *Claw) UpdateSource(ctx context.Context, req *clawv1.UpdateSourceRequest
 		SET(nowMillis).
 		WHERE(table.Sources.ID.EQ(sqlite.Int64(req.Id)))
 
+	// The cursor only makes sense for the source kind and parameter it was produced by,
+	// so it is reset when either changes.
+	var cursorValid []sqlite.BoolExpression
 	if req.Name != nil {
 		updateStmt = updateStmt.SET(table.Sources.Name.SET(sqlite.String(*req.Name)))
+		cursorValid = append(cursorValid, table.Sources.Name.EQ(sqlite.String(*req.Name)))
 	}
 	if req.DisplayName != nil {
 		updateStmt = updateStmt.SET(table.Sources.DisplayName.SET(sqlite.String(*req.DisplayName)))
 	}
 	if req.Parameter != nil {
-		updateStmt = updateStmt.SET(table.Sources.Parameter.SET(sqlite.String(*req.Parameter)))
+		parameter, err := s.normalizeUpdatedParameter(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		updateStmt = updateStmt.SET(table.Sources.Parameter.SET(sqlite.String(parameter)))
+		cursorValid = append(cursorValid, table.Sources.Parameter.EQ(sqlite.String(parameter)))
+	}
+	if len(cursorValid) > 0 {
+		updateStmt = updateStmt.SET(table.Sources.Cursor.SET(sqlite.StringExp(
+			sqlite.CASE().
+				WHEN(sqlite.AND(cursorValid...)).THEN(table.Sources.Cursor).
+				ELSE(sqlite.String("")),
+		)))
 	}
 	if req.Countback != nil {
 		updateStmt = updateStmt.SET(table.Sources.Countback.SET(sqlite.Int32(*req.Countback)))
@@ -54,6 +71,7 @@ func (s *Claw) UpdateSource(ctx context.Context, req *clawv1.UpdateSourceRequest
 	if rowsAffected == 0 {
 		return nil, fmt.Errorf("source not found")
 	}
+	s.RearmSchedules()
 
 	// Get updated source
 	getResp, err := s.GetSource(ctx, &clawv1.GetSourceRequest{Id: req.Id})
@@ -63,3 +81,19 @@ func (s *Claw) UpdateSource(ctx context.Context, req *clawv1.UpdateSourceRequest
 
 	return &clawv1.UpdateSourceResponse{Source: getResp.Source}, nil
 }
+
+// normalizeUpdatedParameter normalizes the new parameter of a source. See [Claw.normalizeParameter].
+func (s *Claw) normalizeUpdatedParameter(ctx context.Context, req *clawv1.UpdateSourceRequest) (string, error) {
+	if req.Name != nil {
+		return s.normalizeParameter(*req.Name, *req.Parameter)
+	}
+	var current model.Sources
+	err := table.Sources.
+		SELECT(table.Sources.Name).
+		WHERE(table.Sources.ID.EQ(sqlite.Int64(req.Id))).
+		QueryContext(ctx, s.db, &current)
+	if err != nil {
+		return "", fmt.Errorf("failed to get source: %w", err)
+	}
+	return s.normalizeParameter(current.Name, *req.Parameter)
+}
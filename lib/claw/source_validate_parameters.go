@@ -18,8 +18,14 @@ func (claw *Claw) ValidateSourceParameters(ctx context.Context, req *clawv1.Vali
 		return nil, fmt.Errorf("source '%s' not found or not registered", req.SourceName)
 	}
 
+	// Sources with a parameter schema get the parameter validated against it first.
+	parameter, err := claw.normalizeParameter(req.SourceName, req.Parameter)
+	if err != nil {
+		return nil, err
+	}
+
 	// Call the backend's ValidateTransformParameter method
-	transformedParam, err := backend.ValidateTransformParameter(ctx, req.Parameter)
+	transformedParam, err := backend.ValidateTransformParameter(ctx, parameter)
 	if err != nil {
 		return nil, err
 	}
@@ -66,12 +66,16 @@ func (sr *StallReader) Read(p []byte) (n int, err error) {
 		n, err := sr.source.Read(p)
 		readCh <- result{n: n, err: err}
 	}()
-	noDataSentTimer := time.NewTimer(sr.monitor.NoDataReceivedDuration)
+	var noDataSent <-chan time.Time
+	if sr.monitor.NoDataReceivedDuration > 0 {
+		noDataSentTimer := time.NewTimer(sr.monitor.NoDataReceivedDuration)
+		defer noDataSentTimer.Stop()
+		noDataSent = noDataSentTimer.C
+	}
 	select {
 	case <-sr.ctx.Done():
 		return 0, sr.ctx.Err()
-	case <-noDataSentTimer.C:
-		noDataSentTimer.Stop()
+	case <-noDataSent:
 		err := &StallError{Cause: fmt.Sprintf("no single bytes received for %s", sr.monitor.NoDataReceivedDuration)}
 		sr.stallError.Store(err)
 		return 0, err
@@ -86,7 +90,7 @@ func (sr *StallReader) Read(p []byte) (n int, err error) {
 	if elapsed <= 0 {
 		elapsed = time.Millisecond // Prevent division by zero
 	}
-	currentSpeed := sr.totalBytes / int64(elapsed/time.Second)
+	currentSpeed := int64(float64(sr.totalBytes) / elapsed.Seconds())
 	threshold := sr.monitor.Speed
 
 	// Check if speed is below threshold for the configured duration
@@ -0,0 +1,54 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// CreateTag creates a new tag
+func (s *Claw) CreateTag(ctx context.Context, req *clawv1.CreateTagRequest) (*clawv1.CreateTagResponse, error) {
+	name := normalizeTagName(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("tag name must not be blank")
+	}
+	if err := s.checkTagNameAvailable(ctx, name, 0); err != nil {
+		return nil, err
+	}
+
+	var created model.Tags
+	err := Tags.INSERT(Tags.Name, Tags.CreatedAt).
+		MODEL(model.Tags{Name: name, CreatedAt: types.UnixMilliNow()}).
+		RETURNING(Tags.AllColumns).
+		QueryContext(ctx, s.db, &created)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tag: %w", err)
+	}
+
+	return &clawv1.CreateTagResponse{
+		Tag: tagModelToProto(created),
+	}, nil
+}
+
+// checkTagNameAvailable returns an error if a tag other than exceptID already has the name.
+func (s *Claw) checkTagNameAvailable(ctx context.Context, name string, exceptID int64) error {
+	var existing model.Tags
+	err := SELECT(Tags.AllColumns).
+		FROM(Tags).
+		WHERE(Tags.Name.EQ(String(name)).AND(Tags.ID.NOT_EQ(Int64(exceptID)))).
+		QueryContext(ctx, s.db, &existing)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check existing tag: %w", err)
+	}
+	return fmt.Errorf("tag %q already exists", name)
+}
@@ -0,0 +1,58 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// DeleteTags deletes tags by their IDs and removes them from all images
+func (s *Claw) DeleteTags(ctx context.Context, req *clawv1.DeleteTagsRequest) (*clawv1.DeleteTagsResponse, error) {
+	if len(req.Ids) == 0 {
+		return &clawv1.DeleteTagsResponse{
+			DeletedCount: 0,
+		}, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var idExprs []Expression
+	for _, id := range req.Ids {
+		idExprs = append(idExprs, Int64(id))
+	}
+
+	// Remove tags from images
+	_, err = ImageTags.DELETE().
+		WHERE(ImageTags.TagID.IN(idExprs...)).
+		ExecContext(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete image tags: %w", err)
+	}
+
+	result, err := Tags.DELETE().
+		WHERE(Tags.ID.IN(idExprs...)).
+		ExecContext(ctx, tx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete tags: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return &clawv1.DeleteTagsResponse{
+		DeletedCount: int32(rowsAffected),
+	}, nil
+}
@@ -0,0 +1,37 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// GetPopularTags returns the most used tags. Tags without any image are not included.
+func (s *Claw) GetPopularTags(ctx context.Context, req *clawv1.GetPopularTagsRequest) (*clawv1.GetPopularTagsResponse, error) {
+	limit := int64(20)
+	if req.Limit != nil {
+		limit = Clamp(int64(req.GetLimit()), 1, 100)
+	}
+
+	var out []tagWithUsage
+	err := SELECT(Tags.AllColumns, tagUsageCountProjection).
+		FROM(Tags.INNER_JOIN(ImageTags, ImageTags.TagID.EQ(Tags.ID))).
+		GROUP_BY(Tags.ID).
+		ORDER_BY(tagUsageCount.DESC(), Tags.Name.ASC()).
+		LIMIT(limit).
+		QueryContext(ctx, s.db, &out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get popular tags: %w", err)
+	}
+
+	tags := make([]*clawv1.Tag, len(out))
+	for i, tag := range out {
+		tags[i] = tag.toProto()
+	}
+	return &clawv1.GetPopularTagsResponse{
+		Tags: tags,
+	}, nil
+}
@@ -0,0 +1,32 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// GetTag retrieves a tag by ID with its usage count
+func (s *Claw) GetTag(ctx context.Context, req *clawv1.GetTagRequest) (*clawv1.GetTagResponse, error) {
+	var tag tagWithUsage
+	err := SELECT(Tags.AllColumns, tagUsageCountProjection).
+		FROM(tagsWithUsage).
+		WHERE(Tags.ID.EQ(Int64(req.Id))).
+		GROUP_BY(Tags.ID).
+		QueryContext(ctx, s.db, &tag)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("tag %d not found", req.Id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tag: %w", err)
+	}
+
+	return &clawv1.GetTagResponse{
+		Tag: tag.toProto(),
+	}, nil
+}
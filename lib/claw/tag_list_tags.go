@@ -0,0 +1,83 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// ListTags lists tags with their usage counts, optional search, and pagination
+func (s *Claw) ListTags(ctx context.Context, req *clawv1.ListTagsRequest) (*clawv1.ListTagsResponse, error) {
+	cond := Bool(true)
+	if search := normalizeTagName(req.GetSearch()); search != "" {
+		cond = cond.AND(containsLike(Tags.Name, search))
+	}
+
+	limit := int64(50)
+	if req.PageSize != nil {
+		limit = Clamp(int64(req.GetPageSize()), 1, 200)
+	}
+	offset := int64(req.GetPageToken())
+
+	var sorts []OrderByClause
+	switch req.GetSortOrder() {
+	case clawv1.TagSortOrder_TAG_SORT_ORDER_MOST_USED:
+		sorts = []OrderByClause{tagUsageCount.DESC(), Tags.Name.ASC()}
+	case clawv1.TagSortOrder_TAG_SORT_ORDER_NEWEST_FIRST:
+		sorts = []OrderByClause{Tags.CreatedAt.DESC(), Tags.ID.DESC()}
+	default:
+		sorts = []OrderByClause{Tags.Name.ASC()}
+	}
+
+	var out []tagWithUsage
+	err := SELECT(Tags.AllColumns, tagUsageCountProjection).
+		FROM(tagsWithUsage).
+		WHERE(cond).
+		GROUP_BY(Tags.ID).
+		ORDER_BY(sorts...).
+		LIMIT(limit).
+		OFFSET(offset).
+		QueryContext(ctx, s.db, &out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+
+	var total struct {
+		Count int64
+	}
+	err = SELECT(COUNT(Tags.ID).AS("count")).
+		FROM(Tags).
+		WHERE(cond).
+		QueryContext(ctx, s.db, &total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count tags: %w", err)
+	}
+
+	tags := make([]*clawv1.Tag, len(out))
+	for i, tag := range out {
+		tags[i] = tag.toProto()
+	}
+	var nextPageToken *uint32
+	if int64(len(out)) >= limit && offset+limit < total.Count {
+		nextPageToken = Ptr(uint32(offset + limit))
+	}
+
+	return &clawv1.ListTagsResponse{
+		Tags:          tags,
+		NextPageToken: nextPageToken,
+		TotalCount:    &total.Count,
+	}, nil
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, with backslash as escape character.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// containsLike matches column values containing search literally, so "%" and "_" in search are not wildcards.
+func containsLike(column StringExpression, search string) BoolExpression {
+	pattern := "%" + likeEscaper.Replace(search) + "%"
+	return BoolExp(CustomExpression(column, Token("LIKE"), String(pattern), Token(`ESCAPE '\'`)))
+}
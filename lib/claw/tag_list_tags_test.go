@@ -0,0 +1,42 @@
+package claw
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+func TestListTags_Search(t *testing.T) {
+	cl := newTestClaw(t)
+	ctx := context.Background()
+	image := insertTestImage(t, cl, "https://example.com/image.jpg")
+	_, err := cl.AssignTags(ctx, &clawv1.AssignTagsRequest{
+		ImageIds: []int64{*image.ID},
+		Tags:     []string{"100% cotton", "1000 cotton", "snake_case", "snakescase", `back\slash`, "backslash"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		search string
+		want   []string
+	}{
+		{"cotton", []string{"100% cotton", "1000 cotton"}},
+		{"100%", []string{"100% cotton"}},
+		{"e_c", []string{"snake_case"}},
+		{`k\s`, []string{`back\slash`}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.search, func(t *testing.T) {
+			resp, err := cl.ListTags(ctx, &clawv1.ListTagsRequest{Search: &tt.search})
+			require.NoError(t, err)
+			names := []string{}
+			for _, tag := range resp.Tags {
+				names = append(names, tag.Name)
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
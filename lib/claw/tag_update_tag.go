@@ -0,0 +1,41 @@
+package claw
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+)
+
+// UpdateTag renames an existing tag
+func (s *Claw) UpdateTag(ctx context.Context, req *clawv1.UpdateTagRequest) (*clawv1.UpdateTagResponse, error) {
+	name := normalizeTagName(req.Name)
+	if name == "" {
+		return nil, fmt.Errorf("tag name must not be blank")
+	}
+	if err := s.checkTagNameAvailable(ctx, name, req.Id); err != nil {
+		return nil, err
+	}
+
+	var updated model.Tags
+	err := Tags.UPDATE(Tags.Name).
+		SET(String(name)).
+		WHERE(Tags.ID.EQ(Int64(req.Id))).
+		RETURNING(Tags.AllColumns).
+		QueryContext(ctx, s.db, &updated)
+	if errors.Is(err, qrm.ErrNoRows) {
+		return nil, fmt.Errorf("tag %d not found", req.Id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update tag: %w", err)
+	}
+
+	return &clawv1.UpdateTagResponse{
+		Tag: tagModelToProto(updated),
+	}, nil
+}
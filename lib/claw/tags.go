@@ -0,0 +1,99 @@
+package claw
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-jet/jet/v2/qrm"
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+)
+
+// tagWithUsage is a tag row with the number of images using it.
+type tagWithUsage struct {
+	model.Tags
+	UsageCount int64
+}
+
+func (tag tagWithUsage) toProto() *clawv1.Tag {
+	out := tagModelToProto(tag.Tags)
+	out.UsageCount = tag.UsageCount
+	return out
+}
+
+// tagUsageCount counts images per tag. Use together with tagsWithUsage.
+var tagUsageCount = COUNT(ImageTags.ImageID)
+
+// tagUsageCountProjection scans tagUsageCount into tagWithUsage.UsageCount.
+var tagUsageCountProjection = tagUsageCount.AS("tag_with_usage.usage_count")
+
+// tagsWithUsage is tags joined with image tags, to be grouped by tag ID.
+var tagsWithUsage = Tags.LEFT_JOIN(ImageTags, ImageTags.TagID.EQ(Tags.ID))
+
+// normalizeTagName trims the name, lowercases it, and collapses whitespace,
+// so "Nature ", "nature", and "NATURE" are the same tag.
+func normalizeTagName(name string) string {
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+// normalizeTagNames normalizes the names, dropping empty and duplicate names.
+func normalizeTagNames(names []string) []string {
+	out := make([]string, 0, len(names))
+	for _, name := range names {
+		name = normalizeTagName(name)
+		if name != "" && !slices.Contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// ensureTags creates the tags that do not exist yet and returns all of them.
+//
+// names must be normalized.
+func ensureTags(ctx context.Context, db qrm.DB, names []string) ([]model.Tags, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	now := types.UnixMilliNow()
+	rows := make([]model.Tags, len(names))
+	for i, name := range names {
+		rows[i] = model.Tags{Name: name, CreatedAt: now}
+	}
+	_, err := Tags.INSERT(Tags.Name, Tags.CreatedAt).
+		MODELS(rows).
+		ON_CONFLICT(Tags.Name).DO_NOTHING().
+		ExecContext(ctx, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tags: %w", err)
+	}
+
+	var tags []model.Tags
+	err = SELECT(Tags.AllColumns).
+		FROM(Tags).
+		WHERE(Tags.Name.IN(jetStringsExpr(names...)...)).
+		QueryContext(ctx, db, &tags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+	return tags, nil
+}
+
+// tagImageIDs returns a subquery selecting IDs of images tagged with the given names.
+//
+// With matchAll, only images having every one of the tags are selected. names must be normalized and unique.
+func tagImageIDs(names []string, matchAll bool) SelectStatement {
+	stmt := SELECT(ImageTags.ImageID).
+		FROM(ImageTags.INNER_JOIN(Tags, Tags.ID.EQ(ImageTags.TagID))).
+		WHERE(Tags.Name.IN(jetStringsExpr(names...)...))
+	if matchAll {
+		stmt = stmt.GROUP_BY(ImageTags.ImageID).
+			HAVING(COUNT(ImageTags.TagID).EQ(Int(int64(len(names)))))
+	}
+	return stmt
+}
@@ -0,0 +1,368 @@
+package claw
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	. "github.com/go-jet/jet/v2/sqlite"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	clawv1 "github.com/tigorlazuardi/claw/lib/claw/gen/proto/claw/v1"
+	"github.com/tigorlazuardi/claw/lib/claw/source"
+	"github.com/tigorlazuardi/claw/lib/otel"
+)
+
+// WebhookEvent is the kind of event sent to webhooks.
+type WebhookEvent string
+
+const (
+	// WebhookEventJobStart is sent when a job starts running.
+	WebhookEventJobStart WebhookEvent = "job_start"
+	// WebhookEventJobFinished is sent when a job is completed, failed, or cancelled.
+	WebhookEventJobFinished WebhookEvent = "job_finished"
+	// WebhookEventImageDownloaded is sent when an image is downloaded and saved.
+	WebhookEventImageDownloaded WebhookEvent = "image_downloaded"
+	// WebhookEventImageFailed is sent when an image failed to download or be saved.
+	WebhookEventImageFailed WebhookEvent = "image_failed"
+	// WebhookEventImageAssigned is sent when an image is assigned to a device.
+	WebhookEventImageAssigned WebhookEvent = "image_assigned"
+)
+
+// WebhookPayload is the JSON body sent to webhooks.
+//
+// Fields that are irrelevant to the event are omitted. Example of an "image_assigned" payload:
+//
+//	{
+//	  "event": "image_assigned",
+//	  "timestamp": "2025-07-20T10:00:00Z",
+//	  "job": {"id": 12},
+//	  "source": {"id": 1, "name": "claw.reddit.v1", "display_name": "Wallpapers", "parameter": "r/wallpapers"},
+//	  "image": {"id": 40, "download_url": "https://i.redd.it/abc.jpg", "width": 3840, "height": 2160, ...},
+//	  "device": {"id": 2, "slug": "desktop", "name": "Desktop", "path": "devices/desktop/abc.jpg"}
+//	}
+//
+// The event name is also sent in the "X-Claw-Event" header.
+type WebhookPayload struct {
+	// Event is the event name.
+	Event WebhookEvent `json:"event"`
+	// Timestamp is when the event happened.
+	Timestamp time.Time `json:"timestamp"`
+	// Job is the job that triggered the event.
+	Job *WebhookJob `json:"job,omitempty"`
+	// Source is the source the job runs for.
+	Source *WebhookSource `json:"source,omitempty"`
+	// Image is set on image events.
+	Image *WebhookImage `json:"image,omitempty"`
+	// Device is set on "image_assigned" events.
+	Device *WebhookDevice `json:"device,omitempty"`
+	// Error is set on "image_failed" events, and on "job_finished" events of failed jobs.
+	Error string `json:"error,omitempty"`
+}
+
+// WebhookJob is the job information in webhook payloads.
+type WebhookJob struct {
+	ID         int64      `json:"id"`
+	ScheduleID int64      `json:"schedule_id,omitempty"`
+	Status     string     `json:"status,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+	RunAt      *time.Time `json:"run_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// WebhookSource is the source information in webhook payloads.
+type WebhookSource struct {
+	ID          int64  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Parameter   string `json:"parameter"`
+}
+
+// WebhookImage is the image information in webhook payloads.
+type WebhookImage struct {
+	// ID is the image ID in the database. Omitted if the image was never saved.
+	ID          int64      `json:"id,omitempty"`
+	DownloadURL string     `json:"download_url"`
+	Width       int64      `json:"width"`
+	Height      int64      `json:"height"`
+	Filesize    int64      `json:"filesize"`
+	Filename    string     `json:"filename,omitempty"`
+	Title       string     `json:"title,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	AuthorURL   string     `json:"author_url,omitempty"`
+	Website     string     `json:"website,omitempty"`
+	NSFW        bool       `json:"nsfw"`
+	PostedAt    *time.Time `json:"posted_at,omitempty"`
+	// Path is the image location relative to the download base directory.
+	Path string `json:"path,omitempty"`
+}
+
+// WebhookDevice is the device information in webhook payloads.
+type WebhookDevice struct {
+	ID   int64  `json:"id"`
+	Slug string `json:"slug"`
+	Name string `json:"name"`
+	// Path is the image location for this device, relative to the download base directory.
+	Path string `json:"path,omitempty"`
+}
+
+func webhookJobFromModel(job model.Jobs) *WebhookJob {
+	out := &WebhookJob{
+		ID:         Deref(job.ID),
+		ScheduleID: Deref(job.ScheduleID),
+		Status:     job.Status,
+		Error:      Deref(job.Error),
+		CreatedAt:  &job.CreatedAt.Time,
+	}
+	if job.RunAt != nil {
+		out.RunAt = &job.RunAt.Time
+	}
+	if job.FinishedAt != nil {
+		out.FinishedAt = &job.FinishedAt.Time
+	}
+	return out
+}
+
+func webhookSourceFromModel(src model.Sources) *WebhookSource {
+	return &WebhookSource{
+		ID:          Deref(src.ID),
+		Name:        src.Name,
+		DisplayName: src.DisplayName,
+		Parameter:   src.Parameter,
+	}
+}
+
+func webhookImageFromSource(image source.Image) *WebhookImage {
+	out := &WebhookImage{
+		DownloadURL: image.DownloadURL,
+		Width:       image.Width,
+		Height:      image.Height,
+		Filesize:    image.Filesize,
+		Filename:    image.Filename,
+		Title:       image.Title,
+		Tags:        image.Tags,
+		Author:      image.Author,
+		AuthorURL:   image.AuthorURL,
+		Website:     image.Website,
+		NSFW:        image.NSFW,
+	}
+	if !image.PostedAt.IsZero() {
+		out.PostedAt = &image.PostedAt
+	}
+	return out
+}
+
+type webhookDelivery struct {
+	hook    config.Webhook
+	event   WebhookEvent
+	payload []byte
+}
+
+// webhookDispatcher delivers webhook payloads in the background.
+//
+// Deliveries are put in a bounded queue. When the queue is full, new deliveries are dropped
+// instead of blocking the caller, so slow receivers cannot stall jobs and downloads.
+type webhookDispatcher struct {
+	config *config.Config
+	client Doer
+	logger *slog.Logger
+	queue  chan webhookDelivery
+}
+
+func newWebhookDispatcher(cfg *config.Config, client Doer, logger *slog.Logger) *webhookDispatcher {
+	size := cfg.Webhooks.QueueSize
+	if size <= 0 {
+		size = config.DefaultWebhooks().QueueSize
+	}
+	return &webhookDispatcher{
+		config: cfg,
+		client: client,
+		logger: logger,
+		queue:  make(chan webhookDelivery, size),
+	}
+}
+
+// hooks returns the configured webhooks for the event.
+func (dispatcher *webhookDispatcher) hooks(event WebhookEvent) []config.Webhook {
+	hooks := dispatcher.config.Webhooks
+	switch event {
+	case WebhookEventJobStart:
+		return hooks.JobStart
+	case WebhookEventJobFinished:
+		return hooks.JobFinished
+	case WebhookEventImageDownloaded:
+		return hooks.ImageDownloadSuccess
+	case WebhookEventImageFailed:
+		return hooks.ImageDownloadFailed
+	case WebhookEventImageAssigned:
+		return hooks.ImageAssigned
+	}
+	return nil
+}
+
+// Enabled reports whether any webhook is configured for the event.
+//
+// Callers can use this to skip building expensive payloads.
+func (dispatcher *webhookDispatcher) Enabled(event WebhookEvent) bool {
+	return dispatcher != nil && len(dispatcher.hooks(event)) > 0
+}
+
+// Dispatch queues the payload for every webhook configured for the payload's event.
+//
+// It never blocks. If the queue is full, the delivery is dropped and logged.
+func (dispatcher *webhookDispatcher) Dispatch(ctx context.Context, payload WebhookPayload) {
+	if !dispatcher.Enabled(payload.Event) {
+		return
+	}
+	if payload.Timestamp.IsZero() {
+		payload.Timestamp = time.Now()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		dispatcher.logger.ErrorContext(ctx, "failed to marshal webhook payload", "event", payload.Event, "error", err)
+		return
+	}
+	for _, hook := range dispatcher.hooks(payload.Event) {
+		if err := hook.ValidateAndNormalize(); err != nil {
+			dispatcher.logger.WarnContext(ctx, "skipping invalid webhook", "event", payload.Event, "error", err)
+			continue
+		}
+		select {
+		case dispatcher.queue <- webhookDelivery{hook: hook, event: payload.Event, payload: body}:
+		default:
+			dispatcher.logger.WarnContext(ctx, "webhook queue is full, dropping delivery", "event", payload.Event, "url", hook.URL)
+		}
+	}
+}
+
+// Run consumes the delivery queue until the context is cancelled.
+func (dispatcher *webhookDispatcher) Run(ctx context.Context) {
+	workers := Clamp(dispatcher.config.Webhooks.Workers, 1, 16)
+	done := make(chan struct{}, workers)
+	for range workers {
+		go func() {
+			defer func() { done <- struct{}{} }()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case delivery := <-dispatcher.queue:
+					if err := dispatcher.deliver(ctx, delivery); err != nil {
+						dispatcher.logger.ErrorContext(ctx, "failed to deliver webhook", "event", delivery.event, "url", delivery.hook.URL, "error", err)
+					}
+				}
+			}
+		}()
+	}
+	for range workers {
+		<-done
+	}
+	dispatcher.logger.DebugContext(ctx, "webhook dispatcher stopped")
+}
+
+// deliver sends the delivery, retrying with exponential backoff on network errors,
+// timeouts, 408, 429 and 5xx responses.
+func (dispatcher *webhookDispatcher) deliver(ctx context.Context, delivery webhookDelivery) error {
+	backoff := dispatcher.config.Webhooks.RetryBackoff
+	if backoff <= 0 {
+		backoff = config.DefaultWebhooks().RetryBackoff
+	}
+	maxBackoff := dispatcher.config.Webhooks.MaxRetryBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = config.DefaultWebhooks().MaxRetryBackoff
+	}
+	var err error
+	for attempt := 1; attempt <= delivery.hook.MaxAttempts; attempt++ {
+		var retryable bool
+		retryable, err = dispatcher.send(ctx, delivery)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt == delivery.hook.MaxAttempts {
+			break
+		}
+		dispatcher.logger.WarnContext(ctx, "webhook delivery failed, retrying",
+			"event", delivery.event, "url", delivery.hook.URL, "attempt", attempt, "backoff", backoff, "error", err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+	return err
+}
+
+// send performs a single delivery attempt and reports whether a failure is worth retrying.
+func (dispatcher *webhookDispatcher) send(ctx context.Context, delivery webhookDelivery) (retryable bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, delivery.hook.Timeout)
+	defer cancel()
+
+	var body io.Reader
+	if delivery.hook.Method != http.MethodGet && delivery.hook.Method != http.MethodHead {
+		body = bytes.NewReader(delivery.payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, strings.ToUpper(delivery.hook.Method), delivery.hook.URL, body)
+	if err != nil {
+		return false, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "claw/1.0")
+	req.Header.Set("X-Claw-Event", string(delivery.event))
+	delivery.hook.Headers.Apply(req.Header)
+
+	resp, err := dispatcher.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send webhook request: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable = resp.StatusCode == http.StatusRequestTimeout ||
+		resp.StatusCode == http.StatusTooManyRequests ||
+		resp.StatusCode >= 500
+	return retryable, fmt.Errorf("webhook responded with status: %d", resp.StatusCode)
+}
+
+// notifyJobStatus sends "job_start" or "job_finished" webhooks based on the job's state.
+func (scheduler *scheduler) notifyJobStatus(ctx context.Context, job model.Jobs) {
+	event := WebhookEventJobStart
+	if job.FinishedAt != nil {
+		event = WebhookEventJobFinished
+	} else if job.Status != clawv1.JobStatus_JOB_STATUS_RUNNING.String() {
+		return
+	}
+	if !scheduler.webhooks.Enabled(event) {
+		return
+	}
+	var src model.Sources
+	ctx = otel.ContextWithDatabaseCaller(ctx, otel.CurrentCaller())
+	err := SELECT(Sources.AllColumns).
+		FROM(Sources).
+		WHERE(Sources.ID.EQ(Int64(job.SourceID))).
+		QueryContext(ctx, scheduler.claw.db, &src)
+	if err != nil {
+		scheduler.logger.ErrorContext(ctx, "failed to get source for webhook", "job_id", Deref(job.ID), "error", err)
+		return
+	}
+	scheduler.webhooks.Dispatch(ctx, WebhookPayload{
+		Event:  event,
+		Job:    webhookJobFromModel(job),
+		Source: webhookSourceFromModel(src),
+		Error:  Deref(job.Error),
+	})
+}
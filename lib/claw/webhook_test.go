@@ -0,0 +1,153 @@
+package claw
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+)
+
+func newTestWebhookConfig(hook config.Webhook) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Webhooks.RetryBackoff = 10 * time.Millisecond
+	cfg.Webhooks.MaxRetryBackoff = 20 * time.Millisecond
+	cfg.Webhooks.ImageAssigned = []config.Webhook{hook}
+	return cfg
+}
+
+func TestWebhookDispatcher(t *testing.T) {
+	t.Run("delivers payload with method and headers", func(t *testing.T) {
+		received := make(chan *http.Request, 1)
+		bodies := make(chan []byte, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			received <- r
+			bodies <- body
+		}))
+		defer server.Close()
+
+		cfg := newTestWebhookConfig(config.Webhook{
+			URL:     server.URL,
+			Method:  http.MethodPut,
+			Headers: config.Headers{"Authorization": "Bearer secret"},
+		})
+		dispatcher := newWebhookDispatcher(cfg, server.Client(), slog.New(slog.DiscardHandler))
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go dispatcher.Run(ctx)
+
+		dispatcher.Dispatch(ctx, WebhookPayload{
+			Event:  WebhookEventImageAssigned,
+			Job:    &WebhookJob{ID: 12},
+			Image:  &WebhookImage{ID: 40, DownloadURL: "https://example.com/a.jpg", Width: 1920, Height: 1080},
+			Device: &WebhookDevice{ID: 2, Slug: "desktop", Path: "devices/desktop/a.jpg"},
+		})
+
+		select {
+		case r := <-received:
+			assert.Equal(t, http.MethodPut, r.Method)
+			assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+			assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+			assert.Equal(t, string(WebhookEventImageAssigned), r.Header.Get("X-Claw-Event"))
+		case <-time.After(2 * time.Second):
+			t.Fatal("webhook was not delivered")
+		}
+		var payload WebhookPayload
+		require.NoError(t, json.Unmarshal(<-bodies, &payload))
+		assert.Equal(t, WebhookEventImageAssigned, payload.Event)
+		assert.Equal(t, int64(12), payload.Job.ID)
+		assert.Equal(t, "devices/desktop/a.jpg", payload.Device.Path)
+		assert.False(t, payload.Timestamp.IsZero())
+	})
+
+	t.Run("retries on server errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if calls.Add(1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		hook := config.Webhook{URL: server.URL, MaxAttempts: 3}
+		require.NoError(t, hook.ValidateAndNormalize())
+		dispatcher := newWebhookDispatcher(newTestWebhookConfig(hook), server.Client(), slog.New(slog.DiscardHandler))
+		err := dispatcher.deliver(context.Background(), webhookDelivery{hook: hook, event: WebhookEventImageAssigned, payload: []byte(`{}`)})
+		assert.NoError(t, err)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("does not retry on client errors", func(t *testing.T) {
+		var calls atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls.Add(1)
+			w.WriteHeader(http.StatusBadRequest)
+		}))
+		defer server.Close()
+
+		hook := config.Webhook{URL: server.URL, MaxAttempts: 3}
+		require.NoError(t, hook.ValidateAndNormalize())
+		dispatcher := newWebhookDispatcher(newTestWebhookConfig(hook), server.Client(), slog.New(slog.DiscardHandler))
+		err := dispatcher.deliver(context.Background(), webhookDelivery{hook: hook, event: WebhookEventImageAssigned, payload: []byte(`{}`)})
+		assert.Error(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("times out slow receivers", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		hook := config.Webhook{URL: server.URL, MaxAttempts: 2, Timeout: 50 * time.Millisecond}
+		require.NoError(t, hook.ValidateAndNormalize())
+		dispatcher := newWebhookDispatcher(newTestWebhookConfig(hook), server.Client(), slog.New(slog.DiscardHandler))
+		start := time.Now()
+		err := dispatcher.deliver(context.Background(), webhookDelivery{hook: hook, event: WebhookEventImageAssigned, payload: []byte(`{}`)})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("drops deliveries when queue is full", func(t *testing.T) {
+		cfg := newTestWebhookConfig(config.Webhook{URL: "http://127.0.0.1:0"})
+		cfg.Webhooks.QueueSize = 1
+		dispatcher := newWebhookDispatcher(cfg, http.DefaultClient, slog.New(slog.DiscardHandler))
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for range 5 {
+				dispatcher.Dispatch(context.Background(), WebhookPayload{Event: WebhookEventImageAssigned})
+			}
+		}()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("dispatch blocked on full queue")
+		}
+		assert.Len(t, dispatcher.queue, 1)
+	})
+
+	t.Run("skips events without hooks", func(t *testing.T) {
+		dispatcher := newWebhookDispatcher(config.DefaultConfig(), http.DefaultClient, slog.New(slog.DiscardHandler))
+		dispatcher.Dispatch(context.Background(), WebhookPayload{Event: WebhookEventJobStart})
+		assert.Empty(t, dispatcher.queue)
+		assert.False(t, dispatcher.Enabled(WebhookEventJobStart))
+	})
+}
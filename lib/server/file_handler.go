@@ -0,0 +1,90 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/tigorlazuardi/claw/lib/claw"
+)
+
+// FileHandler serves downloaded images, thumbnails, and device files over plain HTTP.
+//
+// Responses are served with http.ServeContent, so Range, If-Modified-Since, and If-None-Match
+// requests are supported out of the box.
+type FileHandler struct {
+	service *claw.Claw
+	logger  *slog.Logger
+}
+
+// NewFileHandler creates a new FileHandler
+func NewFileHandler(service *claw.Claw, logger *slog.Logger) *FileHandler {
+	return &FileHandler{service: service, logger: logger}
+}
+
+// Register registers the file routes to the given mux.
+//
+//   - GET /images/{id}/raw serves the downloaded image.
+//   - GET /images/{id}/thumbnail serves the thumbnail of the image, or the image itself if it has no thumbnail.
+//   - GET /devices/{slug}/{path...} serves an image assigned to the device.
+func (h *FileHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /images/{id}/raw", h.ServeImage)
+	mux.HandleFunc("GET /images/{id}/thumbnail", h.ServeThumbnail)
+	mux.HandleFunc("GET /devices/{slug}/{path...}", h.ServeDeviceFile)
+}
+
+// ServeImage serves the downloaded file of the image.
+func (h *FileHandler) ServeImage(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid image id", http.StatusBadRequest)
+		return
+	}
+	f, err := h.service.OpenImageFile(r.Context(), id)
+	h.serveFile(w, r, f, err)
+}
+
+// ServeThumbnail serves the thumbnail of the image.
+func (h *FileHandler) ServeThumbnail(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid image id", http.StatusBadRequest)
+		return
+	}
+	f, err := h.service.OpenThumbnailFile(r.Context(), id)
+	h.serveFile(w, r, f, err)
+}
+
+// ServeDeviceFile serves an image assigned to a device.
+func (h *FileHandler) ServeDeviceFile(w http.ResponseWriter, r *http.Request) {
+	f, err := h.service.OpenDeviceFile(r.Context(), r.PathValue("slug"), r.PathValue("path"))
+	h.serveFile(w, r, f, err)
+}
+
+func (h *FileHandler) serveFile(w http.ResponseWriter, r *http.Request, f *os.File, err error) {
+	if errors.Is(err, claw.ErrFileNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to open file", "path", r.URL.Path, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "failed to stat file", "path", r.URL.Path, "error", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	// ServeContent handles If-None-Match when the ETag header is set before calling it.
+	w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime().UnixNano(), info.Size()))
+	w.Header().Set("Cache-Control", "no-cache")
+	// ServeContent detects Content-Type from the file extension, then by sniffing the content.
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
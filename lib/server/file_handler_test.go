@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/pressly/goose/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tigorlazuardi/claw/lib/claw"
+	"github.com/tigorlazuardi/claw/lib/claw/config"
+	"github.com/tigorlazuardi/claw/lib/claw/gen/jet/model"
+	. "github.com/tigorlazuardi/claw/lib/claw/gen/jet/table"
+	"github.com/tigorlazuardi/claw/lib/claw/types"
+	"github.com/tigorlazuardi/claw/migrations"
+	_ "modernc.org/sqlite"
+)
+
+// newTestFileServer serves the files of an image with the file "images/lake.jpg", assigned to the device
+// "phone" as "devices/phone/2025/lake.jpg". It returns the server and the ID of the image.
+func newTestFileServer(t *testing.T) (*httptest.Server, int64) {
+	t.Helper()
+	ctx := context.Background()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "claw.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	goose.SetLogger(goose.NopLogger())
+	require.NoError(t, migrations.Migrate(ctx, db))
+
+	cfg := config.DefaultConfig()
+	cfg.Download.BaseDir = t.TempDir()
+	for _, file := range []string{"images/lake.jpg", "devices/phone/2025/lake.jpg"} {
+		name := filepath.Join(cfg.Download.BaseDir, filepath.FromSlash(file))
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0o755))
+		require.NoError(t, os.WriteFile(name, []byte("lake image"), 0o644))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(filepath.Dir(cfg.Download.BaseDir), "secret.txt"), []byte("secret"), 0o644))
+
+	var src model.Sources
+	err = Sources.INSERT(Sources.Name, Sources.DisplayName, Sources.Parameter).
+		VALUES("claw.script.v1", "Script", "images.js").
+		RETURNING(Sources.AllColumns).
+		QueryContext(ctx, db, &src)
+	require.NoError(t, err)
+	var image model.Images
+	err = Images.INSERT(Images.SourceID, Images.DownloadURL, Images.Width, Images.Height, Images.Filesize,
+		Images.ThumbnailPath, Images.ImagePath, Images.CreatedAt, Images.UpdatedAt).
+		MODEL(model.Images{
+			SourceID:    *src.ID,
+			DownloadURL: "https://example.com/lake.jpg",
+			ImagePath:   "images/lake.jpg",
+			CreatedAt:   types.UnixMilliNow(),
+			UpdatedAt:   types.UnixMilliNow(),
+		}).
+		RETURNING(Images.AllColumns).
+		QueryContext(ctx, db, &image)
+	require.NoError(t, err)
+	var device model.Devices
+	err = Devices.INSERT(Devices.Slug, Devices.Name, Devices.Width, Devices.Height, Devices.CreatedAt, Devices.UpdatedAt).
+		MODEL(model.Devices{Slug: "phone", Name: "Phone", Width: 1080, Height: 1920, CreatedAt: types.UnixMilliNow(), UpdatedAt: types.UnixMilliNow()}).
+		RETURNING(Devices.AllColumns).
+		QueryContext(ctx, db, &device)
+	require.NoError(t, err)
+	_, err = ImageDevices.INSERT(ImageDevices.AllColumns).
+		MODEL(model.ImageDevices{ImageID: *image.ID, DeviceID: *device.ID, Path: "devices/phone/2025/lake.jpg", CreatedAt: types.UnixMilliNow()}).
+		ExecContext(ctx, db)
+	require.NoError(t, err)
+
+	logger := slog.New(slog.DiscardHandler)
+	mux := http.NewServeMux()
+	NewFileHandler(claw.New(db, cfg, claw.WithLogger(logger)), logger).Register(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, *image.ID
+}
+
+func TestFileHandler(t *testing.T) {
+	server, imageID := newTestFileServer(t)
+	id := strconv.FormatInt(imageID, 10)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "image", path: "/images/" + id + "/raw", wantStatus: http.StatusOK, wantBody: "lake image"},
+		{name: "thumbnail falls back to image", path: "/images/" + id + "/thumbnail", wantStatus: http.StatusOK, wantBody: "lake image"},
+		{name: "unknown image", path: "/images/999/raw", wantStatus: http.StatusNotFound},
+		{name: "invalid image id", path: "/images/lake/raw", wantStatus: http.StatusBadRequest},
+		{name: "device file", path: "/devices/phone/2025/lake.jpg", wantStatus: http.StatusOK, wantBody: "lake image"},
+		{name: "unassigned device file", path: "/devices/phone/lake.jpg", wantStatus: http.StatusNotFound},
+		{name: "escaped parent directory", path: "/devices/phone/..%2F..%2Fsecret.txt", wantStatus: http.StatusNotFound},
+		{name: "escaped absolute path", path: "/devices/phone/%2Fetc%2Fpasswd", wantStatus: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := http.Get(server.URL + tt.path)
+			require.NoError(t, err)
+			body := readBody(t, resp)
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			if tt.wantBody != "" {
+				assert.Equal(t, tt.wantBody, body)
+				assert.Equal(t, "image/jpeg", resp.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestFileHandler_ConditionalAndRange(t *testing.T) {
+	server, imageID := newTestFileServer(t)
+	url := server.URL + "/images/" + strconv.FormatInt(imageID, 10) + "/raw"
+
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	readBody(t, resp)
+	etag := resp.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	t.Run("matching ETag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("If-None-Match", etag)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Empty(t, readBody(t, resp))
+		assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	})
+
+	t.Run("stale ETag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("If-None-Match", `"stale"`)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, "lake image", readBody(t, resp))
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("range", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Header.Set("Range", "bytes=5-")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, "image", readBody(t, resp))
+		assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+		assert.Equal(t, "bytes 5-9/10", resp.Header.Get("Content-Range"))
+	})
+}
+
+func readBody(t *testing.T, resp *http.Response) string {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return string(body)
+}
@@ -66,7 +66,29 @@ func (h *ImageHandler) MarkFavorite(ctx context.Context, req *connect.Request[cl
 
 // AssignTags handles image tag assignment requests
 func (h *ImageHandler) AssignTags(ctx context.Context, req *connect.Request[clawv1.AssignTagsRequest]) (*connect.Response[clawv1.AssignTagsResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.AssignTags(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// ListSimilarImages handles similar image listing requests
+func (h *ImageHandler) ListSimilarImages(ctx context.Context, req *connect.Request[clawv1.ListSimilarImagesRequest]) (*connect.Response[clawv1.ListSimilarImagesResponse], error) {
+	resp, err := h.service.ListSimilarImages(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
+// FindDuplicateGroups handles near-duplicate grouping requests
+func (h *ImageHandler) FindDuplicateGroups(ctx context.Context, req *connect.Request[clawv1.FindDuplicateGroupsRequest]) (*connect.Response[clawv1.FindDuplicateGroupsResponse], error) {
+	resp, err := h.service.FindDuplicateGroups(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // Ensure ImageHandler implements the ImageServiceHandler interface
@@ -84,5 +84,14 @@ func (h *JobHandler) RetryJob(ctx context.Context, req *connect.Request[clawv1.R
 	return connect.NewResponse(resp), nil
 }
 
+// CreateBackfillJob handles backfill job creation requests
+func (h *JobHandler) CreateBackfillJob(ctx context.Context, req *connect.Request[clawv1.CreateBackfillJobRequest]) (*connect.Response[clawv1.CreateBackfillJobResponse], error) {
+	resp, err := h.service.CreateBackfillJob(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
+}
+
 // Ensure JobHandler implements the JobServiceHandler interface
 var _ clawv1connect.JobServiceHandler = (*JobHandler)(nil)
\ No newline at end of file
@@ -10,7 +10,6 @@ import (
 )
 
 // TagHandler implements the ConnectRPC TagService interface
-// Currently returns unimplemented errors as tag functionality is not yet implemented in the claw service
 type TagHandler struct {
 	service *claw.Claw
 }
@@ -22,32 +21,56 @@ func NewTagHandler(service *claw.Claw) *TagHandler {
 
 // CreateTag handles tag creation requests
 func (h *TagHandler) CreateTag(ctx context.Context, req *connect.Request[clawv1.CreateTagRequest]) (*connect.Response[clawv1.CreateTagResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.CreateTag(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // GetTag handles tag retrieval requests
 func (h *TagHandler) GetTag(ctx context.Context, req *connect.Request[clawv1.GetTagRequest]) (*connect.Response[clawv1.GetTagResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.GetTag(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // UpdateTag handles tag update requests
 func (h *TagHandler) UpdateTag(ctx context.Context, req *connect.Request[clawv1.UpdateTagRequest]) (*connect.Response[clawv1.UpdateTagResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.UpdateTag(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // DeleteTags handles tag deletion requests
 func (h *TagHandler) DeleteTags(ctx context.Context, req *connect.Request[clawv1.DeleteTagsRequest]) (*connect.Response[clawv1.DeleteTagsResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.DeleteTags(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // ListTags handles tag listing requests
 func (h *TagHandler) ListTags(ctx context.Context, req *connect.Request[clawv1.ListTagsRequest]) (*connect.Response[clawv1.ListTagsResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.ListTags(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // GetPopularTags handles popular tag retrieval requests
 func (h *TagHandler) GetPopularTags(ctx context.Context, req *connect.Request[clawv1.GetPopularTagsRequest]) (*connect.Response[clawv1.GetPopularTagsResponse], error) {
-	return nil, connect.NewError(connect.CodeUnimplemented, nil)
+	resp, err := h.service.GetPopularTags(ctx, req.Msg)
+	if err != nil {
+		return nil, err
+	}
+	return connect.NewResponse(resp), nil
 }
 
 // Ensure TagHandler implements the TagServiceHandler interface
@@ -0,0 +1,35 @@
+-- +goose Up
+-- SHA-256 (hex encoded) of the downloaded file content. Empty for images
+-- downloaded before hashing was introduced, until `claw backfill-hashes` is run.
+ALTER TABLE images ADD COLUMN sha256 TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX IF NOT EXISTS idx_images_sha256 ON images(sha256);
+
+-- Extra sources and posts pointing to the same image content.
+-- The first source that downloaded the content is kept in the images table.
+CREATE TABLE IF NOT EXISTS image_references (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    image_id INTEGER NOT NULL,
+    source_id INTEGER NOT NULL,
+    download_url TEXT NOT NULL,
+    title TEXT NOT NULL DEFAULT '',
+    post_author TEXT NOT NULL DEFAULT '',
+    post_author_url TEXT NOT NULL DEFAULT '',
+    post_url TEXT NOT NULL DEFAULT '',
+    created_at INTEGER NOT NULL,
+    FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_id) REFERENCES sources(id) ON DELETE CASCADE,
+    UNIQUE(image_id, download_url)
+);
+
+CREATE INDEX IF NOT EXISTS idx_image_references_download_url ON image_references(download_url);
+CREATE INDEX IF NOT EXISTS idx_image_references_source_id ON image_references(source_id);
+
+-- Lookup by download url is done on every image a source returns.
+CREATE INDEX IF NOT EXISTS idx_images_download_url ON images(download_url);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_images_download_url;
+DROP TABLE IF EXISTS image_references;
+DROP INDEX IF EXISTS idx_images_sha256;
+ALTER TABLE images DROP COLUMN sha256;
@@ -0,0 +1,9 @@
+-- +goose Up
+-- 64 bit perceptual hashes, stored as signed integers. NULL when not computed yet.
+-- Near-duplicates are found by Hamming distance, which SQLite cannot index, so these are compared in the application.
+ALTER TABLE images ADD COLUMN dhash INTEGER;
+ALTER TABLE images ADD COLUMN phash INTEGER;
+
+-- +goose Down
+ALTER TABLE images DROP COLUMN phash;
+ALTER TABLE images DROP COLUMN dhash;
@@ -0,0 +1,64 @@
+-- +goose Up
+-- +goose NO TRANSACTION
+-- Jobs that are not created by a schedule, e.g. manual jobs or jobs of watched sources, have no schedule.
+--
+-- SQLite cannot drop a NOT NULL constraint, so the table is rebuilt. Foreign keys are disabled while rebuilding,
+-- otherwise dropping the old table would cascade to job_images. The pragma only applies to the current connection,
+-- so everything runs as a single statement.
+-- +goose StatementBegin
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE jobs_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER NOT NULL,
+    schedule_id INTEGER,
+    created_at INTEGER NOT NULL,
+    run_at INTEGER,
+    finished_at INTEGER,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    FOREIGN KEY (source_id) REFERENCES sources(id) ON DELETE CASCADE,
+    FOREIGN KEY (schedule_id) REFERENCES schedules(id) ON DELETE SET NULL
+);
+INSERT INTO jobs_new (id, source_id, schedule_id, created_at, run_at, finished_at, status, error)
+SELECT id, source_id, NULLIF(schedule_id, 0), created_at, run_at, finished_at, status, error FROM jobs;
+DROP TABLE jobs;
+ALTER TABLE jobs_new RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_jobs_source_id ON jobs(source_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_schedule_id ON jobs(schedule_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+CREATE INDEX IF NOT EXISTS idx_jobs_run_at ON jobs(run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose NO TRANSACTION
+-- +goose StatementBegin
+PRAGMA foreign_keys = OFF;
+BEGIN;
+CREATE TABLE jobs_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    source_id INTEGER NOT NULL,
+    schedule_id INTEGER NOT NULL,
+    created_at INTEGER NOT NULL,
+    run_at INTEGER,
+    finished_at INTEGER,
+    status TEXT NOT NULL DEFAULT 'pending',
+    error TEXT,
+    FOREIGN KEY (source_id) REFERENCES sources(id) ON DELETE CASCADE,
+    FOREIGN KEY (schedule_id) REFERENCES schedules(id) ON DELETE SET NULL
+);
+INSERT INTO jobs_old (id, source_id, schedule_id, created_at, run_at, finished_at, status, error)
+SELECT id, source_id, COALESCE(schedule_id, 0), created_at, run_at, finished_at, status, error FROM jobs;
+DROP TABLE jobs;
+ALTER TABLE jobs_old RENAME TO jobs;
+CREATE INDEX IF NOT EXISTS idx_jobs_source_id ON jobs(source_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_schedule_id ON jobs(schedule_id);
+CREATE INDEX IF NOT EXISTS idx_jobs_status ON jobs(status);
+CREATE INDEX IF NOT EXISTS idx_jobs_run_at ON jobs(run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_finished_at ON jobs(finished_at);
+COMMIT;
+PRAGMA foreign_keys = ON;
+-- +goose StatementEnd
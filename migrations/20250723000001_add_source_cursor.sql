@@ -0,0 +1,8 @@
+-- +goose Up
+-- Opaque position of the newest content seen by the source, e.g. the ID of the newest post.
+-- Stored after each successful job and passed to the next run, so sources can stop paging once they reach content
+-- they have already seen. Empty until the first successful job, and reset when the parameter changes.
+ALTER TABLE sources ADD COLUMN cursor TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+ALTER TABLE sources DROP COLUMN cursor;
@@ -0,0 +1,13 @@
+-- +goose Up
+-- Backfill jobs go through the entire history of a source page by page, instead of only the last countback posts.
+-- The token of the next page is stored after every page, so the backfill continues where it stopped after a restart.
+ALTER TABLE jobs ADD COLUMN backfill INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN backfill_page_token TEXT NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN backfill_pages INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE jobs ADD COLUMN backfill_images INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE jobs DROP COLUMN backfill_images;
+ALTER TABLE jobs DROP COLUMN backfill_pages;
+ALTER TABLE jobs DROP COLUMN backfill_page_token;
+ALTER TABLE jobs DROP COLUMN backfill;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Jobs failing with temporary errors, e.g. network errors, are run again after a backoff.
+-- attempt is the number of the current attempt, starting at 1. next_attempt_at is when the job may run again,
+-- or NULL if the job can run right away.
+ALTER TABLE jobs ADD COLUMN attempt INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE jobs ADD COLUMN next_attempt_at INTEGER;
+CREATE INDEX IF NOT EXISTS idx_jobs_next_attempt_at ON jobs(next_attempt_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_jobs_next_attempt_at;
+ALTER TABLE jobs DROP COLUMN next_attempt_at;
+ALTER TABLE jobs DROP COLUMN attempt;
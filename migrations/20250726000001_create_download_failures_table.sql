@@ -0,0 +1,35 @@
+-- +goose Up
+-- Images that failed to download in a job. Failures are retried with a backoff, and removed once the image is
+-- downloaded. Failures that are not retried anymore have no next_attempt_at.
+CREATE TABLE IF NOT EXISTS download_failures (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    job_id INTEGER NOT NULL,
+    source_id INTEGER NOT NULL,
+    download_url TEXT NOT NULL,
+    -- The image as returned by the source, encoded as JSON, so the retry has the same metadata.
+    image TEXT NOT NULL,
+    error TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 1,
+    next_attempt_at INTEGER,
+    created_at INTEGER NOT NULL,
+    updated_at INTEGER NOT NULL,
+    FOREIGN KEY (job_id) REFERENCES jobs(id) ON DELETE CASCADE,
+    FOREIGN KEY (source_id) REFERENCES sources(id) ON DELETE CASCADE
+);
+
+-- The devices the image was assigned to when it failed.
+CREATE TABLE IF NOT EXISTS download_failure_devices (
+    failure_id INTEGER NOT NULL,
+    device_id INTEGER NOT NULL,
+    PRIMARY KEY (failure_id, device_id),
+    FOREIGN KEY (failure_id) REFERENCES download_failures(id) ON DELETE CASCADE,
+    FOREIGN KEY (device_id) REFERENCES devices(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_download_failures_job_id ON download_failures(job_id);
+CREATE INDEX IF NOT EXISTS idx_download_failures_next_attempt_at ON download_failures(next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_download_failure_devices_device_id ON download_failure_devices(device_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS download_failure_devices;
+DROP TABLE IF EXISTS download_failures;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- Time the perceptual hashes of the image failed to compute because its file could not be decoded,
+-- so it is not decoded again on every job. NULL when not failed. Cleared when the file is downloaded again.
+ALTER TABLE images ADD COLUMN perceptual_hash_failed_at INTEGER;
+
+-- +goose Down
+ALTER TABLE images DROP COLUMN perceptual_hash_failed_at;
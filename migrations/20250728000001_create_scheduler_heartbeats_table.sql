@@ -0,0 +1,11 @@
+-- +goose Up
+-- Heartbeats of running schedulers, so commands that must not run next to a scheduler, like backfill-hashes,
+-- can refuse to run. Schedulers remove their row when they stop. A row that is not updated anymore belongs to
+-- a scheduler that exited without stopping.
+CREATE TABLE IF NOT EXISTS scheduler_heartbeats (
+    instance_id TEXT NOT NULL PRIMARY KEY,
+    updated_at INTEGER NOT NULL
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS scheduler_heartbeats;